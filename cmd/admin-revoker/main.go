@@ -3,15 +3,17 @@ package main
 import (
 	"context"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"log/syslog"
 	"os"
-	"os/user"
-	"sort"
+	"path"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/letsencrypt/boulder/cmd"
 	"github.com/letsencrypt/boulder/core"
@@ -21,248 +23,1920 @@ import (
 	bgrpc "github.com/letsencrypt/boulder/grpc"
 	blog "github.com/letsencrypt/boulder/log"
 	"github.com/letsencrypt/boulder/metrics"
-	rapb "github.com/letsencrypt/boulder/ra/proto"
 	"github.com/letsencrypt/boulder/revocation"
-	"github.com/letsencrypt/boulder/sa"
-	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/revoker"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-const usageString = `
-usage:
-admin-revoker serial-revoke --config <path> <serial> <reason-code>
-admin-revoker batched-serial-revoke --config <path> <serial-file-path> <reason-code> <parallelism>
-admin-revoker reg-revoke --config <path> <registration-id> <reason-code>
-admin-revoker list-reasons --config <path>
+// commandUsage describes how a command is invoked.
+type commandUsage struct {
+	name string
+	// synopsis lists the command's flags and positional arguments.
+	synopsis string
+	// minArgs and maxArgs bound the number of positional arguments. A maxArgs
+	// of -1 means there is no upper bound.
+	minArgs, maxArgs int
+}
+
+// commands lists every command, in the order they're given in the usage.
+var commands = []commandUsage{
+	{"serial-revoke", "--config <path> [--interactive-reason] [--print-cert [--yes]] <serial> <reason-code>", 2, 2},
+	{"batched-serial-revoke", "--config <path> <serial-file-path> <reason-code> <parallelism>", 3, 3},
+	{"batch-revoke", "--config <path> [--strict] [--checkpoint <path>] [--commit-every N] <serial-file-path> <reason-code>", 2, 2},
+	{"fingerprint-revoke", "--config <path> <sha256-hex> <reason-code>", 2, 2},
+	{"cert-revoke", "--config <path> <cert-path> <reason-code>", 2, 2},
+	{"reg-revoke", "--config <path> [--yes] [--deactivate-account] [--notify] [--strict] [--interactive-reason] [--max N] [--force-large] [--no-verify] [--parallelism N] [--checkpoint <path>] [--commit-every N] [--batch-size N] [--issued-after <time>] [--issued-before <time>] [--dns-name <name> [--suffix-match]] [--ids-file <path>] <registration-id>... <reason-code>", 1, -1},
+	{"serial-info", "--config <path> [--format text|json] <serial>", 1, 1},
+	{"reg-count", "--config <path> <registration-id>", 1, 1},
+	{"reg-list", "--config <path> [--format text|json] [--issued-after <time>] [--issued-before <time>] <registration-id>", 1, 1},
+	{"key-revoke", "--config <path> [--yes] [--max N] [--force-large] [--no-verify] [--parallelism N] [--checkpoint <path>] [--commit-every N] [--batch-size N] [--issued-after <time>] [--issued-before <time>] <key-hash> <reason-code>", 2, 2},
+	{"key-block", "--config <path> <cert-pem-path-or-serial>", 1, 1},
+	{"domain-revoke", "--config <path> --match exact|registered-domain [--yes] [--parallelism N] [--checkpoint <path>] <domain> <reason-code>", 2, 2},
+	{"issuer-revoke", "--config <path> --issuer <hex-akid-or-name> --issued-before <time> --yes [--issued-after <time>] [--parallelism N] [--checkpoint <path>] [--rate N] <reason-code>", 1, 1},
+	{"feed-revoke", "--config <path> --url <feed> --state <file> <reason-code>", 1, 1},
+	{"crl-revoke", "--config <path> <crl-file> <default-reason-code>", 2, 2},
+	{"ocsp-refresh", "--config <path> <serial-file-path>", 1, 1},
+	{"reconcile", "--config <path> [--fix] <serial-file-path>", 1, 1},
+	{"verify-serials", "--config <path> [--allow-missing] <serial-file-path>", 1, 1},
+	{"list-reasons", "--config <path> [--format text|json] [--describe] [--no-color]", 0, 0},
+	{"list-runs", "--config <path> [--format text|json] [--since <time>]", 0, 0},
+	{"check-config", "--config <path> [--no-color]", 0, 0},
+}
 
+// findCommand returns the usage of the named command.
+func findCommand(name string) (commandUsage, bool) {
+	for _, cu := range commands {
+		if cu.name == name {
+			return cu, true
+		}
+	}
+	return commandUsage{}, false
+}
+
+// String returns the command's usage line.
+func (cu commandUsage) String() string {
+	return fmt.Sprintf("admin-revoker %s %s", cu.name, cu.synopsis)
+}
+
+// checkArgs returns an error describing the mismatch if got positional
+// arguments aren't acceptable for the command.
+func (cu commandUsage) checkArgs(got int) error {
+	if got >= cu.minArgs && (cu.maxArgs == -1 || got <= cu.maxArgs) {
+		return nil
+	}
+	expected := strconv.Itoa(cu.minArgs)
+	switch {
+	case cu.maxArgs == -1:
+		expected = "at least " + expected
+	case cu.maxArgs != cu.minArgs:
+		expected = fmt.Sprintf("%d to %d", cu.minArgs, cu.maxArgs)
+	}
+	plural := "s"
+	if cu.maxArgs == 1 || (cu.maxArgs == -1 && cu.minArgs == 1) {
+		plural = ""
+	}
+	return fmt.Errorf("expected %s argument%s, got %d", expected, plural, got)
+}
+
+// usage returns the full usage of all commands.
+func usage() string {
+	var b strings.Builder
+	b.WriteString("\nusage:\n")
+	for _, cu := range commands {
+		fmt.Fprintf(&b, "%s\n", cu)
+	}
+	b.WriteString(helpString)
+	return b.String()
+}
+
+const helpString = `
 command descriptions:
   serial-revoke       Revoke a single certificate by the hex serial number
   batched-serial-revoke Revokes all certificates contained in a file of hex serial numbers
-  reg-revoke          Revoke all certificates associated with a registration ID
-  list-reasons        List all revocation reason codes
+                      using <parallelism> concurrent workers, outside of any
+                      transaction. The file is read and the results summarized
+                      as for batch-revoke
+  batch-revoke        Revokes all certificates contained in a file of hex serial numbers
+                      in a single transaction and summarizes the results. A
+                      serial which fails to be revoked is listed without
+                      stopping the batch, unless --strict is given. A line may
+                      give the reason for its serial after a comma, as a code or
+                      name, e.g. "<serial>,keyCompromise", in place of the
+                      reason code argument
+  fingerprint-revoke  Revoke a single certificate by the hex SHA-256 fingerprint of its DER.
+                      This scans the whole certificates table, so it can be slow
+  cert-revoke         Revoke a single certificate given as a PEM or DER file, e.g. as
+                      attached to a report of a compromised key. The certificate
+                      stored with its serial must be byte for byte identical to
+                      the one in the file, or nothing is revoked
+  reg-revoke          Revoke all certificates associated with one or more registration
+                      IDs, summarizing the outcome for each at the end. Each
+                      revocation is committed by the RA as soon as it's made. If
+                      the RA becomes unavailable partway through, reg-revoke
+                      stops at that certificate, keeping those already revoked,
+                      and exits with status 4; re-running it once the RA is back
+                      revokes the rest
+  serial-info         Show the subject, names, validity and status of a certificate,
+                      or of a precertificate if no final certificate was issued,
+                      along with the ID, contact and status of the registration it
+                      was issued to. Revokes nothing
+  reg-count           Count the certificates reg-revoke would revoke for a registration
+                      ID, broken down into valid, revoked and expired, and estimate
+                      how many would be added to the CRLs. Revokes nothing
+  reg-list            List the serial, names, validity and status of each certificate
+                      reg-revoke would revoke for a registration ID, logging an
+                      estimate of how many would be added to the CRLs. Revokes
+                      nothing
+  key-revoke          Revoke all certificates associated with the registration using
+                      the given account key. Like reg-revoke, but identifies the
+                      registration by the SHA-256 hash of its key's DER encoded
+                      SubjectPublicKeyInfo, as hex or base64. Note that this is
+                      not the RFC 7638 JWK thumbprint
+  key-block           Block the public key of a compromised certificate, given as a PEM
+                      file or a serial, so it can't be used for new certificates,
+                      and revoke every certificate using it for keyCompromise.
+                      Only certificates issued while key hashes were being stored
+                      are found
+  domain-revoke       Revoke all unexpired certificates including a domain name, as
+                      recorded when they were issued. --match must say whether to
+                      match the exact name only, or the registered domain of the
+                      name along with all of its subdomains and wildcards
+  issuer-revoke       Revoke all unexpired certificates issued before --issued-before
+                      (and after --issued-after, if given) by an intermediate,
+                      identified by its subject key identifier in hex or by its
+                      common name, e.g. to clean up after rotating it. Every
+                      certificate issued within the window is parsed to find its
+                      issuer, so this can be slow. Because of the potential scale,
+                      --yes is required unless running with --dry-run or --explain
+  feed-revoke         Fetch a feed of serials to revoke, e.g. of certificates
+                      with compromised keys, and revoke those not yet processed
+                      by a previous run, as recorded in the --state file. The feed
+                      must return a JSON array of hex serials. Serials which
+                      aren't found are recorded as processed with a warning; those
+                      which fail to be revoked are retried by the next run. The
+                      client's TLS config and timeout are set by feed in the config
+  crl-revoke          Revoke each certificate listed in a DER or PEM CRL, e.g. one
+                      received from a CA we federate with, using the reason code
+                      of its CRL entry, or the default reason code if the entry
+                      has none. Serials we didn't issue are skipped. Entries
+                      whose reason can't be used by the admin-revoker, such as
+                      certificateHold, fail. The CRL's signature isn't checked
+  ocsp-refresh        Have the CA sign a fresh OCSP response for each revoked
+                      certificate in the serial file, e.g. after rotating the OCSP
+                      signing key, without changing its revocation. Serials which
+                      aren't revoked are skipped. Requires ocspGeneratorService in
+                      the config
+  reconcile           Compare the status of each certificate in the serial file in
+                      the database with the response from the OCSP responder, and
+                      list those which differ: revoked in the database but good in
+                      OCSP or vice versa, unknown to OCSP, or revoked with
+                      different reasons. With --fix, have the CA sign a fresh OCSP
+                      response for each mismatched certificate revoked in the
+                      database, as ocsp-refresh does. Certificates revoked only in
+                      OCSP are never changed. Requires issuerCertPath in the
+                      config, and ocspGeneratorService for --fix. Exits non-zero
+                      if any mismatch remains unfixed
+  verify-serials      Check that every serial in the serial file is found in the
+                      database, as a certificate or, with --include-precert, a
+                      precertificate, before revoking it with batch-revoke.
+                      Prints a line per serial saying whether it was found,
+                      missing or malformed, followed by a summary. Exits with
+                      code 3 if any is missing, unless --allow-missing is
+                      provided, and with code 2 if any is malformed. Revokes
+                      nothing
+  list-reasons        List all revocation reason codes in a table, highlighting
+                      those not accepted for admin revocation when writing to a
+                      terminal
+  list-runs           List the runs recorded with --run-id, oldest first: the
+                      operator, command and arguments of each, when it started
+                      and completed, and how many certificates it revoked,
+                      skipped and failed to revoke. A run which didn't complete
+                      has no counts. Revokes nothing
+  check-config        Check the config without revoking anything: that the
+                      database URL parses, the RA and SA are configured, and the
+                      TLS files, issuer certificate and reason codes load. Then
+                      connect to the database and each gRPC service, checking
+                      that they respond, and disconnect. Prints a line per
+                      component, and exits non-zero if any check fails
 
 args:
-  config    File path to the configuration file for this service
+  config       File path to the JSON or YAML configuration file for this service
+  config-dir   Directory of JSON configuration fragments, used in place of
+               --config. All *.json files in it are deep-merged in alphabetical
+               order, with values in later files overriding those in earlier ones
+  serial       Hex serial number. Colons, whitespace and a leading "0x" are ignored
+  reason-code  Either a numeric reason code or its name, as given by list-reasons.
+               certificateHold (6) is rejected, since Boulder can't honor a
+               temporary hold, and unspecified (0) is accepted with a warning
+  interactive-reason
+               Omit the reason code argument of serial-revoke or reg-revoke, and
+               choose it instead from a list of the reasons accepted for admin
+               revocation, by code or name. Requires stdin to be a terminal
+  dburl        Database URL to connect to in place of the one in the config, e.g.
+               to run reg-list or reg-count against a read replica. The URL is
+               logged with its password redacted, but a password given here is
+               visible to other local users while the command runs. Only
+               serial-info, reg-count, reg-list, list-reasons, reconcile
+               without --fix, and dry runs may be run against a read-only
+               database: any other command checks that @@read_only is off
+               before starting, and refuses to run otherwise
+  dry-run      Log the certificates that would be revoked but don't revoke them,
+               then how many of them are unexpired and so would be added to the
+               CRLs, also included in the --summary-json
+  explain      Print each SQL query executed against the database with its
+               bound values, along with the UPDATE the SA would execute to
+               revoke each certificate, without revoking anything. Unlike
+               dry-run, the RA and SA aren't connected to, only the database
+  comment      Free-text explanation of the revocation, stored alongside it and
+               included in the audit log
+  revocation-date
+               RFC 3339 time stored as the revocation date in place of now, e.g.
+               2020-05-01T00:00:00Z to backdate a key compromise revocation to
+               when it was reported. It may not be in the future, and a
+               certificate issued after it fails rather than being revoked.
+               The audit log records both the date given and when the
+               revocation was actually performed
+  client-tag   Tag attached to every request made to the RA and SA, and recorded
+               by the RA in the audit log of each revocation, identifying e.g. the
+               tool, runbook or ticket it was made for. Defaults to AdminRevoker
+  operator     Name recorded in the revocation and audit log as having performed
+               it. Defaults to the username of the user running the command
+  max-attempts Number of times to attempt each revocation when the RA is
+               unavailable (default 3). Other errors are never retried
+  retry-base-delay
+               Delay before the first retry of a revocation, doubling for each
+               subsequent retry (default 1s)
+  force        Change the reason of certificates which are already revoked to
+               the one given, rather than skipping them. The RA signs a new
+               OCSP response with the new reason, keeping the date each was
+               revoked at unless --revocation-date is given. The reason each
+               was previously revoked with is logged and audit logged along
+               with the new one. Certificates already revoked with the same
+               reason are still skipped
+  include-precert
+               Also look for a precertificate with each serial, revoking it if
+               no final certificate was issued for it (default true). A
+               precertificate shares its serial, and so its revocation status,
+               with its final certificate, so revoking one revokes both. The
+               report and log say whether the certificate, the precertificate
+               or both were revoked
+  skip-expired Skip certificates which have already expired, counting them
+               separately in the summary, since revoking them has no security
+               benefit. Defaults to true for bulk commands, and applies to
+               serial-revoke and fingerprint-revoke only if provided. Operators
+               publishing CRLs who want expired certificates included may pass
+               --skip-expired=false
+  strict-skips Exit non-zero if any serial was skipped rather than revoked:
+               because it was already revoked, already recorded in the
+               --checkpoint, expired, or not found. Each skipped serial is
+               listed in the summary, and in the --report, with the reason.
+               Without it, skipping a serial isn't a failure. Applies to
+               batch-revoke, reg-revoke, key-revoke, key-block, domain-revoke,
+               issuer-revoke, feed-revoke and crl-revoke
+  strict       Abort batch-revoke at the first serial which isn't found or
+               fails to be revoked, or reg-revoke if any registration is not
+               found
+  max          Abort reg-revoke and key-revoke, before revoking anything, if they
+               would select more than this many certificates in total (default
+               10000), in case a registration ID was mistyped. Dry runs aren't
+               limited
+  force-large  Allow reg-revoke and key-revoke to revoke more than --max
+               certificates. Requires --yes
+  no-verify    Don't check, once reg-revoke or key-revoke has finished, that every
+               certificate it attempted to revoke is now revoked in the
+               database. By default their status is read again and the command
+               exits non-zero, listing them, if any of them isn't
+  deactivate-account
+               Deactivate each registration given to reg-revoke once all of its
+               certificates have been revoked, so that it can't be used to issue
+               any more. A registration any of whose certificates failed to be
+               revoked is left active. Requires --yes
+  notify       Email the contacts of each registration given to reg-revoke that
+               its certificates were revoked, listing their serials, once its
+               revocation finishes. The SMTP server, from address, subject and
+               body templates are configured by the notify section of the
+               config. The templates are text/templates executed with the
+               RegistrationID, Serials, Reason, and Comment. A registration with
+               nothing revoked or no email contact isn't notified. Failing to
+               notify is logged, but doesn't fail the command. With --dry-run
+               the messages are only logged
+  dns-name     Only revoke the certificates reg-revoke finds that include this
+               name among their SANs, e.g. to revoke just those of an account's
+               certificates covering a compromised hostname. Each certificate
+               is parsed to check its names, and those not covering it are
+               skipped, counting them separately in the summary. Mutually
+               exclusive with --deactivate-account
+  suffix-match Make --dns-name also match certificates including a subdomain of
+               the name, such as www.example.com or *.example.com for
+               example.com
+  direct-sa    Break-glass option for when the RA is unavailable. Revocations are
+               written directly through the SA, bypassing the RA, and audit
+               logged as such. The CA isn't asked to sign OCSP responses and the
+               stored response of each certificate revoked is cleared, so OCSP
+               won't report them as revoked until new responses are generated
+               and published separately. Requires --yes, and can't be combined
+               with --verify-ocsp
+  rollback     Rehearse a revocation: run every step, including the RA calls,
+               within the command's transaction, then roll it back even if it
+               succeeded. THIS DOESN'T UNDO ANY REVOCATION. Only the
+               admin-revoker's own database reads and writes are in that
+               transaction. The RA and SA commit each revocation through their
+               own connections, and the CA signs and publishes an OCSP response
+               for it, so every certificate revoked stays revoked. It is only
+               safe against an environment whose revocations may persist.
+               Applies to serial-revoke, fingerprint-revoke, cert-revoke,
+               batch-revoke, and reg-revoke and key-revoke without
+               --parallelism. Requires --yes, and can't be combined with
+               --dry-run or --explain, which revoke nothing
+  ids-file     File of registration IDs for reg-revoke to revoke, one per line, in
+               addition to any given as arguments
+  format       Output format for list-reasons, list-runs, reg-list and
+               serial-info, either "text" (the default) or "json". reg-list
+               prints a JSON object per line
+  since        Only list the runs list-runs finds that started at or after the
+               given RFC 3339 time
+  describe     Include a plain-English description of each reason code in the
+               output of list-reasons
+  no-color     Don't highlight the reason codes list-reasons prints which aren't
+               accepted, or color the checks check-config prints, even when
+               writing to a terminal
+  verify-ocsp  After each revocation, query the OCSP responder until it reports
+               the certificate revoked with the expected reason, and count the
+               revocation as failed if it doesn't within --verify-ocsp-timeout
+               (default 30s). Requires issuerCertPath in the config. The
+               responder is ocspResponderURL from the config if set, and
+               otherwise the one in the certificate
+  fix          Have reconcile refresh the OCSP response of each certificate it
+               finds revoked in the database but not in OCSP, or revoked with a
+               different reason. Requires ocspGeneratorService in the config
+  allow-missing
+               Have verify-serials exit successfully even if some serials
+               aren't found, still listing them. Malformed serials still fail
+  rate         Maximum number of revocation requests made to the RA per second,
+               shared between all --parallelism workers. May be fractional, e.g.
+               0.5 for one every two seconds. 0, the default, is unlimited
+  adaptive-rate
+               Halve the --rate each time the RA reports that it's overloaded
+               (ResourceExhausted) and retry the revocation, rather than
+               counting it as failed, then ramp back up to --rate as requests
+               succeed. Requires --rate
+  match        Which certificates domain-revoke revokes, either "exact" or
+               "registered-domain". With "exact", only certificates including
+               the name itself are revoked; wildcard certificates covering it
+               are not, unless the wildcard itself (e.g. *.example.com) is
+               given. With "registered-domain", the name is replaced by its
+               registered domain (e.g. www.example.co.uk by example.co.uk) and
+               certificates including it or any name under it are revoked
+  url          URL of the feed feed-revoke fetches
+  state        File in which feed-revoke records each serial from the feed it has
+               processed, one per line, created if it doesn't exist
+  issuer       Subject key identifier, in hex with or without colons, or common
+               name of the intermediate whose certificates issuer-revoke
+               revokes. Hex is also matched against the common name
+  parallelism  Number of certificates reg-revoke, domain-revoke and issuer-revoke
+               revoke concurrently. When greater than 1, reg-revoke revokes
+               certificates outside of a transaction and a failure to revoke one
+               doesn't prevent revoking the others, as the others always do
+  output-serials
+               File to which each serial revoked is appended as soon as it is
+               revoked, one per line. Serials which were already revoked, or
+               would be revoked by a dry run, aren't written
+  progress     Write a line to stderr as each certificate is processed, leaving
+               stdout for the log and summary. The only format is "json", with
+               which each line is a JSON object such as {"serial":"...",
+               "status":"revoked","done":1,"total":10}. Status is one of the
+               results in --report-csv, and errors are given in "error". The
+               total is omitted by commands which don't know it in advance
+  report-csv   File to which a CSV report is written, with a row for every
+               certificate each command attempted to revoke giving its serial,
+               CN, reason, operator, time and the result: revoked (followed by
+               "precertificate" or "certificate and precertificate" when
+               --include-precert found one), skipped, expired, dry-run, or
+               error followed by the error text. Each row is written
+               immediately, so a failed run still produces a report
+  summary-json File to which a single JSON object summarizing the outcome is
+               written when any command exits, whether or not it succeeded,
+               or "-" for stdout: the command, its arguments, operator and
+               --run-id, the number of certificates processed, revoked, that a
+               dry run would have revoked, skipped and errored, with the
+               skipped broken down by reason and the errored by kind of
+               failure, the duration in seconds, and the exit code and reason,
+               which is "completed" on success or the message it failed with
+  checkpoint   File to which batch-revoke, reg-revoke, key-revoke, domain-revoke
+               and issuer-revoke append each serial they revoke. Serials already
+               listed in the file, or already revoked in the database, are
+               skipped, so an interrupted run can be safely restarted
+  commit-every Commit the transaction batch-revoke, and reg-revoke and key-revoke
+               without --parallelism, read within after every N certificates
+               revoked, and begin a new one, so that a huge revocation doesn't
+               hold a single transaction open throughout. 0, the default, keeps
+               a single transaction. This changes what a failure undoes: only
+               the current transaction is rolled back, and each transaction sees
+               changes made since the previous one, e.g. certificates issued or
+               revoked meanwhile, rather than a single view of the database.
+               As without it, revocations made through the RA are never rolled
+               back. Use it with --checkpoint so that a failed run resumes after
+               the certificates already revoked. Can't be combined with
+               --rollback
+  batch-size   Number of a registration's certificates reg-revoke and
+               key-revoke select at a time, ordered by serial, revoking each
+               batch before selecting the next after its last serial, so that
+               memory use and query plans stay predictable for very large
+               registrations. Since each batch starts after the previous one's
+               last serial, none is skipped or repeated even if certificates
+               change meanwhile. Defaults to 1000
+  issued-after, issued-before
+               Only revoke the certificates reg-revoke or issuer-revoke finds
+               that were issued after and/or before the given RFC 3339 time,
+               e.g. 2020-05-01T00:00:00Z. Other certificates are skipped.
+               issuer-revoke requires --issued-before
+  timeout      Maximum time the command may run for, after which any transaction
+               is rolled back. Defaults to 30s for single certificate commands and
+               10m for the others. Time spent waiting for confirmation isn't counted
+  per-call-timeout
+               Maximum time each revocation request to the RA, or the SA with
+               --direct-sa, may take, so that a single stuck request doesn't use
+               up the whole --timeout. A request which times out fails its
+               certificate as a backend error, without being retried, and bulk
+               commands move on to the next. Never longer than what remains of
+               --timeout. 0, the default, is limited only by --timeout
+  connect-retries
+               Number of times to retry reaching the RA, SA and OCSP generator
+               at startup, with backoff, while they are unreachable, e.g.
+               during a rolling deploy. Each attempt makes a gRPC health check.
+               Only failures to reach a service are retried. Overrides
+               connectRetries in the config. 0, the default, fails on the first
+               attempt
+  connect-timeout
+               Maximum time each attempt to reach a service with
+               --connect-retries may take. Overrides connectTimeout in the
+               config, which defaults to the service's healthCheckTimeout, or
+               5s
+  lock-key     Key of the advisory lock (MySQL's GET_LOCK) each command which
+               writes to the database holds while it runs, so that a second run
+               fails rather than interleaving with it. Defaults to a key derived
+               from the command and its target, e.g. the registration IDs of
+               reg-revoke, but not its reason code, prefixed with
+               lock.keyPrefix from the config. Runs with different targets
+               don't exclude each other unless given the same --lock-key. Dry
+               runs don't take the lock
+  lock-timeout Maximum time to wait for the lock if another run holds it.
+               Overrides lock.timeout in the config. 0, the default, fails
+               immediately
+  max-runtime  Maximum time a bulk command may run for, including time spent
+               waiting for confirmation, e.g. to fit a maintenance window. Once
+               it has passed, the command stops as on the first SIGINT: the
+               revocations in progress are finished, no more are started, a
+               summary of what was done is written, and it exits with code 8.
+               With --checkpoint, re-running the same command resumes where it
+               stopped. Unlike --timeout, revocations in progress aren't
+               aborted. 0, the default, is unlimited
+  run-id       Identifies a bulk command's run, e.g. by incident and batch, so
+               it can't accidentally be run twice. The run is recorded in the
+               admin_revocation_runs table when it starts and marked complete
+               once it succeeds. A run ID which has already completed is
+               refused; one which failed or was interrupted can be re-run.
+               list-runs lists the recorded runs and their outcomes
+  force-rerun  Run a bulk command even if its --run-id has already completed
+  quiet        Write only summaries, warnings and errors to stdout, rather than
+               a line for each certificate. Every revocation is still audit
+               logged to syslog
+  verbose      Log every database query and the time taken by each request to
+               the RA, at debug level, and write debug messages to stdout
+  yes, y       Don't prompt for confirmation before running reg-revoke,
+               domain-revoke or serial-revoke --print-cert. Required by
+               issuer-revoke, which never prompts
+  print-cert   Print the subject, names, issuer, serial, validity and SHA-256
+               fingerprint of the certificate serial-revoke is about to revoke,
+               then prompt for confirmation unless --yes is given, in case the
+               serial was mistyped but matches another certificate
+
+exit codes:
+  1  Any failure not covered below
+  2  Invalid command line arguments
+  3  The certificate or registration wasn't found
+  4  The RA or SA returned an error, or couldn't be reached
+  5  A database error occurred
+  6  The command didn't complete before the --timeout
+  7  The command was interrupted by SIGINT or SIGTERM
+  8  The --max-runtime was exhausted before the command completed
+
+signals:
+  On the first SIGINT or SIGTERM, bulk commands finish revoking the certificates
+  already in progress, start no more, summarize what was done and exit. With
+  --checkpoint, re-running the same command resumes where it stopped. A second
+  signal aborts the revocations in progress immediately
 `
 
-type config struct {
-	Revoker struct {
-		cmd.DBConfig
-		// Similarly, the Revoker needs a TLSConfig to set up its GRPC client certs,
-		// but doesn't get the TLS field from ServiceConfig, so declares its own.
-		TLS cmd.TLSConfig
+// Exit codes returned by the admin-revoker, so that automation can tell the
+// different kinds of failure apart. They are documented in the usage string.
+const (
+	exitGeneric     = 1
+	exitUsage       = 2
+	exitNotFound    = 3
+	exitBackend     = 4
+	exitDB          = 5
+	exitTimeout     = 6
+	exitInterrupted = 7
+	exitRuntime     = 8
+)
+
+// Default values for --timeout, depending on whether the command revokes a
+// single certificate or potentially very many.
+const (
+	singleTimeout = 30 * time.Second
+	bulkTimeout   = 10 * time.Minute
+)
 
-		RAService *cmd.GRPCClientConfig
-		SAService *cmd.GRPCClientConfig
+// defaultMaxCerts is the default for --max, above which reg-revoke refuses to
+// revoke a registration's certificates without --force-large, in case the
+// registration ID was mistyped.
+const defaultMaxCerts = 10000
 
-		Features map[string]bool
+// defaultClientTag is the --client-tag attached to gRPC requests if none is
+// given.
+const defaultClientTag = "AdminRevoker"
+
+// maxClientTagLength bounds the length of --client-tag.
+const maxClientTagLength = 128
+
+// checkClientTag returns an error unless the tag is non-empty printable ASCII
+// of at most maxClientTagLength characters, as allowed in gRPC metadata.
+func checkClientTag(tag string) error {
+	if tag == "" {
+		return errors.New("client tag must not be empty")
+	}
+	if len(tag) > maxClientTagLength {
+		return fmt.Errorf("client tag must be at most %d characters", maxClientTagLength)
 	}
+	for _, r := range tag {
+		if r < 0x20 || r > 0x7e {
+			return fmt.Errorf("client tag %q must be printable ASCII", tag)
+		}
+	}
+	return nil
+}
 
-	Syslog cmd.SyslogConfig
+// bulkCommands are the commands which use bulkTimeout by default.
+var bulkCommands = map[string]bool{
+	"batched-serial-revoke": true,
+	"batch-revoke":          true,
+	"reg-revoke":            true,
+	"key-revoke":            true,
+	"key-block":             true,
+	"domain-revoke":         true,
+	"issuer-revoke":         true,
+	"feed-revoke":           true,
+	"crl-revoke":            true,
+	"ocsp-refresh":          true,
+	"reconcile":             true,
+	"reg-list":              true,
+	"verify-serials":        true,
 }
 
-func setupContext(c config) (core.RegistrationAuthority, blog.Logger, *db.WrappedMap, core.StorageAuthority) {
-	logger := cmd.NewLogger(c.Syslog)
+// rollbackCommands are the commands which run in a single transaction, and so
+// to which --rollback applies. reg-revoke and key-revoke only do so without
+// --parallelism.
+var rollbackCommands = map[string]bool{
+	"serial-revoke":      true,
+	"fingerprint-revoke": true,
+	"cert-revoke":        true,
+	"batch-revoke":       true,
+	"reg-revoke":         true,
+	"key-revoke":         true,
+}
+
+// commitEveryCommands are the commands which run in a transaction it may be
+// worth committing periodically, and so to which --commit-every applies.
+// reg-revoke and key-revoke only do so without --parallelism.
+var commitEveryCommands = map[string]bool{
+	"batch-revoke": true,
+	"reg-revoke":   true,
+	"key-revoke":   true,
+}
+
+// skipSummaryCommands are the commands which record the serials they skip, and
+// why, in their summary, and so to which --strict-skips applies.
+var skipSummaryCommands = map[string]bool{
+	"batched-serial-revoke": true,
+	"batch-revoke":          true,
+	"reg-revoke":            true,
+	"key-revoke":            true,
+	"key-block":             true,
+	"domain-revoke":         true,
+	"issuer-revoke":         true,
+	"feed-revoke":           true,
+	"crl-revoke":            true,
+}
 
-	tlsConfig, err := c.Revoker.TLS.Load()
-	cmd.FailOnError(err, "TLS config")
+// failOnSkipped exits with a failure if strict is set, by --strict-skips, and
+// any serials were skipped.
+func failOnSkipped(strict bool, skipped int) {
+	if strict && skipped > 0 {
+		failWithCode(exitGeneric, fmt.Sprintf("%d serials were skipped rather than revoked, and --strict-skips was provided", skipped))
+	}
+}
 
-	clk := cmd.Clock()
+// bulkRun runs the bulk revocation commands and reports their results, so that
+// they all exit the same way for the same outcome.
+type bulkRun struct {
+	logger      blog.Logger
+	timeout     time.Duration
+	dryRun      bool
+	strictSkips bool
+}
 
-	clientMetrics := bgrpc.NewClientMetrics(metrics.NoopRegisterer)
-	raConn, err := bgrpc.ClientSetup(c.Revoker.RAService, tlsConfig, clientMetrics, clk)
-	cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to RA")
-	rac := bgrpc.NewRegistrationAuthorityClient(rapb.NewRegistrationAuthorityClient(raConn))
+// bulkRevocation revokes certificates, recording them in cp if it isn't nil,
+// and returns the result along with the number of certificates it selected.
+type bulkRevocation func(cp *revoker.Checkpoint) (revoker.BatchResult, int, error)
 
-	dbURL, err := c.Revoker.DBConfig.URL()
-	cmd.FailOnError(err, "Couldn't load DB URL")
-	dbMap, err := sa.NewDbMap(dbURL, c.Revoker.DBConfig.MaxDBConns)
-	cmd.FailOnError(err, "Couldn't setup database connection")
+// run opens the checkpoint file at checkpointPath, unless it is empty, and
+// runs revoke with it. It then logs the result, and exits unless every
+// certificate was revoked: with the code for the error revoke stopped with,
+// after failed, then with the code for the first certificate which couldn't be
+// revoked, and then if any was skipped and --strict-skips was given.
+func (b bulkRun) run(ctx context.Context, checkpointPath string, failed string, revoke bulkRevocation) {
+	var cp *revoker.Checkpoint
+	if checkpointPath != "" {
+		var err error
+		cp, err = revoker.OpenCheckpoint(checkpointPath)
+		failOnError(err, "Couldn't open checkpoint file")
+		defer func() { _ = cp.Close() }()
+	}
+	result, total, err := revoke(cp)
+	result.Log(b.logger)
+	failOnError(checkTimeout(ctx, b.timeout, err), failed)
+	if b.dryRun {
+		b.logger.Info("DRY RUN - no certificates revoked")
+	}
+	if len(result.Failures) > 0 {
+		failOnError(result.Failures[0].Err,
+			fmt.Sprintf("Failed to revoke %d of %d certificates", len(result.Failures), total))
+	}
+	failOnSkipped(b.strictSkips, len(result.Skipped))
+}
 
-	saConn, err := bgrpc.ClientSetup(c.Revoker.SAService, tlsConfig, clientMetrics, clk)
-	cmd.FailOnError(err, "Failed to load credentials and create gRPC connection to SA")
-	sac := bgrpc.NewStorageAuthorityClient(sapb.NewStorageAuthorityClient(saConn))
+// timeoutError is returned in place of the error from an operation which
+// failed because the --timeout deadline passed.
+type timeoutError struct {
+	timeout time.Duration
+	err     error
+}
 
-	return rac, logger, dbMap, sac
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s: %s", e.timeout, e.err)
 }
 
-func revokeBySerial(ctx context.Context, serial string, reasonCode revocation.Reason, rac core.RegistrationAuthority, logger blog.Logger, dbMap db.Executor) (err error) {
-	if reasonCode < 0 || reasonCode == 7 || reasonCode > 10 {
-		panic(fmt.Sprintf("Invalid reason code: %d", reasonCode))
+// checkTimeout returns a timeoutError wrapping err if it occurred after the
+// deadline of ctx had passed, and err otherwise.
+func checkTimeout(ctx context.Context, timeout time.Duration, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return timeoutError{timeout, err}
 	}
+	return err
+}
 
-	certObj, err := sa.SelectCertificate(dbMap, "WHERE serial = ?", serial)
-	if err != nil {
-		if db.IsNoRows(err) {
-			return berrors.NotFoundError("certificate with serial %q not found", serial)
+// exitCode returns the exit code describing the failure represented by err.
+func exitCode(err error) int {
+	if db.IsNoRows(err) || berrors.Is(err, berrors.NotFound) {
+		return exitNotFound
+	}
+	if berrors.Is(err, berrors.Malformed) {
+		// Malformed serials, fingerprints, key hashes and reason codes.
+		return exitUsage
+	}
+	if err == revoker.ErrInterrupted || err == context.Canceled || status.Code(err) == codes.Canceled {
+		// Only the signal handler cancels the command's context, and only it
+		// and the --max-runtime watchdog interrupt bulk revocations.
+		if stopper.RuntimeExhausted() {
+			return exitRuntime
 		}
-		return err
+		return exitInterrupted
 	}
-	cert, err := x509.ParseCertificate(certObj.DER)
-	if err != nil {
-		return
+	switch err.(type) {
+	case timeoutError:
+		return exitTimeout
+	case db.ErrDatabaseOp, *db.RollbackError, revoker.DatabaseError:
+		return exitDB
+	case revoker.BackendError, revoker.BackendUnavailableError:
+		return exitBackend
+	case *berrors.BoulderError:
+		// Errors returned by the RA and SA are unwrapped into BoulderErrors.
+		return exitBackend
 	}
+	if _, ok := status.FromError(err); ok {
+		// Any other gRPC error, e.g. the RA being unavailable.
+		return exitBackend
+	}
+	return exitGeneric
+}
 
-	u, err := user.Current()
+// exitCompleted is the reason recorded in the summary of a command which ran
+// to completion and exited with code 0.
+const exitCompleted = "completed"
+
+// finisher releases the command's lock, writes its summary and pushes its
+// metrics once it has finished, whether or not it succeeded, so that failures
+// are counted too.
+var finisher revoker.Finisher
+
+// stopper, if not nil, stops the bulk revocations of the command gracefully
+// on a signal or once --max-runtime has passed.
+var stopper *revoker.Stopper
+
+// exit finishes the command, exiting with code for the reason.
+func exit(code int, reason string) {
+	finisher.Finish(code, reason)
+	os.Exit(code)
+}
+
+// failWithCode is like cmd.Fail, but exits with the provided exit code.
+func failWithCode(code int, msg string) {
+	logger := blog.Get()
+	logger.AuditErr(msg)
+	fmt.Fprint(os.Stderr, msg)
+	exit(code, msg)
+}
+
+// failOnErrorWithCode is like cmd.FailOnError, but exits with the provided exit
+// code.
+func failOnErrorWithCode(err error, code int, msg string) {
 	if err != nil {
-		return
+		failWithCode(code, fmt.Sprintf("%s: %s", msg, err))
 	}
-	err = rac.AdministrativelyRevokeCertificate(ctx, *cert, reasonCode, u.Username)
+}
+
+// failOnError is like cmd.FailOnError, but exits with the exit code describing
+// err.
+func failOnError(err error, msg string) {
 	if err != nil {
-		return
+		failOnErrorWithCode(err, exitCode(err), msg)
+	}
+}
+
+type config struct {
+	Revoker revoker.Config
+
+	Syslog cmd.SyslogConfig
+}
+
+// setupLogging creates the logger for the command. If a DebugAddr is
+// configured, metrics are served from it for the duration of the run. If
+// only a Pushgateway is, they are recorded in a registry to be pushed once the
+// command finishes.
+func setupLogging(c config) (prometheus.Registerer, blog.Logger) {
+	if c.Revoker.DebugAddr != "" {
+		return cmd.StatsAndLogging(c.Syslog, c.Revoker.DebugAddr)
+	}
+	if c.Revoker.PushGateway.URL != "" {
+		return prometheus.NewRegistry(), cmd.NewLogger(c.Syslog)
 	}
+	return metrics.NoopRegisterer, cmd.NewLogger(c.Syslog)
+}
 
-	logger.Infof("Revoked certificate %s with reason '%s'", serial, revocation.ReasonToString[reasonCode])
-	return
+// setupPush has finisher push the metrics recorded in stats to the
+// Pushgateway, if one is configured.
+func setupPush(c config, stats prometheus.Registerer, logger blog.Logger) error {
+	if c.Revoker.PushGateway.URL == "" {
+		return nil
+	}
+	gatherer, ok := stats.(prometheus.Gatherer)
+	if !ok {
+		return errors.New("metrics registry can't be gathered")
+	}
+	pusher, err := revoker.NewPusher(c.Revoker.PushGateway, gatherer)
+	if err != nil {
+		return err
+	}
+	finisher.PushTo(pusher, logger)
+	return nil
 }
 
-func revokeByReg(ctx context.Context, regID int64, reasonCode revocation.Reason, rac core.RegistrationAuthority, logger blog.Logger, dbMap db.Executor) (err error) {
-	var certs []core.Certificate
-	_, err = dbMap.Select(&certs, "SELECT serial FROM certificates WHERE registrationID = :regID", map[string]interface{}{"regID": regID})
+// quietLogger returns the logger used by the revoker for --quiet, which writes
+// only warnings and errors to stdout so that the lines logged for every
+// certificate don't bury the summary. Everything, including the audit log entry
+// for each revocation, is still written to syslog at the configured level.
+func quietLogger(logConf cmd.SyslogConfig) (blog.Logger, error) {
+	syslogger, err := syslog.Dial("", "", syslog.LOG_INFO, path.Base(os.Args[0]))
 	if err != nil {
-		return
+		return nil, err
+	}
+	syslogLevel := int(syslog.LOG_INFO)
+	if logConf.SyslogLevel != 0 {
+		syslogLevel = logConf.SyslogLevel
+	}
+	stdoutLevel := logConf.StdoutLevel
+	if stdoutLevel > int(syslog.LOG_WARNING) {
+		stdoutLevel = int(syslog.LOG_WARNING)
+	}
+	return blog.New(syslogger, stdoutLevel, syslogLevel)
+}
+
+// setupContext connects to the database, RA and SA. If verbose is true, every
+// database query is logged at debug level. If explain is true, only the
+// database is connected to, and every query is written to stdout instead.
+// Otherwise, if writable is true, it fails if the database is read-only.
+func setupContext(c config, stats prometheus.Registerer, logger blog.Logger, verbose, explain, writable bool) *revoker.Revoker {
+	if explain {
+		r, err := revoker.NewExplainer(c.Revoker, os.Stdout, logger, cmd.Clock(), stats)
+		failOnError(err, "Couldn't set up revoker")
+		return r
+	}
+	r, err := revoker.NewFromConfig(c.Revoker, logger, cmd.Clock(), stats)
+	failOnError(err, "Couldn't set up revoker")
+	if verbose {
+		r.TraceSQL()
 	}
+	if writable {
+		err = r.CheckWritable()
+		failOnError(err, "Refusing to run against a read-only database")
+	}
+	return r
+}
+
+// readOnlyCommands are the commands which never write to the database, and
+// so may be run against a read-only replica.
+var readOnlyCommands = map[string]bool{
+	"serial-info":    true,
+	"reg-count":      true,
+	"reg-list":       true,
+	"list-reasons":   true,
+	"list-runs":      true,
+	"verify-serials": true,
+	"check-config":   true,
+}
 
-	for _, cert := range certs {
-		err = revokeBySerial(ctx, cert.Serial, reasonCode, rac, logger, dbMap)
+// lockTarget returns the positional arguments identifying what command revokes,
+// keying its lock: those other than the reason code and parallelism, so that
+// runs against the same target with different reasons still exclude each
+// other.
+func lockTarget(command string, args []string) []string {
+	switch command {
+	case "serial-revoke", "batched-serial-revoke", "batch-revoke", "fingerprint-revoke", "cert-revoke", "crl-revoke":
+		if len(args) > 0 {
+			return args[:1]
+		}
+	case "reg-revoke", "key-revoke", "domain-revoke", "issuer-revoke", "feed-revoke":
+		if len(args) > 0 {
+			return args[:len(args)-1]
+		}
+	}
+	return args
+}
+
+// needsWritableDB returns whether command may write to the database, or revoke
+// certificates based on what it reads from it, and so must not be run against
+// a read-only replica, whose writes fail and whose reads may lag behind the
+// primary. Dry runs, and reconcile without --fix, only read.
+func needsWritableDB(command string, dryRun, fix bool) bool {
+	if dryRun || readOnlyCommands[command] {
+		return false
+	}
+	if command == "reconcile" {
+		return fix
+	}
+	return true
+}
+
+// checkOperator returns an error if --operator was given but is blank, since
+// the operator would then silently fall back to the current user.
+func checkOperator(flagSet *flag.FlagSet) error {
+	var err error
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == "operator" && strings.TrimSpace(f.Value.String()) == "" {
+			err = errors.New("--operator must not be empty")
+		}
+	})
+	return err
+}
+
+// skipExpiredFor returns whether certificates which have already expired are
+// skipped by command. --skip-expired defaults to true, but only bulk commands
+// skip expired certificates unless it was provided explicitly, since revoking
+// a single certificate is assumed to be deliberate.
+func skipExpiredFor(flagSet *flag.FlagSet, command string, skipExpired bool) bool {
+	provided := false
+	flagSet.Visit(func(f *flag.Flag) {
+		if f.Name == "skip-expired" {
+			provided = true
+		}
+	})
+	if provided {
+		return skipExpired
+	}
+	return skipExpired && bulkCommands[command]
+}
+
+// parseRegIDs parses the registration IDs given to reg-revoke as arguments,
+// along with those listed one per line in idsFile if it isn't empty. Blank
+// lines and lines beginning with '#' in idsFile are ignored.
+func parseRegIDs(args []string, idsFile string) ([]int64, error) {
+	if idsFile != "" {
+		contents, err := ioutil.ReadFile(idsFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			args = append(args, line)
+		}
+	}
+	var regIDs []int64
+	for _, arg := range args {
+		regID, err := strconv.ParseInt(arg, 10, 64)
 		if err != nil {
-			return
+			return nil, fmt.Errorf("registration ID %q must be an integer", arg)
 		}
+		regIDs = append(regIDs, regID)
 	}
+	return regIDs, nil
+}
 
-	return
+// loadCertificate returns the certificate for the key-block argument, which is
+// either the path to a PEM encoded certificate or, if no such file exists, the
+// serial of a certificate in the database.
+func loadCertificate(r *revoker.Revoker, arg string) (*x509.Certificate, error) {
+	contents, err := ioutil.ReadFile(arg)
+	if os.IsNotExist(err) {
+		return r.CertificateBySerial(arg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseCertificatePEM(contents)
 }
 
-func revokeBatch(rac core.RegistrationAuthority, logger blog.Logger, dbMap *db.WrappedMap, serialPath string, reasonCode revocation.Reason, parallelism int) error {
-	serials, err := ioutil.ReadFile(serialPath)
+// parseCertificatePEM parses the first certificate in PEM encoded contents.
+func parseCertificatePEM(contents []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(contents)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, berrors.MalformedError("no PEM encoded certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// parseCertificateFile parses the cert-revoke argument's contents, a single
+// certificate which is PEM encoded or, failing that, DER encoded.
+func parseCertificateFile(contents []byte) (*x509.Certificate, error) {
+	if block, _ := pem.Decode(contents); block != nil {
+		return parseCertificatePEM(contents)
+	}
+	cert, err := x509.ParseCertificate(contents)
 	if err != nil {
-		return err
+		return nil, berrors.MalformedError("no PEM or DER encoded certificate found: %s", err)
 	}
-	wg := new(sync.WaitGroup)
-	work := make(chan string, parallelism)
-	for i := 0; i < parallelism; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for serial := range work {
-				// handle newlines gracefully
-				if serial == "" {
-					continue
-				}
-				err := revokeBySerial(context.Background(), serial, reasonCode, rac, logger, dbMap)
-				if err != nil {
-					logger.Errf("failed to revoke %q: %s", serial, err)
-				}
-			}
-		}()
+	return cert, nil
+}
+
+// parseIssuedWindow parses the RFC 3339 timestamps given to --issued-after and
+// --issued-before, either of which may be empty.
+func parseIssuedWindow(after, before string) (revoker.IssuedWindow, error) {
+	var w revoker.IssuedWindow
+	var err error
+	if after != "" {
+		w.After, err = time.Parse(time.RFC3339, after)
+		if err != nil {
+			return revoker.IssuedWindow{}, fmt.Errorf("invalid --issued-after: %s", err)
+		}
 	}
-	for _, serial := range strings.Split(string(serials), "\n") {
-		if serial == "" {
-			continue
+	if before != "" {
+		w.Before, err = time.Parse(time.RFC3339, before)
+		if err != nil {
+			return revoker.IssuedWindow{}, fmt.Errorf("invalid --issued-before: %s", err)
 		}
-		work <- serial
 	}
-	close(work)
-	wg.Wait()
+	if !w.After.IsZero() && !w.Before.IsZero() && !w.After.Before(w.Before) {
+		return revoker.IssuedWindow{}, errors.New("--issued-after must be earlier than --issued-before")
+	}
+	return w, nil
+}
 
-	return nil
+// parseRevocationDate parses the RFC 3339 timestamp given to
+// --revocation-date, which may be empty, rejecting dates later than now.
+func parseRevocationDate(date string, now time.Time) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --revocation-date: %s", err)
+	}
+	if t.After(now) {
+		return time.Time{}, fmt.Errorf("--revocation-date %s is in the future", date)
+	}
+	return t, nil
 }
 
-// This abstraction is needed so that we can use sort.Sort below
-type revocationCodes []revocation.Reason
+// isTerminal returns true if the provided file is a character device, i.e. an
+// interactive terminal rather than a pipe or regular file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// parseReason parses a reason code argument, which may be either the numeric
+// code or its name as given by list-reasons, and checks that it may be used
+// for administrative revocation. A warning is logged for reasons which are
+// allowed but discouraged.
+func parseReason(logger blog.Logger, s string) (revocation.Reason, error) {
+	reason, err := revocation.ParseAdminReason(s)
+	if err != nil {
+		return 0, err
+	}
+	if note := revocation.AdminReasonNote(reason); note != "" {
+		logger.Warningf("Revoking with reason '%s': %s", reason.String(), note)
+	}
+	return reason, nil
+}
+
+// interactiveReasonCommands are the commands which accept
+// --interactive-reason in place of their reason code argument.
+var interactiveReasonCommands = map[string]bool{
+	"serial-revoke": true,
+	"reg-revoke":    true,
+}
+
+// confirmAndRestart asks the operator to confirm prompt, exiting unless they
+// do, and then restarts the timeout as restartTimeout does.
+func confirmAndRestart(root context.Context, timeout time.Duration, cancel context.CancelFunc, prompt string) (context.Context, context.CancelFunc) {
+	ok, err := revoker.Confirm(os.Stdin, os.Stdout, prompt)
+	failOnError(err, "Couldn't read confirmation")
+	if !ok {
+		failWithCode(exitGeneric, "Revocation aborted by operator")
+	}
+	return restartTimeout(root, timeout, cancel)
+}
 
-func (rc revocationCodes) Len() int           { return len(rc) }
-func (rc revocationCodes) Less(i, j int) bool { return rc[i] < rc[j] }
-func (rc revocationCodes) Swap(i, j int)      { rc[i], rc[j] = rc[j], rc[i] }
+// restartTimeout cancels the command's context, through cancel, and returns a
+// new one derived from root with the full timeout, so that the timeout doesn't
+// include the time spent waiting for the operator.
+func restartTimeout(root context.Context, timeout time.Duration, cancel context.CancelFunc) (context.Context, context.CancelFunc) {
+	cancel()
+	return context.WithTimeout(root, timeout)
+}
 
 func main() {
-	usage := func() {
-		fmt.Fprint(os.Stderr, usageString)
-		os.Exit(1)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage())
+		os.Exit(exitUsage)
+	}
+	command := os.Args[1]
+	cu, ok := findCommand(command)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", command, usage())
+		os.Exit(exitUsage)
 	}
-	if len(os.Args) <= 2 {
-		usage()
+	// commandUsageError prints the usage of just the command being run, along
+	// with what was wrong with its invocation.
+	commandUsageError := func(problem string) {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n%s\nRun admin-revoker without arguments for the usage of all commands\n", cu, problem)
+		exit(exitUsage, problem)
 	}
 
-	command := os.Args[1]
 	flagSet := flag.NewFlagSet(command, flag.ContinueOnError)
 	configFile := flagSet.String("config", "", "File path to the configuration file for this service")
+	configDir := flagSet.String("config-dir", "", "Directory of JSON configuration fragments to merge, in place of --config")
+	dbURL := flagSet.String("dburl", "", "Database URL to connect to, overriding the config, e.g. to query a read replica")
+	dryRun := flagSet.Bool("dry-run", false, "Log the certificates that would be revoked without revoking them")
+	explain := flagSet.Bool("explain", false, "Print the SQL that would be executed, connecting only to the database and modifying nothing")
+	force := flagSet.Bool("force", false, "Change the reason of certificates which are already revoked, rather than skipping them")
+	comment := flagSet.String("comment", "", "Free-text explanation of the revocation")
+	revocationDate := flagSet.String("revocation-date", "", "RFC 3339 time stored as the revocation date in place of now, to backdate revocations")
+	clientTag := flagSet.String("client-tag", defaultClientTag, "Tag attached to every gRPC request, identifying the tool, runbook or ticket the revocations were made for")
+	operator := flagSet.String("operator", "", "Name recorded as having performed the revocation (default the current user)")
+	maxAttempts := flagSet.Int("max-attempts", 3, "Number of times to attempt each revocation when the RA is unavailable")
+	retryBaseDelay := flagSet.Duration("retry-base-delay", time.Second, "Delay before retrying a revocation, doubled for each subsequent retry")
+	strictSkips := flagSet.Bool("strict-skips", false, "Exit non-zero if any serial was skipped rather than revoked, e.g. because it was already revoked")
+	strict := flagSet.Bool("strict", false, "Abort batch-revoke at the first serial which is not found or fails to be revoked, or reg-revoke if any registration is not found")
+	idsFile := flagSet.String("ids-file", "", "File of registration IDs for reg-revoke, one per line")
+	format := flagSet.String("format", "text", "Output format for list-reasons, list-runs, reg-list and serial-info, either \"text\" or \"json\"")
+	issuerArg := flagSet.String("issuer", "", "Subject key identifier in hex, or common name, of the intermediate whose certificates issuer-revoke revokes")
+	parallelism := flagSet.Int("parallelism", 1, "Number of certificates reg-revoke, domain-revoke and issuer-revoke revoke concurrently")
+	feedURL := flagSet.String("url", "", "URL of the JSON feed of serials feed-revoke revokes")
+	statePath := flagSet.String("state", "", "File recording the serials from the feed which feed-revoke has processed")
+	match := flagSet.String("match", "", "Which certificates domain-revoke revokes, either \"exact\" or \"registered-domain\"")
+	allowMissing := flagSet.Bool("allow-missing", false, "Have verify-serials succeed even if some serials aren't found")
+	fix := flagSet.Bool("fix", false, "Have the CA sign a fresh OCSP response for each certificate reconcile finds revoked in the database but not in OCSP")
+	verifyOCSP := flagSet.Bool("verify-ocsp", false, "Confirm each revocation with the OCSP responder")
+	verifyOCSPTimeout := flagSet.Duration("verify-ocsp-timeout", 30*time.Second, "Maximum time to wait for the OCSP responder to confirm each revocation")
+	rate := flagSet.Float64("rate", 0, "Maximum revocation requests made to the RA per second (0 is unlimited)")
+	adaptiveRate := flagSet.Bool("adaptive-rate", false, "Slow down from --rate while the RA reports that it's overloaded")
+	issuedAfter := flagSet.String("issued-after", "", "Only revoke certificates reg-revoke or issuer-revoke finds issued after this RFC 3339 time")
+	since := flagSet.String("since", "", "Only list the runs list-runs finds that started at or after this RFC 3339 time")
+	issuedBefore := flagSet.String("issued-before", "", "Only revoke certificates reg-revoke or issuer-revoke finds issued before this RFC 3339 time")
+	checkpointPath := flagSet.String("checkpoint", "", "File recording the serials a bulk command has revoked, used to resume an interrupted run")
+	batchSize := flagSet.Int("batch-size", revoker.DefaultBatchSize, "Number of a registration's certificates reg-revoke or key-revoke selects at a time")
+	commitEvery := flagSet.Int("commit-every", 0, "Commit batch-revoke's, reg-revoke's or key-revoke's transaction after every N certificates revoked, beginning a new one (0 is a single transaction)")
+	runID := flagSet.String("run-id", "", "Record a bulk command's run under this ID, refusing to run it again once it has completed")
+	forceRerun := flagSet.Bool("force-rerun", false, "Run a bulk command even if its --run-id has already completed")
+	maxRuntime := flagSet.Duration("max-runtime", 0, "Maximum time a bulk command may run for before it stops gracefully, as on the first signal (0 is unlimited)")
+	timeout := flagSet.Duration("timeout", 0, "Maximum time the command may run for (default 30s for single certificate commands, 10m otherwise)")
+	perCallTimeout := flagSet.Duration("per-call-timeout", 0, "Maximum time each revocation request to the RA may take, within --timeout (0 is limited only by --timeout)")
+	connectRetries := flagSet.Int("connect-retries", 0, "Number of times to retry reaching each gRPC service at startup while it is unreachable (default from the config, or 0)")
+	connectTimeout := flagSet.Duration("connect-timeout", 0, "Maximum time each attempt to reach a gRPC service with --connect-retries may take (default from the config, or 5s)")
+	lockKey := flagSet.String("lock-key", "", "Key of the advisory lock held while the command runs (default derived from the command and its target)")
+	lockTimeout := flagSet.Duration("lock-timeout", 0, "Maximum time to wait for the advisory lock held by another run (default from the config, or 0 to fail immediately)")
+	outputSerials := flagSet.String("output-serials", "", "File to which each serial revoked is appended")
+	progressFormat := flagSet.String("progress", "", "Write a line to stderr for every certificate processed in this format. Only \"json\" is supported")
+	summaryJSON := flagSet.String("summary-json", "", "File to which a JSON summary of the outcome is written when the command exits, or \"-\" for stdout")
+	reportCSV := flagSet.String("report-csv", "", "File to which a CSV report of every revocation attempted is written")
+	quiet := flagSet.Bool("quiet", false, "Only write summaries, warnings and errors to stdout, not a line for each certificate")
+	verbose := flagSet.Bool("verbose", false, "Log every database query and the time taken by each RA request")
+	maxCerts := flagSet.Int64("max", defaultMaxCerts, "Abort reg-revoke or key-revoke if it would select more than this many certificates")
+	includePrecert := flagSet.Bool("include-precert", true, "Revoke precertificates for which no final certificate was issued")
+	skipExpired := flagSet.Bool("skip-expired", true, "Skip certificates which have already expired. Only applies to bulk commands unless provided explicitly")
+	noVerify := flagSet.Bool("no-verify", false, "Don't check that every certificate reg-revoke or key-revoke attempted to revoke is revoked once it finishes")
+	forceLarge := flagSet.Bool("force-large", false, "Allow reg-revoke or key-revoke to revoke more than --max certificates. Requires --yes")
+	rollback := flagSet.Bool("rollback", false, "Rehearsal: revoke through the RA, then roll back the command's transaction. Certificates revoked STAY REVOKED. Requires --yes")
+	directSA := flagSet.Bool("direct-sa", false, "Break-glass: revoke directly through the SA, bypassing the RA, without signing OCSP responses. Requires --yes")
+	describe := flagSet.Bool("describe", false, "Include a description of what each reason means in the output of list-reasons")
+	noColor := flagSet.Bool("no-color", false, "Don't color the output of list-reasons, even on a terminal")
+	interactiveReason := flagSet.Bool("interactive-reason", false, "Choose the reason code for serial-revoke or reg-revoke from a list, in place of the reason code argument")
+	notify := flagSet.Bool("notify", false, "Email the contacts of each registration reg-revoke revokes the certificates of")
+	printCert := flagSet.Bool("print-cert", false, "Print the certificate serial-revoke is about to revoke, and prompt for confirmation unless --yes is given")
+	dnsName := flagSet.String("dns-name", "", "Only revoke the certificates reg-revoke finds that include this name among their SANs")
+	suffixMatch := flagSet.Bool("suffix-match", false, "Make --dns-name also match certificates including a subdomain of the name")
+	deactivateAccount := flagSet.Bool("deactivate-account", false, "Deactivate each registration reg-revoke revokes the certificates of. Requires --yes")
+	yes := flagSet.Bool("yes", false, "Don't prompt for confirmation before running reg-revoke, domain-revoke or serial-revoke --print-cert")
+	flagSet.BoolVar(yes, "y", false, "Shorthand for --yes")
 	err := flagSet.Parse(os.Args[2:])
-	cmd.FailOnError(err, "Error parsing flagset")
+	failOnErrorWithCode(err, exitUsage, "Error parsing flagset")
+	tally := &revoker.RunTally{}
+	if *summaryJSON != "" {
+		finisher.SummarizeTo(*summaryJSON, revoker.RunSummary{
+			Command:   command,
+			Arguments: flagSet.Args(),
+			Operator:  *operator,
+			RunID:     *runID,
+		}, tally, cmd.Clock())
+	}
 
-	if *configFile == "" {
-		usage()
+	if (*configFile == "") == (*configDir == "") {
+		commandUsageError("exactly one of --config and --config-dir is required")
+	}
+	if *interactiveReason {
+		if !interactiveReasonCommands[command] {
+			commandUsageError("--interactive-reason only applies to serial-revoke and reg-revoke")
+		}
+		if !isTerminal(os.Stdin) {
+			commandUsageError("--interactive-reason requires stdin to be a terminal, give the reason code as an argument instead")
+		}
+		// The reason code argument is omitted, and chosen once the config
+		// has been loaded.
+		cu.minArgs--
+		if cu.maxArgs != -1 {
+			cu.maxArgs--
+		}
+	}
+	if err := cu.checkArgs(flagSet.NArg()); err != nil {
+		commandUsageError(err.Error())
+	}
+	if *quiet && *verbose {
+		commandUsageError("--quiet and --verbose are mutually exclusive")
+	}
+	if *rate < 0 {
+		failWithCode(exitUsage, "rate argument must be >= 0")
+	}
+	if *adaptiveRate && *rate == 0 {
+		commandUsageError("--adaptive-rate requires --rate")
+	}
+	if *maxRuntime < 0 {
+		failWithCode(exitUsage, "max-runtime argument must be >= 0")
+	}
+	if *maxRuntime > 0 && !bulkCommands[command] {
+		commandUsageError("--max-runtime only applies to bulk commands, use --timeout instead")
+	}
+	if *fix && command != "reconcile" {
+		commandUsageError("--fix only applies to reconcile")
+	}
+	if *allowMissing && command != "verify-serials" {
+		commandUsageError("--allow-missing only applies to verify-serials")
+	}
+	if *perCallTimeout < 0 {
+		failWithCode(exitUsage, "per-call-timeout argument must be >= 0")
+	}
+	if *connectRetries < 0 {
+		failWithCode(exitUsage, "connect-retries argument must be >= 0")
+	}
+	if *connectTimeout < 0 {
+		failWithCode(exitUsage, "connect-timeout argument must be >= 0")
+	}
+	if *lockTimeout < 0 {
+		failWithCode(exitUsage, "lock-timeout argument must be >= 0")
+	}
+	revokedAt, err := parseRevocationDate(*revocationDate, cmd.Clock().Now())
+	failOnErrorWithCode(err, exitUsage, "Invalid revocation date")
+	if *commitEvery < 0 {
+		failWithCode(exitUsage, "commit-every argument must be >= 0")
+	}
+	if *commitEvery > 0 {
+		if !commitEveryCommands[command] {
+			commandUsageError("--commit-every only applies to batch-revoke, reg-revoke and key-revoke")
+		}
+		if *parallelism > 1 {
+			commandUsageError("--commit-every and --parallelism are mutually exclusive, since parallel revocations don't run in a transaction")
+		}
+		if *rollback {
+			commandUsageError("--commit-every and --rollback are mutually exclusive, since --rollback rolls back a single transaction")
+		}
+	}
+	if *dnsName != "" && command != "reg-revoke" {
+		commandUsageError("--dns-name only applies to reg-revoke")
+	}
+	if *printCert && command != "serial-revoke" {
+		commandUsageError("--print-cert only applies to serial-revoke")
+	}
+	if *suffixMatch && *dnsName == "" {
+		commandUsageError("--suffix-match requires --dns-name")
+	}
+	if *batchSize < 1 {
+		failWithCode(exitUsage, "batch-size argument must be >= 1")
+	}
+	if *batchSize != revoker.DefaultBatchSize && command != "reg-revoke" && command != "key-revoke" {
+		commandUsageError("--batch-size only applies to reg-revoke and key-revoke")
+	}
+	if *runID != "" && !bulkCommands[command] {
+		commandUsageError("--run-id only applies to bulk commands")
+	}
+	if *strictSkips && !skipSummaryCommands[command] {
+		commandUsageError("--strict-skips doesn't apply to " + command)
+	}
+	if *forceRerun && *runID == "" {
+		commandUsageError("--force-rerun requires --run-id")
+	}
+	if *rollback {
+		if !rollbackCommands[command] {
+			commandUsageError("--rollback doesn't apply to " + command + ", which doesn't run in a single transaction")
+		}
+		if *parallelism > 1 {
+			commandUsageError("--rollback and --parallelism are mutually exclusive, since parallel revocations don't run in a single transaction")
+		}
+		if *dryRun || *explain {
+			commandUsageError("--rollback and --dry-run are mutually exclusive, since a dry run never revokes anything")
+		}
+		if !*yes {
+			commandUsageError("--rollback requires --yes, since certificates revoked through the RA aren't rolled back")
+		}
+	}
+	if *directSA && !*yes {
+		commandUsageError("--direct-sa requires --yes")
+	}
+	if *directSA && *verifyOCSP {
+		commandUsageError("--direct-sa and --verify-ocsp are mutually exclusive, since no OCSP response is signed")
+	}
+	err = checkOperator(flagSet)
+	failOnErrorWithCode(err, exitUsage, "Invalid operator")
+	err = checkClientTag(*clientTag)
+	failOnErrorWithCode(err, exitUsage, "Invalid client tag")
+
+	rateLimiter := revoker.NewRateLimiter(cmd.Clock(), *rate)
+	if *adaptiveRate {
+		rateLimiter = revoker.NewAdaptiveRateLimiter(cmd.Clock(), *rate)
+	}
+	opts := revoker.Options{
+		// Explaining is a dry run which also prints each query.
+		DryRun:         *dryRun || *explain,
+		Rollback:       *rollback,
+		Force:          *force,
+		IncludePrecert: *includePrecert,
+		SkipExpired:    skipExpiredFor(flagSet, command, *skipExpired),
+		DNSName:        *dnsName,
+		SuffixMatch:    *suffixMatch,
+		Comment:        *comment,
+		RevocationDate: revokedAt,
+		Operator:       *operator,
+		MaxAttempts:    *maxAttempts,
+		RetryBaseDelay: *retryBaseDelay,
+		CallTimeout:    *perCallTimeout,
+		CommitEvery:    *commitEvery,
+		BatchSize:      *batchSize,
+		RateLimit:      rateLimiter,
+		DirectSA:       *directSA,
+		Tally:          tally,
+	}
+
+	if *outputSerials != "" {
+		opts.Output, err = revoker.OpenSerialOutput(*outputSerials)
+		failOnError(err, "Couldn't open output serials file")
+		defer func() { _ = opts.Output.Close() }()
+	}
+	if *reportCSV != "" {
+		opts.Report, err = revoker.OpenReport(*reportCSV)
+		failOnError(err, "Couldn't open CSV report")
+		defer func() { _ = opts.Report.Close() }()
+	}
+	switch *progressFormat {
+	case "":
+	case "json":
+		opts.Progress = revoker.NewProgress(os.Stderr)
+	default:
+		commandUsageError(fmt.Sprintf("unknown progress format %q, only \"json\" is supported", *progressFormat))
 	}
 
 	var c config
-	err = cmd.ReadConfigFile(*configFile, &c)
-	cmd.FailOnError(err, "Reading JSON config file into config structure")
+	var conflicts []string
+	if *configDir != "" {
+		conflicts, err = cmd.ReadConfigDir(*configDir, &c)
+		failOnError(err, "Reading config directory into config structure")
+	} else {
+		err = cmd.ReadConfigFile(*configFile, &c)
+		failOnError(err, "Reading config file into config structure")
+	}
 	err = features.Set(c.Revoker.Features)
-	cmd.FailOnError(err, "Failed to set feature flags")
+	failOnError(err, "Failed to set feature flags")
+	if *dbURL != "" {
+		c.Revoker.DBConfig.DBConnect = *dbURL
+		c.Revoker.DBConfig.DBConnectFile = ""
+	}
+	if *connectRetries > 0 {
+		c.Revoker.ConnectRetries = *connectRetries
+	}
+	if *connectTimeout > 0 {
+		c.Revoker.ConnectTimeout = cmd.ConfigDuration{Duration: *connectTimeout}
+	}
+	if *lockTimeout > 0 {
+		c.Revoker.Lock.Timeout = cmd.ConfigDuration{Duration: *lockTimeout}
+	}
+
+	if *verbose {
+		c.Syslog.StdoutLevel = int(syslog.LOG_DEBUG)
+	}
+	stats, logger := setupLogging(c)
+	err = setupPush(c, stats, logger)
+	failOnError(err, "Couldn't set up pushing metrics")
+	revokerLogger := logger
+	if *quiet {
+		revokerLogger, err = quietLogger(c.Syslog)
+		failOnError(err, "Couldn't set up quiet logger")
+	}
+	for _, conflict := range conflicts {
+		logger.Warningf("Config conflict in %s: %s", *configDir, conflict)
+	}
+	if *rollback {
+		logger.Warning("!!! --rollback: the command's transaction is rolled back once it completes, but " +
+			"REVOCATIONS ARE NOT ROLLED BACK. The RA and SA commit each revocation through their own database " +
+			"connections and the CA signs an OCSP response for it, so every certificate revoked STAYS REVOKED. " +
+			"Only the admin-revoker's own database reads and writes are rolled back !!!")
+	}
+	if *directSA {
+		logger.Warning("!!! --direct-sa: revoking directly through the SA, BYPASSING THE RA. " +
+			"No OCSP responses will be signed and the stored responses of revoked certificates are cleared. " +
+			"OCSP will NOT report these certificates as revoked until new responses are generated and published separately !!!")
+	}
+
+	if *verifyOCSP {
+		if c.Revoker.IssuerCertPath == "" {
+			failWithCode(exitUsage, "--verify-ocsp requires issuerCertPath in the config")
+		}
+		issuer, err := core.LoadCert(c.Revoker.IssuerCertPath)
+		failOnError(err, "Couldn't load issuer certificate")
+		opts.VerifyOCSP = revoker.NewOCSPVerifier(issuer, c.Revoker.OCSPResponderURL, *verifyOCSPTimeout, time.Second, cmd.Clock())
+	}
+	// Panics in any command are audit logged. AuditPanic recovers from the
+	// panic, so exit with a failure afterwards rather than returning from main
+	// as though the command succeeded.
+	completed := false
+	defer func() {
+		if !completed {
+			exit(exitGeneric, "panic")
+		}
+	}()
+	defer logger.AuditPanic()
+
+	if *timeout == 0 {
+		*timeout = singleTimeout
+		if bulkCommands[command] {
+			*timeout = bulkTimeout
+		}
+	}
+	// The timeout is derived from root so that a second signal aborts the
+	// command even after the timeout is restarted.
+	root, abort := context.WithCancel(bgrpc.WithClientTag(context.Background(), *clientTag))
+	defer abort()
+	if bulkCommands[command] {
+		stopper = revoker.NewStopper()
+		opts.Stop = stopper.C()
+		stopper.HandleSignals(logger, abort)
+		if *maxRuntime > 0 {
+			defer stopper.WatchRuntime(logger, *maxRuntime).Stop()
+		}
+	}
+	ctx, cancel := context.WithTimeout(root, *timeout)
+	defer func() { cancel() }()
 
-	ctx := context.Background()
 	args := flagSet.Args()
+	if *interactiveReason {
+		reason, err := revoker.PromptReason(os.Stdin, os.Stdout)
+		failOnErrorWithCode(err, exitUsage, "Couldn't select a reason code")
+		args = append(args, strconv.Itoa(int(reason)))
+		ctx, cancel = restartTimeout(root, *timeout, cancel)
+	}
+	writable := needsWritableDB(command, opts.DryRun, *fix)
+	if writable {
+		key := *lockKey
+		if key == "" {
+			key = revoker.LockKey(c.Revoker.Lock, command, lockTarget(command, args))
+		}
+		lock, err := revoker.AcquireLock(ctx, c.Revoker, key, logger)
+		if _, ok := err.(revoker.LockHeldError); ok {
+			failWithCode(exitGeneric, fmt.Sprintf("Refusing to run: %s. Wait for it to finish, or raise --lock-timeout to wait for it", err))
+		}
+		failOnError(err, "Couldn't acquire the lock")
+		// The lock is released before exiting, by finisher, or if main panics.
+		finisher.HoldLock(lock)
+		defer lock.Release()
+	}
+	var runs *revoker.RunLog
+	if *runID != "" {
+		// A run is recorded, writing to the database, unless it is a dry run.
+		runs, err = revoker.OpenRunLog(c.Revoker, logger, cmd.Clock(), !opts.DryRun)
+		failOnError(err, "Couldn't open the run log")
+		err = runs.Start(*runID, command, args, opts, *forceRerun)
+		if _, ok := err.(revoker.RunCompletedError); ok {
+			failWithCode(exitGeneric, fmt.Sprintf("Refusing to run again: %s. Re-run with --force-rerun to run it anyway", err))
+		}
+		failOnError(err, "Couldn't record the start of the run")
+	}
+	bulk := bulkRun{logger: logger, timeout: *timeout, dryRun: opts.DryRun, strictSkips: *strictSkips}
 	switch {
 	case command == "batched-serial-revoke" && len(args) == 3:
 		// 1: serial file path,  2: reasonCode, 3: parallelism
 		serialPath := args[0]
-		reasonCode, err := strconv.Atoi(args[1])
-		cmd.FailOnError(err, "Reason code argument must be an integer")
+		reasonCode, err := parseReason(logger, args[1])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
 		parallelism, err := strconv.Atoi(args[2])
-		cmd.FailOnError(err, "parallelism argument must be an integer")
+		failOnErrorWithCode(err, exitUsage, "parallelism argument must be an integer")
 		if parallelism < 1 {
-			cmd.Fail("parallelism argument must be >= 1")
+			failWithCode(exitUsage, "parallelism argument must be >= 1")
 		}
 
-		rac, logger, dbMap, _ := setupContext(c)
-		err = revokeBatch(rac, logger, dbMap, serialPath, revocation.Reason(reasonCode), parallelism)
-		cmd.FailOnError(err, "Batch revocation failed")
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+		bulk.run(ctx, "", "Batch revocation failed", func(*revoker.Checkpoint) (revoker.BatchResult, int, error) {
+			result, err := r.RevokeBatch(ctx, serialPath, reasonCode, parallelism, opts)
+			return result, result.Revoked + len(result.Failures), err
+		})
+	case command == "batch-revoke" && len(args) == 2:
+		// 1: serial file path,  2: default reasonCode
+		serials, reasons, err := revoker.ReadSerialReasonFile(args[0])
+		failOnError(err, "Couldn't read serial file")
+		reasonCode, err := parseReason(logger, args[1])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		opts.Progress.SetTotal(len(serials))
+		bulk.run(ctx, *checkpointPath, "Batch revocation failed", func(cp *revoker.Checkpoint) (revoker.BatchResult, int, error) {
+			result, err := r.RevokeSerials(ctx, serials, reasonCode, reasons, opts, *strict, cp)
+			return result, len(serials), err
+		})
+
 	case command == "serial-revoke" && len(args) == 2:
 		// 1: serial,  2: reasonCode
 		serial := args[0]
-		reasonCode, err := strconv.Atoi(args[1])
-		cmd.FailOnError(err, "Reason code argument must be an integer")
+		reasonCode, err := parseReason(logger, args[1])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
 
-		rac, logger, dbMap, _ := setupContext(c)
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
 
-		_, err = db.WithTransaction(ctx, dbMap, func(txWithCtx db.Executor) (interface{}, error) {
-			err := revokeBySerial(ctx, serial, revocation.Reason(reasonCode), rac, logger, txWithCtx)
-			return nil, err
-		})
-		cmd.FailOnError(err, "Couldn't revoke certificate by serial")
+		if *printCert {
+			cert, kind, err := r.FindCertificate(serial, *includePrecert)
+			failOnError(checkTimeout(ctx, *timeout, err), "Couldn't fetch certificate")
+			failOnError(revoker.PrintCertificate(os.Stdout, cert, kind), "Couldn't print certificate")
+			if !*yes && !opts.DryRun {
+				if !isTerminal(os.Stdin) {
+					failWithCode(exitUsage, "Refusing to revoke without confirmation: stdin is not a terminal and --yes was not provided")
+				}
+				ctx, cancel = confirmAndRestart(root, *timeout, cancel, fmt.Sprintf("Revoke this %s with reason '%s'?", kind, reasonCode.String()))
+			}
+		}
+
+		err = r.RevokeSerial(ctx, serial, reasonCode, opts)
+		err = checkTimeout(ctx, *timeout, err)
+		failOnError(err, "Couldn't revoke certificate by serial")
+		if opts.DryRun {
+			logger.Info("DRY RUN - no certificates revoked")
+		}
+
+	case command == "fingerprint-revoke" && len(args) == 2:
+		// 1: fingerprint,  2: reasonCode
+		fingerprint := args[0]
+		reasonCode, err := parseReason(logger, args[1])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		err = r.RevokeFingerprint(ctx, fingerprint, reasonCode, opts)
+		err = checkTimeout(ctx, *timeout, err)
+		failOnError(err, "Couldn't revoke certificate by fingerprint")
+		if opts.DryRun {
+			logger.Info("DRY RUN - no certificates revoked")
+		}
+
+	case command == "cert-revoke" && len(args) == 2:
+		// 1: certificate path,  2: reasonCode
+		contents, err := ioutil.ReadFile(args[0])
+		failOnError(err, "Couldn't read certificate file")
+		cert, err := parseCertificateFile(contents)
+		failOnError(err, "Couldn't parse certificate file")
+		reasonCode, err := parseReason(logger, args[1])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		err = r.RevokeCertificate(ctx, cert, reasonCode, opts)
+		err = checkTimeout(ctx, *timeout, err)
+		failOnError(err, "Couldn't revoke certificate")
+		if opts.DryRun {
+			logger.Info("DRY RUN - no certificates revoked")
+		}
 
-	case command == "reg-revoke" && len(args) == 2:
-		// 1: registration ID,  2: reasonCode
+	case (command == "reg-revoke" && len(args) >= 1) || (command == "key-revoke" && len(args) == 2):
+		// 1..n-1: registration IDs or an account key hash,  n: reasonCode
+		var regIDs []int64
+		if command == "reg-revoke" {
+			regIDs, err = parseRegIDs(args[:len(args)-1], *idsFile)
+			failOnErrorWithCode(err, exitUsage, "Invalid registration IDs")
+			if len(regIDs) == 0 {
+				commandUsageError("no registration IDs given as arguments or in --ids-file")
+			}
+		}
+		reasonCode, err := parseReason(logger, args[len(args)-1])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
+		if *parallelism < 1 {
+			failWithCode(exitUsage, "parallelism argument must be >= 1")
+		}
+		window, err := parseIssuedWindow(*issuedAfter, *issuedBefore)
+		failOnErrorWithCode(err, exitUsage, "Invalid issued window")
+		if *maxCerts < 1 {
+			failWithCode(exitUsage, "max argument must be >= 1")
+		}
+		if *forceLarge && !*yes {
+			commandUsageError("--force-large requires --yes")
+		}
+		if *notify && command != "reg-revoke" {
+			commandUsageError("--notify only applies to reg-revoke")
+		}
+		if *deactivateAccount {
+			if command != "reg-revoke" {
+				commandUsageError("--deactivate-account only applies to reg-revoke")
+			}
+			if !*yes {
+				commandUsageError("--deactivate-account requires --yes")
+			}
+			if *dnsName != "" {
+				commandUsageError("--deactivate-account and --dns-name are mutually exclusive, since certificates not covering the name are left unrevoked")
+			}
+		}
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		var notifier *revoker.Notifier
+		if *notify {
+			notifier, err = revoker.NewNotifier(c.Revoker.Notify, opts.DryRun, logger, stats)
+			failOnError(err, "Couldn't set up notifications")
+		}
+
+		if command == "key-revoke" {
+			regID, err := r.RegistrationIDForKeyHash(args[0])
+			failOnError(err, "Couldn't find registration for account key")
+			logger.Infof("Account key %s belongs to registration %d", args[0], regID)
+			regIDs = []int64{regID}
+		}
+
+		// Registrations which can't be found are reported in the summary
+		// rather than aborting the others, unless --strict was provided.
+		regs, err := r.FindRegistrations(ctx, regIDs, *strict)
+		failOnError(checkTimeout(ctx, *timeout, err), "Couldn't fetch registration")
+
+		checkMax := !*forceLarge && !opts.DryRun
+		if checkMax || opts.Progress != nil {
+			selected, err := r.CountRegistrationsSerials(regs, window)
+			failOnError(err, "Couldn't count certificates for registration")
+			opts.Progress.SetTotal(int(selected))
+			if checkMax && selected > *maxCerts {
+				failWithCode(exitUsage, fmt.Sprintf(
+					"Refusing to revoke: %d certificates selected, more than --max %d. Check the registration IDs, then re-run with --max raised or with --yes --force-large",
+					selected, *maxCerts))
+			}
+		}
+
+		if !*yes && !opts.DryRun && len(regs.Found) > 0 {
+			if !isTerminal(os.Stdin) {
+				failWithCode(exitUsage, "Refusing to revoke without confirmation: stdin is not a terminal and --yes was not provided")
+			}
+			prompt, err := r.DescribeRegistrations(os.Stdout, regs, window, reasonCode, opts)
+			failOnError(err, "Couldn't select certificates for registration")
+			ctx, cancel = confirmAndRestart(root, *timeout, cancel, prompt)
+		}
+
+		var cp *revoker.Checkpoint
+		if *checkpointPath != "" {
+			cp, err = revoker.OpenCheckpoint(*checkpointPath)
+			failOnError(err, "Couldn't open checkpoint file")
+			defer func() { _ = cp.Close() }()
+		}
+
+		summary, err := r.RevokeRegistrations(ctx, regs, window, reasonCode, opts, revoker.RegistrationsOptions{
+			Parallelism: *parallelism,
+			Deactivate:  *deactivateAccount,
+			Notifier:    notifier,
+			Verify:      !opts.DryRun && !*noVerify,
+			Logger:      logger,
+		}, cp)
+		failOnError(err, "Couldn't verify revocations")
+		if opts.DryRun {
+			logger.Info("DRY RUN - no certificates revoked")
+		}
+		if summary.Failed > 0 {
+			failOnError(checkTimeout(ctx, *timeout, summary.Err),
+				fmt.Sprintf("Failed to revoke certificates for %d of %d registrations", summary.Failed, len(regIDs)))
+		}
+		if len(summary.NotRevoked) > 0 {
+			failWithCode(exitGeneric, fmt.Sprintf("%d certificates are not revoked: %s", len(summary.NotRevoked), strings.Join(summary.NotRevoked, ", ")))
+		}
+		failOnSkipped(*strictSkips, summary.Skipped)
+
+	case command == "serial-info" && len(args) == 1:
+		// 1: serial
+		if *format != "" && *format != "text" && *format != "json" {
+			commandUsageError(fmt.Sprintf("unknown format %q, must be \"text\" or \"json\"", *format))
+		}
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		info, err := r.SerialInfo(ctx, args[0])
+		failOnError(checkTimeout(ctx, *timeout, err), "Couldn't look up serial")
+		failOnError(revoker.PrintSerialInfo(os.Stdout, info, *format), "Couldn't write serial info")
+
+	case command == "reg-count" && len(args) == 1:
+		// 1: registration ID
+		regID, err := strconv.ParseInt(args[0], 10, 64)
+		failOnErrorWithCode(err, exitUsage, "Registration ID argument must be an integer")
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		_, err = r.GetRegistration(ctx, regID)
+		failOnError(checkTimeout(ctx, *timeout, err), "Couldn't fetch registration")
+
+		counts, err := r.CountRegistrationCertificates(regID)
+		failOnError(err, "Couldn't count certificates for registration")
+		fmt.Printf("Registration %d has %d certificates: %d valid, %d revoked, %d expired\n",
+			regID, counts.Total, counts.Valid, counts.Revoked, counts.Expired)
+		impact, err := r.EstimateRegistrationImpact(regID, revoker.IssuedWindow{})
+		failOnError(err, "Couldn't estimate the impact of revoking the registration's certificates")
+		fmt.Printf("If revoked, %s\n", impact)
+
+	case command == "reg-list" && len(args) == 1:
+		// 1: registration ID
 		regID, err := strconv.ParseInt(args[0], 10, 64)
-		cmd.FailOnError(err, "Registration ID argument must be an integer")
-		reasonCode, err := strconv.Atoi(args[1])
-		cmd.FailOnError(err, "Reason code argument must be an integer")
+		failOnErrorWithCode(err, exitUsage, "Registration ID argument must be an integer")
+		window, err := parseIssuedWindow(*issuedAfter, *issuedBefore)
+		failOnErrorWithCode(err, exitUsage, "Invalid issued window")
+		list, err := revoker.NewCertLister(os.Stdout, *format)
+		failOnErrorWithCode(err, exitUsage, "Invalid format")
 
-		rac, logger, dbMap, sac := setupContext(c)
-		defer logger.AuditPanic()
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
 
-		_, err = sac.GetRegistration(ctx, regID)
+		_, err = r.GetRegistration(ctx, regID)
+		failOnError(checkTimeout(ctx, *timeout, err), "Couldn't fetch registration")
+
+		impact, err := r.ListRegistration(regID, window, list.Write)
+		failOnError(err, "Couldn't list certificates for registration")
+		failOnError(list.Flush(), "Couldn't write certificate list")
+		logger.Infof("If the certificates listed were revoked, %s", impact)
+
+	case command == "key-block" && len(args) == 1:
+		// 1: certificate PEM path or serial
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		cert, err := loadCertificate(r, args[0])
+		failOnError(err, "Couldn't load certificate")
+
+		bulk.run(ctx, "", "Couldn't block key", func(*revoker.Checkpoint) (revoker.BatchResult, int, error) {
+			result, err := r.BlockKey(ctx, cert, opts)
+			if err == nil {
+				logger.Infof("Blocked key %x: %d certificates across %d registrations",
+					result.KeyHash, result.Certificates, result.Registrations)
+				if opts.DryRun {
+					logger.Info("DRY RUN - no key blocked")
+				}
+			}
+			return result.Result, result.Certificates, err
+		})
+
+	case command == "domain-revoke" && len(args) == 2:
+		// 1: domain name,  2: reasonCode
+		nameMatch, err := revoker.ParseNameMatch(*match)
 		if err != nil {
-			cmd.FailOnError(err, "Couldn't fetch registration")
+			commandUsageError(err.Error())
+		}
+		domain, err := revoker.MatchedDomain(args[0], nameMatch)
+		failOnErrorWithCode(err, exitUsage, "Invalid domain name")
+		if domain != args[0] {
+			logger.Infof("Matching %s as %s", args[0], domain)
+		}
+		reasonCode, err := parseReason(logger, args[1])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
+		if *parallelism < 1 {
+			failWithCode(exitUsage, "parallelism argument must be >= 1")
 		}
 
-		_, err = db.WithTransaction(ctx, dbMap, func(txWithCtx db.Executor) (interface{}, error) {
-			err := revokeByReg(ctx, regID, revocation.Reason(reasonCode), rac, logger, txWithCtx)
-			return nil, err
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		serials, err := r.DomainSerials(domain, nameMatch)
+		failOnError(err, "Couldn't select certificates for domain")
+		description := domain
+		if nameMatch == revoker.MatchRegisteredDomain {
+			description = fmt.Sprintf("%s and its subdomains", domain)
+		}
+		logger.Infof("Found %d unexpired certificates for %s", len(serials), description)
+
+		if !*yes && !opts.DryRun && len(serials) > 0 {
+			if !isTerminal(os.Stdin) {
+				failWithCode(exitUsage, "Refusing to revoke without confirmation: stdin is not a terminal and --yes was not provided")
+			}
+			prompt := fmt.Sprintf("Revoke all %d certificates for %s with reason '%s'?", len(serials), description, reasonCode.String())
+			ctx, cancel = confirmAndRestart(root, *timeout, cancel, prompt)
+		}
+
+		bulk.run(ctx, *checkpointPath, "Domain revocation failed", func(cp *revoker.Checkpoint) (revoker.BatchResult, int, error) {
+			result, err := r.RevokeSerialsParallel(ctx, serials, reasonCode, opts, *parallelism, cp)
+			return result, len(serials), err
 		})
-		cmd.FailOnError(err, "Couldn't revoke certificate by registration")
+
+	case command == "issuer-revoke" && len(args) == 1:
+		// 1: reasonCode
+		if *issuerArg == "" {
+			commandUsageError("--issuer is required")
+		}
+		issuer, err := revoker.ParseIssuer(*issuerArg)
+		failOnErrorWithCode(err, exitUsage, "Invalid issuer")
+		window, err := parseIssuedWindow(*issuedAfter, *issuedBefore)
+		failOnErrorWithCode(err, exitUsage, "Invalid issued window")
+		if window.Before.IsZero() {
+			commandUsageError("--issued-before is required")
+		}
+		if !*yes && !opts.DryRun {
+			commandUsageError("issuer-revoke may revoke very many certificates, so --yes is required. Run with --dry-run to see which")
+		}
+		reasonCode, err := parseReason(logger, args[0])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
+		if *parallelism < 1 {
+			failWithCode(exitUsage, "parallelism argument must be >= 1")
+		}
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		logger.Infof("Revoking certificates issued %s by %s", window, issuer)
+		bulk.run(ctx, *checkpointPath, "Issuer revocation failed", func(cp *revoker.Checkpoint) (revoker.BatchResult, int, error) {
+			result, err := r.RevokeIssuer(ctx, issuer, window, reasonCode, opts, *parallelism, cp)
+			return result, result.Revoked + len(result.Failures), err
+		})
+
+	case command == "feed-revoke" && len(args) == 1:
+		// 1: reasonCode
+		if *feedURL == "" {
+			commandUsageError("--url is required")
+		}
+		if *statePath == "" {
+			commandUsageError("--state is required")
+		}
+		reasonCode, err := parseReason(logger, args[0])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
+		feed, err := revoker.NewFeed(*feedURL, c.Revoker.Feed)
+		failOnError(err, "Couldn't set up feed client")
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		logger.Infof("Revoking new serials from feed %s", *feedURL)
+		// The state file is the checkpoint of the serials already revoked.
+		bulk.run(ctx, *statePath, "Feed revocation failed", func(state *revoker.Checkpoint) (revoker.BatchResult, int, error) {
+			result, err := r.RevokeFeed(ctx, feed, state, reasonCode, opts)
+			return result, result.Revoked + len(result.Failures), err
+		})
+
+	case command == "crl-revoke" && len(args) == 2:
+		// 1: CRL file path,  2: default reasonCode
+		entries, err := revoker.ReadCRLFile(args[0])
+		failOnError(err, "Couldn't read CRL")
+		reasonCode, err := parseReason(logger, args[1])
+		failOnErrorWithCode(err, exitUsage, "Invalid reason code argument")
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		logger.Infof("Revoking the certificates listed in CRL %s which we issued", args[0])
+		bulk.run(ctx, "", "CRL revocation failed", func(*revoker.Checkpoint) (revoker.BatchResult, int, error) {
+			result, err := r.RevokeCRL(ctx, entries, reasonCode, opts)
+			return result, result.Revoked + len(result.Failures), err
+		})
+
+	case command == "ocsp-refresh" && len(args) == 1:
+		// 1: serial file path
+		serials, err := revoker.ReadSerialFile(args[0])
+		failOnError(err, "Couldn't read serial file")
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		result, err := r.RefreshOCSP(ctx, serials, opts)
+		result.Log(logger)
+		err = checkTimeout(ctx, *timeout, err)
+		failOnError(err, "OCSP refresh failed")
+		if opts.DryRun {
+			logger.Info("DRY RUN - no OCSP responses refreshed")
+		}
+		if len(result.Failures) > 0 {
+			failOnError(result.Failures[0].Err,
+				fmt.Sprintf("Failed to refresh %d of %d OCSP responses", len(result.Failures), result.Refreshed+len(result.Failures)))
+		}
+
+	case command == "reconcile" && len(args) == 1:
+		// 1: serial file path
+		serials, err := revoker.ReadSerialFile(args[0])
+		failOnError(err, "Couldn't read serial file")
+		if c.Revoker.IssuerCertPath == "" {
+			failWithCode(exitUsage, "reconcile requires issuerCertPath in the config")
+		}
+		issuer, err := core.LoadCert(c.Revoker.IssuerCertPath)
+		failOnError(err, "Couldn't load issuer certificate")
+		verifier := revoker.NewOCSPVerifier(issuer, c.Revoker.OCSPResponderURL, *verifyOCSPTimeout, time.Second, cmd.Clock())
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		result, err := r.Reconcile(ctx, serials, verifier, *fix, opts)
+		result.Log(logger)
+		err = checkTimeout(ctx, *timeout, err)
+		failOnError(err, "Reconcile failed")
+		if opts.DryRun && *fix {
+			logger.Info("DRY RUN - no OCSP responses refreshed")
+		}
+		if len(result.Failures) > 0 {
+			failOnError(result.Failures[0].Err,
+				fmt.Sprintf("Failed to reconcile %d of %d certificates", len(result.Failures), result.Checked+len(result.Failures)))
+		}
+		if unfixed := result.Unfixed(); unfixed > 0 {
+			failWithCode(exitGeneric, fmt.Sprintf("%d of %d certificates don't match OCSP", unfixed, result.Checked))
+		}
+
+	case command == "verify-serials" && len(args) == 1:
+		// 1: serial file path
+		serials, err := revoker.ReadSerialFile(args[0])
+		failOnError(err, "Couldn't read serial file")
+
+		r := setupContext(c, stats, revokerLogger, *verbose, *explain, writable)
+
+		results, err := r.FindSerials(serials, opts.IncludePrecert)
+		failOnError(err, "Couldn't look up serials")
+		missing, malformed := revoker.PrintSerialPresence(os.Stdout, results)
+		switch {
+		case malformed > 0:
+			failWithCode(exitUsage, fmt.Sprintf("%d of %d serials are malformed", malformed, len(results)))
+		case missing > 0 && !*allowMissing:
+			failWithCode(exitNotFound, fmt.Sprintf("%d of %d serials weren't found", missing, len(results)))
+		}
 
 	case command == "list-reasons":
-		var codes revocationCodes
-		for k := range revocation.ReasonToString {
-			codes = append(codes, k)
+		err := revoker.ListReasons(os.Stdout, *format, *describe, !*noColor && isTerminal(os.Stdout))
+		failOnError(err, "Couldn't list reasons")
+
+	case command == "list-runs":
+		var sinceTime time.Time
+		if *since != "" {
+			sinceTime, err = time.Parse(time.RFC3339, *since)
+			failOnErrorWithCode(err, exitUsage, "Invalid --since")
+		}
+		if *format != "" && *format != "text" && *format != "json" {
+			commandUsageError(fmt.Sprintf("unknown format %q, must be \"text\" or \"json\"", *format))
 		}
-		sort.Sort(codes)
-		fmt.Printf("Revocation reason codes\n-----------------------\n\n")
-		for _, k := range codes {
-			fmt.Printf("%d: %s\n", k, revocation.ReasonToString[k])
+		runLog, err := revoker.OpenRunLog(c.Revoker, logger, cmd.Clock(), false)
+		failOnError(err, "Couldn't open the run log")
+		list, err := runLog.ListRuns(sinceTime)
+		failOnError(err, "Couldn't list runs")
+		failOnError(revoker.PrintRuns(os.Stdout, list, *format), "Couldn't write run list")
+
+	case command == "check-config":
+		checks := revoker.CheckConfig(ctx, c.Revoker, logger, cmd.Clock(), stats)
+		failed := revoker.PrintConfigChecks(os.Stdout, checks, !*noColor && isTerminal(os.Stdout))
+		if failed > 0 {
+			failWithCode(exitGeneric, fmt.Sprintf("%d of %d config checks failed", failed, len(checks)))
 		}
 
 	default:
-		usage()
+		// Only reachable if commands disagrees with the cases above.
+		commandUsageError(fmt.Sprintf("unexpected arguments %q", args))
+	}
+	if runs != nil && !opts.DryRun {
+		failOnError(runs.Complete(*runID, opts.Tally.Counts()), "Couldn't record the completion of the run")
+	}
+	if summary := opts.Tally.Summary(); summary.DryRun > 0 {
+		logger.Infof("DRY RUN - if revoked, %s", summary.Impact)
 	}
+	completed = true
+	finisher.Finish(0, exitCompleted)
 }
@@ -2,96 +2,354 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
-	"math/big"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/jmhodges/clock"
-	caPB "github.com/letsencrypt/boulder/ca/proto"
-	"github.com/letsencrypt/boulder/core"
-	"github.com/letsencrypt/boulder/goodkey"
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
 	blog "github.com/letsencrypt/boulder/log"
-	"github.com/letsencrypt/boulder/metrics"
-	"github.com/letsencrypt/boulder/mocks"
-	"github.com/letsencrypt/boulder/ra"
-	"github.com/letsencrypt/boulder/sa"
-	sapb "github.com/letsencrypt/boulder/sa/proto"
-	"github.com/letsencrypt/boulder/sa/satest"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/revoker"
 	"github.com/letsencrypt/boulder/test"
-	"github.com/letsencrypt/boulder/test/vars"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-type mockCA struct {
-	mocks.MockCA
+func TestCheckClientTag(t *testing.T) {
+	test.AssertNotError(t, checkClientTag(defaultClientTag), "default client tag rejected")
+	test.AssertNotError(t, checkClientTag("INC-1234 key-compromise runbook"), "client tag with spaces rejected")
+	test.AssertError(t, checkClientTag(""), "empty client tag accepted")
+	test.AssertError(t, checkClientTag("tag\n"), "client tag with a newline accepted")
+	test.AssertError(t, checkClientTag("tâg"), "non-ASCII client tag accepted")
+	test.AssertError(t, checkClientTag(strings.Repeat("a", maxClientTagLength+1)), "overlong client tag accepted")
 }
 
-func (ca *mockCA) GenerateOCSP(ctx context.Context, req *caPB.GenerateOCSPRequest) (*caPB.OCSPResponse, error) {
-	return &caPB.OCSPResponse{}, nil
+func TestBulkRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulk-run")
+	test.AssertNotError(t, err, "failed to create temp dir")
+	defer os.RemoveAll(dir)
+
+	log := blog.NewMock()
+	bulk := bulkRun{logger: log, timeout: time.Minute, dryRun: true}
+	var got *revoker.Checkpoint
+	revoke := func(cp *revoker.Checkpoint) (revoker.BatchResult, int, error) {
+		got = cp
+		return revoker.BatchResult{Revoked: 2}, 2, nil
+	}
+
+	// Without a path, there is no checkpoint.
+	bulk.run(context.Background(), "", "Revocation failed", revoke)
+	test.Assert(t, got == nil, "checkpoint opened without a path")
+	test.AssertEquals(t, len(log.GetAllMatching("DRY RUN - no certificates revoked")), 1)
+
+	path := dir + "/checkpoint"
+	bulk.run(context.Background(), path, "Revocation failed", revoke)
+	test.Assert(t, got != nil, "checkpoint not opened with a path")
+	_, err = os.Stat(path)
+	test.AssertNotError(t, err, "checkpoint file not created")
+}
+
+func TestRestartTimeout(t *testing.T) {
+	root, abort := context.WithCancel(context.Background())
+	defer abort()
+	ctx, cancel := context.WithTimeout(root, time.Hour)
+	first, _ := ctx.Deadline()
+
+	restarted, cancel := restartTimeout(root, time.Hour, cancel)
+	defer cancel()
+	test.AssertEquals(t, ctx.Err(), context.Canceled)
+	test.AssertNotError(t, restarted.Err(), "restarted context is already done")
+	deadline, ok := restarted.Deadline()
+	test.Assert(t, ok, "restarted context has no deadline")
+	test.Assert(t, !deadline.Before(first), "restarted deadline is earlier than the original")
+
+	// The restarted context is still aborted along with root.
+	abort()
+	test.AssertEquals(t, restarted.Err(), context.Canceled)
 }
 
-func TestRevokeBatch(t *testing.T) {
-	log := blog.UseMock()
-	fc := clock.NewFake()
-	// Set to some non-zero time.
-	fc.Set(time.Date(2015, 3, 4, 5, 0, 0, 0, time.UTC))
-	dbMap, err := sa.NewDbMap(vars.DBConnSA, 0)
-	if err != nil {
-		t.Fatalf("Failed to create dbMap: %s", err)
+func TestParseReason(t *testing.T) {
+	log := blog.NewMock()
+	reason, err := parseReason(log, "1")
+	test.AssertNotError(t, err, "parseReason failed on a code")
+	test.AssertEquals(t, reason, revocation.Reason(1))
+	reason, err = parseReason(log, "superseded")
+	test.AssertNotError(t, err, "parseReason failed on a name")
+	test.AssertEquals(t, reason, revocation.Reason(4))
+	test.AssertEquals(t, len(log.GetAllMatching("WARNING")), 0)
+
+	_, err = parseReason(log, "6")
+	test.AssertError(t, err, "parseReason accepted certificateHold")
+	test.AssertContains(t, err.Error(), "certificateHold")
+	_, err = parseReason(log, "7")
+	test.AssertError(t, err, "parseReason accepted an unused code")
+	_, err = parseReason(log, "notAReason")
+	test.AssertError(t, err, "parseReason accepted an unknown name")
+
+	reason, err = parseReason(log, "unspecified")
+	test.AssertNotError(t, err, "parseReason rejected unspecified")
+	test.AssertEquals(t, reason, revocation.Reason(0))
+	test.AssertEquals(t, len(log.GetAllMatching("Revoking with reason 'unspecified'")), 1)
+}
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected int
+	}{
+		{errors.New("oops"), exitGeneric},
+		{berrors.MalformedError("invalid reason code: 7"), exitUsage},
+		{berrors.NotFoundError("certificate not found"), exitNotFound},
+		{db.ErrDatabaseOp{Op: "select", Table: "certificates", Err: sql.ErrNoRows}, exitNotFound},
+		{db.ErrDatabaseOp{Op: "select", Table: "certificates", Err: errors.New("bad connection")}, exitDB},
+		{&db.RollbackError{Err: errors.New("oops"), RollbackErr: errors.New("bad connection")}, exitDB},
+		{berrors.InternalServerError("no certificate with serial"), exitBackend},
+		{status.Error(codes.Unavailable, "connection refused"), exitBackend},
+		{revoker.BackendError{Err: errors.New("connection refused")}, exitBackend},
+		{revoker.BackendUnavailableError{Revoked: 2, Err: status.Error(codes.Unavailable, "connection refused")}, exitBackend},
+		{revoker.DatabaseError{Err: errors.New("bad DB URL")}, exitDB},
+		{timeoutError{time.Second, status.Error(codes.DeadlineExceeded, "context deadline exceeded")}, exitTimeout},
+		{revoker.ErrInterrupted, exitInterrupted},
+		{status.Error(codes.Canceled, "context canceled"), exitInterrupted},
 	}
-	ssa, err := sa.NewSQLStorageAuthority(dbMap, fc, log, metrics.NoopRegisterer, 1)
-	if err != nil {
-		t.Fatalf("Failed to create SA: %s", err)
+	for _, tc := range testCases {
+		test.AssertEquals(t, exitCode(tc.err), tc.expected)
 	}
-	defer test.ResetSATestDatabase(t)
-	reg := satest.CreateWorkingRegistration(t, ssa)
+}
+
+func TestParseIssuedWindow(t *testing.T) {
+	_, err := parseIssuedWindow("yesterday", "")
+	test.AssertError(t, err, "parseIssuedWindow accepted an invalid time")
+	_, err = parseIssuedWindow("2020-05-02T00:00:00Z", "2020-05-01T00:00:00Z")
+	test.AssertError(t, err, "parseIssuedWindow accepted an empty window")
+
+	w, err := parseIssuedWindow("", "")
+	test.AssertNotError(t, err, "parseIssuedWindow failed")
+	test.AssertEquals(t, w, revoker.IssuedWindow{})
 
-	ra := ra.NewRegistrationAuthorityImpl(fc,
-		log,
-		metrics.NoopRegisterer,
-		1, goodkey.KeyPolicy{}, 100, true, false, 300*24*time.Hour, 7*24*time.Hour, nil, nil, 0, nil, nil, &x509.Certificate{})
-	ra.SA = ssa
-	ra.CA = &mockCA{}
+	w, err = parseIssuedWindow("2020-05-01T00:00:00Z", "2020-05-02T00:00:00Z")
+	test.AssertNotError(t, err, "parseIssuedWindow failed")
+	test.AssertEquals(t, w.String(), "after 2020-05-01T00:00:00Z and before 2020-05-02T00:00:00Z")
 
-	serialFile, err := ioutil.TempFile("", "serials")
+	w, err = parseIssuedWindow("2020-05-01T00:00:00Z", "")
+	test.AssertNotError(t, err, "parseIssuedWindow failed")
+	test.AssertEquals(t, w.String(), "after 2020-05-01T00:00:00Z")
+}
+
+func TestParseRevocationDate(t *testing.T) {
+	now := time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC)
+	_, err := parseRevocationDate("yesterday", now)
+	test.AssertError(t, err, "parseRevocationDate accepted an invalid time")
+	_, err = parseRevocationDate("2020-05-03T00:00:00Z", now)
+	test.AssertError(t, err, "parseRevocationDate accepted a time in the future")
+
+	date, err := parseRevocationDate("", now)
+	test.AssertNotError(t, err, "parseRevocationDate failed")
+	test.Assert(t, date.IsZero(), "parseRevocationDate returned a date for an empty string")
+
+	date, err = parseRevocationDate("2020-05-01T00:00:00Z", now)
+	test.AssertNotError(t, err, "parseRevocationDate failed")
+	test.AssertEquals(t, date, time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestCheckTimeout(t *testing.T) {
+	err := errors.New("oops")
+	test.AssertEquals(t, checkTimeout(context.Background(), time.Second, nil), nil)
+	test.AssertEquals(t, checkTimeout(context.Background(), time.Second, err), err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	test.AssertEquals(t, checkTimeout(ctx, time.Second, nil), nil)
+	timedOut := checkTimeout(ctx, time.Second, err)
+	test.AssertEquals(t, timedOut, timeoutError{time.Second, err})
+	test.AssertEquals(t, timedOut.Error(), "timed out after 1s: oops")
+	test.AssertEquals(t, exitCode(timedOut), exitTimeout)
+}
+
+func TestParseRegIDs(t *testing.T) {
+	idsFile, err := ioutil.TempFile("", "regids")
 	test.AssertNotError(t, err, "failed to open temp file")
-	defer os.Remove(serialFile.Name())
-
-	serials := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
-	k, err := rsa.GenerateKey(rand.Reader, 512)
-	test.AssertNotError(t, err, "failed to generate test key")
-	issued := time.Now().UnixNano()
-	for _, serial := range serials {
-		template := &x509.Certificate{
-			SerialNumber: serial,
-			DNSNames:     []string{"asd"},
-		}
-		der, err := x509.CreateCertificate(rand.Reader, template, template, &k.PublicKey, k)
-		test.AssertNotError(t, err, "failed to generate test cert")
-		_, err = ssa.AddPrecertificate(context.Background(), &sapb.AddCertificateRequest{
-			Der:    der,
-			RegID:  &reg.ID,
-			Issued: &issued,
-		})
-		test.AssertNotError(t, err, "failed to add test cert")
-		now := time.Now()
-		_, err = ssa.AddCertificate(context.Background(), der, reg.ID, nil, &now)
-		test.AssertNotError(t, err, "failed to add test cert")
-		_, err = serialFile.WriteString(fmt.Sprintf("%s\n", core.SerialToString(serial)))
-		test.AssertNotError(t, err, "failed to write serial to temp file")
+	defer os.Remove(idsFile.Name())
+	_, err = idsFile.WriteString("# abusive accounts\n\n3\n  4  \n#5\n")
+	test.AssertNotError(t, err, "failed to write temp file")
+
+	regIDs, err := parseRegIDs([]string{"1", "2"}, "")
+	test.AssertNotError(t, err, "parseRegIDs failed")
+	test.AssertDeepEquals(t, regIDs, []int64{1, 2})
+
+	regIDs, err = parseRegIDs([]string{"1"}, idsFile.Name())
+	test.AssertNotError(t, err, "parseRegIDs failed with an IDs file")
+	test.AssertDeepEquals(t, regIDs, []int64{1, 3, 4})
+
+	_, err = parseRegIDs([]string{"1", "two"}, "")
+	test.AssertError(t, err, "parseRegIDs accepted a non-integer ID")
+	_, err = parseRegIDs(nil, "/does/not/exist")
+	test.AssertError(t, err, "parseRegIDs didn't fail on a missing IDs file")
+}
+
+func TestNeedsWritableDB(t *testing.T) {
+	testCases := []struct {
+		command  string
+		dryRun   bool
+		fix      bool
+		expected bool
+	}{
+		{"serial-revoke", false, false, true},
+		{"serial-revoke", true, false, false},
+		{"reg-revoke", false, false, true},
+		{"ocsp-refresh", false, false, true},
+		{"reg-list", false, false, false},
+		{"reg-count", false, false, false},
+		{"serial-info", false, false, false},
+		{"reconcile", false, false, false},
+		{"reconcile", false, true, true},
+	}
+	for _, tc := range testCases {
+		test.AssertEquals(t, needsWritableDB(tc.command, tc.dryRun, tc.fix), tc.expected)
 	}
+	for command := range readOnlyCommands {
+		_, ok := findCommand(command)
+		test.Assert(t, ok, fmt.Sprintf("read-only command %q has no usage", command))
+	}
+}
+
+func TestSkipExpiredFor(t *testing.T) {
+	testCases := []struct {
+		args     []string
+		command  string
+		expected bool
+	}{
+		{nil, "reg-revoke", true},
+		{nil, "serial-revoke", false},
+		{[]string{"--skip-expired=false"}, "reg-revoke", false},
+		{[]string{"--skip-expired"}, "serial-revoke", true},
+	}
+	for _, tc := range testCases {
+		flagSet := flag.NewFlagSet(tc.command, flag.ContinueOnError)
+		skipExpired := flagSet.Bool("skip-expired", true, "")
+		err := flagSet.Parse(tc.args)
+		test.AssertNotError(t, err, "parsing flags failed")
+		test.AssertEquals(t, skipExpiredFor(flagSet, tc.command, *skipExpired), tc.expected)
+	}
+}
+
+func TestParseCertificatePEM(t *testing.T) {
+	_, cert := test.ThrowAwayCert(t, 1)
+	contents := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+
+	parsed, err := parseCertificatePEM(contents)
+	test.AssertNotError(t, err, "parseCertificatePEM failed")
+	test.AssertDeepEquals(t, parsed.Raw, cert.Raw)
+
+	_, err = parseCertificatePEM(cert.Raw)
+	test.AssertError(t, err, "parseCertificatePEM accepted DER")
+	_, err = parseCertificatePEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: cert.RawSubjectPublicKeyInfo}))
+	test.AssertError(t, err, "parseCertificatePEM accepted a public key")
+}
+
+func TestParseCertificateFile(t *testing.T) {
+	_, cert := test.ThrowAwayCert(t, 1)
+
+	parsed, err := parseCertificateFile(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	test.AssertNotError(t, err, "parseCertificateFile failed on PEM")
+	test.AssertDeepEquals(t, parsed.Raw, cert.Raw)
+
+	parsed, err = parseCertificateFile(cert.Raw)
+	test.AssertNotError(t, err, "parseCertificateFile failed on DER")
+	test.AssertDeepEquals(t, parsed.Raw, cert.Raw)
+
+	_, err = parseCertificateFile([]byte("not a certificate"))
+	test.AssertError(t, err, "parseCertificateFile accepted garbage")
+	test.Assert(t, berrors.Is(err, berrors.Malformed), "garbage wasn't a malformed error")
+}
 
-	err = revokeBatch(ra, log, dbMap, serialFile.Name(), 0, 2)
-	test.AssertNotError(t, err, "revokeBatch failed")
+func TestExitCodeRuntimeExhausted(t *testing.T) {
+	defer func() { stopper = nil }()
+	stopper = revoker.NewStopper()
+	test.AssertEquals(t, exitCode(revoker.ErrInterrupted), exitInterrupted)
 
-	for _, serial := range serials {
-		status, err := ssa.GetCertificateStatus(context.Background(), core.SerialToString(serial))
-		test.AssertNotError(t, err, "failed to retrieve certificate status")
-		test.AssertEquals(t, status.Status, core.OCSPStatusRevoked)
+	timer := stopper.WatchRuntime(blog.NewMock(), time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-stopper.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop wasn't closed after --max-runtime passed")
 	}
+	test.AssertEquals(t, exitCode(revoker.ErrInterrupted), exitRuntime)
+	test.AssertEquals(t, exitCode(status.Error(codes.Canceled, "context canceled")), exitRuntime)
+}
+
+func TestCheckOperator(t *testing.T) {
+	for _, tc := range []struct {
+		args  []string
+		valid bool
+	}{
+		{nil, true},
+		{[]string{"--operator", "alice"}, true},
+		{[]string{"--operator", ""}, false},
+		{[]string{"--operator", "  "}, false},
+	} {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.String("operator", "", "")
+		test.AssertNotError(t, flagSet.Parse(tc.args), "failed to parse flags")
+		err := checkOperator(flagSet)
+		test.AssertEquals(t, err == nil, tc.valid)
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	for command := range bulkCommands {
+		_, ok := findCommand(command)
+		test.Assert(t, ok, fmt.Sprintf("bulk command %q has no usage", command))
+	}
+	for command := range rollbackCommands {
+		_, ok := findCommand(command)
+		test.Assert(t, ok, fmt.Sprintf("rollback command %q has no usage", command))
+	}
+	for command := range commitEveryCommands {
+		cu, ok := findCommand(command)
+		test.Assert(t, ok, fmt.Sprintf("commit-every command %q has no usage", command))
+		test.AssertContains(t, cu.String(), "[--commit-every N]")
+	}
+	_, ok := findCommand("auth-revoke")
+	test.Assert(t, !ok, "found usage for an unknown command")
+
+	cu, ok := findCommand("serial-revoke")
+	test.Assert(t, ok, "no usage for serial-revoke")
+	test.AssertEquals(t, cu.String(), "admin-revoker serial-revoke --config <path> [--interactive-reason] [--print-cert [--yes]] <serial> <reason-code>")
+	test.AssertContains(t, usage(), cu.String()+"\n")
+	test.AssertNotError(t, cu.checkArgs(2), "checkArgs rejected the right number of arguments")
+	test.AssertEquals(t, cu.checkArgs(1).Error(), "expected 2 arguments, got 1")
+
+	cu, _ = findCommand("serial-info")
+	test.AssertEquals(t, cu.checkArgs(0).Error(), "expected 1 argument, got 0")
+
+	cu, _ = findCommand("reg-count")
+	test.AssertEquals(t, cu.checkArgs(3).Error(), "expected 1 argument, got 3")
+
+	cu, _ = findCommand("reg-revoke")
+	test.AssertNotError(t, cu.checkArgs(5), "checkArgs rejected several registrations")
+	test.AssertEquals(t, cu.checkArgs(0).Error(), "expected at least 1 argument, got 0")
+	test.AssertContains(t, cu.String(), "[--max N] [--force-large]")
+
+	cu, ok = findCommand("domain-revoke")
+	test.Assert(t, ok, "no usage for domain-revoke")
+	test.AssertContains(t, cu.String(), "--match exact|registered-domain")
+	test.AssertNotError(t, cu.checkArgs(2), "checkArgs rejected a domain and reason")
+}
+
+func TestLockTarget(t *testing.T) {
+	test.AssertDeepEquals(t, lockTarget("reg-revoke", []string{"1", "2", "keyCompromise"}), []string{"1", "2"})
+	test.AssertDeepEquals(t, lockTarget("batched-serial-revoke", []string{"serials.txt", "1", "10"}), []string{"serials.txt"})
+	test.AssertDeepEquals(t, lockTarget("issuer-revoke", []string{"1"}), []string{})
+	test.AssertDeepEquals(t, lockTarget("ocsp-refresh", []string{"serials.txt"}), []string{"serials.txt"})
 }
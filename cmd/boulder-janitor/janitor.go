@@ -52,7 +52,11 @@ func New(clk clock.Clock, config Config) (*janitor, error) {
 	if err != nil {
 		return nil, err
 	}
-	dbMap, err := sa.NewDbMap(dbURL, config.Janitor.DBConfig.MaxDBConns)
+	dbMap, err := sa.NewDbMapWithSettings(dbURL, sa.DbSettings{
+		MaxOpenConns:    config.Janitor.DBConfig.MaxDBConns,
+		MaxIdleConns:    config.Janitor.DBConfig.MaxIdleConns,
+		ConnMaxLifetime: config.Janitor.DBConfig.ConnMaxLifetime.Duration,
+	})
 	if err != nil {
 		return nil, err
 	}
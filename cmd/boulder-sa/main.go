@@ -58,7 +58,11 @@ func main() {
 	dbURL, err := saConf.DBConfig.URL()
 	cmd.FailOnError(err, "Couldn't load DB URL")
 
-	dbMap, err := sa.NewDbMap(dbURL, saConf.DBConfig.MaxDBConns)
+	dbMap, err := sa.NewDbMapWithSettings(dbURL, sa.DbSettings{
+		MaxOpenConns:    saConf.DBConfig.MaxDBConns,
+		MaxIdleConns:    saConf.DBConfig.MaxIdleConns,
+		ConnMaxLifetime: saConf.DBConfig.ConnMaxLifetime.Duration,
+	})
 	cmd.FailOnError(err, "Couldn't connect to SA database")
 
 	// Collect and periodically report DB metrics using the DBMap and prometheus scope.
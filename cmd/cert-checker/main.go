@@ -377,7 +377,11 @@ func main() {
 
 	saDbURL, err := config.CertChecker.DBConfig.URL()
 	cmd.FailOnError(err, "Couldn't load DB URL")
-	saDbMap, err := sa.NewDbMap(saDbURL, config.CertChecker.DBConfig.MaxDBConns)
+	saDbMap, err := sa.NewDbMapWithSettings(saDbURL, sa.DbSettings{
+		MaxOpenConns:    config.CertChecker.DBConfig.MaxDBConns,
+		MaxIdleConns:    config.CertChecker.DBConfig.MaxIdleConns,
+		ConnMaxLifetime: config.CertChecker.DBConfig.ConnMaxLifetime.Duration,
+	})
 	cmd.FailOnError(err, "Could not connect to database")
 
 	sa.InitDBMetrics(saDbMap, prometheus.DefaultRegisterer)
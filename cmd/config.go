@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -50,6 +52,14 @@ type DBConfig struct {
 	// A file containing a connect URL for the DB.
 	DBConnectFile string
 	MaxDBConns    int
+	// MaxIdleConns is the maximum number of idle connections kept open for
+	// reuse. If zero, database/sql's default of 2 is used, and if negative no
+	// idle connections are kept.
+	MaxIdleConns int
+	// ConnMaxLifetime is the maximum time a connection may be reused for
+	// before it is closed, so that long running commands don't hold on to
+	// stale connections. If zero, connections are reused indefinitely.
+	ConnMaxLifetime ConfigDuration
 }
 
 // URL returns the DBConnect URL represented by this DBConfig object, either
@@ -103,18 +113,42 @@ type TLSConfig struct {
 	CertFile   *string
 	KeyFile    *string
 	CACertFile *string
+	// PKCS11, if present, identifies the token holding the private key for
+	// the certificate in CertFile, which is then used in place of KeyFile. The
+	// key is found by the certificate's public key. Only components which load
+	// their TLSConfig with LoadWithPKCS11 support it.
+	PKCS11 *PKCS11KeyConfig
 }
 
+// PKCS11KeyConfig describes the PKCS#11 token holding a private key: the
+// module to load, the label of the token and the PIN to log in to it with.
+type PKCS11KeyConfig struct {
+	Module     string
+	TokenLabel string
+	PIN        string
+}
+
+// PKCS11KeyLoader returns a crypto.Signer for the private key matching pub, held
+// in the PKCS#11 token described by c.
+type PKCS11KeyLoader func(c PKCS11KeyConfig, pub crypto.PublicKey) (crypto.Signer, error)
+
 // Load reads and parses the certificates and key listed in the TLSConfig, and
 // returns a *tls.Config suitable for either client or server use.
 func (t *TLSConfig) Load() (*tls.Config, error) {
+	return t.LoadWithPKCS11(nil)
+}
+
+// LoadWithPKCS11 is like Load, but if the TLSConfig's PKCS11 section is present
+// the private key is loaded from the token with loadKey rather than read from
+// KeyFile. It is an error for the section to be present if loadKey is nil.
+func (t *TLSConfig) LoadWithPKCS11(loadKey PKCS11KeyLoader) (*tls.Config, error) {
 	if t == nil {
 		return nil, fmt.Errorf("nil TLS section in config")
 	}
 	if t.CertFile == nil {
 		return nil, fmt.Errorf("nil CertFile in TLSConfig")
 	}
-	if t.KeyFile == nil {
+	if t.KeyFile == nil && t.PKCS11 == nil {
 		return nil, fmt.Errorf("nil KeyFile in TLSConfig")
 	}
 	if t.CACertFile == nil {
@@ -128,10 +162,22 @@ func (t *TLSConfig) Load() (*tls.Config, error) {
 	if ok := rootCAs.AppendCertsFromPEM(caCertBytes); !ok {
 		return nil, fmt.Errorf("parsing CA certs from %s failed", *t.CACertFile)
 	}
-	cert, err := tls.LoadX509KeyPair(*t.CertFile, *t.KeyFile)
-	if err != nil {
-		return nil, fmt.Errorf("loading key pair from %q and %q: %s",
-			*t.CertFile, *t.KeyFile, err)
+	var cert tls.Certificate
+	if t.PKCS11 != nil {
+		if loadKey == nil {
+			return nil, errors.New("PKCS11 in TLSConfig is not supported by this component")
+		}
+		cert, err = loadPKCS11KeyPair(*t.CertFile, *t.PKCS11, loadKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading key pair from %q and PKCS#11 token %q: %s",
+				*t.CertFile, t.PKCS11.TokenLabel, err)
+		}
+	} else {
+		cert, err = tls.LoadX509KeyPair(*t.CertFile, *t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading key pair from %q and %q: %s",
+				*t.CertFile, *t.KeyFile, err)
+		}
 	}
 	return &tls.Config{
 		RootCAs:      rootCAs,
@@ -141,6 +187,43 @@ func (t *TLSConfig) Load() (*tls.Config, error) {
 	}, nil
 }
 
+// loadPKCS11KeyPair reads the PEM encoded certificate chain in certFile and
+// returns it along with a crypto.Signer for the leaf's private key, held in the
+// PKCS#11 token described by c and loaded with loadKey.
+func loadPKCS11KeyPair(certFile string, c PKCS11KeyConfig, loadKey PKCS11KeyLoader) (tls.Certificate, error) {
+	if c.Module == "" || c.TokenLabel == "" || c.PIN == "" {
+		return tls.Certificate{}, errors.New("PKCS11 config must set Module, TokenLabel and PIN")
+	}
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	var cert tls.Certificate
+	for {
+		var block *pem.Block
+		block, certPEM = pem.Decode(certPEM)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return tls.Certificate{}, errors.New("failed to find any PEM certificates")
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	key, err := loadKey(c, cert.Leaf.PublicKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert.PrivateKey = key
+	return cert, nil
+}
+
 // RPCServerConfig contains configuration particular to a specific RPC server
 // type (e.g. RA, SA, etc)
 type RPCServerConfig struct {
@@ -206,6 +289,25 @@ func (d *ConfigDuration) UnmarshalYAML(unmarshal func(interface{}) error) error
 type GRPCClientConfig struct {
 	ServerAddress string
 	Timeout       ConfigDuration
+	// HealthCheckTimeout, if non-zero, causes the client to perform a gRPC
+	// health check when first connecting, failing if the server doesn't
+	// report that it is serving within this duration.
+	HealthCheckTimeout ConfigDuration
+	// KeepaliveTime, if non-zero, causes the client to ping the server after
+	// this long without activity while RPCs are in flight, so that a connection
+	// silently dropped by an intermediary is detected. gRPC servers reject
+	// pings more frequent than every 5 minutes by default, and pings aren't
+	// sent on idle connections for the same reason.
+	KeepaliveTime ConfigDuration
+	// KeepaliveTimeout is how long the client waits for a response to a
+	// keepalive ping before closing the connection. If zero, gRPC's default of
+	// 20 seconds is used.
+	KeepaliveTimeout ConfigDuration
+	// ServerNameOverride, if set, is the name the server's certificate is
+	// verified against, in place of the host in ServerAddress, e.g. when the
+	// server is reached through a load balancer whose address its
+	// certificate doesn't include.
+	ServerNameOverride string
 }
 
 // GRPCServerConfig contains the information needed to run a gRPC service
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"crypto"
+	"errors"
 	"regexp"
 	"strings"
 	"testing"
@@ -56,19 +58,27 @@ func TestTLSConfigLoad(t *testing.T) {
 	cert := "testdata/cert.pem"
 	key := "testdata/key.pem"
 	caCert := "testdata/minica.pem"
+	hsm := &PKCS11KeyConfig{Module: "module.so", TokenLabel: "admin-revoker", PIN: "1234"}
+	noToken := func(PKCS11KeyConfig, crypto.PublicKey) (crypto.Signer, error) {
+		return nil, errors.New("no such token")
+	}
 	testCases := []struct {
 		TLSConfig
 		want string
 	}{
-		{TLSConfig{nil, &null, &null}, "nil CertFile in TLSConfig"},
-		{TLSConfig{&null, nil, &null}, "nil KeyFile in TLSConfig"},
-		{TLSConfig{&null, &null, nil}, "nil CACertFile in TLSConfig"},
-		{TLSConfig{&nonExistent, &key, &caCert}, "loading key pair.*no such file or directory"},
-		{TLSConfig{&cert, &nonExistent, &caCert}, "loading key pair.*no such file or directory"},
-		{TLSConfig{&cert, &key, &nonExistent}, "reading CA cert from.*no such file or directory"},
-		{TLSConfig{&null, &key, &caCert}, "loading key pair.*failed to find any PEM data"},
-		{TLSConfig{&cert, &null, &caCert}, "loading key pair.*failed to find any PEM data"},
-		{TLSConfig{&cert, &key, &null}, "parsing CA certs"},
+		{TLSConfig{nil, &null, &null, nil}, "nil CertFile in TLSConfig"},
+		{TLSConfig{&null, nil, &null, nil}, "nil KeyFile in TLSConfig"},
+		{TLSConfig{&null, &null, nil, nil}, "nil CACertFile in TLSConfig"},
+		{TLSConfig{&nonExistent, &key, &caCert, nil}, "loading key pair.*no such file or directory"},
+		{TLSConfig{&cert, &nonExistent, &caCert, nil}, "loading key pair.*no such file or directory"},
+		{TLSConfig{&cert, &key, &nonExistent, nil}, "reading CA cert from.*no such file or directory"},
+		{TLSConfig{&null, &key, &caCert, nil}, "loading key pair.*failed to find any PEM data"},
+		{TLSConfig{&cert, &null, &caCert, nil}, "loading key pair.*failed to find any PEM data"},
+		{TLSConfig{&cert, &key, &null, nil}, "parsing CA certs"},
+		{TLSConfig{&cert, nil, &caCert, &PKCS11KeyConfig{Module: "module.so"}}, "PKCS#11 token.*must set Module, TokenLabel and PIN"},
+		{TLSConfig{&nonExistent, nil, &caCert, hsm}, "loading key pair.*PKCS#11 token.*no such file or directory"},
+		{TLSConfig{&null, nil, &caCert, hsm}, "loading key pair.*PKCS#11 token.*failed to find any PEM certificates"},
+		{TLSConfig{&cert, nil, &caCert, hsm}, "loading key pair.*PKCS#11 token.*no such token"},
 	}
 	for _, tc := range testCases {
 		var title [3]string
@@ -88,7 +98,7 @@ func TestTLSConfigLoad(t *testing.T) {
 			title[2] = *tc.CACertFile
 		}
 		t.Run(strings.Join(title[:], "_"), func(t *testing.T) {
-			_, err := tc.TLSConfig.Load()
+			_, err := tc.TLSConfig.LoadWithPKCS11(noToken)
 			if err == nil {
 				t.Errorf("got no error")
 			}
@@ -97,4 +107,9 @@ func TestTLSConfigLoad(t *testing.T) {
 			}
 		})
 	}
+
+	// Components which can't load keys from a PKCS#11 token reject it.
+	_, err := (&TLSConfig{&cert, nil, &caCert, hsm}).Load()
+	test.AssertError(t, err, "Load succeeded with a PKCS11 section")
+	test.AssertContains(t, err.Error(), "not supported")
 }
@@ -477,7 +477,11 @@ func main() {
 	// Configure DB
 	dbURL, err := c.Mailer.DBConfig.URL()
 	cmd.FailOnError(err, "Couldn't load DB URL")
-	dbMap, err := sa.NewDbMap(dbURL, c.Mailer.DBConfig.MaxDBConns)
+	dbMap, err := sa.NewDbMapWithSettings(dbURL, sa.DbSettings{
+		MaxOpenConns:    c.Mailer.DBConfig.MaxDBConns,
+		MaxIdleConns:    c.Mailer.DBConfig.MaxIdleConns,
+		ConnMaxLifetime: c.Mailer.DBConfig.ConnMaxLifetime.Duration,
+	})
 	cmd.FailOnError(err, "Could not connect to database")
 	sa.SetSQLDebug(dbMap, logger)
 
@@ -78,7 +78,11 @@ func main() {
 
 	dbURL, err := c.ExpiredAuthzPurger2.DBConfig.URL()
 	cmd.FailOnError(err, "Couldn't load DB URL")
-	dbMap, err := sa.NewDbMap(dbURL, c.ExpiredAuthzPurger2.DBConfig.MaxDBConns)
+	dbMap, err := sa.NewDbMapWithSettings(dbURL, sa.DbSettings{
+		MaxOpenConns:    c.ExpiredAuthzPurger2.DBConfig.MaxDBConns,
+		MaxIdleConns:    c.ExpiredAuthzPurger2.DBConfig.MaxIdleConns,
+		ConnMaxLifetime: c.ExpiredAuthzPurger2.DBConfig.ConnMaxLifetime.Duration,
+	})
 	cmd.FailOnError(err, "Could not connect to database")
 
 	for {
@@ -120,7 +120,11 @@ func main() {
 
 	dbURL, err := config.KeyHashBackfiller.DBConfig.URL()
 	cmd.FailOnError(err, "Couldn't load DB URL")
-	dbMap, err := sa.NewDbMap(dbURL, config.KeyHashBackfiller.MaxDBConns)
+	dbMap, err := sa.NewDbMapWithSettings(dbURL, sa.DbSettings{
+		MaxOpenConns:    config.KeyHashBackfiller.MaxDBConns,
+		MaxIdleConns:    config.KeyHashBackfiller.MaxIdleConns,
+		ConnMaxLifetime: config.KeyHashBackfiller.ConnMaxLifetime.Duration,
+	})
 	cmd.FailOnError(err, "Could not connect to database")
 
 	backfill(logger, dbMap, *batchSize, *initialID)
@@ -237,7 +237,11 @@ as generated by Boulder's single-ocsp command.
 			dbConnect = config.Source
 		}
 		logger.Infof("Loading OCSP Database for CA Cert: %s", c.Common.IssuerCert)
-		dbMap, err := sa.NewDbMap(dbConnect, config.DBConfig.MaxDBConns)
+		dbMap, err := sa.NewDbMapWithSettings(dbConnect, sa.DbSettings{
+			MaxOpenConns:    config.DBConfig.MaxDBConns,
+			MaxIdleConns:    config.DBConfig.MaxIdleConns,
+			ConnMaxLifetime: config.DBConfig.ConnMaxLifetime.Duration,
+		})
 		cmd.FailOnError(err, "Could not connect to database")
 		sa.SetSQLDebug(dbMap, logger)
 		sa.InitDBMetrics(dbMap, stats)
@@ -88,7 +88,7 @@ type RegistrationAuthority interface {
 	FinalizeOrder(ctx context.Context, req *rapb.FinalizeOrderRequest) (*corepb.Order, error)
 
 	// [AdminRevoker]
-	AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, code revocation.Reason, adminName string) error
+	AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, code revocation.Reason, adminName string, comment string, revokedAt time.Time, force bool) error
 }
 
 // CertificateAuthority defines the public interface for the Boulder CA
@@ -152,6 +152,7 @@ type StorageAdder interface {
 	FinalizeOrder(ctx context.Context, order *corepb.Order) error
 	SetOrderError(ctx context.Context, order *corepb.Order) error
 	RevokeCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) error
+	UpdateRevokedCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) error
 	// New authz2 methods
 	NewAuthorizations2(ctx context.Context, req *sapb.AddPendingAuthorizationsRequest) (*sapb.Authorization2IDs, error)
 	FinalizeAuthorization2(ctx context.Context, req *sapb.FinalizeAuthorizationRequest) error
@@ -19,6 +19,12 @@ func (re *RollbackError) Error() string {
 	return fmt.Sprintf("%s (also, while rolling back: %s)", re.Err, re.RollbackErr)
 }
 
+// Unwrap returns the original database error, rather than the rollback error,
+// since it's the cause of the failure.
+func (re *RollbackError) Unwrap() error {
+	return re.Err
+}
+
 // rollback rolls back the provided transaction. If the rollback fails for any
 // reason a `RollbackError` error is returned wrapping the original error. If no
 // rollback error occurs then the original error is returned.
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"errors"
 	"testing"
 
 	berrors "github.com/letsencrypt/boulder/errors"
@@ -34,3 +35,13 @@ func TestRollback(t *testing.T) {
 	// We expect that the err is returned unwrapped.
 	test.AssertEquals(t, result, innerErr)
 }
+
+func TestRollbackErrorError(t *testing.T) {
+	innerErr := berrors.InternalServerError("couldn't update certificateStatus")
+	rbErr := &RollbackError{Err: innerErr}
+	test.AssertEquals(t, rbErr.Error(), "couldn't update certificateStatus")
+
+	rbErr.RollbackErr = errors.New("bad connection")
+	test.AssertEquals(t, rbErr.Error(), "couldn't update certificateStatus (also, while rolling back: bad connection)")
+	test.AssertEquals(t, rbErr.Unwrap(), innerErr)
+}
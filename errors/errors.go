@@ -27,6 +27,7 @@ const (
 	DNS
 	BadPublicKey
 	BadCSR
+	AlreadyRevoked
 )
 
 // BoulderError represents internal Boulder errors
@@ -140,3 +141,7 @@ func BadPublicKeyError(msg string, args ...interface{}) error {
 func BadCSRError(msg string, args ...interface{}) error {
 	return New(BadCSR, msg, args...)
 }
+
+func AlreadyRevokedError(msg string, args ...interface{}) error {
+	return New(AlreadyRevoked, msg, args...)
+}
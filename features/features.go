@@ -56,6 +56,9 @@ const (
 	// was checked for extant unrevoked certificates in the blockedKeys table. It should
 	// only be enabled if BlockedKeyTable is also enabled.
 	StoreRevokerInfo
+	// StoreRevocationComment enables storage of the free-text comment provided
+	// with administrative revocations in the certificateStatus table.
+	StoreRevocationComment
 )
 
 // List of features and their default value, protected by fMu
@@ -80,6 +83,7 @@ var features = map[FeatureFlag]bool{
 	StoreKeyHashes:                false,
 	BlockedKeyTable:               false,
 	StoreRevokerInfo:              false,
+	StoreRevocationComment:        false,
 }
 
 var fMu = new(sync.RWMutex)
@@ -1,9 +1,12 @@
 package grpc
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
+	"time"
 
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/jmhodges/clock"
@@ -11,12 +14,16 @@ import (
 	bcreds "github.com/letsencrypt/boulder/grpc/creds"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 )
 
 // ClientSetup creates a gRPC TransportCredentials that presents
 // a client certificate and validates the the server certificate based
 // on the provided *tls.Config.
-// It dials the remote service and returns a grpc.ClientConn if successful.
+// It dials the remote service and returns a grpc.ClientConn if successful. If
+// the config has a HealthCheckTimeout the remote service must also report
+// itself as SERVING to a gRPC health check within that timeout.
 func ClientSetup(c *cmd.GRPCClientConfig, tlsConfig *tls.Config, metrics clientMetrics, clk clock.Clock) (*grpc.ClientConn, error) {
 	if c == nil {
 		return nil, errors.New("nil gRPC client config provided. JSON config is probably missing a fooService section.")
@@ -34,17 +41,72 @@ func ClientSetup(c *cmd.GRPCClientConfig, tlsConfig *tls.Config, metrics clientM
 	tlsConfig.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305}
 
 	ci := clientInterceptor{c.Timeout.Duration, metrics, clk}
-	host, _, err := net.SplitHostPort(c.ServerAddress)
+	host, err := serverName(c)
 	if err != nil {
 		return nil, err
 	}
 	creds := bcreds.NewClientCredentials(tlsConfig.RootCAs, tlsConfig.Certificates, host)
-	return grpc.Dial(
-		"dns:///"+c.ServerAddress,
+	opts := []grpc.DialOption{
 		grpc.WithBalancerName("round_robin"),
 		grpc.WithTransportCredentials(creds),
 		grpc.WithUnaryInterceptor(ci.intercept),
-	)
+	}
+	if kp, ok := keepaliveParams(c); ok {
+		opts = append(opts, grpc.WithKeepaliveParams(kp))
+	}
+	conn, err := grpc.Dial("dns:///"+c.ServerAddress, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if c.HealthCheckTimeout.Duration > 0 {
+		err = checkHealth(conn, c.HealthCheckTimeout.Duration)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("health check of %s failed: %s", c.ServerAddress, err)
+		}
+	}
+	return conn, nil
+}
+
+// serverName returns the name the server's certificate must be valid for: the
+// config's ServerNameOverride if set, and otherwise the host of its
+// ServerAddress.
+func serverName(c *cmd.GRPCClientConfig) (string, error) {
+	host, _, err := net.SplitHostPort(c.ServerAddress)
+	if err != nil {
+		return "", err
+	}
+	if c.ServerNameOverride != "" {
+		return c.ServerNameOverride, nil
+	}
+	return host, nil
+}
+
+// keepaliveParams returns the client keepalive parameters for the config, and
+// false if keepalives are disabled because no KeepaliveTime is configured.
+func keepaliveParams(c *cmd.GRPCClientConfig) (keepalive.ClientParameters, bool) {
+	if c.KeepaliveTime.Duration <= 0 {
+		return keepalive.ClientParameters{}, false
+	}
+	return keepalive.ClientParameters{
+		Time:    c.KeepaliveTime.Duration,
+		Timeout: c.KeepaliveTimeout.Duration,
+	}, true
+}
+
+// checkHealth performs a gRPC health check against the server on the other end
+// of conn, returning an error unless it reports SERVING before the timeout.
+func checkHealth(conn *grpc.ClientConn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("service reported status %s", resp.Status)
+	}
+	return nil
 }
 
 type registry interface {
@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestCheckHealth(t *testing.T) {
+	serverMetrics := NewServerMetrics(metrics.NoopRegisterer)
+	si := newServerInterceptor(serverMetrics, clock.NewFake())
+	ci := clientInterceptor{time.Second, NewClientMetrics(metrics.NoopRegisterer), clock.NewFake()}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(si.intercept))
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	lis, err := net.Listen("tcp", "127.0.0.1:")
+	test.AssertNotError(t, err, "Failed to create listener")
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(
+		lis.Addr().String(),
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(ci.intercept),
+	)
+	test.AssertNotError(t, err, "Failed to dial grpc test server")
+	defer conn.Close()
+
+	err = checkHealth(conn, time.Second)
+	test.AssertNotError(t, err, "checkHealth failed for serving server")
+
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	err = checkHealth(conn, time.Second)
+	test.AssertError(t, err, "checkHealth didn't fail for server that isn't serving")
+	test.AssertEquals(t, err.Error(), "service reported status NOT_SERVING")
+}
+
+func TestKeepaliveParams(t *testing.T) {
+	_, ok := keepaliveParams(&cmd.GRPCClientConfig{})
+	test.Assert(t, !ok, "Keepalives should be disabled by default")
+
+	kp, ok := keepaliveParams(&cmd.GRPCClientConfig{
+		KeepaliveTime:    cmd.ConfigDuration{Duration: 5 * time.Minute},
+		KeepaliveTimeout: cmd.ConfigDuration{Duration: 10 * time.Second},
+	})
+	test.Assert(t, ok, "Keepalives should be enabled when KeepaliveTime is set")
+	test.AssertEquals(t, kp.Time, 5*time.Minute)
+	test.AssertEquals(t, kp.Timeout, 10*time.Second)
+	test.Assert(t, !kp.PermitWithoutStream, "Pings shouldn't be sent on idle connections")
+}
+
+func TestServerName(t *testing.T) {
+	name, err := serverName(&cmd.GRPCClientConfig{ServerAddress: "ra.boulder:9094"})
+	test.AssertNotError(t, err, "serverName failed")
+	test.AssertEquals(t, name, "ra.boulder")
+
+	name, err = serverName(&cmd.GRPCClientConfig{ServerAddress: "lb.example.net:9094", ServerNameOverride: "ra.boulder"})
+	test.AssertNotError(t, err, "serverName failed")
+	test.AssertEquals(t, name, "ra.boulder")
+
+	_, err = serverName(&cmd.GRPCClientConfig{ServerAddress: "ra.boulder", ServerNameOverride: "ra.boulder"})
+	test.AssertError(t, err, "serverName accepted an address without a port")
+}
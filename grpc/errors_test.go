@@ -50,6 +50,11 @@ func TestErrorWrapping(t *testing.T) {
 	test.Assert(t, err != nil, fmt.Sprintf("nil error returned, expected: %s", err))
 	test.AssertDeepEquals(t, err, es.err)
 
+	es.err = berrors.AlreadyRevokedError("certificate already revoked")
+	_, err = client.Chill(context.Background(), &testproto.Time{})
+	test.Assert(t, berrors.Is(err, berrors.AlreadyRevoked), fmt.Sprintf("expected an AlreadyRevoked error, got: %s", err))
+	test.AssertDeepEquals(t, err, es.err)
+
 	test.AssertEquals(t, wrapError(context.Background(), nil), nil)
 	test.AssertEquals(t, unwrapError(nil, nil), nil)
 }
@@ -22,8 +22,26 @@ const (
 	meaningfulWorkOverhead = 100 * time.Millisecond
 	clientRequestTimeKey   = "client-request-time"
 	serverLatencyKey       = "server-latency"
+	clientTagKey           = "client-tag"
 )
 
+// WithClientTag returns a context whose outgoing gRPC requests identify the
+// tool, ticket or person they were made for with the tag, so that servers can
+// record it, e.g. in audit logs.
+func WithClientTag(ctx context.Context, tag string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, clientTagKey, tag)
+}
+
+// ClientTag returns the tag the client attached to an incoming gRPC request
+// with WithClientTag, or "" if there is none.
+func ClientTag(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[clientTagKey]) == 0 {
+		return ""
+	}
+	return md[clientTagKey][0]
+}
+
 // serverInterceptor is a gRPC interceptor that adds Prometheus
 // metrics to requests handled by a gRPC server, and wraps Boulder-specific
 // errors for transmission in a grpc/metadata trailer (see bcodes.go).
@@ -152,11 +170,9 @@ func (ci *clientInterceptor) intercept(
 	// Convert the current unix nano timestamp to a string for embedding in the grpc metadata
 	nowTS := strconv.FormatInt(ci.clk.Now().UnixNano(), 10)
 
-	// Create a grpc/metadata.Metadata instance for the request metadata.
-	// Initialize it with the request time.
-	reqMD := metadata.New(map[string]string{clientRequestTimeKey: nowTS})
-	// Configure the localCtx with the metadata so it gets sent along in the request
-	localCtx = metadata.NewOutgoingContext(localCtx, reqMD)
+	// Add the request time to the request metadata, keeping any metadata, such
+	// as a client tag, already attached to the context.
+	localCtx = metadata.AppendToOutgoingContext(localCtx, clientRequestTimeKey, nowTS)
 
 	// Create a grpc/metadata.Metadata instance for a grpc.Trailer.
 	respMD := metadata.New(nil)
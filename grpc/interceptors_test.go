@@ -73,6 +73,25 @@ func TestClientInterceptor(t *testing.T) {
 	test.AssertError(t, err, "ci.intercept didn't fail when handler returned a error")
 }
 
+func TestClientTag(t *testing.T) {
+	ci := clientInterceptor{
+		timeout: time.Second,
+		metrics: NewClientMetrics(metrics.NoopRegisterer),
+		clk:     clock.NewFake(),
+	}
+	var sent metadata.MD
+	invoker := func(ctx context.Context, _ string, _, _ interface{}, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+		sent, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	err := ci.intercept(WithClientTag(context.Background(), "runbook-42"), "-service-test", nil, nil, nil, invoker)
+	test.AssertNotError(t, err, "ci.intercept failed")
+	test.AssertEquals(t, len(sent[clientRequestTimeKey]), 1)
+	test.AssertEquals(t, ClientTag(metadata.NewIncomingContext(context.Background(), sent)), "runbook-42")
+
+	test.AssertEquals(t, ClientTag(context.Background()), "")
+}
+
 // TestFailFastFalse sends a gRPC request to a backend that is
 // unavailable, and ensures that the request doesn't error out until the
 // timeout is reached, i.e. that FailFast is set to false.
@@ -9,6 +9,7 @@ package grpc
 import (
 	"context"
 	"crypto/x509"
+	"time"
 
 	"github.com/letsencrypt/boulder/core"
 	corepb "github.com/letsencrypt/boulder/core/proto"
@@ -150,13 +151,24 @@ func (rac RegistrationAuthorityClientWrapper) DeactivateAuthorization(ctx contex
 	return nil
 }
 
-func (rac RegistrationAuthorityClientWrapper) AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, code revocation.Reason, adminName string) error {
+func (rac RegistrationAuthorityClientWrapper) AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, code revocation.Reason, adminName string, comment string, revokedAt time.Time, force bool) error {
 	reason := int64(code)
-	_, err := rac.inner.AdministrativelyRevokeCertificate(ctx, &rapb.AdministrativelyRevokeCertificateRequest{
+	req := &rapb.AdministrativelyRevokeCertificateRequest{
 		Cert:      cert.Raw,
 		Code:      &reason,
 		AdminName: &adminName,
-	})
+	}
+	if comment != "" {
+		req.Comment = &comment
+	}
+	if !revokedAt.IsZero() {
+		revokedAtNS := revokedAt.UnixNano()
+		req.RevokedAt = &revokedAtNS
+	}
+	if force {
+		req.Force = &force
+	}
+	_, err := rac.inner.AdministrativelyRevokeCertificate(ctx, req)
 	if err != nil {
 		return err
 	}
@@ -321,7 +333,11 @@ func (ras *RegistrationAuthorityServerWrapper) AdministrativelyRevokeCertificate
 	if err != nil {
 		return nil, err
 	}
-	err = ras.inner.AdministrativelyRevokeCertificate(ctx, *cert, revocation.Reason(*request.Code), *request.AdminName)
+	var revokedAt time.Time
+	if request.RevokedAt != nil {
+		revokedAt = time.Unix(0, *request.RevokedAt)
+	}
+	err = ras.inner.AdministrativelyRevokeCertificate(ctx, *cert, revocation.Reason(*request.Code), *request.AdminName, request.GetComment(), revokedAt, request.GetForce())
 	if err != nil {
 		return nil, err
 	}
@@ -402,6 +402,11 @@ func (sas StorageAuthorityClientWrapper) RevokeCertificate(ctx context.Context,
 	return err
 }
 
+func (sas StorageAuthorityClientWrapper) UpdateRevokedCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) error {
+	_, err := sas.inner.UpdateRevokedCertificate(ctx, req)
+	return err
+}
+
 func (sas StorageAuthorityClientWrapper) NewAuthorizations2(ctx context.Context, req *sapb.AddPendingAuthorizationsRequest) (*sapb.Authorization2IDs, error) {
 	resp, err := sas.inner.NewAuthorizations2(ctx, req)
 	if err != nil {
@@ -848,6 +853,13 @@ func (sas StorageAuthorityServerWrapper) RevokeCertificate(ctx context.Context,
 	return &corepb.Empty{}, sas.inner.RevokeCertificate(ctx, req)
 }
 
+func (sas StorageAuthorityServerWrapper) UpdateRevokedCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) (*corepb.Empty, error) {
+	if req == nil || req.Serial == nil || req.Reason == nil || req.Date == nil || req.Response == nil {
+		return nil, errIncompleteRequest
+	}
+	return &corepb.Empty{}, sas.inner.UpdateRevokedCertificate(ctx, req)
+}
+
 func (sas StorageAuthorityServerWrapper) NewAuthorizations2(ctx context.Context, req *sapb.AddPendingAuthorizationsRequest) (*sapb.Authorization2IDs, error) {
 	if req == nil || req.Authz == nil {
 		return nil, errIncompleteRequest
@@ -11,6 +11,8 @@ import (
 	bcreds "github.com/letsencrypt/boulder/grpc/creds"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // CodedError is a alias required to appease go vet
@@ -22,6 +24,8 @@ var errNilTLS = errors.New("boulder/grpc: received nil tls.Config")
 // verifies that clients present a certificate that (a) is signed by one of
 // the configured ClientCAs, and (b) contains at least one
 // subjectAlternativeName matching the accepted list from GRPCServerConfig.
+// The server also implements the gRPC health checking service, reporting
+// itself as SERVING.
 func NewServer(c *cmd.GRPCServerConfig, tlsConfig *tls.Config, metrics serverMetrics, clk clock.Clock) (*grpc.Server, net.Listener, error) {
 	if tlsConfig == nil {
 		return nil, nil, errNilTLS
@@ -47,10 +51,12 @@ func NewServer(c *cmd.GRPCServerConfig, tlsConfig *tls.Config, metrics serverMet
 	}
 
 	si := newServerInterceptor(metrics, clk)
-	return grpc.NewServer(
+	server := grpc.NewServer(
 		grpc.Creds(creds),
 		grpc.UnaryInterceptor(si.intercept),
-	), l, nil
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	return server, l, nil
 }
 
 // serverMetrics is a struct type used to return a few registered metrics from
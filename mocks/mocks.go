@@ -700,6 +700,11 @@ func (sa *StorageAuthority) RevokeCertificate(ctx context.Context, req *sapb.Rev
 	return nil
 }
 
+// UpdateRevokedCertificate is a mock
+func (sa *StorageAuthority) UpdateRevokedCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) error {
+	return nil
+}
+
 // AddBlockedKey is a mock
 func (sa *StorageAuthority) AddBlockedKey(context.Context, *sapb.AddBlockedKeyRequest) (*corepb.Empty, error) {
 	return &corepb.Empty{}, nil
@@ -1687,30 +1687,56 @@ func revokeEvent(state, serial, cn string, names []string, revocationCode revoca
 }
 
 // revokeCertificate generates a revoked OCSP response for the given certificate, stores
-// the revocation information, and purges OCSP request URLs from Akamai.
-func (ra *RegistrationAuthorityImpl) revokeCertificate(ctx context.Context, cert x509.Certificate, code revocation.Reason, revokedBy int64, source string, comment string) error {
+// the revocation information, and purges OCSP request URLs from Akamai. If comment
+// is non-empty it is stored alongside the revocation and, for keyCompromise
+// revocations, the blocked key. The certificate is recorded as revoked at
+// revokedAt, or now if it is zero. With force, the certificate must already be
+// revoked, and its revocation is updated with code instead, keeping the date it
+// was revoked at unless revokedAt is set.
+func (ra *RegistrationAuthorityImpl) revokeCertificate(ctx context.Context, cert x509.Certificate, code revocation.Reason, revokedBy int64, source string, comment string, revokedAt time.Time, force bool) error {
 	status := string(core.OCSPStatusRevoked)
 	reason := int32(code)
-	revokedAt := ra.clk.Now().UnixNano()
+	serial := core.SerialToString(cert.SerialNumber)
+	now := ra.clk.Now().UnixNano()
+	revokedAtNS := now
+	if !revokedAt.IsZero() {
+		revokedAtNS = revokedAt.UnixNano()
+	} else if force {
+		certStatus, err := ra.SA.GetCertificateStatus(ctx, serial)
+		if err != nil {
+			return err
+		}
+		if certStatus.Status != core.OCSPStatusRevoked {
+			return berrors.NotFoundError("certificate with serial %s is not revoked", serial)
+		}
+		revokedAtNS = certStatus.RevokedDate.UnixNano()
+	}
 	ocspResponse, err := ra.CA.GenerateOCSP(ctx, &caPB.GenerateOCSPRequest{
 		CertDER:   cert.Raw,
 		Status:    &status,
 		Reason:    &reason,
-		RevokedAt: &revokedAt,
+		RevokedAt: &revokedAtNS,
 	})
 	if err != nil {
 		return err
 	}
-	serial := core.SerialToString(cert.SerialNumber)
 	// for some reason we use int32 and int64 for the reason in different
 	// protobuf messages, so we have to re-cast it here.
 	reason64 := int64(reason)
-	err = ra.SA.RevokeCertificate(ctx, &sapb.RevokeCertificateRequest{
+	revokeReq := &sapb.RevokeCertificateRequest{
 		Serial:   &serial,
 		Reason:   &reason64,
-		Date:     &revokedAt,
+		Date:     &revokedAtNS,
 		Response: ocspResponse.Response,
-	})
+	}
+	if comment != "" {
+		revokeReq.Comment = &comment
+	}
+	if force {
+		err = ra.SA.UpdateRevokedCertificate(ctx, revokeReq)
+	} else {
+		err = ra.SA.RevokeCertificate(ctx, revokeReq)
+	}
 	if err != nil {
 		return err
 	}
@@ -1721,7 +1747,7 @@ func (ra *RegistrationAuthorityImpl) revokeCertificate(ctx context.Context, cert
 		}
 		req := &sapb.AddBlockedKeyRequest{
 			KeyHash: digest[:],
-			Added:   &revokedAt,
+			Added:   &now,
 			Source:  &source,
 		}
 		if comment != "" {
@@ -1749,7 +1775,7 @@ func (ra *RegistrationAuthorityImpl) revokeCertificate(ctx context.Context, cert
 // RevokeCertificateWithReg terminates trust in the certificate provided.
 func (ra *RegistrationAuthorityImpl) RevokeCertificateWithReg(ctx context.Context, cert x509.Certificate, revocationCode revocation.Reason, regID int64) error {
 	serialString := core.SerialToString(cert.SerialNumber)
-	err := ra.revokeCertificate(ctx, cert, revocationCode, regID, "API", "")
+	err := ra.revokeCertificate(ctx, cert, revocationCode, regID, "API", "", time.Time{}, false)
 
 	state := "Failure"
 	defer func() {
@@ -1776,12 +1802,19 @@ func (ra *RegistrationAuthorityImpl) RevokeCertificateWithReg(ctx context.Contex
 
 // AdministrativelyRevokeCertificate terminates trust in the certificate provided and
 // does not require the registration ID of the requester since this method is only
-// called from the admin-revoker tool.
-func (ra *RegistrationAuthorityImpl) AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, revocationCode revocation.Reason, user string) error {
+// called from the admin-revoker tool. The optional comment is recorded with the
+// revocation to explain why it happened. The certificate is recorded as revoked
+// at revokedAt, or now if it is zero, e.g. to backdate the revocation to when a
+// compromise was discovered. With force, a certificate which is already revoked
+// has its revocation reason changed to revocationCode, keeping the date it was
+// revoked at unless revokedAt is set, and one which isn't fails as not found.
+func (ra *RegistrationAuthorityImpl) AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, revocationCode revocation.Reason, user string, comment string, revokedAt time.Time, force bool) error {
 	serialString := core.SerialToString(cert.SerialNumber)
-	// TODO(#4774): allow setting the comment via the RPC, format should be:
-	// "revoked by %s: %s", user, comment
-	err := ra.revokeCertificate(ctx, cert, revocationCode, 0, "admin-revoker", fmt.Sprintf("revoked by %s", user))
+	revokeComment := fmt.Sprintf("revoked by %s", user)
+	if comment != "" {
+		revokeComment = fmt.Sprintf("revoked by %s: %s", user, comment)
+	}
+	err := ra.revokeCertificate(ctx, cert, revocationCode, 0, "admin-revoker", revokeComment, revokedAt, force)
 
 	state := "Failure"
 	defer func() {
@@ -1791,10 +1824,12 @@ func (ra *RegistrationAuthorityImpl) AdministrativelyRevokeCertificate(ctx conte
 		//   DNS names
 		//   Revocation reason
 		//   Name of admin-revoker user
+		//   Comment (if there was one)
+		//   Client tag (if there was one)
 		//   Error (if there was one)
-		ra.log.AuditInfof("%s, admin-revoker user: %s",
+		ra.log.AuditInfof("%s, admin-revoker user: %s, comment: %q, client tag: %q",
 			revokeEvent(state, serialString, cert.Subject.CommonName, cert.DNSNames, revocationCode),
-			user)
+			user, comment, bgrpc.ClientTag(ctx))
 	}()
 
 	if err != nil {
@@ -3816,7 +3816,13 @@ rA==
 type mockSABlockedKey struct {
 	mocks.StorageAuthority
 
-	added *sapb.AddBlockedKeyRequest
+	added   *sapb.AddBlockedKeyRequest
+	revoked *sapb.RevokeCertificateRequest
+}
+
+func (msabk *mockSABlockedKey) RevokeCertificate(_ context.Context, req *sapb.RevokeCertificateRequest) error {
+	msabk.revoked = req
+	return nil
 }
 
 func (msabk *mockSABlockedKey) AddBlockedKey(_ context.Context, req *sapb.AddBlockedKeyRequest) (*corepb.Empty, error) {
@@ -3875,11 +3881,100 @@ func TestRevocationAddBlockedKey(t *testing.T) {
 	test.Assert(t, mockSA.added.Comment == nil, "Comment is not nil")
 
 	mockSA.added = nil
-	err = ra.AdministrativelyRevokeCertificate(context.Background(), *cert, ocsp.KeyCompromise, "root")
+	err = ra.AdministrativelyRevokeCertificate(context.Background(), *cert, ocsp.KeyCompromise, "root", "", time.Time{}, false)
 	test.AssertNotError(t, err, "AdministrativelyRevokeCertificate failed")
 	test.Assert(t, mockSA.added != nil, "blocked key was not added when reason was keyCompromise")
 	test.Assert(t, bytes.Equal(digest[:], mockSA.added.KeyHash), "key hash mismatch")
 	test.AssertEquals(t, *mockSA.added.Source, "admin-revoker")
 	test.Assert(t, mockSA.added.Comment != nil, "Comment is nil")
 	test.AssertEquals(t, *mockSA.added.Comment, "revoked by root")
+
+	mockSA.added = nil
+	err = ra.AdministrativelyRevokeCertificate(context.Background(), *cert, ocsp.KeyCompromise, "root", "key posted publicly", time.Time{}, false)
+	test.AssertNotError(t, err, "AdministrativelyRevokeCertificate failed")
+	test.Assert(t, mockSA.added != nil, "blocked key was not added when reason was keyCompromise")
+	test.Assert(t, mockSA.added.Comment != nil, "Comment is nil")
+	test.AssertEquals(t, *mockSA.added.Comment, "revoked by root: key posted publicly")
+	test.Assert(t, mockSA.revoked.Comment != nil, "RevokeCertificate comment is nil")
+	test.AssertEquals(t, *mockSA.revoked.Comment, "revoked by root: key posted publicly")
+	test.AssertEquals(t, *mockSA.revoked.Date, ra.clk.Now().UnixNano())
+
+	// A backdated revocation is stored with the date given, but the key is
+	// blocked as of now.
+	mockSA.added = nil
+	revokedAt := ra.clk.Now().Add(-48 * time.Hour)
+	err = ra.AdministrativelyRevokeCertificate(context.Background(), *cert, ocsp.KeyCompromise, "root", "", revokedAt, false)
+	test.AssertNotError(t, err, "AdministrativelyRevokeCertificate failed")
+	test.AssertEquals(t, *mockSA.revoked.Date, revokedAt.UnixNano())
+	test.AssertEquals(t, *mockSA.added.Added, ra.clk.Now().UnixNano())
+}
+
+// mockSARevoked is a mockSABlockedKey whose certificates have the status
+// status, recording the last revocation it was asked to update.
+type mockSARevoked struct {
+	mockSABlockedKey
+
+	status  core.CertificateStatus
+	updated *sapb.RevokeCertificateRequest
+}
+
+func (msar *mockSARevoked) GetCertificateStatus(context.Context, string) (core.CertificateStatus, error) {
+	return msar.status, nil
+}
+
+func (msar *mockSARevoked) UpdateRevokedCertificate(_ context.Context, req *sapb.RevokeCertificateRequest) error {
+	msar.updated = req
+	return nil
+}
+
+func TestAdministrativelyRevokeCertificateForce(t *testing.T) {
+	_, _, ra, _, cleanUp := initAuthorities(t)
+	defer cleanUp()
+
+	err := features.Set(map[string]bool{"BlockedKeyTable": true})
+	test.AssertNotError(t, err, "features.Set failed")
+	defer features.Reset()
+
+	originally := ra.clk.Now().Add(-24 * time.Hour)
+	mockSA := &mockSARevoked{status: core.CertificateStatus{
+		Status:        core.OCSPStatusRevoked,
+		RevokedReason: ocsp.Superseded,
+		RevokedDate:   originally,
+	}}
+	ra.SA = mockSA
+	ra.CA = &mockCAOCSP{}
+	ra.purger = &mockPurger{}
+
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "ecdsa.GenerateKey failed")
+	template := x509.Certificate{PublicKey: k, SerialNumber: big.NewInt(257)}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, k.Public(), k)
+	test.AssertNotError(t, err, "x509.CreateCertificate failed")
+	cert, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "x509.ParseCertificate failed")
+	ra.issuer = cert
+
+	// The revocation is updated with the new reason and response, keeping the
+	// date the certificate was revoked at, and the key is blocked.
+	err = ra.AdministrativelyRevokeCertificate(context.Background(), *cert, ocsp.KeyCompromise, "root", "", time.Time{}, true)
+	test.AssertNotError(t, err, "AdministrativelyRevokeCertificate failed")
+	test.Assert(t, mockSA.revoked == nil, "RevokeCertificate was called for a forced revocation")
+	test.Assert(t, mockSA.updated != nil, "UpdateRevokedCertificate wasn't called")
+	test.AssertEquals(t, *mockSA.updated.Reason, int64(ocsp.KeyCompromise))
+	test.AssertEquals(t, *mockSA.updated.Date, originally.UnixNano())
+	test.AssertDeepEquals(t, mockSA.updated.Response, []byte{1, 2, 3})
+	test.Assert(t, mockSA.added != nil, "blocked key was not added when reason was keyCompromise")
+
+	// A date given replaces the one the certificate was revoked at.
+	revokedAt := ra.clk.Now().Add(-48 * time.Hour)
+	err = ra.AdministrativelyRevokeCertificate(context.Background(), *cert, ocsp.KeyCompromise, "root", "", revokedAt, true)
+	test.AssertNotError(t, err, "AdministrativelyRevokeCertificate failed")
+	test.AssertEquals(t, *mockSA.updated.Date, revokedAt.UnixNano())
+
+	// A certificate which isn't revoked isn't revoked by a forced revocation.
+	mockSA.updated = nil
+	mockSA.status = core.CertificateStatus{Status: core.OCSPStatusGood}
+	err = ra.AdministrativelyRevokeCertificate(context.Background(), *cert, ocsp.KeyCompromise, "root", "", time.Time{}, true)
+	test.Assert(t, berrors.Is(err, berrors.NotFound), "expected a NotFound error")
+	test.Assert(t, mockSA.updated == nil && mockSA.revoked == nil, "certificate which isn't revoked was revoked")
 }
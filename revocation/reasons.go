@@ -3,6 +3,7 @@ package revocation
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/crypto/ocsp"
@@ -26,6 +27,31 @@ var ReasonToString = map[Reason]string{
 	ocsp.AACompromise:       "aAcompromise",
 }
 
+// ReasonDescription provides a plain-English description of each reason code
+// in ReasonToString, based on RFC 5280 Section 5.3.1.
+var ReasonDescription = map[Reason]string{
+	ocsp.Unspecified:          "No reason was given for the revocation.",
+	ocsp.KeyCompromise:        "The certificate's private key is known or suspected to have been compromised.",
+	ocsp.CACompromise:         "The private key of the CA which issued the certificate is known or suspected to have been compromised.",
+	ocsp.AffiliationChanged:   "The subject's name or other information in the certificate has changed.",
+	ocsp.Superseded:           "The certificate has been replaced by a new one.",
+	ocsp.CessationOfOperation: "The certificate is no longer needed because whatever it was issued for has ceased operating.",
+	ocsp.CertificateHold:      "The certificate is temporarily on hold, and may later be reinstated.",
+	ocsp.RemoveFromCRL:        "The certificate, previously on hold, has been reinstated and should be removed from the CRL.",
+	ocsp.PrivilegeWithdrawn:   "A privilege granted to the subject of the certificate has been withdrawn.",
+	ocsp.AACompromise:         "The private key of the attribute authority which issued the certificate is known or suspected to have been compromised.",
+}
+
+// String returns the name of the reason, as given in ReasonToString, or
+// "unknown(n)" for a code which isn't a known reason, so that unknown codes
+// don't appear as blanks in logs.
+func (r Reason) String() string {
+	if name, ok := ReasonToString[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", r)
+}
+
 // UserAllowedReasons contains the subset of Reasons which users are
 // allowed to use
 var UserAllowedReasons = map[Reason]struct{}{
@@ -36,12 +62,86 @@ var UserAllowedReasons = map[Reason]struct{}{
 	ocsp.CessationOfOperation: {}, // cessationOfOperation
 }
 
+// AdminAllowedReasons contains the subset of Reasons which administrators
+// are allowed to use when revoking via the admin-revoker tool. This is every
+// reason defined by RFC 5280 Section 5.3.1 except for code 7, which is unused,
+// and certificateHold, since Boulder can't honor a temporary hold.
+var AdminAllowedReasons = map[Reason]struct{}{
+	ocsp.Unspecified:          {}, // unspecified
+	ocsp.KeyCompromise:        {}, // keyCompromise
+	ocsp.CACompromise:         {}, // cACompromise
+	ocsp.AffiliationChanged:   {}, // affiliationChanged
+	ocsp.Superseded:           {}, // superseded
+	ocsp.CessationOfOperation: {}, // cessationOfOperation
+	ocsp.RemoveFromCRL:        {}, // removeFromCRL
+	ocsp.PrivilegeWithdrawn:   {}, // privilegeWithdrawn
+	ocsp.AACompromise:         {}, // aAcompromise
+}
+
+// IsValidAdminReason returns true if the provided Reason may be used for an
+// administrative revocation.
+func IsValidAdminReason(r Reason) bool {
+	_, ok := AdminAllowedReasons[r]
+	return ok
+}
+
+// AdminReasonNote returns an explanation of why the provided Reason is
+// rejected or discouraged for administrative revocation, or "" if it may be
+// used freely.
+func AdminReasonNote(r Reason) string {
+	switch r {
+	case ocsp.CertificateHold:
+		return "Boulder doesn't support certificateHold, which marks a revocation as temporary, because revocations can't be undone"
+	case ocsp.Unspecified:
+		return "unspecified is often used by mistake; prefer a more specific reason if one applies"
+	}
+	return ""
+}
+
+// CheckAdminReason returns an error explaining why the provided Reason may not
+// be used for an administrative revocation, or nil if it may.
+func CheckAdminReason(r Reason) error {
+	if IsValidAdminReason(r) {
+		return nil
+	}
+	if r == ocsp.CertificateHold {
+		return fmt.Errorf("invalid reason code: %d: %s", r, AdminReasonNote(r))
+	}
+	return fmt.Errorf("invalid reason code: %d", r)
+}
+
+// ParseAdminReason parses a reason given to the admin-revoker, either as its
+// numeric code or as its name, and checks that it may be used for an
+// administrative revocation.
+func ParseAdminReason(s string) (Reason, error) {
+	reason, err := ReasonFromString(s)
+	if code, atoiErr := strconv.Atoi(s); atoiErr == nil {
+		reason, err = Reason(code), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	err = CheckAdminReason(reason)
+	if err != nil {
+		return 0, err
+	}
+	return reason, nil
+}
+
 // UserAllowedReasonsMessage contains a string describing a list of user allowed
 // revocation reasons. This is useful when a revocation is rejected because it
 // is not a valid user supplied reason and the allowed values must be
 // communicated. This variable is populated during package initialization.
 var UserAllowedReasonsMessage = ""
 
+// stringToReason is the reverse of ReasonToString, keyed by the lowercased
+// reason name. This variable is populated during package initialization.
+var stringToReason = map[string]Reason{}
+
+// reasonsMessage contains a string describing all known revocation reasons,
+// ordered by code. This variable is populated during package initialization.
+var reasonsMessage = ""
+
 func init() {
 	// Build a slice of ints from the allowed reason codes.
 	// We want a slice because iterating `UserAllowedReasons` will change order
@@ -59,4 +159,28 @@ func init() {
 			ReasonToString[Reason(reason)], reason))
 	}
 	UserAllowedReasonsMessage = strings.Join(reasonStrings, ", ")
+
+	var all []int
+	for reason, name := range ReasonToString {
+		stringToReason[strings.ToLower(name)] = reason
+		all = append(all, int(reason))
+	}
+	sort.Ints(all)
+
+	var allStrings []string
+	for _, reason := range all {
+		allStrings = append(allStrings, fmt.Sprintf("%s (%d)",
+			ReasonToString[Reason(reason)], reason))
+	}
+	reasonsMessage = strings.Join(allStrings, ", ")
+}
+
+// ReasonFromString returns the Reason whose name, as given in ReasonToString,
+// matches the provided string. The comparison is case-insensitive.
+func ReasonFromString(s string) (Reason, error) {
+	reason, ok := stringToReason[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return 0, fmt.Errorf("unknown revocation reason %q, valid reasons are: %s", s, reasonsMessage)
+	}
+	return reason, nil
 }
@@ -0,0 +1,104 @@
+package revocation
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/letsencrypt/boulder/test"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestReasonFromString(t *testing.T) {
+	for code, name := range ReasonToString {
+		reason, err := ReasonFromString(name)
+		test.AssertNotError(t, err, "ReasonFromString failed")
+		test.AssertEquals(t, reason, code)
+	}
+
+	reason, err := ReasonFromString("KEYCOMPROMISE")
+	test.AssertNotError(t, err, "ReasonFromString failed on uppercase name")
+	test.AssertEquals(t, reason, Reason(ocsp.KeyCompromise))
+
+	_, err = ReasonFromString("notAReason")
+	test.AssertError(t, err, "ReasonFromString didn't fail on unknown name")
+	test.AssertContains(t, err.Error(), "keyCompromise (1)")
+}
+
+func TestIsValidAdminReason(t *testing.T) {
+	for code, name := range ReasonToString {
+		if code == ocsp.CertificateHold {
+			continue
+		}
+		test.Assert(t, IsValidAdminReason(code), fmt.Sprintf("%s should be allowed", name))
+	}
+	test.Assert(t, IsValidAdminReason(ocsp.KeyCompromise), "keyCompromise should be allowed")
+	test.Assert(t, IsValidAdminReason(ocsp.Unspecified), "unspecified should be allowed")
+	test.Assert(t, !IsValidAdminReason(ocsp.CertificateHold), "certificateHold should not be allowed")
+	test.Assert(t, !IsValidAdminReason(7), "unused code 7 should not be allowed")
+	test.Assert(t, !IsValidAdminReason(-1), "negative codes should not be allowed")
+	test.Assert(t, !IsValidAdminReason(11), "codes above 10 should not be allowed")
+}
+
+func TestCheckAdminReason(t *testing.T) {
+	test.AssertNotError(t, CheckAdminReason(ocsp.KeyCompromise), "keyCompromise should be allowed")
+	test.AssertNotError(t, CheckAdminReason(ocsp.Unspecified), "unspecified should be allowed")
+
+	err := CheckAdminReason(ocsp.CertificateHold)
+	test.AssertError(t, err, "certificateHold should not be allowed")
+	test.AssertContains(t, err.Error(), "doesn't support certificateHold")
+
+	err = CheckAdminReason(7)
+	test.AssertError(t, err, "unused code 7 should not be allowed")
+	test.AssertEquals(t, err.Error(), "invalid reason code: 7")
+
+	test.AssertEquals(t, AdminReasonNote(ocsp.KeyCompromise), "")
+	test.Assert(t, AdminReasonNote(ocsp.Unspecified) != "", "unspecified should have a note")
+}
+
+func TestParseAdminReason(t *testing.T) {
+	reason, err := ParseAdminReason("1")
+	test.AssertNotError(t, err, "ParseAdminReason rejected a numeric code")
+	test.AssertEquals(t, reason, Reason(ocsp.KeyCompromise))
+
+	reason, err = ParseAdminReason("KeyCompromise")
+	test.AssertNotError(t, err, "ParseAdminReason rejected a reason name")
+	test.AssertEquals(t, reason, Reason(ocsp.KeyCompromise))
+
+	_, err = ParseAdminReason("certificateHold")
+	test.AssertError(t, err, "ParseAdminReason accepted certificateHold")
+	_, err = ParseAdminReason("7")
+	test.AssertError(t, err, "ParseAdminReason accepted unused code 7")
+	_, err = ParseAdminReason("bogus")
+	test.AssertError(t, err, "ParseAdminReason accepted an unknown reason")
+}
+
+func TestReasonString(t *testing.T) {
+	test.AssertEquals(t, Reason(ocsp.KeyCompromise).String(), "keyCompromise")
+	test.AssertEquals(t, fmt.Sprintf("%s", Reason(ocsp.Superseded)), "superseded")
+	test.AssertEquals(t, Reason(7).String(), "unknown(7)")
+	test.AssertEquals(t, Reason(-1).String(), "unknown(-1)")
+}
+
+// TestReasonToStringComplete checks that every CRLReason defined by RFC 5280
+// Section 5.3.1 has a name, so that none are logged as blanks. Code 7 is not
+// a reason; it is unused.
+func TestReasonToStringComplete(t *testing.T) {
+	for code := Reason(ocsp.Unspecified); code <= ocsp.AACompromise; code++ {
+		if code == 7 {
+			_, ok := ReasonToString[code]
+			test.Assert(t, !ok, "unused code 7 shouldn't have a name")
+			continue
+		}
+		test.Assert(t, ReasonToString[code] != "", fmt.Sprintf("reason %d has no name", code))
+	}
+	test.AssertEquals(t, len(ReasonToString), 10)
+}
+
+// TestReasonDescriptionComplete checks that every reason with a name has a
+// description, and that no code without a name has one.
+func TestReasonDescriptionComplete(t *testing.T) {
+	for code, name := range ReasonToString {
+		test.Assert(t, ReasonDescription[code] != "", fmt.Sprintf("reason %d (%s) has no description", code, name))
+	}
+	test.AssertEquals(t, len(ReasonDescription), len(ReasonToString))
+}
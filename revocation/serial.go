@@ -0,0 +1,30 @@
+package revocation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// NormalizeSerial converts a hex encoded certificate serial number as copied
+// from OpenSSL output ("AA:BB:CC"), CT logs ("0xaabbcc") or the database into
+// the canonical lowercase form used to store serials. Whitespace, colons and
+// any "0x" prefix are removed. An error is returned if what remains isn't hex.
+func NormalizeSerial(s string) (string, error) {
+	serial := strings.Map(func(r rune) rune {
+		if r == ':' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+	serial = strings.TrimPrefix(serial, "0x")
+	if serial == "" {
+		return "", fmt.Errorf("serial %q is empty", s)
+	}
+	for _, r := range serial {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return "", fmt.Errorf("serial %q contains non-hex character %q", s, r)
+		}
+	}
+	return serial, nil
+}
@@ -0,0 +1,29 @@
+package revocation
+
+import (
+	"testing"
+
+	"github.com/letsencrypt/boulder/test"
+)
+
+func TestNormalizeSerial(t *testing.T) {
+	const canonical = "03a1b2c3d4e5f60718293a4b5c6d7e8f9012"
+	for _, s := range []string{
+		canonical,
+		"03A1B2C3D4E5F60718293A4B5C6D7E8F9012",
+		"03:a1:b2:c3:d4:e5:f6:07:18:29:3a:4b:5c:6d:7e:8f:90:12",
+		"03:A1:B2:C3:D4:E5:F6:07:18:29:3A:4B:5C:6D:7E:8F:90:12",
+		"0x03a1b2c3d4e5f60718293a4b5c6d7e8f9012",
+		"0X03A1B2C3D4E5F60718293A4B5C6D7E8F9012",
+		"  03a1b2c3d4e5f607 18293a4b5c6d7e8f9012\n",
+	} {
+		serial, err := NormalizeSerial(s)
+		test.AssertNotError(t, err, "NormalizeSerial failed")
+		test.AssertEquals(t, serial, canonical)
+	}
+
+	for _, s := range []string{"", "0x", " : ", "03a1-b2c3", "serial", "0x0x03a1"} {
+		_, err := NormalizeSerial(s)
+		test.AssertError(t, err, "NormalizeSerial didn't fail for invalid serial")
+	}
+}
@@ -0,0 +1,499 @@
+package revoker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/status"
+
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// SerialOutput appends each serial revoked to a file, one per line, syncing it
+// after every write so that a record of exactly which certificates were revoked
+// survives a crash. A nil *SerialOutput records nothing.
+type SerialOutput struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenSerialOutput opens the file at path for appending, creating it if it
+// doesn't exist.
+func OpenSerialOutput(path string) (*SerialOutput, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &SerialOutput{file: f}, nil
+}
+
+// record appends the serial to the file and syncs it to disk.
+func (so *SerialOutput) record(serial string) error {
+	if so == nil {
+		return nil
+	}
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	if _, err := so.file.WriteString(serial + "\n"); err != nil {
+		return err
+	}
+	return so.file.Sync()
+}
+
+// Close closes the file.
+func (so *SerialOutput) Close() error {
+	if so == nil {
+		return nil
+	}
+	return so.file.Close()
+}
+
+// Checkpoint records the serials successfully revoked by a bulk revocation to
+// a file, so that an interrupted run can be resumed without attempting to
+// revoke them again. Serials are recorded normalized, so that a serial is
+// recognized however it's written, e.g. in upper case or with a 0x prefix. A
+// nil *Checkpoint records nothing and contains nothing.
+type Checkpoint struct {
+	out  *SerialOutput
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+// OpenCheckpoint loads the serials already recorded in the checkpoint file at
+// path, creating it if it doesn't exist, and opens it for appending.
+func OpenCheckpoint(path string) (*Checkpoint, error) {
+	done := make(map[string]bool)
+	contents, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			done[checkpointSerial(line)] = true
+		}
+	}
+	out, err := OpenSerialOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{out: out, done: done}, nil
+}
+
+// contains returns true if the serial was recorded as revoked.
+func (cp *Checkpoint) contains(serial string) bool {
+	if cp == nil {
+		return false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.done[checkpointSerial(serial)]
+}
+
+// record appends the serial to the checkpoint file, syncing it to disk so
+// that it survives the process dying immediately afterwards.
+func (cp *Checkpoint) record(serial string) error {
+	if cp == nil {
+		return nil
+	}
+	serial = checkpointSerial(serial)
+	if err := cp.out.record(serial); err != nil {
+		return err
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.done[serial] = true
+	return nil
+}
+
+// checkpointSerial returns the normalized form of serial kept in checkpoints.
+// A serial which can't be normalized is kept as it is, so that it still never
+// matches a valid one.
+func checkpointSerial(serial string) string {
+	if normalized, err := revocation.NormalizeSerial(serial); err == nil {
+		return normalized
+	}
+	return serial
+}
+
+// Close closes the checkpoint file.
+func (cp *Checkpoint) Close() error {
+	if cp == nil {
+		return nil
+	}
+	return cp.out.Close()
+}
+
+// revokeCheckpointed revokes a single serial as part of a bulk revocation. The
+// serial is skipped if the checkpoint shows it was revoked by a previous run,
+// and is recorded in the checkpoint once it has been revoked.
+func (r *Revoker) revokeCheckpointed(ctx context.Context, certs certLookup, serial string, reasonCode revocation.Reason, opts Options, cp *Checkpoint) error {
+	if cp.contains(serial) {
+		r.log.Infof("Skipping certificate %s, already revoked according to checkpoint", serial)
+		r.report(opts, serial, "", reasonCode, reportSkipped, errSkippedCheckpointed)
+		return errSkippedCheckpointed
+	}
+	err := r.revokeBySerial(ctx, certs, serial, reasonCode, opts)
+	if err != nil || opts.DryRun {
+		return err
+	}
+	return cp.record(serial)
+}
+
+// SerialError records the error encountered while revoking a single serial.
+type SerialError struct {
+	Serial string
+	Err    error
+}
+
+// SkippedSerial records why a serial was skipped rather than revoked.
+type SkippedSerial struct {
+	Serial string
+	// Reason is why it was skipped, e.g. "already revoked" or "expired".
+	Reason string
+}
+
+// BatchResult summarizes the outcome of revoking a list of serials.
+type BatchResult struct {
+	Revoked int
+	// Skipped lists the serials which weren't revoked, but whose skipping
+	// isn't a failure, in the order they were processed.
+	Skipped  []SkippedSerial
+	Failures []SerialError
+	// Attempted lists every serial revocation was attempted for, whether or
+	// not it succeeded, other than those skipped. It is only set when revoking
+	// by registration or in parallel, so that the revocations can be verified
+	// once they are complete.
+	Attempted []string
+}
+
+// RevokedSerials returns the serials of Attempted which didn't fail, i.e.
+// those which were revoked.
+func (br BatchResult) RevokedSerials() []string {
+	failed := make(map[string]bool, len(br.Failures))
+	for _, f := range br.Failures {
+		failed[f.Serial] = true
+	}
+	var revoked []string
+	for _, serial := range br.Attempted {
+		if !failed[serial] {
+			revoked = append(revoked, serial)
+		}
+	}
+	return revoked
+}
+
+// Log writes a summary of the batch, including the reason each serial was
+// skipped and the error for each serial that couldn't be revoked, followed by
+// the number skipped for each reason and the number of failures of each kind.
+func (br BatchResult) Log(logger blog.Logger) {
+	logger.Infof("Batch complete: %d revoked, %d skipped, %d failed", br.Revoked, len(br.Skipped), len(br.Failures))
+	for _, s := range br.Skipped {
+		logger.Infof("Skipped %s: %s", s.Serial, s.Reason)
+	}
+	for _, f := range br.Failures {
+		logger.Errf("Failed to revoke %s: %s", f.Serial, f.Err)
+	}
+	for _, reason := range skipReasons {
+		if n := br.SkippedFor(reason); n > 0 {
+			logger.Infof("%d skipped as %s", n, reason)
+		}
+	}
+	logFailureCategories(logger, br.Failures)
+}
+
+// skipReasons lists every reason a serial may be skipped, in the order
+// they're summarized.
+var skipReasons = []string{skipAlreadyRevoked, skipCheckpointed, skipSameReason, skipExpired, skipDNSName, skipNotFound}
+
+// SkippedFor returns the number of serials skipped for the reason.
+func (br BatchResult) SkippedFor(reason string) int {
+	var n int
+	for _, s := range br.Skipped {
+		if s.Reason == reason {
+			n++
+		}
+	}
+	return n
+}
+
+// Kinds of failure counted by logFailureCategories, in the order they are
+// logged.
+const (
+	failureNotFound       = "not found"
+	failureAlreadyRevoked = "already revoked"
+	failureMalformed      = "malformed"
+	failureBackend        = "backend error"
+	failureDatabase       = "database error"
+	failureOther          = "other error"
+)
+
+var failureCategories = []string{
+	failureNotFound,
+	failureAlreadyRevoked,
+	failureMalformed,
+	failureBackend,
+	failureDatabase,
+	failureOther,
+}
+
+// categorizeFailure returns the kind of failure err represents, derived from
+// its berrors type where it has one.
+func categorizeFailure(err error) string {
+	switch {
+	case db.IsNoRows(err) || berrors.Is(err, berrors.NotFound):
+		return failureNotFound
+	case berrors.Is(err, berrors.AlreadyRevoked):
+		return failureAlreadyRevoked
+	case berrors.Is(err, berrors.Malformed):
+		return failureMalformed
+	}
+	switch err.(type) {
+	case db.ErrDatabaseOp, DatabaseError:
+		return failureDatabase
+	case *berrors.BoulderError, BackendError:
+		// Other errors returned by the RA and SA are unwrapped into
+		// BoulderErrors.
+		return failureBackend
+	}
+	if _, ok := status.FromError(err); ok {
+		return failureBackend
+	}
+	return failureOther
+}
+
+// failureCategory counts the failures of a single kind.
+type failureCategory struct {
+	Category string
+	Count    int
+	// Sample is the first failure of this kind.
+	Sample SerialError
+}
+
+// categorizeFailures counts the failures of each kind, omitting kinds with no
+// failures.
+func categorizeFailures(failures []SerialError) []failureCategory {
+	counts := make(map[string]*failureCategory)
+	for _, f := range failures {
+		category := categorizeFailure(f.Err)
+		if counts[category] == nil {
+			counts[category] = &failureCategory{Category: category, Sample: f}
+		}
+		counts[category].Count++
+	}
+	var categories []failureCategory
+	for _, category := range failureCategories {
+		if c := counts[category]; c != nil {
+			categories = append(categories, *c)
+		}
+	}
+	return categories
+}
+
+// logFailureCategories logs the number of failures of each kind, with the
+// first of each as an example, so that a long list of failures can be triaged.
+func logFailureCategories(logger blog.Logger, failures []SerialError) {
+	for _, c := range categorizeFailures(failures) {
+		logger.Errf("%d %s, e.g. %s: %s", c.Count, c.Category, c.Sample.Serial, c.Sample.Err)
+	}
+}
+
+// add records the outcome of attempting to revoke a serial, returning err
+// unless the serial was revoked or skipped.
+func (br *BatchResult) add(serial string, err error) error {
+	if skip, ok := err.(skipError); ok {
+		br.Skipped = append(br.Skipped, SkippedSerial{serial, skip.reason})
+		return nil
+	}
+	switch {
+	case err != nil:
+		br.Failures = append(br.Failures, SerialError{serial, err})
+		return err
+	}
+	br.Revoked++
+	return nil
+}
+
+// revokeSerialsParallel revokes each of the serials sent to work by feed using
+// parallelism concurrent workers, collecting the results. Any error returned by
+// feed is returned once the serials it sent have been revoked. Once opts.Stop is
+// closed, serials which haven't been started yet are dropped.
+func (r *Revoker) revokeSerialsParallel(ctx context.Context, feed func(work chan<- string) error, reasonCode revocation.Reason, opts Options, parallelism int, cp *Checkpoint) (BatchResult, error) {
+	var result BatchResult
+	var mu sync.Mutex
+	wg := new(sync.WaitGroup)
+	work := make(chan string, parallelism)
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for serial := range work {
+				if opts.stopped() {
+					// Drain the serials already queued without revoking them.
+					continue
+				}
+				err := r.revokeCheckpointed(ctx, dbLookup{r.dbMap}, serial, reasonCode, opts, cp)
+				mu.Lock()
+				if _, skipped := err.(skipError); !skipped {
+					result.Attempted = append(result.Attempted, serial)
+				}
+				_ = result.add(serial, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	err := feed(work)
+	close(work)
+	wg.Wait()
+
+	sort.Slice(result.Skipped, func(i, j int) bool {
+		return result.Skipped[i].Serial < result.Skipped[j].Serial
+	})
+	sort.Slice(result.Failures, func(i, j int) bool {
+		return result.Failures[i].Serial < result.Failures[j].Serial
+	})
+	sort.Strings(result.Attempted)
+	return result, err
+}
+
+// RevokeBatch revokes all certificates listed in the file of hex serials at
+// serialPath, read as by ReadSerialFile, using parallelism concurrent workers,
+// outside of any transaction. As with RevokeSerialsParallel, a failure to
+// revoke one certificate is recorded in the result rather than stopping the
+// others, and an error is only returned if the file can't be read or the
+// revocation is stopped.
+func (r *Revoker) RevokeBatch(ctx context.Context, serialPath string, reasonCode revocation.Reason, parallelism int, opts Options) (BatchResult, error) {
+	serials, err := ReadSerialFile(serialPath)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	opts.Progress.SetTotal(len(serials))
+	result, err := r.RevokeSerialsParallel(ctx, serials, reasonCode, opts, parallelism, nil)
+	if err == ErrInterrupted {
+		r.log.Warningf("Batch interrupted after processing %d serials", len(result.Attempted)+len(result.Skipped))
+	}
+	return result, err
+}
+
+// ReadSerialFile reads a file containing one hex serial per line. Blank lines
+// and lines beginning with '#' are ignored.
+func ReadSerialFile(path string) ([]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var serials []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		serials = append(serials, line)
+	}
+	return serials, nil
+}
+
+// ReadSerialReasonFile reads a file of serials like ReadSerialFile, except that
+// each serial may be followed by a comma and the reason to revoke it with, as
+// a code or a name, in place of the default. It returns the serials and the
+// reasons given for them, keyed by serial as given in the file. A reason which
+// can't be parsed or may not be used for administrative revocation, or a
+// serial given twice with different reasons, fails the whole file.
+func ReadSerialReasonFile(path string) ([]string, map[string]revocation.Reason, error) {
+	lines, err := ReadSerialFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var serials []string
+	reasons := make(map[string]revocation.Reason)
+	for _, line := range lines {
+		fields := strings.SplitN(line, ",", 2)
+		serial := strings.TrimSpace(fields[0])
+		serials = append(serials, serial)
+		if len(fields) == 1 {
+			continue
+		}
+		reason, err := revocation.ParseAdminReason(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("reason for serial %s: %s", serial, err)
+		}
+		if previous, ok := reasons[serial]; ok && previous != reason {
+			return nil, nil, fmt.Errorf("serial %s is given with both reason '%s' and '%s'", serial, previous.String(), reason.String())
+		}
+		reasons[serial] = reason
+	}
+	return serials, reasons, nil
+}
+
+// RevokeSerials revokes each of the provided serials in turn, in a single
+// transaction unless opts.CommitEvery is set, with the reason given for it in
+// reasons, or reasonCode if none is. A serial which fails to be revoked is
+// recorded in the result and doesn't stop the others from being revoked, and
+// serials which aren't found are recorded as skipped. With strict, the first
+// failure, including a serial which isn't found, aborts the batch instead.
+// Serials recorded in the checkpoint are skipped, and each serial revoked is
+// recorded in it. If the backend becomes unreachable, the batch stops without
+// rolling the transaction back, as described by RevokeRegistration, returning
+// a BackendUnavailableError.
+func (r *Revoker) RevokeSerials(ctx context.Context, serials []string, reasonCode revocation.Reason, reasons map[string]revocation.Reason, opts Options, strict bool, cp *Checkpoint) (BatchResult, error) {
+	var result BatchResult
+	var unavailable error
+	opts.skipNotFound = !strict
+	err := r.inCommittingTransactions(ctx, opts, func(certs certLookup) error {
+		var err error
+		result, err = r.revokeSerials(ctx, certs, serials, reasonCode, reasons, opts, strict, cp)
+		if _, ok := err.(BackendUnavailableError); ok {
+			unavailable = err
+			return nil
+		}
+		return err
+	})
+	if err == nil && unavailable != nil {
+		return result, unavailable
+	}
+	return result, err
+}
+
+// revokeSerials revokes each of the serials in turn, finding them with certs,
+// with the reason given for it in reasons, or reasonCode if none is. Each
+// failure is recorded in the result, and the revocation continues unless
+// strict is set, the failure is because the backend couldn't be reached, in
+// which case a BackendUnavailableError is returned, or ctx is done.
+func (r *Revoker) revokeSerials(ctx context.Context, certs certLookup, serials []string, reasonCode revocation.Reason, reasons map[string]revocation.Reason, opts Options, strict bool, cp *Checkpoint) (BatchResult, error) {
+	var result BatchResult
+	for _, serial := range serials {
+		if opts.stopped() {
+			return result, ErrInterrupted
+		}
+		reason, ok := reasons[serial]
+		if !ok {
+			reason = reasonCode
+		}
+		err := r.revokeCheckpointed(ctx, certs, serial, reason, opts, cp)
+		_, skipped := err.(skipError)
+		err = result.add(serial, err)
+		if err != nil {
+			if strict || ctx.Err() != nil {
+				return result, err
+			}
+			if isConnectionError(err) {
+				return result, BackendUnavailableError{Revoked: result.Revoked, Err: err}
+			}
+			continue
+		}
+		if !skipped {
+			err = recordRevoked(certs)
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package revoker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
+	bgrpc "github.com/letsencrypt/boulder/grpc"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// checkHealthTimeout is used to check that a gRPC service is reachable when
+// its config doesn't set a HealthCheckTimeout.
+const checkHealthTimeout = 5 * time.Second
+
+// ConfigCheck is the outcome of checking a single component of a Config.
+type ConfigCheck struct {
+	Component string
+	// Err is nil if the component is correctly configured.
+	Err error
+}
+
+// CheckConfig validates the config, without revoking anything: the database
+// URL must be readable and parseable, the RA and SA, the only transport, must
+// be configured, and the TLS files, issuer certificate and reason codes must
+// load. It then connects to the database and each configured gRPC service,
+// checking that they respond, and closes each connection immediately. Every
+// component is checked, even if an earlier one failed, and the outcome of each
+// is returned in turn.
+func CheckConfig(ctx context.Context, c Config, logger blog.Logger, clk clock.Clock, stats prometheus.Registerer) []ConfigCheck {
+	var checks []ConfigCheck
+	check := func(component string, err error) {
+		checks = append(checks, ConfigCheck{component, err})
+	}
+
+	dbURL, err := c.DBConfig.URL()
+	if err == nil {
+		_, err = mysql.ParseDSN(dbURL)
+	}
+	check("database URL", err)
+	dbURLOK := err == nil
+
+	check("RA service", checkServiceConfig(c.RAService))
+	check("SA service", checkServiceConfig(c.SAService))
+
+	var tlsConfig *tls.Config
+	err = checkTLSConfig(c)
+	if err == nil {
+		tlsConfig, err = loadTLSConfig(&c.TLS)
+	}
+	check("TLS files", err)
+
+	if c.IssuerCertPath != "" {
+		_, err = core.LoadCert(c.IssuerCertPath)
+		check("issuer certificate", err)
+	}
+	check("reason codes", checkReasons())
+
+	if dbURLOK {
+		check("database connection", checkDB(ctx, c, logger))
+	}
+	if tlsConfig == nil {
+		return checks
+	}
+	clientMetrics := bgrpc.NewClientMetrics(stats)
+	services := []struct {
+		name   string
+		config *cmd.GRPCClientConfig
+	}{
+		{"RA connection", c.RAService},
+		{"SA connection", c.SAService},
+		{"OCSP generator connection", c.OCSPGeneratorService},
+	}
+	for _, s := range services {
+		if s.config == nil || s.config.ServerAddress == "" {
+			continue
+		}
+		// Always check the service's health, so that a service which isn't
+		// listening is reported rather than only failing on first use.
+		healthChecked := *s.config
+		if healthChecked.HealthCheckTimeout.Duration == 0 {
+			healthChecked.HealthCheckTimeout = cmd.ConfigDuration{Duration: checkHealthTimeout}
+		}
+		conn, err := bgrpc.ClientSetup(&healthChecked, tlsConfig.Clone(), clientMetrics, clk)
+		if err == nil {
+			err = conn.Close()
+		}
+		check(s.name, err)
+	}
+	return checks
+}
+
+// checkServiceConfig returns an error if a required gRPC service isn't
+// configured.
+func checkServiceConfig(c *cmd.GRPCClientConfig) error {
+	if c == nil {
+		return errors.New("not configured")
+	}
+	if c.ServerAddress == "" {
+		return errors.New("serverAddress must not be empty")
+	}
+	return nil
+}
+
+// checkReasons returns an error if any reason code accepted for admin
+// revocation can't be parsed from its name, as given on the command line.
+func checkReasons() error {
+	for reason := range revocation.AdminAllowedReasons {
+		name, ok := revocation.ReasonToString[reason]
+		if !ok {
+			return fmt.Errorf("reason code %d has no name", reason)
+		}
+		parsed, err := revocation.ReasonFromString(name)
+		if err != nil {
+			return err
+		}
+		if parsed != reason {
+			return fmt.Errorf("reason name %q parses as %d, not %d", name, parsed, reason)
+		}
+	}
+	return nil
+}
+
+// checkDB connects to the database and pings it.
+func checkDB(ctx context.Context, c Config, logger blog.Logger) error {
+	dbMap, err := connectDB(c, logger)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dbMap.Db.Close() }()
+	return dbMap.Db.PingContext(ctx)
+}
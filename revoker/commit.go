@@ -0,0 +1,115 @@
+package revoker
+
+import (
+	"context"
+
+	"github.com/letsencrypt/boulder/db"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// periodicCommitter is implemented by certLookups reading within a transaction
+// which is committed, and replaced by a new one, as a sequential bulk
+// revocation progresses. revoked is called after each certificate is revoked.
+type periodicCommitter interface {
+	revoked() error
+}
+
+// committingLookup is a certLookup reading within a transaction which is
+// committed after every `every` revocations, beginning a new one, so that a
+// huge bulk revocation doesn't hold a single transaction open throughout. With
+// discard set, each transaction is rolled back rather than committed.
+type committingLookup struct {
+	dbLookup
+	dbMap   db.DatabaseMap
+	ctx     context.Context
+	tx      db.Transaction
+	every   int
+	pending int
+	total   int
+	discard bool
+	log     blog.Logger
+}
+
+// begin begins a new transaction, which subsequent lookups read within.
+func (l *committingLookup) begin() error {
+	tx, err := l.dbMap.Begin()
+	if err != nil {
+		return err
+	}
+	l.tx = tx
+	l.dbLookup = dbLookup{tx.WithContext(l.ctx)}
+	l.pending = 0
+	return nil
+}
+
+// end commits the current transaction, or rolls it back if l.discard is set.
+func (l *committingLookup) end() error {
+	if l.discard {
+		return l.tx.Rollback()
+	}
+	return l.tx.Commit()
+}
+
+// abort rolls back the current transaction because of err, which is returned.
+func (l *committingLookup) abort(err error) error {
+	if rbErr := l.tx.Rollback(); rbErr != nil {
+		return &db.RollbackError{Err: err, RollbackErr: rbErr}
+	}
+	return err
+}
+
+func (l *committingLookup) revoked() error {
+	l.pending++
+	l.total++
+	if l.pending < l.every {
+		return nil
+	}
+	err := l.end()
+	if err != nil {
+		return DatabaseError{err}
+	}
+	l.log.Infof("Committed the transaction after %d revocations (%d in total), beginning another", l.pending, l.total)
+	err = l.begin()
+	if err != nil {
+		return DatabaseError{err}
+	}
+	return nil
+}
+
+// recordRevoked tells certs that a certificate was revoked, if it commits its
+// transaction periodically.
+func recordRevoked(certs certLookup) error {
+	if c, ok := certs.(periodicCommitter); ok {
+		return c.revoked()
+	}
+	return nil
+}
+
+// inCommittingTransactions runs f, reading within a transaction, as
+// inTransaction does. If opts.CommitEvery is set, the transaction is committed
+// after every opts.CommitEvery revocations instead, and a new one begun. A
+// failure then only rolls back the current transaction, and each transaction
+// sees the changes committed since the previous one began.
+func (r *Revoker) inCommittingTransactions(ctx context.Context, opts Options, f func(certLookup) error) error {
+	if opts.CommitEvery <= 0 {
+		return r.inTransaction(ctx, opts, func(tx db.Executor) error {
+			return f(dbLookup{tx})
+		})
+	}
+	l := &committingLookup{
+		dbMap:   r.dbMap,
+		ctx:     ctx,
+		every:   opts.CommitEvery,
+		discard: opts.DryRun || opts.Rollback,
+		log:     r.log,
+	}
+	err := l.begin()
+	if err != nil {
+		return err
+	}
+	err = f(l)
+	if err != nil {
+		return l.abort(err)
+	}
+	return l.end()
+}
@@ -0,0 +1,99 @@
+package revoker
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// Backoff between attempts to reach a gRPC service, and the default bound on
+// each attempt, used when Config.ConnectRetries is set.
+const (
+	connectBaseDelay      = time.Second
+	connectMaxDelay       = 30 * time.Second
+	defaultConnectTimeout = 5 * time.Second
+)
+
+// isConnectionError returns true if err is a gRPC error indicating that the
+// service couldn't be reached, rather than that it answered with an error.
+func isConnectionError(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
+
+// connectService sets up a client connection to the gRPC service configured by
+// sc using setup, which is bgrpc.ClientSetup with the Revoker's TLS config and
+// metrics. If c.ConnectRetries is set, it then checks that the service is
+// reachable with a health check bounded by c.ConnectTimeout, in place of any
+// configured in sc, retrying up to c.ConnectRetries times with backoff while
+// it can't be reached, e.g. while the service restarts during a rolling
+// deploy. Any other failure isn't retried.
+func connectService(name string, c Config, sc *cmd.GRPCClientConfig, setup func(*cmd.GRPCClientConfig) (*grpc.ClientConn, error), clk clock.Clock, logger blog.Logger) (*grpc.ClientConn, error) {
+	if c.ConnectRetries <= 0 || sc == nil {
+		return setup(sc)
+	}
+	timeout := c.ConnectTimeout.Duration
+	if timeout == 0 {
+		timeout = sc.HealthCheckTimeout.Duration
+	}
+	if timeout == 0 {
+		timeout = defaultConnectTimeout
+	}
+	// The service's health is checked below, with retries, rather than once
+	// by setup.
+	unchecked := *sc
+	unchecked.HealthCheckTimeout = cmd.ConfigDuration{}
+	conn, err := setup(&unchecked)
+	if err != nil {
+		return nil, err
+	}
+	err = retryConnect(name, c.ConnectRetries, clk, logger, func() error {
+		return checkServing(conn, timeout)
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// retryConnect calls check until it succeeds, fails with an error other than a
+// connection error, or has been retried retries times, sleeping with
+// exponential backoff between attempts, and returns its last error.
+func retryConnect(name string, retries int, clk clock.Clock, logger blog.Logger, check func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := check()
+		if err == nil || !isConnectionError(err) || attempt > retries {
+			return err
+		}
+		delay := core.RetryBackoff(attempt, connectBaseDelay, connectMaxDelay, 2)
+		logger.Warningf("Couldn't reach the %s (attempt %d of %d), retrying in %s: %s",
+			name, attempt, retries+1, delay, err)
+		clk.Sleep(delay)
+	}
+}
+
+// checkServing makes a gRPC health check of the service at the other end of
+// conn, returning the gRPC error if it can't be made within timeout, and an
+// Unavailable error if the service reports that it isn't serving.
+func checkServing(conn *grpc.ClientConn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return status.Errorf(codes.Unavailable, "service reported status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package revoker
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// oidCRLReason identifies the reasonCode extension of a CRL entry, as defined
+// by RFC 5280 Section 5.3.1.
+var oidCRLReason = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// CRLEntry is a single revoked certificate listed in a CRL.
+type CRLEntry struct {
+	Serial string
+	// Reason is the entry's reasonCode extension, or nil if it has none.
+	Reason *revocation.Reason
+}
+
+// ReadCRLFile reads the DER or PEM encoded CRL at path, returning its revoked
+// entries in the order they're listed. The CRL's signature isn't verified, so
+// it must come from a trusted source.
+func ReadCRLFile(path string) ([]CRLEntry, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCRL(contents)
+}
+
+func parseCRL(contents []byte) ([]CRLEntry, error) {
+	crl, err := x509.ParseCRL(contents)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CRL: %s", err)
+	}
+	var entries []CRLEntry
+	for _, rc := range crl.TBSCertList.RevokedCertificates {
+		entry := CRLEntry{Serial: core.SerialToString(rc.SerialNumber)}
+		for _, ext := range rc.Extensions {
+			if !ext.Id.Equal(oidCRLReason) {
+				continue
+			}
+			var code asn1.Enumerated
+			rest, err := asn1.Unmarshal(ext.Value, &code)
+			if err != nil || len(rest) != 0 {
+				return nil, fmt.Errorf("parsing reason code of CRL entry %s: malformed extension", entry.Serial)
+			}
+			reason := revocation.Reason(code)
+			entry.Reason = &reason
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// RevokeCRL revokes each certificate listed in entries, a CRL received from
+// another CA, with the reason given by its entry, or defaultReason if the entry
+// has none. Serials which aren't found, since the certificates weren't issued
+// by Boulder, are recorded in the result as skipped. An entry whose reason may
+// not be used for administrative revocation, e.g. certificateHold, is recorded
+// as a failure rather than revoked with another reason. Failures don't stop the
+// others from being revoked, and an error is only returned if the revocation is
+// stopped.
+func (r *Revoker) RevokeCRL(ctx context.Context, entries []CRLEntry, defaultReason revocation.Reason, opts Options) (BatchResult, error) {
+	return r.revokeCRLEntries(ctx, dbLookup{r.dbMap}, entries, defaultReason, opts)
+}
+
+// revokeCRLEntries revokes the certificates listed in a CRL, as described by
+// RevokeCRL, finding them with certs.
+func (r *Revoker) revokeCRLEntries(ctx context.Context, certs certLookup, entries []CRLEntry, defaultReason revocation.Reason, opts Options) (BatchResult, error) {
+	var result BatchResult
+	opts.Progress.SetTotal(len(entries))
+	opts.skipNotFound = true
+	for _, entry := range entries {
+		if opts.stopped() {
+			return result, ErrInterrupted
+		}
+		reasonCode := defaultReason
+		if entry.Reason != nil {
+			reasonCode = *entry.Reason
+		}
+		_ = result.add(entry.Serial, r.revokeBySerial(ctx, certs, entry.Serial, reasonCode, opts))
+	}
+	return result, nil
+}
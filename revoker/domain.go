@@ -0,0 +1,98 @@
+package revoker
+
+import (
+	"context"
+	"strings"
+
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+	"github.com/weppos/publicsuffix-go/publicsuffix"
+)
+
+// NameMatch determines which certificates are selected when revoking by domain
+// name.
+type NameMatch string
+
+const (
+	// MatchExact selects only the certificates which include the name itself.
+	// A wildcard certificate covering the name isn't selected unless the name
+	// given is that wildcard, e.g. "*.example.com".
+	MatchExact NameMatch = "exact"
+	// MatchRegisteredDomain selects the certificates which include the
+	// registered domain (eTLD+1) of the name, or any subdomain or wildcard
+	// under it.
+	MatchRegisteredDomain NameMatch = "registered-domain"
+)
+
+// ParseNameMatch parses a NameMatch, which must be given explicitly.
+func ParseNameMatch(s string) (NameMatch, error) {
+	switch m := NameMatch(s); m {
+	case MatchExact, MatchRegisteredDomain:
+		return m, nil
+	}
+	return "", berrors.MalformedError("name match %q must be %q or %q", s, MatchExact, MatchRegisteredDomain)
+}
+
+// MatchedDomain returns the name whose certificates are selected when revoking
+// by name with the given match. The name is lowercased and any trailing dot
+// removed. For MatchRegisteredDomain it is replaced by its registered domain,
+// and a name which is itself a public suffix is rejected rather than matching
+// every certificate under that suffix.
+func MatchedDomain(name string, match NameMatch) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if name == "" {
+		return "", berrors.MalformedError("domain name must not be empty")
+	}
+	if match == MatchExact {
+		return name, nil
+	}
+	domain, err := publicsuffix.Domain(strings.TrimPrefix(name, "*."))
+	if err != nil {
+		return "", berrors.MalformedError("no registered domain for %q: %s", name, err)
+	}
+	return domain, nil
+}
+
+// DomainSerials returns the serials of the unexpired certificates selected by
+// the match for domain, as returned by MatchedDomain. Certificates are found
+// using the issuedNames table, and each is returned once however many of its
+// names match. Expired certificates are skipped and their number logged.
+func (r *Revoker) DomainSerials(domain string, match NameMatch) ([]string, error) {
+	certs, err := sa.SelectCertificatesByName(r.dbMap, domain, match == MatchRegisteredDomain)
+	if err != nil {
+		return nil, err
+	}
+	now := r.clk.Now()
+	var serials []string
+	var skipped int
+	for _, cert := range certs {
+		if !cert.Expires.After(now) {
+			skipped++
+			continue
+		}
+		serials = append(serials, cert.Serial)
+	}
+	if skipped > 0 {
+		r.log.Infof("Skipping %d expired certificates for %s", skipped, domain)
+	}
+	return serials, nil
+}
+
+// RevokeSerialsParallel revokes each of the provided serials using parallelism
+// concurrent workers, outside of any transaction. A failure to revoke one
+// certificate doesn't stop the others from being revoked; all failures are
+// collected in the result instead. An error is only returned if the revocation
+// is stopped, in which case the result covers the serials started before then.
+func (r *Revoker) RevokeSerialsParallel(ctx context.Context, serials []string, reasonCode revocation.Reason, opts Options, parallelism int, cp *Checkpoint) (BatchResult, error) {
+	return r.revokeSerialsParallel(ctx, func(work chan<- string) error {
+		for _, serial := range serials {
+			select {
+			case work <- serial:
+			case <-opts.Stop:
+				return ErrInterrupted
+			}
+		}
+		return nil
+	}, reasonCode, opts, parallelism, cp)
+}
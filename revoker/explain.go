@@ -0,0 +1,123 @@
+package revoker
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/prometheus/client_golang/prometheus"
+
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// explainedResponse stands in for the OCSP response the CA would sign, which
+// can't be known in advance.
+const explainedResponse = "<OCSP response signed by the CA>"
+
+// NewExplainer connects to the database described by the config, but not to
+// the RA or SA, and returns a Revoker which writes every query it executes to
+// out along with its bound values. Revocations must be run with
+// Options.DryRun, so nothing is modified. For each certificate which would be
+// revoked, the statement the SA would execute to revoke it is written too.
+func NewExplainer(c Config, out io.Writer, logger blog.Logger, clk clock.Clock, stats prometheus.Registerer) (*Revoker, error) {
+	dbMap, err := connectDB(c, logger)
+	if err != nil {
+		return nil, err
+	}
+	dbMap.TraceOn("", explainLogger{out})
+	r := New(nil, nil, dbMap, logger, clk, stats)
+	r.explain = out
+	return r, nil
+}
+
+// explainLogger writes the queries traced by gorp for NewExplainer.
+type explainLogger struct {
+	out io.Writer
+}
+
+// Printf implements gorp.GorpLogger. gorp traces each query with the format
+// "%s%s [%s] (%v)", for the prefix, query, bound values and duration; anything
+// else is written as is.
+func (l explainLogger) Printf(format string, v ...interface{}) {
+	if format == "%s%s [%s] (%v)" && len(v) == 4 {
+		fmt.Fprintf(l.out, "%s;\n-- bound values: %s\n\n", strings.TrimSpace(fmt.Sprint(v[1])), v[2])
+		return
+	}
+	fmt.Fprintf(l.out, format+"\n", v...)
+}
+
+// explainArgs formats bound values like gorp's trace does, except that reason
+// codes are shown as the integers stored rather than their names.
+func explainArgs(args []interface{}) string {
+	var formatted []string
+	for i, a := range args {
+		if v, ok := a.(driver.Valuer); ok {
+			if value, err := v.Value(); err == nil {
+				a = value
+			}
+		}
+		switch v := a.(type) {
+		case string:
+			a = fmt.Sprintf("%q", v)
+		case []byte:
+			a = fmt.Sprintf("%q", v)
+		case revocation.Reason:
+			a = int(v)
+		}
+		formatted = append(formatted, fmt.Sprintf("%d:%v", i+1, a))
+	}
+	return strings.Join(formatted, " ")
+}
+
+// explainRevocation writes the statement the SA would execute when the RA, or
+// the admin-revoker itself with opts.DirectSA, asks it to revoke the
+// certificate with the provided serial. With force, it writes the statement
+// changing the reason of the certificate, already revoked at revokedAt,
+// instead. The revokedComment column is included if the StoreRevocationComment
+// feature is enabled in the admin-revoker's config, which should match the
+// SA's.
+func (r *Revoker) explainRevocation(serial string, reasonCode revocation.Reason, opts Options, force bool, revokedAt time.Time) error {
+	operator, err := opts.operator()
+	if err != nil {
+		return err
+	}
+	comment := revocationComment(operator, opts.Comment)
+	reason := int64(reasonCode)
+	date := opts.revocationDate(r.clk).UnixNano()
+	if force && opts.RevocationDate.IsZero() {
+		date = revokedAt.UnixNano()
+	}
+	response := []byte(explainedResponse)
+	action := "the RA revokes the certificate"
+	if force {
+		action = "the RA changes the certificate's revocation reason"
+	}
+	if opts.DirectSA {
+		response = []byte{}
+		action = "revoking the certificate directly through it"
+		if force {
+			action = "changing the certificate's revocation reason directly through it"
+		}
+	}
+	req := &sapb.RevokeCertificateRequest{
+		Serial:   &serial,
+		Reason:   &reason,
+		Date:     &date,
+		Response: response,
+		Comment:  &comment,
+	}
+	query, args := sa.RevokeCertificateQuery(req)
+	if force {
+		query, args = sa.UpdateRevokedCertificateQuery(req, r.clk.Now())
+	}
+	header := "Executed by the SA when " + action
+	_, err = fmt.Fprintf(r.explain, "-- %s:\n%s;\n-- bound values: %s\n\n",
+		header, strings.TrimSpace(query), explainArgs(args))
+	return err
+}
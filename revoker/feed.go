@@ -0,0 +1,146 @@
+package revoker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/letsencrypt/boulder/cmd"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// FeedConfig configures the HTTP client used by feed-revoke to fetch a feed
+// of serials to revoke.
+type FeedConfig struct {
+	// TLS, if set, provides the client certificate presented to the feed and
+	// the CA used to verify it. Otherwise the system roots are used and no
+	// client certificate is presented.
+	TLS *cmd.TLSConfig
+	// Timeout bounds each fetch of the feed. Defaults to 30 seconds.
+	Timeout cmd.ConfigDuration
+}
+
+// defaultFeedTimeout is used when FeedConfig.Timeout is zero.
+const defaultFeedTimeout = 30 * time.Second
+
+// maxFeedSize bounds the size of the feed read, so that a misbehaving feed
+// can't exhaust memory.
+const maxFeedSize = 10 << 20
+
+// Feed fetches the serials of certificates to revoke, e.g. those with keys
+// known to be compromised, from a URL returning a JSON array of hex serials.
+type Feed struct {
+	url    string
+	client *http.Client
+}
+
+// NewFeed returns a Feed fetching url with an HTTP client configured by c.
+func NewFeed(url string, c FeedConfig) (*Feed, error) {
+	timeout := c.Timeout.Duration
+	if timeout == 0 {
+		timeout = defaultFeedTimeout
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if c.TLS != nil {
+		tlsConfig, err := loadTLSConfig(c.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &Feed{
+		url:    url,
+		client: &http.Client{Transport: transport, Timeout: timeout},
+	}, nil
+}
+
+// Fetch returns the serials currently in the feed, as given by it.
+func (f *Feed) Fetch(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequest("GET", f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching feed %s: unexpected status %q", f.url, resp.Status)
+	}
+	var serials []string
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxFeedSize)).Decode(&serials)
+	if err != nil {
+		return nil, fmt.Errorf("decoding feed %s: %s", f.url, err)
+	}
+	return serials, nil
+}
+
+// RevokeFeed fetches the feed and revokes each serial in it which isn't yet
+// recorded in state, the serials processed by previous runs, in turn. Each
+// serial is recorded in state once it has been revoked, or found to be already
+// revoked. Serials which aren't found are also recorded, with a warning, since
+// a feed may include certificates which weren't issued by Boulder; they aren't
+// counted as failures. Other failures aren't recorded, so the serials are
+// attempted again by the next run, and don't stop the others from being
+// revoked. An error is only returned if fetching the feed fails or the
+// revocation is stopped.
+func (r *Revoker) RevokeFeed(ctx context.Context, feed *Feed, state *Checkpoint, reasonCode revocation.Reason, opts Options) (BatchResult, error) {
+	serials, err := feed.Fetch(ctx)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	return r.revokeFeedSerials(ctx, dbLookup{r.dbMap}, serials, state, reasonCode, opts)
+}
+
+// revokeFeedSerials revokes the serials fetched from a feed, as described by
+// RevokeFeed, finding them with certs.
+func (r *Revoker) revokeFeedSerials(ctx context.Context, certs certLookup, serials []string, state *Checkpoint, reasonCode revocation.Reason, opts Options) (BatchResult, error) {
+	var result BatchResult
+	var fresh []string
+	seen := make(map[string]bool)
+	for _, s := range serials {
+		serial, err := revocation.NormalizeSerial(s)
+		if err != nil {
+			result.Failures = append(result.Failures, SerialError{s, berrors.MalformedError("invalid serial: %s", err)})
+			continue
+		}
+		if !state.contains(serial) && !seen[serial] {
+			seen[serial] = true
+			fresh = append(fresh, serial)
+		}
+	}
+	r.log.Infof("Feed contains %d serials, %d of them not yet processed", len(serials), len(fresh))
+	opts.Progress.SetTotal(len(fresh))
+
+	opts.skipNotFound = true
+	for _, serial := range fresh {
+		if opts.stopped() {
+			return result, ErrInterrupted
+		}
+		err := r.revokeBySerial(ctx, certs, serial, reasonCode, opts)
+		if err == errSkippedNotFound {
+			r.log.Warningf("Serial %s from feed not found, recording it as processed", serial)
+		}
+		if result.add(serial, err) != nil {
+			continue
+		}
+		if opts.DryRun {
+			continue
+		}
+		err = state.record(serial)
+		if err != nil {
+			return result, fmt.Errorf("recording serial %s in state file: %s", serial, err)
+		}
+	}
+	return result, nil
+}
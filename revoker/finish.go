@@ -0,0 +1,138 @@
+package revoker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/jmhodges/clock"
+
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// Finisher runs what must be done once an admin-revoker command has finished,
+// however it exits: releasing its lock, writing its summary and pushing its
+// metrics. Each is only done if it was set up. The zero value does nothing.
+type Finisher struct {
+	mu        sync.Mutex
+	lock      *Lock
+	summarize func(code int, reason string)
+	push      func()
+}
+
+// HoldLock has Finish release the lock.
+func (f *Finisher) HoldLock(l *Lock) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lock = l
+}
+
+// SummarizeTo has Finish write the summary of the command described by base,
+// counting the outcomes in tally, to path, as WriteRunSummary does. The
+// duration is measured from now, according to clk. A failure to write the
+// summary is reported on stderr, but doesn't change the exit code.
+func (f *Finisher) SummarizeTo(path string, base RunSummary, tally *RunTally, clk clock.Clock) {
+	start := clk.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.summarize = func(code int, reason string) {
+		s := NewRunSummary(base, tally.Summary(), clk.Since(start), code, reason)
+		if operator, err := (Options{Operator: s.Operator}).operator(); err == nil {
+			s.Operator = operator
+		}
+		err := WriteRunSummary(path, s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't write the summary to %s: %s\n", path, err)
+		}
+	}
+}
+
+// PushTo has Finish push the command's metrics with the pusher, so that
+// failures are counted too. A failure to push them is logged.
+func (f *Finisher) PushTo(p *Pusher, logger blog.Logger) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.push = func() {
+		err := p.Push(context.Background())
+		if err != nil {
+			logger.Warningf("Couldn't push metrics to the Pushgateway: %s", err)
+		}
+	}
+}
+
+// Finish releases the command's lock, writes the summary of the command
+// exiting with code for the reason, and pushes its metrics.
+func (f *Finisher) Finish(code int, reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lock.Release()
+	if f.summarize != nil {
+		f.summarize(code, reason)
+	}
+	if f.push != nil {
+		f.push()
+	}
+}
+
+// Stopper closes a channel at most once, so that bulk revocations can be
+// stopped gracefully, through Options.Stop, by either a signal or a limit on
+// their runtime.
+type Stopper struct {
+	once sync.Once
+	ch   chan struct{}
+	// exhausted is set once the runtime watched by WatchRuntime has passed.
+	exhausted int32
+}
+
+// NewStopper returns a Stopper which hasn't been stopped.
+func NewStopper() *Stopper {
+	return &Stopper{ch: make(chan struct{})}
+}
+
+// C returns the channel closed by Stop, to be used as Options.Stop.
+func (s *Stopper) C() <-chan struct{} {
+	return s.ch
+}
+
+// Stop closes the channel, if it hasn't been closed already.
+func (s *Stopper) Stop() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+// HandleSignals installs a handler for SIGINT and SIGTERM, which calls Stop on
+// the first signal so that bulk revocations stop gracefully. abort is called
+// on the second signal, to cancel revocations in progress.
+func (s *Stopper) HandleSignals(logger blog.Logger, abort func()) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		logger.Warningf("Caught %s, finishing revocations in progress. Send it again to abort them", sig)
+		s.Stop()
+		sig = <-sigChan
+		logger.Warningf("Caught %s again, aborting revocations in progress", sig)
+		abort()
+	}()
+}
+
+// WatchRuntime calls Stop once maxRuntime has passed, as the first signal
+// does, so that a run can be bounded to a maintenance window and resumed from
+// its checkpoint in the next one.
+func (s *Stopper) WatchRuntime(logger blog.Logger, maxRuntime time.Duration) *time.Timer {
+	return time.AfterFunc(maxRuntime, func() {
+		atomic.StoreInt32(&s.exhausted, 1)
+		logger.Warningf("--max-runtime of %s exhausted, finishing revocations in progress and starting no more", maxRuntime)
+		s.Stop()
+	})
+}
+
+// RuntimeExhausted returns true if the Stopper was stopped by WatchRuntime,
+// rather than by a signal.
+func (s *Stopper) RuntimeExhausted() bool {
+	return s != nil && atomic.LoadInt32(&s.exhausted) != 0
+}
@@ -0,0 +1,58 @@
+package revoker
+
+import (
+	"fmt"
+	"time"
+)
+
+// crlEntrySize approximates the size in bytes of a single entry in a CRL: its
+// serial, revocation date and reasonCode extension, with their DER overhead.
+const crlEntrySize = 40
+
+// impactSoonWindow is how soon a certificate must expire for it to count as
+// expiring soon in a RevocationImpact.
+const impactSoonWindow = 7 * 24 * time.Hour
+
+// RevocationImpact estimates the load revoking a set of certificates would put
+// on the CRLs and the OCSP responder, so that a bulk revocation can be planned.
+// Certificates which have expired, or are already revoked, are already off or
+// on the revocation lists, and so aren't counted.
+type RevocationImpact struct {
+	// Unexpired is the number of certificates which would be added to the
+	// revocation lists, each of which also has its OCSP response replaced.
+	Unexpired int `json:"unexpired"`
+	// ExpiringSoon is how many of those expire within a week, and so only stay
+	// listed briefly.
+	ExpiringSoon int `json:"expiringSoon"`
+	// LastExpiry is when the last of them expires, after which none need to be
+	// listed. It is zero if Unexpired is.
+	LastExpiry time.Time `json:"lastExpiry"`
+	// CRLBytes estimates how much the CRLs would grow.
+	CRLBytes int `json:"crlBytes"`
+}
+
+// add counts a certificate expiring at notAfter, unless it has expired by now
+// or is already revoked.
+func (ri *RevocationImpact) add(notAfter time.Time, revoked bool, now time.Time) {
+	if revoked || !notAfter.After(now) {
+		return
+	}
+	ri.Unexpired++
+	ri.CRLBytes += crlEntrySize
+	if notAfter.Sub(now) <= impactSoonWindow {
+		ri.ExpiringSoon++
+	}
+	if notAfter.After(ri.LastExpiry) {
+		ri.LastExpiry = notAfter
+	}
+}
+
+// String describes the impact for logging.
+func (ri RevocationImpact) String() string {
+	if ri.Unexpired == 0 {
+		return "no unexpired certificates would be added to the revocation lists"
+	}
+	return fmt.Sprintf("%d unexpired certificates would be added to the revocation lists, about %d bytes of CRL entries, "+
+		"%d of them expiring within 7 days and the last at %s",
+		ri.Unexpired, ri.CRLBytes, ri.ExpiringSoon, ri.LastExpiry.Format(time.RFC3339))
+}
@@ -0,0 +1,128 @@
+package revoker
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+)
+
+// IssuerMatcher identifies the intermediate which issued certificates, either
+// by its subject key identifier, as given in the authority key identifier
+// extension of the certificates it issued, or by its common name. The
+// certificates table doesn't record the issuer, so it is matched against the
+// parsed DER of each certificate.
+type IssuerMatcher struct {
+	keyID []byte
+	name  string
+}
+
+// ParseIssuer parses an issuer given as either a hex key identifier, with or
+// without colons, or a common name. Since a common name may look like hex, an
+// argument which parses as a key identifier also matches issuers with that
+// common name.
+func ParseIssuer(s string) (IssuerMatcher, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return IssuerMatcher{}, berrors.MalformedError("issuer must not be empty")
+	}
+	m := IssuerMatcher{name: s}
+	keyID, err := hex.DecodeString(strings.Replace(s, ":", "", -1))
+	if err == nil {
+		m.keyID = keyID
+	}
+	return m, nil
+}
+
+// Matches returns true if cert was issued by the issuer.
+func (m IssuerMatcher) Matches(cert *x509.Certificate) bool {
+	if m.keyID != nil && bytes.Equal(cert.AuthorityKeyId, m.keyID) {
+		return true
+	}
+	return cert.Issuer.CommonName == m.name
+}
+
+// String describes the issuer for logging.
+func (m IssuerMatcher) String() string {
+	if m.keyID != nil {
+		return fmt.Sprintf("issuer with key ID %x or common name %q", m.keyID, m.name)
+	}
+	return fmt.Sprintf("issuer with common name %q", m.name)
+}
+
+// issuerPageSize is the number of certificates selected at a time when
+// iterating over the certificates issued within a window.
+var issuerPageSize = 1000
+
+// forEachIssuerSerial calls f with the serial of each unexpired certificate
+// issued within the window by the issuer, stopping at the first error. The
+// certificates issued within the window are selected a page at a time ordered
+// by ID, and those issued by other issuers are skipped and their number logged.
+func (r *Revoker) forEachIssuerSerial(issuer IssuerMatcher, window IssuedWindow, f func(serial string) error) error {
+	where := "WHERE id > :id AND expires > :now"
+	args := map[string]interface{}{"now": r.clk.Now(), "limit": issuerPageSize}
+	if !window.After.IsZero() {
+		where += " AND issued > :after"
+		args["after"] = window.After
+	}
+	if !window.Before.IsZero() {
+		where += " AND issued < :before"
+		args["before"] = window.Before
+	}
+	var skipped int
+	var lastID int64
+	for {
+		args["id"] = lastID
+		certs, err := sa.SelectCertificates(r.dbMap, where+" ORDER BY id LIMIT :limit", args)
+		if err != nil {
+			return err
+		}
+		for _, c := range certs {
+			cert, err := x509.ParseCertificate(c.DER)
+			if err != nil {
+				return fmt.Errorf("parsing certificate %s: %s", c.Serial, err)
+			}
+			if !issuer.Matches(cert) {
+				skipped++
+				continue
+			}
+			err = f(c.Serial)
+			if err != nil {
+				return err
+			}
+		}
+		if len(certs) < issuerPageSize {
+			break
+		}
+		lastID = certs[len(certs)-1].ID
+	}
+	if skipped > 0 {
+		r.log.Infof("Skipping %d certificates issued %s by other issuers", skipped, window)
+	}
+	return nil
+}
+
+// RevokeIssuer revokes all unexpired certificates issued within the window by
+// the issuer, using parallelism concurrent workers outside of any transaction.
+// A failure to revoke one certificate doesn't stop the others from being
+// revoked; all failures are collected in the result instead. An error is only
+// returned if selecting the certificates fails or the revocation is stopped,
+// in which case the result covers those selected before then.
+func (r *Revoker) RevokeIssuer(ctx context.Context, issuer IssuerMatcher, window IssuedWindow, reasonCode revocation.Reason, opts Options, parallelism int, cp *Checkpoint) (BatchResult, error) {
+	return r.revokeSerialsParallel(ctx, func(work chan<- string) error {
+		return r.forEachIssuerSerial(issuer, window, func(serial string) error {
+			select {
+			case work <- serial:
+				return nil
+			case <-opts.Stop:
+				return ErrInterrupted
+			}
+		})
+	}, reasonCode, opts, parallelism, cp)
+}
@@ -0,0 +1,104 @@
+package revoker
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"golang.org/x/crypto/ocsp"
+)
+
+// CertificateBySerial fetches and parses the certificate with the provided
+// serial.
+func (r *Revoker) CertificateBySerial(serial string) (*x509.Certificate, error) {
+	serial, err := revocation.NormalizeSerial(serial)
+	if err != nil {
+		return nil, berrors.MalformedError("invalid serial: %s", err)
+	}
+	certObj, err := sa.SelectCertificate(r.dbMap, "WHERE serial = ?", serial)
+	if err != nil {
+		if db.IsNoRows(err) {
+			return nil, berrors.NotFoundError("certificate with serial %q not found", serial)
+		}
+		return nil, err
+	}
+	return x509.ParseCertificate(certObj.DER)
+}
+
+// KeyBlockResult summarizes the outcome of blocking a public key.
+type KeyBlockResult struct {
+	// KeyHash is the SHA-256 hash of the key's SubjectPublicKeyInfo, as stored
+	// in the blockedKeys table.
+	KeyHash []byte
+	// Certificates and Registrations count the distinct certificates using the
+	// key and the registrations they were issued to.
+	Certificates  int
+	Registrations int
+	Result        BatchResult
+}
+
+// BlockKey adds the public key of cert to the blockedKeys table, so that it
+// can't be used for new certificates, and revokes every certificate using the
+// key for keyCompromise. Certificates are found using the keyHashToSerial
+// table, so only those issued while the StoreKeyHashes feature was enabled are
+// revoked. Each revocation is performed outside of any transaction, and a
+// failure to revoke one certificate doesn't stop the others from being
+// revoked; all failures are collected in the result instead.
+func (r *Revoker) BlockKey(ctx context.Context, cert *x509.Certificate, opts Options) (KeyBlockResult, error) {
+	digest, err := core.KeyDigest(cert.PublicKey)
+	if err != nil {
+		return KeyBlockResult{}, err
+	}
+	result := KeyBlockResult{KeyHash: digest[:]}
+
+	if opts.DryRun {
+		r.log.Infof("Would block key %x", result.KeyHash)
+	} else {
+		operator, err := opts.operator()
+		if err != nil {
+			return result, err
+		}
+		comment := fmt.Sprintf("blocked by %s", operator)
+		if opts.Comment != "" {
+			comment = fmt.Sprintf("%s: %s", comment, opts.Comment)
+		}
+		added := r.clk.Now().UnixNano()
+		source := "admin-revoker"
+		_, err = r.sac.AddBlockedKey(ctx, &sapb.AddBlockedKeyRequest{
+			KeyHash: result.KeyHash,
+			Added:   &added,
+			Source:  &source,
+			Comment: &comment,
+		})
+		if err != nil {
+			return result, err
+		}
+		r.log.AuditInfof("Blocked key %x: %s", result.KeyHash, comment)
+	}
+
+	certs, err := sa.SelectCertificatesByPublicKeyHash(r.dbMap, result.KeyHash)
+	if err != nil {
+		return result, err
+	}
+	regIDs := make(map[int64]bool)
+	for _, c := range certs {
+		regIDs[c.RegistrationID] = true
+	}
+	result.Certificates = len(certs)
+	result.Registrations = len(regIDs)
+
+	for _, c := range certs {
+		if opts.stopped() {
+			return result, ErrInterrupted
+		}
+		err := r.revokeBySerial(ctx, dbLookup{r.dbMap}, c.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+		_ = result.Result.add(c.Serial, err)
+	}
+	return result, nil
+}
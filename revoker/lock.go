@@ -0,0 +1,160 @@
+package revoker
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/boulder/cmd"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// LockConfig configures the advisory lock each admin-revoker run writing to
+// the database holds, so that two runs against the same target can't
+// interleave.
+type LockConfig struct {
+	// KeyPrefix is prepended to the key of each lock. Defaults to
+	// "admin-revoker".
+	KeyPrefix string
+	// Timeout is how long to wait for a lock held by another run before
+	// failing. Zero, the default, fails immediately.
+	Timeout cmd.ConfigDuration
+}
+
+const defaultLockKeyPrefix = "admin-revoker"
+
+// maxLockKeyLen is the longest lock name MySQL accepts.
+const maxLockKeyLen = 64
+
+// LockKey returns the key of the lock held while running command against the
+// target, the positional arguments identifying what it revokes. The key
+// includes them as given if it fits within MySQL's limit on lock names, and is
+// otherwise truncated and suffixed with a hash of the whole key, so that
+// distinct targets still get distinct keys.
+func LockKey(c LockConfig, command string, target []string) string {
+	prefix := c.KeyPrefix
+	if prefix == "" {
+		prefix = defaultLockKeyPrefix
+	}
+	key := fmt.Sprintf("%s:%s:%s", prefix, command, strings.Join(target, ","))
+	if len(key) <= maxLockKeyLen {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("%.31s:%x", key, sum[:16])
+}
+
+// LockHeldError is returned by AcquireLock when another run holds the lock.
+type LockHeldError struct {
+	Key string
+}
+
+func (e LockHeldError) Error() string {
+	return fmt.Sprintf("another revocation is in progress: lock %q is held by another run", e.Key)
+}
+
+// lockSession is a database session in which advisory locks are held. It is
+// implemented by dbLockSession, and is an interface so that locking can be
+// tested without a database.
+type lockSession interface {
+	// getLock tries to acquire the lock for up to timeout, returning false if
+	// it is still held by another session.
+	getLock(ctx context.Context, key string, timeout time.Duration) (bool, error)
+	releaseLock(key string) error
+	close() error
+}
+
+// dbLockSession holds locks with MySQL's GET_LOCK on a single connection, since
+// they are held by the connection's session rather than the pool. A lock is
+// also released if the connection closes, e.g. because the process died.
+type dbLockSession struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+func (s dbLockSession) getLock(ctx context.Context, key string, timeout time.Duration) (bool, error) {
+	var got sql.NullInt64
+	// GET_LOCK's timeout is in whole seconds.
+	seconds := int64(math.Ceil(timeout.Seconds()))
+	err := s.conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", key, seconds).Scan(&got)
+	if err != nil {
+		return false, err
+	}
+	if !got.Valid {
+		return false, fmt.Errorf("GET_LOCK(%q) failed", key)
+	}
+	return got.Int64 == 1, nil
+}
+
+func (s dbLockSession) releaseLock(key string) error {
+	_, err := s.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", key)
+	return err
+}
+
+func (s dbLockSession) close() error {
+	err := s.conn.Close()
+	if dbErr := s.db.Close(); err == nil {
+		err = dbErr
+	}
+	return err
+}
+
+// Lock is an advisory lock held by a run in the database.
+type Lock struct {
+	key     string
+	session lockSession
+	log     blog.Logger
+	once    sync.Once
+}
+
+// AcquireLock connects to the database described by the config and acquires
+// the lock with the key, waiting up to c.Lock.Timeout for another run holding
+// it to release it. It returns a LockHeldError if the lock is still held then.
+func AcquireLock(ctx context.Context, c Config, key string, logger blog.Logger) (*Lock, error) {
+	dbMap, err := connectDB(c, logger)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dbMap.Db.Conn(ctx)
+	if err != nil {
+		_ = dbMap.Db.Close()
+		return nil, DatabaseError{err}
+	}
+	return acquireLock(ctx, dbLockSession{dbMap.Db, conn}, key, c.Lock.Timeout.Duration, logger)
+}
+
+func acquireLock(ctx context.Context, session lockSession, key string, timeout time.Duration, logger blog.Logger) (*Lock, error) {
+	got, err := session.getLock(ctx, key, timeout)
+	if err != nil {
+		_ = session.close()
+		return nil, DatabaseError{err}
+	}
+	if !got {
+		_ = session.close()
+		return nil, LockHeldError{key}
+	}
+	logger.Infof("Acquired lock %q", key)
+	return &Lock{key: key, session: session, log: logger}, nil
+}
+
+// Release releases the lock. It may be called more than once, and on a nil
+// *Lock, so that it can both be deferred and called before exiting. A failure
+// to release the lock is logged, since it is released anyway once the
+// connection holding it closes.
+func (l *Lock) Release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		err := l.session.releaseLock(l.key)
+		if err != nil {
+			l.log.Warningf("Couldn't release lock %q, it will be released when the connection closes: %s", l.key, err)
+		}
+		_ = l.session.close()
+	})
+}
@@ -0,0 +1,189 @@
+package revoker
+
+import (
+	"crypto/x509"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+)
+
+// certLookup finds the certificates to be revoked and their current status. It
+// is implemented over a database connection or transaction by dbLookup, and is
+// an interface so that revocation can be tested without a database.
+type certLookup interface {
+	// certificate returns the certificate with the serial, or a NotFound error
+	// if there is none.
+	certificate(serial string) (core.Certificate, error)
+	// precertificate returns the precertificate with the serial, or a NotFound
+	// error if there is none.
+	precertificate(serial string) (core.Certificate, error)
+	// status returns the OCSP status of the certificate with the serial.
+	status(serial string) (core.OCSPStatus, error)
+	// previousRevocation returns the reason and date recorded for the
+	// revocation of the certificate with the serial, which are only meaningful
+	// if it is revoked.
+	previousRevocation(serial string) (revocation.Reason, time.Time, error)
+	// regCertificates returns up to limit of the certificates associated with
+	// a registration whose serials sort after the provided serial, ordered by
+	// serial. Only their serials and issued times are set.
+	regCertificates(regID int64, after string, limit int) ([]core.Certificate, error)
+}
+
+// dbLookup is a certLookup which reads from the certificates, precertificates
+// and certificateStatus tables.
+type dbLookup struct {
+	dbMap db.Executor
+}
+
+func (l dbLookup) certificate(serial string) (core.Certificate, error) {
+	certObj, err := sa.SelectCertificate(l.dbMap, "WHERE serial = ?", serial)
+	if db.IsNoRows(err) {
+		return core.Certificate{}, berrors.NotFoundError("certificate with serial %q not found", serial)
+	}
+	return certObj, err
+}
+
+func (l dbLookup) precertificate(serial string) (core.Certificate, error) {
+	precert, err := sa.SelectPrecertificate(l.dbMap, serial)
+	if db.IsNoRows(err) {
+		return core.Certificate{}, berrors.NotFoundError("precertificate with serial %q not found", serial)
+	}
+	return precert, err
+}
+
+func (l dbLookup) status(serial string) (core.OCSPStatus, error) {
+	status, err := sa.SelectCertificateStatus(l.dbMap, "WHERE serial = ?", serial)
+	if err != nil {
+		return "", err
+	}
+	return status.Status, nil
+}
+
+func (l dbLookup) previousRevocation(serial string) (revocation.Reason, time.Time, error) {
+	status, err := sa.SelectCertificateStatus(l.dbMap, "WHERE serial = ?", serial)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return status.RevokedReason, status.RevokedDate, nil
+}
+
+func (l dbLookup) regCertificates(regID int64, after string, limit int) ([]core.Certificate, error) {
+	var certs []core.Certificate
+	_, err := l.dbMap.Select(
+		&certs,
+		`SELECT serial, issued FROM certificates
+		WHERE registrationID = :regID AND serial > :after
+		ORDER BY serial LIMIT :limit`,
+		map[string]interface{}{"regID": regID, "after": after, "limit": limit},
+	)
+	return certs, err
+}
+
+// What was found for a serial by findCertificate, and so revoked.
+const (
+	kindCertificate    = "certificate"
+	kindPrecertificate = "precertificate"
+	kindBoth           = "certificate and precertificate"
+)
+
+// findCertificate returns the certificate with the serial, along with which of
+// a final certificate and a precertificate exist for it. A precertificate and
+// its final certificate share a serial, and so a certificateStatus row: one
+// revocation revokes both. If includePrecert is false, only final certificates
+// are looked for. Otherwise the precertificate is returned if no final
+// certificate was issued for it.
+func findCertificate(certs certLookup, serial string, includePrecert bool) (core.Certificate, string, error) {
+	certObj, err := certs.certificate(serial)
+	if !includePrecert {
+		return certObj, kindCertificate, err
+	}
+	if berrors.Is(err, berrors.NotFound) {
+		precert, err := certs.precertificate(serial)
+		if berrors.Is(err, berrors.NotFound) {
+			return core.Certificate{}, "", berrors.NotFoundError("no certificate or precertificate with serial %q found", serial)
+		}
+		return precert, kindPrecertificate, err
+	}
+	if err != nil {
+		return core.Certificate{}, "", err
+	}
+	_, err = certs.precertificate(serial)
+	if berrors.Is(err, berrors.NotFound) {
+		return certObj, kindCertificate, nil
+	}
+	if err != nil {
+		return core.Certificate{}, "", err
+	}
+	return certObj, kindBoth, nil
+}
+
+// FindCertificate fetches and parses the certificate with the serial, as
+// revoking it would, returning which of a certificate and a precertificate was
+// found. If includePrecert is false, only final certificates are looked for.
+func (r *Revoker) FindCertificate(serial string, includePrecert bool) (*x509.Certificate, string, error) {
+	serial, err := revocation.NormalizeSerial(serial)
+	if err != nil {
+		return nil, "", berrors.MalformedError("invalid serial: %s", err)
+	}
+	certObj, kind, err := findCertificate(dbLookup{r.dbMap}, serial, includePrecert)
+	if err != nil {
+		return nil, "", err
+	}
+	cert, err := x509.ParseCertificate(certObj.DER)
+	if err != nil {
+		return nil, "", err
+	}
+	return cert, kind, nil
+}
+
+// SerialPresence records whether a serial looked up by FindSerials was found.
+type SerialPresence struct {
+	// Serial is the serial as given.
+	Serial string
+	// Normalized is the serial as looked up, which is empty if it is
+	// malformed.
+	Normalized string
+	// Kind is which of a certificate and a precertificate were found, and is
+	// empty if neither was.
+	Kind string
+	// Err is set if the serial is malformed.
+	Err error
+}
+
+// Found returns whether a certificate or precertificate was found.
+func (sp SerialPresence) Found() bool {
+	return sp.Kind != ""
+}
+
+// FindSerials normalizes and looks up each of the serials, as a bulk
+// revocation would, without revoking anything, so that a serial file can be
+// checked before it is revoked. It only reads from the database, outside of any
+// transaction. Malformed and missing serials are recorded in the result; an
+// error is only returned if a lookup fails for another reason.
+func (r *Revoker) FindSerials(serials []string, includePrecert bool) ([]SerialPresence, error) {
+	return findSerials(dbLookup{r.dbMap}, serials, includePrecert)
+}
+
+func findSerials(certs certLookup, serials []string, includePrecert bool) ([]SerialPresence, error) {
+	var results []SerialPresence
+	for _, s := range serials {
+		serial, err := revocation.NormalizeSerial(s)
+		if err != nil {
+			results = append(results, SerialPresence{Serial: s, Err: berrors.MalformedError("invalid serial: %s", err)})
+			continue
+		}
+		found := SerialPresence{Serial: s, Normalized: serial}
+		_, kind, err := findCertificate(certs, serial, includePrecert)
+		if err == nil {
+			found.Kind = kind
+		} else if !berrors.Is(err, berrors.NotFound) {
+			return results, err
+		}
+		results = append(results, found)
+	}
+	return results, nil
+}
@@ -0,0 +1,159 @@
+package revoker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
+	blog "github.com/letsencrypt/boulder/log"
+	bmail "github.com/letsencrypt/boulder/mail"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// NotifyConfig configures notifying the contacts of a registration by email
+// once reg-revoke --notify has revoked its certificates.
+type NotifyConfig struct {
+	cmd.SMTPConfig
+	// From is the address notifications are sent from.
+	From string
+	// Subject is a text/template for the subject of each notification,
+	// executed with a RevocationNotice. Defaults to defaultNotifySubject.
+	Subject string
+	// EmailTemplate is the path to a text/template for the body of each
+	// notification, executed with a RevocationNotice.
+	EmailTemplate string
+}
+
+const defaultNotifySubject = "Certificates for your Let's Encrypt account {{.RegistrationID}} have been revoked"
+
+// How long the mailer waits before reconnecting to the SMTP server after a
+// failure, doubling up to the maximum.
+const (
+	notifyReconnectBase = time.Second
+	notifyReconnectMax  = time.Minute
+)
+
+// RevocationNotice is the data the subject and body templates of a
+// notification are executed with.
+type RevocationNotice struct {
+	RegistrationID int64
+	// Serials are the serials of the certificates revoked.
+	Serials []string
+	Reason  string
+	// Comment is the --comment given to the admin-revoker, if any.
+	Comment string
+}
+
+// Notifier emails the contacts of a registration whose certificates were
+// revoked.
+type Notifier struct {
+	mailer  bmail.Mailer
+	subject *template.Template
+	body    *template.Template
+	log     blog.Logger
+}
+
+// NewNotifier returns a Notifier sending mail as configured by c. With dryRun,
+// each message is logged rather than sent.
+func NewNotifier(c NotifyConfig, dryRun bool, logger blog.Logger, stats prometheus.Registerer) (*Notifier, error) {
+	if c.EmailTemplate == "" {
+		return nil, errors.New("notify.emailTemplate must be set")
+	}
+	body, err := ioutil.ReadFile(c.EmailTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("reading notification template: %s", err)
+	}
+	subject := c.Subject
+	if subject == "" {
+		subject = defaultNotifySubject
+	}
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return nil, fmt.Errorf("parsing notify.from address %q: %s", c.From, err)
+	}
+	var mailer bmail.Mailer
+	if dryRun {
+		mailer = bmail.NewDryRun(*from, logger)
+	} else {
+		password, err := c.PasswordConfig.Pass()
+		if err != nil {
+			return nil, fmt.Errorf("loading SMTP password: %s", err)
+		}
+		mailer = bmail.New(c.Server, c.Port, c.Username, password, nil, *from,
+			logger, stats, notifyReconnectBase, notifyReconnectMax)
+	}
+	return newNotifier(mailer, subject, string(body), logger)
+}
+
+func newNotifier(mailer bmail.Mailer, subject, body string, logger blog.Logger) (*Notifier, error) {
+	subjectTmpl, err := template.New("notify-subject").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("parsing notification subject template: %s", err)
+	}
+	bodyTmpl, err := template.New("notify-email").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing notification template: %s", err)
+	}
+	return &Notifier{mailer: mailer, subject: subjectTmpl, body: bodyTmpl, log: logger}, nil
+}
+
+// emailContacts returns the addresses of the mailto: contacts of reg.
+func emailContacts(reg core.Registration) []string {
+	if reg.Contact == nil {
+		return nil
+	}
+	var emails []string
+	for _, contact := range *reg.Contact {
+		parsed, err := url.Parse(contact)
+		if err == nil && parsed.Scheme == "mailto" {
+			emails = append(emails, parsed.Opaque)
+		}
+	}
+	return emails
+}
+
+// NotifyRevoked emails the contacts of reg that the certificates with the
+// serials were revoked with the reason. A registration without an email
+// contact isn't notified, which isn't an error.
+func (n *Notifier) NotifyRevoked(reg core.Registration, serials []string, reasonCode revocation.Reason, comment string) error {
+	emails := emailContacts(reg)
+	if len(emails) == 0 {
+		n.log.Infof("Registration %d has no email contact, not notifying it", reg.ID)
+		return nil
+	}
+	notice := RevocationNotice{
+		RegistrationID: reg.ID,
+		Serials:        serials,
+		Reason:         reasonCode.String(),
+		Comment:        comment,
+	}
+	var subject, body bytes.Buffer
+	err := n.subject.Execute(&subject, notice)
+	if err != nil {
+		return fmt.Errorf("executing notification subject template: %s", err)
+	}
+	err = n.body.Execute(&body, notice)
+	if err != nil {
+		return fmt.Errorf("executing notification template: %s", err)
+	}
+	err = n.mailer.Connect()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = n.mailer.Close() }()
+	err = n.mailer.SendMail(emails, subject.String(), body.String())
+	if err != nil {
+		return err
+	}
+	n.log.Infof("Notified the %d email contacts of registration %d that %d certificates were revoked", len(emails), reg.ID, len(serials))
+	return nil
+}
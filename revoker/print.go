@@ -0,0 +1,341 @@
+package revoker
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// Confirm writes the prompt to out and reads a line from in, returning true
+// only if the operator typed "yes".
+func Confirm(in io.Reader, out io.Writer, prompt string) (bool, error) {
+	fmt.Fprintf(out, "%s Type \"yes\" to continue: ", prompt)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return strings.TrimSpace(answer) == "yes", nil
+}
+
+// PromptReason writes the reason codes accepted for admin revocation to out,
+// and reads the operator's choice of one by code or name from in, prompting
+// again until a valid reason is given.
+func PromptReason(in io.Reader, out io.Writer) (revocation.Reason, error) {
+	var codes revocationCodes
+	for k := range revocation.ReasonToString {
+		if revocation.IsValidAdminReason(k) {
+			codes = append(codes, k)
+		}
+	}
+	sort.Sort(codes)
+	tab := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tab, "CODE\tNAME\tDESCRIPTION")
+	for _, k := range codes {
+		description := revocation.ReasonDescription[k]
+		if note := revocation.AdminReasonNote(k); note != "" {
+			description = fmt.Sprintf("%s (%s)", description, note)
+		}
+		fmt.Fprintf(tab, "%d\t%s\t%s\n", k, k.String(), description)
+	}
+	err := tab.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		fmt.Fprint(out, "Reason code or name: ")
+		answer, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer != "" {
+			reason, parseErr := revocation.ParseAdminReason(answer)
+			if parseErr == nil {
+				return reason, nil
+			}
+			fmt.Fprintf(out, "Invalid reason: %s\n", parseErr)
+		}
+		if err == io.EOF {
+			return 0, errors.New("no reason selected")
+		}
+	}
+}
+
+// CertLister writes the certificates listed by the admin-revoker's reg-list
+// to out, either as an aligned table or as a JSON object per line.
+type CertLister struct {
+	json *json.Encoder
+	tab  *tabwriter.Writer
+}
+
+// NewCertLister returns a CertLister writing in the given format, which is
+// either "text" or "json".
+func NewCertLister(out io.Writer, format string) (*CertLister, error) {
+	switch format {
+	case "", "text":
+		tab := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tab, "SERIAL\tSTATUS\tISSUED\tEXPIRES\tCOMMON NAME\tNAMES")
+		return &CertLister{tab: tab}, nil
+	case "json":
+		return &CertLister{json: json.NewEncoder(out)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, must be \"text\" or \"json\"", format)
+}
+
+// Write writes a single certificate's description.
+func (cl *CertLister) Write(info CertificateInfo) error {
+	if cl.json != nil {
+		return cl.json.Encode(info)
+	}
+	_, err := fmt.Fprintf(cl.tab, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		info.Serial, info.Status, info.Issued.Format(time.RFC3339), info.Expires.Format(time.RFC3339),
+		info.CommonName, strings.Join(info.DNSNames, ","))
+	return err
+}
+
+// Flush writes any buffered output, which must be done once all certificates
+// have been written.
+func (cl *CertLister) Flush() error {
+	if cl.tab != nil {
+		return cl.tab.Flush()
+	}
+	return nil
+}
+
+// PrintSerialInfo writes the description of a certificate and its
+// registration looked up by serial-info to out, in the given format, which is
+// either "text" or "json".
+func PrintSerialInfo(out io.Writer, info SerialInfo, format string) error {
+	switch format {
+	case "", "text":
+	case "json":
+		return json.NewEncoder(out).Encode(info)
+	default:
+		return fmt.Errorf("unknown format %q, must be \"text\" or \"json\"", format)
+	}
+	contact := strings.Join(info.Contact, ", ")
+	if contact == "" {
+		contact = "none"
+	}
+	tab := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tab, "Serial:\t%s (%s)\n", info.Serial, info.Kind)
+	fmt.Fprintf(tab, "Subject:\t%s\n", info.Subject)
+	fmt.Fprintf(tab, "Names:\t%s\n", strings.Join(info.DNSNames, ", "))
+	fmt.Fprintf(tab, "Not before:\t%s\n", info.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(tab, "Not after:\t%s\n", info.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(tab, "Status:\t%s\n", info.Status)
+	fmt.Fprintf(tab, "Registration:\t%d\n", info.RegistrationID)
+	fmt.Fprintf(tab, "Contact:\t%s\n", contact)
+	fmt.Fprintf(tab, "Registration status:\t%s\n", info.RegistrationStatus)
+	return tab.Flush()
+}
+
+// PrintCertificate writes the details of the certificate serial-revoke
+// --print-cert is about to revoke to out, so that the operator can check it is
+// the one they meant. kind is which of a certificate and a precertificate it
+// is.
+func PrintCertificate(out io.Writer, cert *x509.Certificate, kind string) error {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	tab := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tab, "Serial:\t%s (%s)\n", core.SerialToString(cert.SerialNumber), kind)
+	fmt.Fprintf(tab, "Subject:\t%s\n", cert.Subject)
+	fmt.Fprintf(tab, "Names:\t%s\n", strings.Join(names, ", "))
+	fmt.Fprintf(tab, "Issuer:\t%s\n", cert.Issuer)
+	fmt.Fprintf(tab, "Not before:\t%s\n", cert.NotBefore.Format(time.RFC3339))
+	fmt.Fprintf(tab, "Not after:\t%s\n", cert.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(tab, "SHA-256 fingerprint:\t%x\n", sha256.Sum256(cert.Raw))
+	return tab.Flush()
+}
+
+// PrintSerialPresence writes a line to out for each serial looked up by
+// verify-serials, saying whether it was found, missing or malformed, followed by
+// a summary, and returns the numbers missing and malformed.
+func PrintSerialPresence(out io.Writer, results []SerialPresence) (int, int) {
+	var found, missing, malformed int
+	for _, sp := range results {
+		switch {
+		case sp.Err != nil:
+			malformed++
+			fmt.Fprintf(out, "malformed %s: %s\n", sp.Serial, sp.Err)
+		case sp.Found():
+			found++
+			fmt.Fprintf(out, "found     %s (%s)\n", sp.Normalized, sp.Kind)
+		default:
+			missing++
+			fmt.Fprintf(out, "missing   %s\n", sp.Normalized)
+		}
+	}
+	fmt.Fprintf(out, "%d serials: %d found, %d missing, %d malformed\n", len(results), found, missing, malformed)
+	return missing, malformed
+}
+
+// PrintRuns writes the runs listed by list-runs to out, in the given format,
+// which is either "text", as an aligned table, or "json", as a JSON array.
+func PrintRuns(out io.Writer, runs []RunRecord, format string) error {
+	switch format {
+	case "", "text":
+	case "json":
+		if runs == nil {
+			runs = []RunRecord{}
+		}
+		return json.NewEncoder(out).Encode(runs)
+	default:
+		return fmt.Errorf("unknown format %q, must be \"text\" or \"json\"", format)
+	}
+	tab := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tab, "RUN ID\tOPERATOR\tCOMMAND\tARGS\tSTARTED\tCOMPLETED\tREVOKED\tSKIPPED\tFAILED")
+	for _, run := range runs {
+		completed := "incomplete"
+		counts := "-\t-\t-"
+		if run.Completed != nil {
+			completed = run.Completed.UTC().Format(time.RFC3339)
+			counts = fmt.Sprintf("%d\t%d\t%d", run.Revoked, run.Skipped, run.Failed)
+		}
+		fmt.Fprintf(tab, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", run.RunID, run.Operator, run.Command, run.Args,
+			run.Started.UTC().Format(time.RFC3339), completed, counts)
+	}
+	return tab.Flush()
+}
+
+// This abstraction is needed so that we can use sort.Sort below
+type revocationCodes []revocation.Reason
+
+func (rc revocationCodes) Len() int           { return len(rc) }
+func (rc revocationCodes) Less(i, j int) bool { return rc[i] < rc[j] }
+func (rc revocationCodes) Swap(i, j int)      { rc[i], rc[j] = rc[j], rc[i] }
+
+// reasonJSON is the JSON representation of a single reason code printed by
+// list-reasons.
+type reasonJSON struct {
+	Code revocation.Reason `json:"code"`
+	Name string            `json:"name"`
+	// Description is only included with --describe.
+	Description string `json:"description,omitempty"`
+	// Accepted is true if the reason may be used with the admin-revoker.
+	Accepted bool   `json:"accepted"`
+	Note     string `json:"note,omitempty"`
+}
+
+// ANSI escape sequences used to highlight the reasons list-reasons prints which
+// aren't accepted for admin revocation, and the outcome of each check made by
+// check-config.
+const (
+	warningColor = "\x1b[33m"
+	okColor      = "\x1b[32m"
+	failColor    = "\x1b[31m"
+	resetColor   = "\x1b[0m"
+)
+
+// PrintConfigChecks writes a line for each of the checks made by
+// check-config to out, returning the number which failed. If color is true,
+// checks which passed are printed in green and those which failed in red.
+func PrintConfigChecks(out io.Writer, checks []ConfigCheck, color bool) int {
+	var failed int
+	for _, check := range checks {
+		line := fmt.Sprintf("[ OK ] %s", check.Component)
+		lineColor := okColor
+		if check.Err != nil {
+			failed++
+			line = fmt.Sprintf("[FAIL] %s: %s", check.Component, check.Err)
+			lineColor = failColor
+		}
+		if color {
+			line = lineColor + line + resetColor
+		}
+		fmt.Fprintln(out, line)
+	}
+	return failed
+}
+
+// ListReasons writes all revocation reason codes to out, sorted by code,
+// either as an aligned table or, if format is "json", as a JSON array. Each is
+// annotated with whether it is accepted for admin revocation, and why not or
+// why it is discouraged, and, if describe is true, with a description of what
+// it means. If color is true, the table rows of reasons which aren't accepted
+// are highlighted in a warning color.
+func ListReasons(out io.Writer, format string, describe bool, color bool) error {
+	var codes revocationCodes
+	for k := range revocation.ReasonToString {
+		codes = append(codes, k)
+	}
+	sort.Sort(codes)
+
+	switch format {
+	case "", "text":
+		// The table is aligned before being colored, since tabwriter would
+		// count the escape sequences towards the width of each cell.
+		var table strings.Builder
+		tab := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+		if describe {
+			fmt.Fprintln(tab, "CODE\tNAME\tDESCRIPTION\tADMIN REVOCATION")
+		} else {
+			fmt.Fprintln(tab, "CODE\tNAME\tADMIN REVOCATION")
+		}
+		for _, k := range codes {
+			accepted := "accepted"
+			if !revocation.IsValidAdminReason(k) {
+				accepted = "not accepted: " + revocation.AdminReasonNote(k)
+			} else if note := revocation.AdminReasonNote(k); note != "" {
+				accepted = "accepted, but: " + note
+			}
+			if describe {
+				fmt.Fprintf(tab, "%d\t%s\t%s\t%s\n", k, k.String(), revocation.ReasonDescription[k], accepted)
+			} else {
+				fmt.Fprintf(tab, "%d\t%s\t%s\n", k, k.String(), accepted)
+			}
+		}
+		err := tab.Flush()
+		if err != nil {
+			return err
+		}
+		lines := strings.SplitAfter(table.String(), "\n")
+		for i, line := range lines {
+			// The first line is the header, followed by a line per code.
+			if color && i > 0 && i <= len(codes) && !revocation.IsValidAdminReason(codes[i-1]) {
+				line = warningColor + strings.TrimSuffix(line, "\n") + resetColor + "\n"
+			}
+			_, err = io.WriteString(out, line)
+			if err != nil {
+				return err
+			}
+		}
+	case "json":
+		reasons := []reasonJSON{}
+		for _, k := range codes {
+			reason := reasonJSON{
+				Code:     k,
+				Name:     k.String(),
+				Accepted: revocation.IsValidAdminReason(k),
+				Note:     revocation.AdminReasonNote(k),
+			}
+			if describe {
+				reason.Description = revocation.ReasonDescription[k]
+			}
+			reasons = append(reasons, reason)
+		}
+		encoded, err := json.Marshal(reasons)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", encoded)
+	default:
+		return fmt.Errorf("unknown format %q, must be \"text\" or \"json\"", format)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package revoker
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// progressLine is the JSON object written by a Progress for each serial
+// processed.
+type progressLine struct {
+	Serial string `json:"serial"`
+	Status string `json:"status"`
+	// Reason is why the serial was skipped, if it was.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Done   int    `json:"done"`
+	// Total is omitted when the number of serials to be processed isn't known
+	// in advance.
+	Total int `json:"total,omitempty"`
+}
+
+// Progress writes a JSON object per line describing the outcome of each
+// serial processed, along with the number processed so far and, when known,
+// the total, so that a tool driving the admin-revoker can show its progress
+// and detect stalls. A nil *Progress records nothing.
+type Progress struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	done  int
+	total int
+}
+
+// NewProgress returns a Progress writing to out.
+func NewProgress(out io.Writer) *Progress {
+	return &Progress{enc: json.NewEncoder(out)}
+}
+
+// SetTotal sets the number of serials which are expected to be processed.
+func (p *Progress) SetTotal(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+// record writes a line for a serial with the provided status, one of the
+// results recorded in a Report, and the error if the status is reportError or
+// the reason if it is reportSkipped.
+// Failures to write are ignored, since progress is purely informational.
+func (p *Progress) record(serial, status string, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	line := progressLine{Serial: serial, Status: status, Done: p.done, Total: p.total}
+	if skip, ok := err.(skipError); ok {
+		line.Reason = skip.reason
+	} else if err != nil {
+		line.Error = err.Error()
+	}
+	_ = p.enc.Encode(line)
+}
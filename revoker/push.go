@@ -0,0 +1,109 @@
+package revoker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/letsencrypt/boulder/cmd"
+)
+
+// PushGatewayConfig configures pushing the admin-revoker's metrics to a
+// Prometheus Pushgateway when it exits. Since the admin-revoker is short-lived,
+// it is unlikely to be scraped while it runs.
+type PushGatewayConfig struct {
+	// URL is the Pushgateway's base URL, e.g. http://pushgateway:9091. If
+	// empty, metrics aren't pushed.
+	URL string
+	// Job is the job label the metrics are grouped under. Defaults to
+	// "admin-revoker".
+	Job string
+	// Timeout bounds the push. Defaults to 10 seconds.
+	Timeout cmd.ConfigDuration
+}
+
+// Defaults used when the PushGatewayConfig fields are empty.
+const (
+	defaultPushJob     = "admin-revoker"
+	defaultPushTimeout = 10 * time.Second
+)
+
+// Pusher pushes the metrics gathered from a registry to a Pushgateway,
+// replacing any previously pushed under the same job.
+type Pusher struct {
+	url      string
+	gatherer prometheus.Gatherer
+	client   *http.Client
+}
+
+// NewPusher returns a Pusher pushing the metrics gathered by g to the
+// Pushgateway configured by c.
+func NewPusher(c PushGatewayConfig, g prometheus.Gatherer) (*Pusher, error) {
+	if c.URL == "" {
+		return nil, errors.New("pushGateway URL must not be empty")
+	}
+	base, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing pushGateway URL: %s", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("pushGateway URL %q must be http or https", c.URL)
+	}
+	job := c.Job
+	if job == "" {
+		job = defaultPushJob
+	}
+	timeout := c.Timeout.Duration
+	if timeout == 0 {
+		timeout = defaultPushTimeout
+	}
+	return &Pusher{
+		url:      strings.TrimSuffix(c.URL, "/") + "/metrics/job/" + url.PathEscape(job),
+		gatherer: g,
+		client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Push gathers the metrics and pushes them, keeping the names they are
+// registered under so that they match those scraped from DebugAddr.
+func (p *Pusher) Push(ctx context.Context) error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %s", err)
+	}
+	var body bytes.Buffer
+	enc := expfmt.NewEncoder(&body, expfmt.FmtProtoDelim)
+	for _, mf := range families {
+		err = enc.Encode(mf)
+		if err != nil {
+			return fmt.Errorf("encoding metric %s: %s", mf.GetName(), err)
+		}
+	}
+	req, err := http.NewRequest("PUT", p.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", string(expfmt.FmtProtoDelim))
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("pushing metrics to %s: unexpected status %q", p.url, resp.Status)
+	}
+	return nil
+}
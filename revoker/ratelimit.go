@@ -0,0 +1,117 @@
+package revoker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+)
+
+// maxSlowdown bounds how far an adaptive RateLimiter slows down: to no less
+// than 1/maxSlowdown of its configured rate.
+const maxSlowdown = 64
+
+// RateLimiter throttles calls to the RA to a maximum rate, shared between all
+// of the goroutines using it. It is a token bucket holding a single token, so
+// calls are spaced evenly rather than allowed in bursts. A nil *RateLimiter
+// doesn't limit anything.
+type RateLimiter struct {
+	clk clock.Clock
+	// base is the interval between calls at the configured rate.
+	base     time.Duration
+	adaptive bool
+
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing perSecond calls per second, or
+// nil if perSecond isn't positive.
+func NewRateLimiter(clk clock.Clock, perSecond float64) *RateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / perSecond)
+	return &RateLimiter{clk: clk, base: interval, interval: interval}
+}
+
+// NewAdaptiveRateLimiter is like NewRateLimiter, but the RateLimiter halves its
+// rate each time the RA reports that it's overloaded, and ramps back up to
+// perSecond gradually as calls succeed.
+func NewAdaptiveRateLimiter(clk clock.Clock, perSecond float64) *RateLimiter {
+	rl := NewRateLimiter(clk, perSecond)
+	if rl != nil {
+		rl.adaptive = true
+	}
+	return rl
+}
+
+// isAdaptive returns true if the rate adapts to the RA being overloaded.
+func (rl *RateLimiter) isAdaptive() bool {
+	return rl != nil && rl.adaptive
+}
+
+// rate returns the current rate, in calls per second.
+func (rl *RateLimiter) rate() float64 {
+	return float64(time.Second) / float64(rl.interval)
+}
+
+// slowDown halves the rate of an adaptive RateLimiter, down to 1/maxSlowdown
+// of its configured rate, returning the new rate.
+func (rl *RateLimiter) slowDown() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.interval *= 2
+	if rl.interval > rl.base*maxSlowdown {
+		rl.interval = rl.base * maxSlowdown
+	}
+	return rl.rate()
+}
+
+// speedUp raises the rate of an adaptive RateLimiter by a tenth, up to its
+// configured rate, after a call succeeded. It does nothing for any other.
+func (rl *RateLimiter) speedUp() {
+	if !rl.isAdaptive() {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.interval -= rl.interval / 11
+	if rl.interval < rl.base {
+		rl.interval = rl.base
+	}
+}
+
+// reserve claims the next slot for a call, returning how long the caller must
+// wait before making it.
+func (rl *RateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.clk.Now()
+	if rl.next.Before(now) {
+		rl.next = now
+	}
+	delay := rl.next.Sub(now)
+	rl.next = rl.next.Add(rl.interval)
+	return delay
+}
+
+// wait blocks until a call is allowed, or returns ctx's error if it is done
+// first.
+func (rl *RateLimiter) wait(ctx context.Context) error {
+	if rl == nil {
+		return nil
+	}
+	delay := rl.reserve()
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-rl.clk.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,176 @@
+package revoker
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// ocspQuerier queries the OCSP responder for a certificate's response. It is
+// implemented by OCSPVerifier, and is an interface so that reconciliation can
+// be tested without a responder.
+type ocspQuerier interface {
+	query(ctx context.Context, cert *x509.Certificate) (*ocsp.Response, error)
+}
+
+// ocspStatusNames names the statuses an OCSP response may give.
+var ocspStatusNames = map[int]string{
+	ocsp.Good:    "good",
+	ocsp.Revoked: "revoked",
+	ocsp.Unknown: "unknown",
+}
+
+// OCSPMismatch describes a certificate whose status in the database differs
+// from the one published by the OCSP responder.
+type OCSPMismatch struct {
+	Serial string
+	// DBStatus is the certificate's status in the certificateStatus table.
+	DBStatus core.OCSPStatus
+	// OCSPStatus is the status reported by the OCSP responder: good, revoked
+	// or unknown.
+	OCSPStatus string
+	// Detail explains a mismatch between two revoked statuses, e.g. differing
+	// reasons. It is empty otherwise.
+	Detail string
+	// Fixed is set once a fresh OCSP response has been signed and stored for
+	// a certificate revoked in the database.
+	Fixed bool
+}
+
+// ReconcileResult summarizes the outcome of comparing the revocation status
+// of a list of serials in the database with the OCSP responder.
+type ReconcileResult struct {
+	Checked    int
+	Mismatches []OCSPMismatch
+	Failures   []SerialError
+}
+
+// Unfixed returns the number of mismatches which weren't fixed.
+func (rr ReconcileResult) Unfixed() int {
+	unfixed := 0
+	for _, m := range rr.Mismatches {
+		if !m.Fixed {
+			unfixed++
+		}
+	}
+	return unfixed
+}
+
+// Log writes a summary of the reconciliation, including each mismatch and the
+// error for each serial which couldn't be checked, followed by the number of
+// failures of each kind.
+func (rr ReconcileResult) Log(logger blog.Logger) {
+	logger.Infof("Reconcile complete: %d checked, %d mismatched, %d fixed, %d failed",
+		rr.Checked, len(rr.Mismatches), len(rr.Mismatches)-rr.Unfixed(), len(rr.Failures))
+	for _, m := range rr.Mismatches {
+		fixed := ""
+		if m.Fixed {
+			fixed = ", refreshed the OCSP response"
+		}
+		detail := ""
+		if m.Detail != "" {
+			detail = ": " + m.Detail
+		}
+		logger.Warningf("Certificate %s is %s in the database but %s in OCSP%s%s",
+			m.Serial, m.DBStatus, m.OCSPStatus, detail, fixed)
+	}
+	for _, f := range rr.Failures {
+		logger.Errf("Failed to reconcile %s: %s", f.Serial, f.Err)
+	}
+	logFailureCategories(logger, rr.Failures)
+}
+
+// Reconcile compares the status of each of the serials in the certificateStatus
+// table with the response published by the OCSP responder queried by
+// verifier, recording each which differs: revoked in the database but good in
+// OCSP or vice versa, unknown to OCSP, or revoked in both with different
+// reasons. If fix is set, the CA signs a fresh OCSP response for each
+// mismatched certificate which is revoked in the database, as ocsp-refresh
+// does. Certificates only revoked in OCSP are never changed. A failure to check
+// one serial doesn't stop the others from being checked; all failures are
+// collected in the result instead. An error is only returned if the
+// reconciliation is stopped.
+func (r *Revoker) Reconcile(ctx context.Context, serials []string, verifier *OCSPVerifier, fix bool, opts Options) (ReconcileResult, error) {
+	if fix && r.ogc == nil && !opts.DryRun {
+		return ReconcileResult{}, errNoOCSPGenerator
+	}
+	return r.reconcile(ctx, dbOCSPStore{r.dbMap}, verifier, serials, fix, opts)
+}
+
+func (r *Revoker) reconcile(ctx context.Context, store ocspStore, querier ocspQuerier, serials []string, fix bool, opts Options) (ReconcileResult, error) {
+	var result ReconcileResult
+	for _, s := range serials {
+		if opts.stopped() {
+			return result, ErrInterrupted
+		}
+		serial, err := revocation.NormalizeSerial(s)
+		if err != nil {
+			result.Failures = append(result.Failures, SerialError{s, berrors.MalformedError("invalid serial: %s", err)})
+			continue
+		}
+		mismatch, err := r.reconcileSerial(ctx, store, querier, serial)
+		if err != nil {
+			result.Failures = append(result.Failures, SerialError{serial, err})
+			continue
+		}
+		result.Checked++
+		if mismatch == nil {
+			continue
+		}
+		if fix && mismatch.DBStatus == core.OCSPStatusRevoked {
+			_, err = r.refreshSerial(ctx, store, serial, opts)
+			if err != nil {
+				result.Failures = append(result.Failures, SerialError{serial, fmt.Errorf("refreshing OCSP response: %s", err)})
+			} else {
+				mismatch.Fixed = !opts.DryRun
+			}
+		}
+		result.Mismatches = append(result.Mismatches, *mismatch)
+	}
+	return result, nil
+}
+
+// reconcileSerial compares the status of a single serial in the database with
+// its OCSP response, returning a description of the mismatch if they differ
+// and nil if they don't.
+func (r *Revoker) reconcileSerial(ctx context.Context, store ocspStore, querier ocspQuerier, serial string) (*OCSPMismatch, error) {
+	status, err := store.certificateStatus(serial)
+	if err != nil {
+		return nil, err
+	}
+	der, err := store.certificateDER(serial)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := querier.query(ctx, cert)
+	if err != nil {
+		return nil, fmt.Errorf("querying OCSP: %s", err)
+	}
+	mismatch := &OCSPMismatch{
+		Serial:     serial,
+		DBStatus:   status.Status,
+		OCSPStatus: ocspStatusNames[resp.Status],
+	}
+	dbRevoked := status.Status == core.OCSPStatusRevoked
+	switch {
+	case resp.Status == ocsp.Unknown, dbRevoked != (resp.Status == ocsp.Revoked):
+		return mismatch, nil
+	case dbRevoked && revocation.Reason(resp.RevocationReason) != status.RevokedReason:
+		mismatch.Detail = fmt.Sprintf("reason is '%s' in the database but '%s' in OCSP",
+			status.RevokedReason.String(), revocation.Reason(resp.RevocationReason).String())
+		return mismatch, nil
+	}
+	r.log.Debugf("Certificate %s is %s in both the database and OCSP", serial, status.Status)
+	return nil, nil
+}
@@ -0,0 +1,199 @@
+package revoker
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	capb "github.com/letsencrypt/boulder/ca/proto"
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+)
+
+// ocspStore reads the revocation status of certificates and stores the OCSP
+// responses signed for them. It is implemented over the database by
+// dbOCSPStore, and is an interface so that refreshing responses can be tested
+// without a database.
+type ocspStore interface {
+	// certificateStatus returns the status of the certificate with the serial,
+	// or a NotFound error if there is none.
+	certificateStatus(serial string) (core.CertificateStatus, error)
+	// certificateDER returns the DER of the certificate, or else the
+	// precertificate, with the serial.
+	certificateDER(serial string) ([]byte, error)
+	// storeRevokedResponse stores response as the OCSP response of the
+	// certificate with the serial, provided that it is still revoked.
+	storeRevokedResponse(serial string, response []byte, updated time.Time) error
+}
+
+// dbOCSPStore is an ocspStore which reads from and updates the
+// certificateStatus table, as the ocsp-updater does.
+type dbOCSPStore struct {
+	dbMap db.Executor
+}
+
+func (s dbOCSPStore) certificateStatus(serial string) (core.CertificateStatus, error) {
+	status, err := sa.SelectCertificateStatus(s.dbMap, "WHERE serial = ?", serial)
+	if db.IsNoRows(err) {
+		return core.CertificateStatus{}, berrors.NotFoundError("status for certificate with serial %q not found", serial)
+	}
+	if err != nil {
+		return core.CertificateStatus{}, err
+	}
+	return core.CertificateStatus{
+		Serial:        status.Serial,
+		Status:        status.Status,
+		RevokedDate:   status.RevokedDate,
+		RevokedReason: status.RevokedReason,
+		IssuerID:      status.IssuerID,
+	}, nil
+}
+
+func (s dbOCSPStore) certificateDER(serial string) ([]byte, error) {
+	cert, err := sa.SelectCertificate(s.dbMap, "WHERE serial = ?", serial)
+	if db.IsNoRows(err) {
+		cert, err = sa.SelectPrecertificate(s.dbMap, serial)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cert.DER, nil
+}
+
+func (s dbOCSPStore) storeRevokedResponse(serial string, response []byte, updated time.Time) error {
+	res, err := s.dbMap.Exec(
+		`UPDATE certificateStatus
+		SET ocspResponse = ?, ocspLastUpdated = ?
+		WHERE serial = ? AND status = ?`,
+		response,
+		updated,
+		serial,
+		string(core.OCSPStatusRevoked),
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return berrors.NotFoundError("certificate with serial %q is no longer revoked", serial)
+	}
+	return nil
+}
+
+// errNoOCSPGenerator is returned by RefreshOCSP, and by Reconcile when fixing
+// mismatches, if the Revoker wasn't configured with an ocspGeneratorService.
+var errNoOCSPGenerator = errors.New("refreshing OCSP responses requires ocspGeneratorService in the config")
+
+// OCSPRefreshResult summarizes the outcome of refreshing the OCSP responses of
+// a list of serials.
+type OCSPRefreshResult struct {
+	Refreshed int
+	// Skipped counts the serials which weren't revoked.
+	Skipped  int
+	Failures []SerialError
+}
+
+// Log writes a summary of the refresh, including the error for each serial
+// whose response couldn't be refreshed, followed by the number of failures of
+// each kind.
+func (rr OCSPRefreshResult) Log(logger blog.Logger) {
+	logger.Infof("OCSP refresh complete: %d refreshed, %d skipped as not revoked, %d failed",
+		rr.Refreshed, rr.Skipped, len(rr.Failures))
+	for _, f := range rr.Failures {
+		logger.Errf("Failed to refresh OCSP response for %s: %s", f.Serial, f.Err)
+	}
+	logFailureCategories(logger, rr.Failures)
+}
+
+// RefreshOCSP has the CA sign a fresh OCSP response for each of the serials
+// which is revoked, e.g. after a rotation of the OCSP signing key, and stores
+// it without otherwise changing the certificate's status. Serials which aren't
+// revoked are skipped. A failure to refresh one response doesn't stop the
+// others from being refreshed; all failures are collected in the result
+// instead. An error is only returned if the refresh is stopped.
+func (r *Revoker) RefreshOCSP(ctx context.Context, serials []string, opts Options) (OCSPRefreshResult, error) {
+	if r.ogc == nil && !opts.DryRun {
+		return OCSPRefreshResult{}, errNoOCSPGenerator
+	}
+	return r.refreshOCSP(ctx, dbOCSPStore{r.dbMap}, serials, opts)
+}
+
+func (r *Revoker) refreshOCSP(ctx context.Context, store ocspStore, serials []string, opts Options) (OCSPRefreshResult, error) {
+	var result OCSPRefreshResult
+	for _, s := range serials {
+		if opts.stopped() {
+			return result, ErrInterrupted
+		}
+		serial, err := revocation.NormalizeSerial(s)
+		if err != nil {
+			result.Failures = append(result.Failures, SerialError{s, berrors.MalformedError("invalid serial: %s", err)})
+			continue
+		}
+		refreshed, err := r.refreshSerial(ctx, store, serial, opts)
+		if err != nil {
+			result.Failures = append(result.Failures, SerialError{serial, err})
+		} else if refreshed {
+			result.Refreshed++
+		} else {
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+// refreshSerial refreshes the OCSP response of a single serial, returning
+// false if it was skipped because the certificate isn't revoked.
+func (r *Revoker) refreshSerial(ctx context.Context, store ocspStore, serial string, opts Options) (bool, error) {
+	status, err := store.certificateStatus(serial)
+	if err != nil {
+		return false, err
+	}
+	if status.Status != core.OCSPStatusRevoked {
+		r.log.Infof("Certificate %s is %s, not revoked, skipping", serial, status.Status)
+		return false, nil
+	}
+	if opts.DryRun {
+		r.log.Infof("Would refresh OCSP response for certificate %s, revoked at %s with reason '%s'",
+			serial, status.RevokedDate, status.RevokedReason)
+		return true, nil
+	}
+
+	statusStr := string(status.Status)
+	reason := int32(status.RevokedReason)
+	revokedAt := status.RevokedDate.UnixNano()
+	req := &capb.GenerateOCSPRequest{
+		Status:    &statusStr,
+		Reason:    &reason,
+		RevokedAt: &revokedAt,
+	}
+	if status.IssuerID != nil {
+		req.Serial = &status.Serial
+		req.IssuerID = status.IssuerID
+	} else {
+		req.CertDER, err = store.certificateDER(serial)
+		if err != nil {
+			return false, err
+		}
+	}
+	err = opts.RateLimit.wait(ctx)
+	if err != nil {
+		return false, err
+	}
+	resp, err := r.ogc.GenerateOCSP(ctx, req)
+	if err != nil {
+		return false, err
+	}
+	err = store.storeRevokedResponse(serial, resp.Response, r.clk.Now())
+	if err != nil {
+		return false, err
+	}
+	r.log.Infof("Refreshed OCSP response for certificate %s", serial)
+	return true, nil
+}
@@ -0,0 +1,545 @@
+package revoker
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+)
+
+// IssuedWindow restricts revocation by registration to certificates issued
+// within a window of time. A zero After or Before leaves that end of the window
+// open.
+type IssuedWindow struct {
+	After  time.Time
+	Before time.Time
+}
+
+// Contains returns true if a certificate issued at the given time falls within
+// the window.
+func (w IssuedWindow) Contains(issued time.Time) bool {
+	if !w.After.IsZero() && !issued.After(w.After) {
+		return false
+	}
+	if !w.Before.IsZero() && !issued.Before(w.Before) {
+		return false
+	}
+	return true
+}
+
+// String describes the window for logging.
+func (w IssuedWindow) String() string {
+	switch {
+	case w.After.IsZero() && w.Before.IsZero():
+		return "at any time"
+	case w.Before.IsZero():
+		return fmt.Sprintf("after %s", w.After.Format(time.RFC3339))
+	case w.After.IsZero():
+		return fmt.Sprintf("before %s", w.Before.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("after %s and before %s", w.After.Format(time.RFC3339), w.Before.Format(time.RFC3339))
+}
+
+// DefaultBatchSize is the number of certificates selected at a time when
+// iterating over the certificates associated with a registration, unless
+// Options.BatchSize is set.
+const DefaultBatchSize = 1000
+
+// regSerialsPageSize is DefaultBatchSize, overridden by tests.
+var regSerialsPageSize = DefaultBatchSize
+
+// regPageSize returns the number of certificates selected at a time when
+// iterating over the certificates associated with a registration.
+func (o Options) regPageSize() int {
+	if o.BatchSize > 0 {
+		return o.BatchSize
+	}
+	return regSerialsPageSize
+}
+
+// forEachRegSerial calls f with the serial of each certificate associated with
+// a registration which was issued within the window, stopping at the first
+// error. Rather than loading every certificate at once, which could exhaust
+// memory for registrations with very many certificates, they are selected
+// pageSize at a time ordered by serial, each page processed before the next is
+// selected. Each page is selected after the last serial of the previous one,
+// rather than by offset, so that no certificate is skipped or repeated even if
+// rows are modified between pages: serials are unique and never change. A page
+// out of order stops the iteration with an error. Certificates issued outside
+// of the window are skipped and their number logged.
+//
+// Before f is called, each certificate is looked up again by serial to check
+// that it really belongs to the registration, so that an inconsistent
+// database can't cause another registration's certificates to be revoked. A
+// mismatch stops the iteration with an error.
+func (r *Revoker) forEachRegSerial(lookup certLookup, regID int64, window IssuedWindow, pageSize int, f func(serial string) error) error {
+	var skipped int
+	var after string
+	for {
+		certs, err := lookup.regCertificates(regID, after, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, cert := range certs {
+			if cert.Serial <= after {
+				return berrors.InternalServerError(
+					"certificate %s was selected for registration %d after %q, out of order", cert.Serial, regID, after)
+			}
+			after = cert.Serial
+			if !window.Contains(cert.Issued) {
+				skipped++
+				continue
+			}
+			owned, err := lookup.certificate(cert.Serial)
+			if err != nil {
+				return err
+			}
+			if owned.RegistrationID != regID {
+				return berrors.InternalServerError(
+					"certificate %s was selected for registration %d but belongs to registration %d, the database may be inconsistent",
+					cert.Serial, regID, owned.RegistrationID)
+			}
+			err = f(cert.Serial)
+			if err != nil {
+				return err
+			}
+		}
+		if len(certs) < pageSize {
+			break
+		}
+	}
+	if skipped > 0 {
+		r.log.Infof("Skipping %d certificates for registration %d not issued %s", skipped, regID, window)
+	}
+	return nil
+}
+
+// keyHashToDigest converts a SHA-256 digest of an account key's
+// SubjectPublicKeyInfo, encoded as hex or as base64 with either alphabet and
+// with or without padding, to the padded standard base64 encoding stored in
+// the registrations table.
+func keyHashToDigest(keyHash string) (string, error) {
+	keyHash = strings.TrimSpace(keyHash)
+	var digest []byte
+	var err error
+	if len(keyHash) == hex.EncodedLen(sha256.Size) {
+		digest, err = hex.DecodeString(keyHash)
+	} else {
+		digest, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(
+			strings.NewReplacer("+", "-", "/", "_").Replace(keyHash), "="))
+	}
+	if err != nil {
+		return "", berrors.MalformedError("key hash %q is not valid hex or base64: %s", keyHash, err)
+	}
+	if len(digest) != sha256.Size {
+		return "", berrors.MalformedError("key hash %q is not a SHA-256 digest", keyHash)
+	}
+	return base64.StdEncoding.EncodeToString(digest), nil
+}
+
+// RegistrationIDForKeyHash returns the ID of the registration whose account key
+// has the provided hash. The registrations table has a unique index on the key
+// hash, so more than one match indicates serious database corruption and is
+// reported as an error rather than picking one of them.
+func (r *Revoker) RegistrationIDForKeyHash(keyHash string) (int64, error) {
+	digest, err := keyHashToDigest(keyHash)
+	if err != nil {
+		return 0, err
+	}
+	regIDs, err := sa.SelectRegistrationIDsByKeyHash(r.dbMap, digest)
+	if err != nil {
+		return 0, err
+	}
+	switch len(regIDs) {
+	case 0:
+		return 0, berrors.NotFoundError("no registration with account key hash %q", digest)
+	case 1:
+		return regIDs[0], nil
+	default:
+		r.log.AuditErrf("Found %d registrations %v with the same account key hash %q. This should be impossible!",
+			len(regIDs), regIDs, digest)
+		return 0, berrors.InternalServerError("multiple registrations with account key hash %q", digest)
+	}
+}
+
+// GetRegistration fetches the registration with the provided ID from the SA.
+// A Revoker returned by NewExplainer isn't connected to the SA, so it only
+// checks that the registration exists in the database, and returns a
+// registration with just its ID set.
+func (r *Revoker) GetRegistration(ctx context.Context, regID int64) (core.Registration, error) {
+	if r.explain == nil {
+		return r.sac.GetRegistration(ctx, regID)
+	}
+	var count int64
+	err := r.dbMap.SelectOne(&count, "SELECT COUNT(1) FROM registrations WHERE id = ?", regID)
+	if err != nil {
+		return core.Registration{}, err
+	}
+	if count == 0 {
+		return core.Registration{}, berrors.NotFoundError("no registration with ID %d", regID)
+	}
+	return core.Registration{ID: regID}, nil
+}
+
+// RevokeRegistration revokes all certificates associated with a registration
+// which were issued within the window, in a single transaction unless
+// opts.CommitEvery is set. The first failure aborts the revocation and is
+// recorded in the result as well as returned.
+//
+// The transaction only holds the admin-revoker's own reads. Each revocation is
+// committed by the RA, through the SA, as soon as the RA confirms it, and the
+// certificate stays revoked whatever becomes of the transaction, so the
+// database never lags behind the revocations the RA has made. What a failure
+// leaves behind is therefore a prefix of the certificates, in serial order,
+// revoked, and the rest untouched. If the RA stops being reachable partway
+// through, the revocation stops at that certificate without rolling the
+// transaction back, returning a BackendUnavailableError, and each certificate
+// revoked before then is recorded in cp as usual, so that a re-run resumes
+// after it.
+func (r *Revoker) RevokeRegistration(ctx context.Context, regID int64, window IssuedWindow, reasonCode revocation.Reason, opts Options, cp *Checkpoint) (BatchResult, error) {
+	var result BatchResult
+	var unavailable error
+	err := r.inCommittingTransactions(ctx, opts, func(certs certLookup) error {
+		var err error
+		result, err = r.revokeByReg(ctx, certs, regID, window, reasonCode, opts, cp)
+		if _, ok := err.(BackendUnavailableError); ok {
+			unavailable = err
+			return nil
+		}
+		return err
+	})
+	if err == nil && unavailable != nil {
+		return result, unavailable
+	}
+	return result, err
+}
+
+// revokeByReg revokes each certificate associated with a registration which
+// was issued within the window in turn, finding them with certs. The first
+// failure stops the revocation and is recorded in the result as well as
+// returned, wrapped in a BackendUnavailableError if the backend couldn't be
+// reached.
+func (r *Revoker) revokeByReg(ctx context.Context, certs certLookup, regID int64, window IssuedWindow, reasonCode revocation.Reason, opts Options, cp *Checkpoint) (BatchResult, error) {
+	var result BatchResult
+	err := r.forEachRegSerial(certs, regID, window, opts.regPageSize(), func(serial string) error {
+		if opts.stopped() {
+			return ErrInterrupted
+		}
+		err := r.revokeCheckpointed(ctx, certs, serial, reasonCode, opts, cp)
+		_, skipped := err.(skipError)
+		if !skipped {
+			result.Attempted = append(result.Attempted, serial)
+		}
+		err = result.add(serial, err)
+		if err != nil && ctx.Err() == nil && isConnectionError(err) {
+			return BackendUnavailableError{Revoked: result.Revoked, Err: err}
+		}
+		if err == nil && !skipped {
+			err = recordRevoked(certs)
+		}
+		return err
+	})
+	return result, err
+}
+
+// VerifyRevoked reads the status of each of the serials from the database
+// again, outside of any transaction, and returns those which aren't revoked,
+// including any which no longer have a status at all. It is used to confirm
+// that every revocation a bulk command attempted actually took effect.
+func (r *Revoker) VerifyRevoked(serials []string) ([]string, error) {
+	return verifyRevoked(dbLookup{r.dbMap}, serials)
+}
+
+func verifyRevoked(lookup certLookup, serials []string) ([]string, error) {
+	var notRevoked []string
+	for _, serial := range serials {
+		status, err := lookup.status(serial)
+		if db.IsNoRows(err) {
+			notRevoked = append(notRevoked, serial)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if status != core.OCSPStatusRevoked {
+			notRevoked = append(notRevoked, serial)
+		}
+	}
+	return notRevoked, nil
+}
+
+// deactivationEvent is audit logged for every registration deactivated.
+type deactivationEvent struct {
+	RegistrationID int64 `json:"registrationID"`
+	// Operator is the --operator given to the admin-revoker, or else the
+	// local username of whoever ran it
+	Operator  string    `json:"operator"`
+	Comment   string    `json:"comment,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DeactivateRegistration deactivates a registration through the SA, so that
+// it can't be used to issue any more certificates, e.g. after revoking its
+// certificates for abuse. A registration which isn't valid is left as it is.
+// With opts.DryRun, nothing is deactivated.
+func (r *Revoker) DeactivateRegistration(ctx context.Context, regID int64, opts Options) error {
+	operator, err := opts.operator()
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		r.log.Infof("Would deactivate registration %d", regID)
+		return nil
+	}
+	err = r.sac.DeactivateRegistration(ctx, regID)
+	if err != nil {
+		return err
+	}
+	r.log.AuditObject("Administrative registration deactivation", deactivationEvent{
+		RegistrationID: regID,
+		Operator:       operator,
+		Comment:        opts.Comment,
+		Timestamp:      r.clk.Now(),
+	})
+	r.log.Infof("Deactivated registration %d", regID)
+	return nil
+}
+
+// RevokeRegistrationParallel revokes all certificates associated with a
+// registration using parallelism concurrent workers. Because a transaction
+// can't be shared between goroutines, the certificates are selected and each
+// revocation is performed outside of any transaction. Unlike
+// RevokeRegistration, a failure to revoke one certificate doesn't stop the
+// others from being revoked; all failures are collected in the result instead.
+// An error is only returned if selecting the certificates fails or the
+// revocation is stopped, in which case the result covers those selected before
+// then.
+func (r *Revoker) RevokeRegistrationParallel(ctx context.Context, regID int64, window IssuedWindow, reasonCode revocation.Reason, opts Options, parallelism int, cp *Checkpoint) (BatchResult, error) {
+	return r.revokeSerialsParallel(ctx, func(work chan<- string) error {
+		return r.forEachRegSerial(dbLookup{r.dbMap}, regID, window, opts.regPageSize(), func(serial string) error {
+			select {
+			case work <- serial:
+				return nil
+			case <-opts.Stop:
+				return ErrInterrupted
+			}
+		})
+	}, reasonCode, opts, parallelism, cp)
+}
+
+// CertificateInfo describes a certificate associated with a registration, as
+// listed by ListRegistration.
+type CertificateInfo struct {
+	Serial     string          `json:"serial"`
+	CommonName string          `json:"commonName"`
+	DNSNames   []string        `json:"dnsNames"`
+	Issued     time.Time       `json:"issued"`
+	Expires    time.Time       `json:"expires"`
+	Status     core.OCSPStatus `json:"status"`
+}
+
+// ListRegistration calls f with a description of each certificate associated
+// with a registration which was issued within the window, as selected by
+// RevokeRegistration, stopping at the first error, and returns an estimate of
+// the impact of revoking them. It only reads from the database, outside of any
+// transaction.
+func (r *Revoker) ListRegistration(regID int64, window IssuedWindow, f func(CertificateInfo) error) (RevocationImpact, error) {
+	var impact RevocationImpact
+	now := r.clk.Now()
+	err := r.forEachRegSerial(dbLookup{r.dbMap}, regID, window, regSerialsPageSize, func(serial string) error {
+		certObj, err := sa.SelectCertificate(r.dbMap, "WHERE serial = ?", serial)
+		if err != nil {
+			return err
+		}
+		cert, err := x509.ParseCertificate(certObj.DER)
+		if err != nil {
+			return err
+		}
+		status, err := sa.SelectCertificateStatus(r.dbMap, "WHERE serial = ?", serial)
+		if err != nil {
+			return err
+		}
+		impact.add(cert.NotAfter, status.Status == core.OCSPStatusRevoked, now)
+		return f(CertificateInfo{
+			Serial:     serial,
+			CommonName: cert.Subject.CommonName,
+			DNSNames:   cert.DNSNames,
+			Issued:     certObj.Issued,
+			Expires:    cert.NotAfter,
+			Status:     status.Status,
+		})
+	})
+	if err != nil {
+		return RevocationImpact{}, err
+	}
+	return impact, nil
+}
+
+// EstimateRegistrationImpact estimates the impact of revoking every certificate
+// associated with a registration which was issued within the window, from the
+// notAfter of each, as ListRegistration does.
+func (r *Revoker) EstimateRegistrationImpact(regID int64, window IssuedWindow) (RevocationImpact, error) {
+	return r.ListRegistration(regID, window, func(CertificateInfo) error { return nil })
+}
+
+// SerialInfo describes a certificate, or a precertificate if no final
+// certificate was issued for it, and the registration it was issued to, as
+// looked up by SerialInfo.
+type SerialInfo struct {
+	Serial string `json:"serial"`
+	// Kind is which of a certificate and a precertificate were found.
+	Kind      string          `json:"kind"`
+	Subject   string          `json:"subject"`
+	DNSNames  []string        `json:"dnsNames"`
+	NotBefore time.Time       `json:"notBefore"`
+	NotAfter  time.Time       `json:"notAfter"`
+	Status    core.OCSPStatus `json:"status"`
+
+	RegistrationID     int64           `json:"registrationID"`
+	Contact            []string        `json:"contact"`
+	RegistrationStatus core.AcmeStatus `json:"registrationStatus"`
+}
+
+// SerialInfo looks up the certificate with the serial, and the registration it
+// was issued to, without revoking anything. A Revoker returned by
+// NewExplainer can't fetch the registration's contact and status, so only its
+// ID is set.
+func (r *Revoker) SerialInfo(ctx context.Context, serial string) (SerialInfo, error) {
+	serial, err := revocation.NormalizeSerial(serial)
+	if err != nil {
+		return SerialInfo{}, berrors.MalformedError("invalid serial: %s", err)
+	}
+	return r.serialInfo(ctx, dbLookup{r.dbMap}, serial)
+}
+
+// serialInfo implements SerialInfo, finding the certificate with certs.
+func (r *Revoker) serialInfo(ctx context.Context, certs certLookup, serial string) (SerialInfo, error) {
+	certObj, kind, err := findCertificate(certs, serial, true)
+	if err != nil {
+		return SerialInfo{}, err
+	}
+	cert, err := x509.ParseCertificate(certObj.DER)
+	if err != nil {
+		return SerialInfo{}, err
+	}
+	status, err := certs.status(serial)
+	if err != nil {
+		return SerialInfo{}, err
+	}
+	reg, err := r.GetRegistration(ctx, certObj.RegistrationID)
+	if err != nil {
+		return SerialInfo{}, err
+	}
+	info := SerialInfo{
+		Serial:             serial,
+		Kind:               kind,
+		Subject:            cert.Subject.String(),
+		DNSNames:           cert.DNSNames,
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		Status:             status,
+		RegistrationID:     certObj.RegistrationID,
+		RegistrationStatus: reg.Status,
+	}
+	if reg.Contact != nil {
+		info.Contact = *reg.Contact
+	}
+	return info, nil
+}
+
+// SummarizeRegistration returns the number of certificates associated with a
+// registration, along with the subject common names of up to sampleSize of
+// them.
+func (r *Revoker) SummarizeRegistration(regID int64, sampleSize int) (int64, []string, error) {
+	var count int64
+	err := r.dbMap.SelectOne(&count, "SELECT COUNT(1) FROM certificates WHERE registrationID = ?", regID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var certs []core.Certificate
+	_, err = r.dbMap.Select(
+		&certs,
+		"SELECT der FROM certificates WHERE registrationID = :regID LIMIT :limit",
+		map[string]interface{}{"regID": regID, "limit": sampleSize},
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	var names []string
+	for _, c := range certs {
+		cert, err := x509.ParseCertificate(c.DER)
+		if err != nil {
+			return 0, nil, err
+		}
+		names = append(names, cert.Subject.CommonName)
+	}
+	return count, names, nil
+}
+
+// CountRegistrationSerials counts the certificates associated with a
+// registration which were issued within the window, i.e. those that
+// RevokeRegistration would select, including any already revoked or expired.
+func (r *Revoker) CountRegistrationSerials(regID int64, window IssuedWindow) (int64, error) {
+	query := "SELECT COUNT(1) FROM certificates WHERE registrationID = :regID"
+	args := map[string]interface{}{"regID": regID}
+	if !window.After.IsZero() {
+		query += " AND issued > :after"
+		args["after"] = window.After
+	}
+	if !window.Before.IsZero() {
+		query += " AND issued < :before"
+		args["before"] = window.Before
+	}
+	var count int64
+	err := r.dbMap.SelectOne(&count, query, args)
+	return count, err
+}
+
+// RegCertCounts is a breakdown of the certificates associated with a
+// registration by their current status.
+type RegCertCounts struct {
+	Total   int64 `db:"total"`
+	Revoked int64 `db:"revoked"`
+	Expired int64 `db:"expired"`
+	// Valid is the number of certificates which are neither revoked nor
+	// expired, i.e. those RevokeRegistration would actually affect.
+	Valid int64 `db:"-"`
+}
+
+// CountRegistrationCertificates counts the certificates associated with a
+// registration, as selected by RevokeRegistration. Revoked certificates are
+// not counted as expired, even if they have since expired.
+func (r *Revoker) CountRegistrationCertificates(regID int64) (RegCertCounts, error) {
+	var counts RegCertCounts
+	err := r.dbMap.SelectOne(
+		&counts,
+		`SELECT
+			COUNT(1) AS total,
+			COALESCE(SUM(cs.status = :revoked), 0) AS revoked,
+			COALESCE(SUM(cs.status != :revoked AND cs.notAfter <= :now), 0) AS expired
+		FROM certificates AS c
+		LEFT JOIN certificateStatus AS cs ON cs.serial = c.serial
+		WHERE c.registrationID = :regID`,
+		map[string]interface{}{
+			"regID":   regID,
+			"revoked": string(core.OCSPStatusRevoked),
+			"now":     r.clk.Now(),
+		},
+	)
+	if err != nil {
+		return RegCertCounts{}, err
+	}
+	counts.Valid = counts.Total - counts.Revoked - counts.Expired
+	return counts, nil
+}
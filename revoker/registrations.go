@@ -0,0 +1,261 @@
+package revoker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/letsencrypt/boulder/core"
+	berrors "github.com/letsencrypt/boulder/errors"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// Registrations are those whose certificates a reg-revoke or key-revoke of
+// several registrations revokes, as found by FindRegistrations.
+type Registrations struct {
+	Found []core.Registration
+	// NotFound records the registrations which couldn't be found, so that
+	// they are reported alongside the others by RevokeRegistrations.
+	NotFound []RegistrationResult
+}
+
+// FindRegistrations fetches the registrations with the provided IDs from the
+// SA. Those which can't be found are logged and recorded as not found rather
+// than aborting the others, unless strict is true.
+func (r *Revoker) FindRegistrations(ctx context.Context, regIDs []int64, strict bool) (Registrations, error) {
+	var regs Registrations
+	for _, regID := range regIDs {
+		reg, err := r.GetRegistration(ctx, regID)
+		if err != nil {
+			if !berrors.Is(err, berrors.NotFound) || strict {
+				return Registrations{}, err
+			}
+			r.log.Errf("Registration %d not found, skipping", regID)
+			regs.NotFound = append(regs.NotFound, RegistrationResult{RegID: regID, Err: err})
+			continue
+		}
+		regs.Found = append(regs.Found, reg)
+	}
+	return regs, nil
+}
+
+// CountRegistrationsSerials counts the certificates of all the registrations
+// found which were issued within the window, as CountRegistrationSerials does
+// for each.
+func (r *Revoker) CountRegistrationsSerials(regs Registrations, window IssuedWindow) (int64, error) {
+	var selected int64
+	for _, reg := range regs.Found {
+		count, err := r.CountRegistrationSerials(reg.ID, window)
+		if err != nil {
+			return 0, err
+		}
+		selected += count
+	}
+	return selected, nil
+}
+
+// DescribeRegistrations writes a line to out for each registration found, with
+// the number of its certificates and a sample of their names, and returns the
+// prompt asking the operator to confirm revoking them for the reason, within
+// the window and opts.DNSName.
+func (r *Revoker) DescribeRegistrations(out io.Writer, regs Registrations, window IssuedWindow, reasonCode revocation.Reason, opts Options) (string, error) {
+	var total int64
+	for _, reg := range regs.Found {
+		count, names, err := r.SummarizeRegistration(reg.ID, 5)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(out, "Registration %d has %d certificates, including: %s\n", reg.ID, count, strings.Join(names, ", "))
+		total += count
+	}
+	return registrationsPrompt(total, window, reasonCode, opts), nil
+}
+
+// registrationsPrompt returns the prompt asking the operator to confirm
+// revoking the total certificates of the registrations for the reason,
+// describing the window and opts.DNSName which limit them if either is set.
+func registrationsPrompt(total int64, window IssuedWindow, reasonCode revocation.Reason, opts Options) string {
+	if window == (IssuedWindow{}) && opts.DNSName == "" {
+		return fmt.Sprintf("Revoke all %d certificates with reason '%s'?", total, reasonCode.String())
+	}
+	var scope []string
+	if window != (IssuedWindow{}) {
+		scope = append(scope, "issued "+window.String())
+	}
+	if opts.DNSName != "" && opts.SuffixMatch {
+		scope = append(scope, fmt.Sprintf("covering %s or a subdomain of it", opts.DNSName))
+	} else if opts.DNSName != "" {
+		scope = append(scope, "covering "+opts.DNSName)
+	}
+	return fmt.Sprintf("Revoke those certificates %s with reason '%s'?", strings.Join(scope, " and "), reasonCode.String())
+}
+
+// RegistrationResult records the outcome of revoking the certificates of one
+// of the registrations revoked by RevokeRegistrations.
+type RegistrationResult struct {
+	RegID  int64
+	Result BatchResult
+	Err    error
+	// Deactivated is true if the registration was deactivated, by
+	// RegistrationsOptions.Deactivate, and DeactivateErr is the error if that
+	// failed.
+	Deactivated   bool
+	DeactivateErr error
+	// Notified is true if the registration's contacts were notified, by
+	// RegistrationsOptions.Notifier.
+	Notified bool
+}
+
+// Log writes a one line summary of the registration's outcome, returning the
+// error that prevented any of its certificates from being revoked, if there
+// was one.
+func (rr RegistrationResult) Log(logger blog.Logger) error {
+	if rr.Err == ErrInterrupted {
+		logger.Warningf("Registration %d: interrupted after %d revoked, %d failed",
+			rr.RegID, rr.Result.Revoked, len(rr.Result.Failures))
+		return rr.Err
+	}
+	if _, ok := rr.Err.(BackendUnavailableError); ok {
+		logger.Warningf("Registration %d: %s. The %d certificates revoked stay revoked: "+
+			"re-run the same command, with --checkpoint, once the RA is available to revoke the rest",
+			rr.RegID, rr.Err, rr.Result.Revoked)
+		return rr.Err
+	}
+	if rr.Err != nil {
+		logger.Errf("Registration %d: %s", rr.RegID, rr.Err)
+		return rr.Err
+	}
+	var extra string
+	if rr.Deactivated {
+		extra += ", deactivated"
+	}
+	if rr.Notified {
+		extra += ", notified"
+	}
+	logger.Infof("Registration %d: %d revoked, %d skipped, %d failed%s",
+		rr.RegID, rr.Result.Revoked, len(rr.Result.Skipped), len(rr.Result.Failures), extra)
+	if len(rr.Result.Failures) > 0 {
+		return rr.Result.Failures[0].Err
+	}
+	if rr.DeactivateErr != nil {
+		logger.Errf("Registration %d: couldn't deactivate: %s", rr.RegID, rr.DeactivateErr)
+		return rr.DeactivateErr
+	}
+	return nil
+}
+
+// RegistrationsOptions configures what RevokeRegistrations does beyond
+// revoking each registration's certificates.
+type RegistrationsOptions struct {
+	// Parallelism, if greater than one, is the number of each registration's
+	// certificates revoked concurrently, by RevokeRegistrationParallel.
+	Parallelism int
+	// Deactivate deactivates each registration once all of its certificates
+	// have been revoked.
+	Deactivate bool
+	// Notifier, if not nil, notifies the contacts of each registration of the
+	// certificates revoked.
+	Notifier *Notifier
+	// Verify checks that every certificate attempted is revoked once all the
+	// registrations have been revoked.
+	Verify bool
+	// Logger, if not nil, logs the result of each registration in place of
+	// the Revoker's logger, so that the results aren't hidden when the
+	// Revoker's logger only writes warnings and errors to stdout.
+	Logger blog.Logger
+}
+
+// RegistrationsSummary summarizes the outcome of RevokeRegistrations.
+type RegistrationsSummary struct {
+	// Failed is the number of registrations all of whose certificates
+	// couldn't be revoked, including those not found, and Err is the error the
+	// last of them failed with.
+	Failed int
+	Err    error
+	// Skipped is the number of certificates skipped across all registrations.
+	Skipped int
+	// NotRevoked lists the serials attempted which aren't revoked, if
+	// RegistrationsOptions.Verify was set.
+	NotRevoked []string
+}
+
+// RevokeRegistrations revokes the certificates of each registration found, as
+// RevokeRegistration does, recording them in cp, and then logs the result of
+// each, including those not found. A registration failing doesn't stop the
+// others from being revoked, but the remaining registrations are skipped if
+// the revocation is stopped or the RA becomes unavailable. An error is only
+// returned if verifying the revocations fails.
+func (r *Revoker) RevokeRegistrations(ctx context.Context, regs Registrations, window IssuedWindow, reasonCode revocation.Reason, opts Options, ro RegistrationsOptions, cp *Checkpoint) (RegistrationsSummary, error) {
+	logger := ro.Logger
+	if logger == nil {
+		logger = r.log
+	}
+	results := append([]RegistrationResult{}, regs.NotFound...)
+	for i, reg := range regs.Found {
+		var result BatchResult
+		var err error
+		if ro.Parallelism > 1 {
+			result, err = r.RevokeRegistrationParallel(ctx, reg.ID, window, reasonCode, opts, ro.Parallelism, cp)
+		} else {
+			result, err = r.RevokeRegistration(ctx, reg.ID, window, reasonCode, opts, cp)
+		}
+		result.Log(logger)
+		rr := RegistrationResult{RegID: reg.ID, Result: result, Err: err}
+		if ro.Deactivate {
+			// Only deactivate a registration once nothing remains to be
+			// revoked, so that it can still be revoked by re-running.
+			if err == nil && len(result.Failures) == 0 {
+				rr.DeactivateErr = r.DeactivateRegistration(ctx, reg.ID, opts)
+				rr.Deactivated = rr.DeactivateErr == nil && !opts.DryRun
+			} else {
+				r.log.Warningf("Not deactivating registration %d, since not all of its certificates were revoked", reg.ID)
+			}
+		}
+		if ro.Notifier != nil {
+			if serials := result.RevokedSerials(); len(serials) > 0 {
+				// Notifying is best effort: the certificates are revoked
+				// either way.
+				nerr := ro.Notifier.NotifyRevoked(reg, serials, reasonCode, opts.Comment)
+				if nerr != nil {
+					r.log.Errf("Registration %d: couldn't notify its contacts: %s", reg.ID, nerr)
+				}
+				rr.Notified = nerr == nil
+			}
+		}
+		results = append(results, rr)
+		if err == ErrInterrupted {
+			r.log.Warningf("Interrupted, skipping the remaining %d registrations", len(regs.Found)-i-1)
+			break
+		}
+		if _, ok := err.(BackendUnavailableError); ok {
+			r.log.Warningf("Backend unavailable, skipping the remaining %d registrations", len(regs.Found)-i-1)
+			break
+		}
+	}
+
+	var summary RegistrationsSummary
+	var attempted []string
+	for _, rr := range results {
+		if err := rr.Log(logger); err != nil {
+			summary.Failed++
+			summary.Err = err
+		}
+		attempted = append(attempted, rr.Result.Attempted...)
+		summary.Skipped += len(rr.Result.Skipped)
+	}
+	if !ro.Verify {
+		return summary, nil
+	}
+	notRevoked, err := r.VerifyRevoked(attempted)
+	if err != nil {
+		return summary, err
+	}
+	for _, serial := range notRevoked {
+		r.log.Errf("Certificate %s is not revoked after attempting to revoke it", serial)
+	}
+	logger.Infof("Verified %d of %d certificates attempted are revoked", len(attempted)-len(notRevoked), len(attempted))
+	summary.NotRevoked = notRevoked
+	return summary, nil
+}
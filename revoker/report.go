@@ -0,0 +1,97 @@
+package revoker
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// Results recorded in a Report. Errors are recorded as reportError followed by
+// the error text, and skipped certificates as reportSkipped followed by the
+// reason they were skipped.
+const (
+	reportRevoked = "revoked"
+	reportSkipped = "skipped"
+	reportDryRun  = "dry-run"
+	reportError   = "error"
+)
+
+// reportHeader is the first row of every report.
+var reportHeader = []string{"serial", "CN", "reasonCode", "reasonName", "operator", "timestamp", "result"}
+
+// Report writes a CSV row describing the outcome of every attempt to revoke a
+// certificate, flushing it after every row so that the report is complete even
+// if the run fails part way through. A nil *Report records nothing.
+type Report struct {
+	mu   sync.Mutex
+	file *os.File
+	csv  *csv.Writer
+}
+
+// OpenReport creates the CSV report at path, truncating it if it exists, and
+// writes the header row.
+func OpenReport(path string) (*Report, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	rep := &Report{file: f, csv: csv.NewWriter(f)}
+	if err := rep.write(reportHeader); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return rep, nil
+}
+
+// write writes a single row and flushes it to the file.
+func (rep *Report) write(row []string) error {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if err := rep.csv.Write(row); err != nil {
+		return err
+	}
+	rep.csv.Flush()
+	return rep.csv.Error()
+}
+
+// Close closes the report file.
+func (rep *Report) Close() error {
+	if rep == nil {
+		return nil
+	}
+	return rep.file.Close()
+}
+
+// report records the result of attempting to revoke a certificate in
+// opts.Report, opts.Progress and opts.Tally, logging rather than returning any
+// failure to do so. If result is reportError or reportSkipped, err is recorded
+// along with it.
+func (r *Revoker) report(opts Options, serial, commonName string, reasonCode revocation.Reason, result string, err error) {
+	opts.Progress.record(serial, result, err)
+	opts.Tally.record(result, err)
+	if opts.Report == nil {
+		return
+	}
+	if err != nil {
+		result = fmt.Sprintf("%s: %s", result, err)
+	}
+	// A failure to determine the operator is reported by the revocation itself.
+	operator, _ := opts.operator()
+	err = opts.Report.write([]string{
+		serial,
+		commonName,
+		strconv.Itoa(int(reasonCode)),
+		reasonCode.String(),
+		operator,
+		r.clk.Now().UTC().Format(time.RFC3339),
+		result,
+	})
+	if err != nil {
+		r.log.Errf("Couldn't record certificate %s in the report: %s", serial, err)
+	}
+}
@@ -0,0 +1,959 @@
+// Package revoker implements administrative revocation of certificates, as
+// performed by the admin-revoker command. It is importable so that other tools
+// can revoke certificates without shelling out to admin-revoker.
+package revoker
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pkcs11key/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	capb "github.com/letsencrypt/boulder/ca/proto"
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	bgrpc "github.com/letsencrypt/boulder/grpc"
+	blog "github.com/letsencrypt/boulder/log"
+	rapb "github.com/letsencrypt/boulder/ra/proto"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+)
+
+// Config contains the information needed to connect to the database, RA and SA.
+type Config struct {
+	cmd.DBConfig
+	// Similarly, the Revoker needs a TLSConfig to set up its GRPC client certs,
+	// but doesn't get the TLS field from ServiceConfig, so declares its own.
+	TLS cmd.TLSConfig
+
+	RAService *cmd.GRPCClientConfig
+	SAService *cmd.GRPCClientConfig
+	// OCSPGeneratorService is the CA's OCSP generator, which is only needed
+	// to refresh OCSP responses with ocsp-refresh.
+	OCSPGeneratorService *cmd.GRPCClientConfig
+
+	// ConnectRetries is the number of times reaching each gRPC service is
+	// retried, with backoff, if it is unreachable when the admin-revoker
+	// starts, e.g. because it is restarting during a rolling deploy. Zero,
+	// the default, fails on the first attempt as configured by the service.
+	ConnectRetries int
+	// ConnectTimeout bounds each attempt to reach a gRPC service when
+	// ConnectRetries is set. Defaults to the service's HealthCheckTimeout, or
+	// 5 seconds if it has none.
+	ConnectTimeout cmd.ConfigDuration
+
+	Features map[string]bool
+
+	// IssuerCertPath is the path to the intermediate used to issue
+	// certificates. It is only needed to verify revocations with OCSP.
+	IssuerCertPath string
+	// OCSPResponderURL, if set, is queried to verify revocations instead of
+	// the responder given in each certificate's AIA extension.
+	OCSPResponderURL string
+
+	// Feed configures how feed-revoke fetches its feed.
+	Feed FeedConfig
+
+	// DebugAddr is the address from which metrics are served while the
+	// admin-revoker runs. If empty, and PushGateway isn't configured, metrics
+	// are not exported.
+	DebugAddr string
+	// PushGateway, if its URL is set, configures pushing metrics to a
+	// Prometheus Pushgateway when the admin-revoker exits.
+	PushGateway PushGatewayConfig
+
+	// Notify configures how reg-revoke --notify emails the contacts of each
+	// registration whose certificates it revoked.
+	Notify NotifyConfig
+
+	// Lock configures the advisory lock held by each run which writes to the
+	// database.
+	Lock LockConfig
+}
+
+// Revoker revokes certificates through the RA, using its own database
+// connection to find them.
+type Revoker struct {
+	rac core.RegistrationAuthority
+	sac core.StorageAuthority
+	// ogc is nil unless an OCSPGeneratorService was configured.
+	ogc   capb.OCSPGeneratorClient
+	dbMap *db.WrappedMap
+	log   blog.Logger
+	clk   clock.Clock
+	// explain, if not nil, is where the statements explaining each revocation
+	// are written. See NewExplainer.
+	explain io.Writer
+
+	revokedCerts      *prometheus.CounterVec
+	revocationErrors  *prometheus.CounterVec
+	revocationLatency prometheus.Histogram
+}
+
+// New returns a Revoker using the provided clients, which allows callers to
+// inject mocks for testing. Its metrics are registered with stats.
+func New(rac core.RegistrationAuthority, sac core.StorageAuthority, dbMap *db.WrappedMap, logger blog.Logger, clk clock.Clock, stats prometheus.Registerer) *Revoker {
+	revokedCerts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_revoker_revoked_certificates",
+		Help: "A counter of certificates administratively revoked, labelled by reason",
+	}, []string{"reason"})
+	stats.MustRegister(revokedCerts)
+
+	revocationErrors := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "admin_revoker_revocation_errors",
+		Help: "A counter of failed administrative revocation requests to the RA, or to the SA with --direct-sa, labelled by reason",
+	}, []string{"reason"})
+	stats.MustRegister(revocationErrors)
+
+	revocationLatency := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name: "admin_revoker_revocation_latency",
+		Help: "Histogram of latencies of each AdministrativelyRevokeCertificate call to the RA",
+	})
+	stats.MustRegister(revocationLatency)
+
+	return &Revoker{
+		rac:               rac,
+		sac:               sac,
+		dbMap:             dbMap,
+		log:               logger,
+		clk:               clk,
+		revokedCerts:      revokedCerts,
+		revocationErrors:  revocationErrors,
+		revocationLatency: revocationLatency,
+	}
+}
+
+// checkTLSConfig returns an error if a gRPC service is configured without the
+// TLS certificate needed to connect to it, which would otherwise surface as an
+// opaque error from deep within the gRPC client setup.
+func checkTLSConfig(c Config) error {
+	if c.TLS.CertFile != nil {
+		return nil
+	}
+	if c.RAService != nil {
+		return errors.New("gRPC RAService configured but no TLS cert provided")
+	}
+	if c.SAService != nil {
+		return errors.New("gRPC SAService configured but no TLS cert provided")
+	}
+	if c.OCSPGeneratorService != nil {
+		return errors.New("gRPC OCSPGeneratorService configured but no TLS cert provided")
+	}
+	return nil
+}
+
+// loadTLSConfig loads c, taking the private key from a PKCS#11 token if one is
+// configured.
+func loadTLSConfig(c *cmd.TLSConfig) (*tls.Config, error) {
+	return c.LoadWithPKCS11(func(hsm cmd.PKCS11KeyConfig, pub crypto.PublicKey) (crypto.Signer, error) {
+		return pkcs11key.New(hsm.Module, hsm.TokenLabel, hsm.PIN, pub)
+	})
+}
+
+// BackendError is returned by NewFromConfig when a gRPC connection to the RA or
+// SA can't be set up.
+type BackendError struct {
+	Err error
+}
+
+func (e BackendError) Error() string {
+	return e.Err.Error()
+}
+
+// BackendUnavailableError is returned by RevokeRegistration when it stopped
+// because the RA, or the SA with DirectSA, became unreachable partway through.
+// The certificates revoked before then stay revoked, and the transaction the
+// revocation read within is ended as if it had completed, so re-running the
+// revocation once the backend is back skips them and revokes the rest.
+type BackendUnavailableError struct {
+	// Revoked is the number of certificates revoked before it stopped.
+	Revoked int
+	Err     error
+}
+
+func (e BackendUnavailableError) Error() string {
+	return fmt.Sprintf("stopped after revoking %d certificates because the backend is unavailable: %s", e.Revoked, e.Err)
+}
+
+// DatabaseError is returned by NewFromConfig when the database connection
+// can't be set up.
+type DatabaseError struct {
+	Err error
+}
+
+func (e DatabaseError) Error() string {
+	return e.Err.Error()
+}
+
+// redactDBURL returns the database URL with any password replaced, so that
+// it can be logged. A URL which can't be parsed isn't returned at all, since
+// where the password is within it can't be known.
+func redactDBURL(dbURL string) string {
+	dsn, err := mysql.ParseDSN(dbURL)
+	if err != nil {
+		return "(unparseable URL)"
+	}
+	if dsn.Passwd != "" {
+		dsn.Passwd = "REDACTED"
+	}
+	return dsn.FormatDSN()
+}
+
+// connectDB connects to the database described by the config, returning any
+// failure as a DatabaseError.
+func connectDB(c Config, logger blog.Logger) (*db.WrappedMap, error) {
+	dbURL, err := c.DBConfig.URL()
+	if err != nil {
+		return nil, DatabaseError{err}
+	}
+	logger.Infof("Connecting to database %s", redactDBURL(dbURL))
+	dbSettings := sa.DbSettings{
+		MaxOpenConns:    c.DBConfig.MaxDBConns,
+		MaxIdleConns:    c.DBConfig.MaxIdleConns,
+		ConnMaxLifetime: c.DBConfig.ConnMaxLifetime.Duration,
+	}
+	logger.Infof("Database connection pool: %s", dbSettings)
+	dbMap, err := sa.NewDbMapWithSettings(dbURL, dbSettings)
+	if err != nil {
+		return nil, DatabaseError{err}
+	}
+	return dbMap, nil
+}
+
+// NewFromConfig connects to the database, RA and SA described by the config,
+// and the CA's OCSP generator if configured, and returns a Revoker using them,
+// registering its metrics and those of its gRPC clients with stats. Failures to
+// connect are returned as a DatabaseError or BackendError so that callers can
+// tell them apart. Unreachable gRPC services are retried as configured by
+// c.ConnectRetries.
+func NewFromConfig(c Config, logger blog.Logger, clk clock.Clock, stats prometheus.Registerer) (*Revoker, error) {
+	if err := checkTLSConfig(c); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := loadTLSConfig(&c.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	clientMetrics := bgrpc.NewClientMetrics(stats)
+	setup := func(sc *cmd.GRPCClientConfig) (*grpc.ClientConn, error) {
+		return bgrpc.ClientSetup(sc, tlsConfig, clientMetrics, clk)
+	}
+	raConn, err := connectService("RA", c, c.RAService, setup, clk, logger)
+	if err != nil {
+		return nil, BackendError{err}
+	}
+	rac := bgrpc.NewRegistrationAuthorityClient(rapb.NewRegistrationAuthorityClient(raConn))
+
+	dbMap, err := connectDB(c, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	saConn, err := connectService("SA", c, c.SAService, setup, clk, logger)
+	if err != nil {
+		return nil, BackendError{err}
+	}
+	sac := bgrpc.NewStorageAuthorityClient(sapb.NewStorageAuthorityClient(saConn))
+
+	r := New(rac, sac, dbMap, logger, clk, stats)
+	if c.OCSPGeneratorService != nil {
+		caConn, err := connectService("OCSP generator", c, c.OCSPGeneratorService, setup, clk, logger)
+		if err != nil {
+			return nil, BackendError{err}
+		}
+		r.ogc = bgrpc.NewOCSPGeneratorClient(capb.NewOCSPGeneratorClient(caConn))
+	}
+	return r, nil
+}
+
+// TraceSQL logs every query the Revoker makes against the database at debug
+// level.
+func (r *Revoker) TraceSQL() {
+	sa.SetSQLDebug(r.dbMap, r.log)
+}
+
+// ErrReadOnlyDatabase is returned, wrapped in a DatabaseError, by
+// CheckWritable when the database is read-only.
+var ErrReadOnlyDatabase = errors.New("the database is read-only, so it is probably a replica: point the config's dbConnect, or --dburl, at the primary")
+
+// CheckWritable returns a DatabaseError if the database the Revoker is
+// connected to is read-only, as a replica is, so that a command which writes
+// to it refuses to start rather than failing part way through, or reading
+// stale data which hasn't been replicated yet.
+func (r *Revoker) CheckWritable() error {
+	return checkWritable(r.dbMap)
+}
+
+func checkWritable(s db.OneSelector) error {
+	var readOnly int64
+	err := s.SelectOne(&readOnly, "SELECT @@global.read_only")
+	if err != nil {
+		return DatabaseError{fmt.Errorf("checking whether the database is read-only: %s", err)}
+	}
+	if readOnly != 0 {
+		return DatabaseError{ErrReadOnlyDatabase}
+	}
+	return nil
+}
+
+// Options controls how each certificate is revoked.
+type Options struct {
+	// DryRun logs the certificates which would be revoked without revoking
+	// them.
+	DryRun bool
+	// Rollback rolls back the transaction a revocation is run in, rather than
+	// committing it, even if it succeeds, for rehearsing a revocation. Unlike
+	// DryRun, certificates are revoked through the RA. The RA and SA commit
+	// revocations through their own database connections, and the CA signs an
+	// OCSP response for each, so only the admin-revoker's own reads and writes
+	// are rolled back: every certificate revoked stays revoked.
+	Rollback bool
+	// Force changes the reason of certificates which are already revoked,
+	// rather than skipping them, recording the reason they were previously
+	// revoked with in the audit log. They keep the date they were revoked at
+	// unless RevocationDate is set. Those already revoked with the same reason
+	// are still skipped.
+	Force bool
+	// IncludePrecert also finds precertificates for which no final
+	// certificate was issued, and records whether a revocation covered the
+	// precertificate as well as the final certificate.
+	IncludePrecert bool
+	// SkipExpired skips certificates which have already expired, rather than
+	// revoking them. Revoking an expired certificate has no security benefit,
+	// but operators publishing CRLs may still want them included.
+	SkipExpired bool
+	// DNSName, if not empty, skips certificates which don't include it among
+	// their SANs, e.g. to revoke only those of a registration's certificates
+	// covering a compromised hostname. With SuffixMatch, certificates
+	// including a subdomain of it are revoked as well.
+	DNSName     string
+	SuffixMatch bool
+	// skipNotFound skips serials for which no certificate is found, rather
+	// than failing, for bulk revocations which tolerate them.
+	skipNotFound bool
+	// Comment is an optional explanation of why the certificates are being
+	// revoked, which is stored with the revocation.
+	Comment string
+	// RevocationDate, if not zero, is stored as the date the certificates were
+	// revoked, rather than the current time, e.g. to backdate the revocation
+	// to when a key compromise was reported. It must not be before a
+	// certificate's notBefore.
+	RevocationDate time.Time
+	// MaxAttempts is the number of times a revocation is attempted when the RA
+	// returns a transient error. Values less than 2 disable retries.
+	MaxAttempts int
+	// RetryBaseDelay is the delay before the first retry, which doubles with
+	// each subsequent retry.
+	RetryBaseDelay time.Duration
+	// Operator is the name recorded as having performed the revocation. If
+	// empty, the username of the current user is used.
+	Operator string
+	// Output, if not nil, records the serial of each certificate revoked.
+	Output *SerialOutput
+	// Report, if not nil, records the outcome of every attempt to revoke a
+	// certificate.
+	Report *Report
+	// Progress, if not nil, is written a line for every attempt to revoke a
+	// certificate as it completes.
+	Progress *Progress
+	// Tally, if not nil, counts the outcome of every attempt to revoke a
+	// certificate, for recording with the run.
+	Tally *RunTally
+	// VerifyOCSP, if not nil, is used to confirm that each revocation has
+	// propagated to the OCSP responder. A revocation which can't be verified
+	// is reported as a failure, even though the certificate was revoked.
+	VerifyOCSP *OCSPVerifier
+	// CallTimeout, if not zero, bounds each revocation request made to the RA,
+	// or the SA when DirectSA is set, so that a single stuck request fails
+	// rather than consuming the whole context's deadline. Each request is
+	// still bounded by the context's deadline if that is sooner.
+	CallTimeout time.Duration
+	// RateLimit, if not nil, limits the rate of calls to the RA, including
+	// retries, across all concurrent revocations using it.
+	RateLimit *RateLimiter
+	// DirectSA revokes certificates by writing the revocation directly through
+	// the SA, bypassing the RA, for use when the RA is unavailable. No OCSP
+	// response is signed: the stored response is cleared instead, and a new
+	// one must be generated separately for the revocation to be published.
+	DirectSA bool
+	// CommitEvery, if not zero, commits the transaction a sequential bulk
+	// revocation reads within after every CommitEvery certificates revoked,
+	// beginning a new one, rather than holding a single transaction open for
+	// the whole revocation. The revocation then no longer reads from a single
+	// consistent view of the database, and a failure only rolls back the
+	// current transaction.
+	CommitEvery int
+	// BatchSize, if not zero, is the number of a registration's certificates
+	// selected at a time by revocations by registration, in place of
+	// DefaultBatchSize.
+	BatchSize int
+	// Stop, if not nil, is closed to interrupt a bulk revocation gracefully.
+	// Revocations already in progress are allowed to finish, but no more are
+	// started and ErrInterrupted is returned.
+	Stop <-chan struct{}
+}
+
+// operator returns the name recorded as having performed the revocation:
+// o.Operator if it was provided, and otherwise the current user's username.
+func (o Options) operator() (string, error) {
+	if o.Operator != "" {
+		return o.Operator, nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+// revocationDate returns the date to store with a revocation performed now
+// according to clk: o.RevocationDate if it was provided, and otherwise now.
+func (o Options) revocationDate(clk clock.Clock) time.Time {
+	if !o.RevocationDate.IsZero() {
+		return o.RevocationDate
+	}
+	return clk.Now()
+}
+
+// ErrInterrupted is returned by bulk revocations which were stopped by closing
+// Options.Stop before every certificate was revoked.
+var ErrInterrupted = errors.New("revocation interrupted")
+
+// Reasons a serial is skipped rather than revoked, as recorded in a
+// BatchResult and in the report.
+const (
+	skipAlreadyRevoked = "already revoked"
+	skipCheckpointed   = "revoked by a previous run"
+	skipExpired        = "expired"
+	skipNotFound       = "not found"
+	skipDNSName        = "not covering the DNS name"
+	skipSameReason     = "already revoked with the same reason"
+)
+
+// skipError is returned by revokeBySerial when a serial was skipped rather
+// than revoked, so that bulk revocations can record why. It isn't a failure.
+// Its text is just the reason it was skipped.
+type skipError struct {
+	reason string
+}
+
+func (e skipError) Error() string {
+	return e.reason
+}
+
+var (
+	errSkippedRevoked      = skipError{skipAlreadyRevoked}
+	errSkippedCheckpointed = skipError{skipCheckpointed}
+	errSkippedExpired      = skipError{skipExpired}
+	errSkippedNotFound     = skipError{skipNotFound}
+	errSkippedDNSName      = skipError{skipDNSName}
+	errSkippedSameReason   = skipError{skipSameReason}
+)
+
+// stopped returns true if o.Stop has been closed.
+func (o Options) stopped() bool {
+	select {
+	case <-o.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryMaxDelay bounds the exponential backoff between revocation attempts.
+const retryMaxDelay = 30 * time.Second
+
+// isTransient returns true if err is a gRPC error indicating that the RA was
+// temporarily unavailable, such that retrying the request may succeed.
+func isTransient(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
+
+// isOverloaded returns true if err is a gRPC error indicating that the RA is
+// overloaded, such that it should be called less often.
+func isOverloaded(err error) bool {
+	return status.Code(err) == codes.ResourceExhausted
+}
+
+// revocationComment returns the comment stored with a revocation performed by
+// operator, matching the one built by the RA.
+func revocationComment(operator string, comment string) string {
+	if comment == "" {
+		return fmt.Sprintf("revoked by %s", operator)
+	}
+	return fmt.Sprintf("revoked by %s: %s", operator, comment)
+}
+
+// revokeThroughSA marks cert as revoked by writing the revocation directly
+// through the SA, as the RA would after having the CA sign an OCSP response
+// for it. Since the CA isn't involved, the stored OCSP response is cleared.
+func (r *Revoker) revokeThroughSA(ctx context.Context, cert *x509.Certificate, reasonCode revocation.Reason, user string, opts Options, force bool) error {
+	serial := core.SerialToString(cert.SerialNumber)
+	reason := int64(reasonCode)
+	date := opts.revocationDate(r.clk).UnixNano()
+	if force && opts.RevocationDate.IsZero() {
+		// Like the RA, keep the date the certificate was revoked at.
+		status, err := r.sac.GetCertificateStatus(ctx, serial)
+		if err != nil {
+			return err
+		}
+		date = status.RevokedDate.UnixNano()
+	}
+	comment := revocationComment(user, opts.Comment)
+	req := &sapb.RevokeCertificateRequest{
+		Serial:   &serial,
+		Reason:   &reason,
+		Date:     &date,
+		Response: []byte{},
+		Comment:  &comment,
+	}
+	if force {
+		return r.sac.UpdateRevokedCertificate(ctx, req)
+	}
+	return r.sac.RevokeCertificate(ctx, req)
+}
+
+// callContext returns the context for a single request to the RA or SA,
+// bounded by timeout unless it is zero.
+func callContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// revokeWithRetry administratively revokes cert, retrying with exponential
+// backoff as configured by opts if the RA, or the SA when opts.DirectSA is set,
+// returns a transient error. Permanent errors are returned immediately. Each
+// attempt waits for opts.RateLimit, and its latency is recorded. If
+// opts.RateLimit is adaptive, a request rejected because the backend is
+// overloaded is retried once the rate has been halved, rather than failing.
+// An attempt which doesn't complete within opts.CallTimeout fails with a
+// BackendError, without being retried. With force, cert must already be
+// revoked, and its reason is changed to reasonCode.
+func (r *Revoker) revokeWithRetry(ctx context.Context, cert *x509.Certificate, reasonCode revocation.Reason, user string, opts Options, force bool) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = opts.RateLimit.wait(ctx)
+		if err != nil {
+			return err
+		}
+		callCtx, cancel := callContext(ctx, opts.CallTimeout)
+		start := r.clk.Now()
+		backend := "RA"
+		if opts.DirectSA {
+			backend = "SA"
+			err = r.revokeThroughSA(callCtx, cert, reasonCode, user, opts, force)
+		} else {
+			err = r.rac.AdministrativelyRevokeCertificate(callCtx, *cert, reasonCode, user, opts.Comment, opts.RevocationDate, force)
+		}
+		// Only the request's own deadline is reported as a timeout of the
+		// request: the context's deadline is reported by the caller.
+		callTimedOut := err != nil && callCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+		cancel()
+		latency := r.clk.Since(start)
+		r.revocationLatency.Observe(latency.Seconds())
+		r.log.Debugf("%s revocation request for certificate %s (attempt %d) took %s",
+			backend, core.SerialToString(cert.SerialNumber), attempt, latency)
+		if err == nil {
+			opts.RateLimit.speedUp()
+			return nil
+		}
+		if callTimedOut {
+			r.log.Warningf("%s request revoking certificate %s (attempt %d) timed out after %s: %s",
+				backend, core.SerialToString(cert.SerialNumber), attempt, opts.CallTimeout, err)
+			return BackendError{fmt.Errorf("%s request timed out after %s: %s", backend, opts.CallTimeout, err)}
+		}
+		if isOverloaded(err) && opts.RateLimit.isAdaptive() && attempt < opts.MaxAttempts {
+			r.log.Warningf("%s overloaded revoking certificate %s (attempt %d of %d), slowing to %.2f requests per second: %s",
+				backend, core.SerialToString(cert.SerialNumber), attempt, opts.MaxAttempts, opts.RateLimit.slowDown(), err)
+			continue
+		}
+		if !isTransient(err) || attempt >= opts.MaxAttempts {
+			return err
+		}
+		delay := core.RetryBackoff(attempt, opts.RetryBaseDelay, retryMaxDelay, 2)
+		r.log.Warningf("Transient error revoking certificate %s (attempt %d of %d), retrying in %s: %s",
+			core.SerialToString(cert.SerialNumber), attempt, opts.MaxAttempts, delay, err)
+		r.clk.Sleep(delay)
+	}
+}
+
+// errDryRun and errRollback are returned from within a transaction when
+// running in dry-run or rollback mode so that the transaction is rolled back
+// rather than committed.
+var (
+	errDryRun   = errors.New("dry run, rolling back transaction")
+	errRollback = errors.New("rollback mode, rolling back transaction")
+)
+
+// inTransaction runs f in a database transaction, which is rolled back rather
+// than committed if opts.DryRun or opts.Rollback is set.
+func (r *Revoker) inTransaction(ctx context.Context, opts Options, f func(db.Executor) error) error {
+	_, err := db.WithTransaction(ctx, r.dbMap, func(txWithCtx db.Executor) (interface{}, error) {
+		err := f(txWithCtx)
+		if err == nil && opts.DryRun {
+			err = errDryRun
+		} else if err == nil && opts.Rollback {
+			err = errRollback
+		}
+		return nil, err
+	})
+	switch err {
+	case errDryRun:
+		return nil
+	case errRollback:
+		r.log.Warning("Rolled back the transaction because of --rollback. Certificates revoked through the RA are still revoked")
+		return nil
+	}
+	return err
+}
+
+// revocationEvent is the structured audit log record emitted for every
+// certificate revoked by the admin-revoker.
+type revocationEvent struct {
+	Serial       string            `json:"serial"`
+	ReasonCode   revocation.Reason `json:"reasonCode"`
+	ReasonString string            `json:"reasonString"`
+	// Operator is the --operator given to the admin-revoker, or else the
+	// local username of whoever ran it
+	Operator  string    `json:"operator"`
+	Comment   string    `json:"comment,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// RevocationDate is the date stored with the revocation, which differs
+	// from Timestamp if the revocation was backdated.
+	RevocationDate time.Time `json:"revocationDate"`
+	// DirectSA is set if the revocation was written directly through the SA,
+	// bypassing the RA, so no OCSP response was signed for it.
+	DirectSA bool `json:"directSA,omitempty"`
+	// Revoked is what was revoked for the serial: the certificate, the
+	// precertificate, or both.
+	Revoked string `json:"revoked"`
+	// PreviousReasonCode is the reason the certificate was already revoked
+	// with, if --force revoked it again with a different reason.
+	PreviousReasonCode   *revocation.Reason `json:"previousReasonCode,omitempty"`
+	PreviousReasonString string             `json:"previousReasonString,omitempty"`
+}
+
+// RevokeSerial revokes the certificate with the provided hex serial.
+func (r *Revoker) RevokeSerial(ctx context.Context, serial string, reasonCode revocation.Reason, opts Options) error {
+	return r.inTransaction(ctx, opts, func(tx db.Executor) error {
+		return ignoreSkipped(r.revokeBySerial(ctx, dbLookup{tx}, serial, reasonCode, opts))
+	})
+}
+
+// ignoreSkipped returns nil in place of a skipError, for revocations which
+// don't record skipped certificates.
+func ignoreSkipped(err error) error {
+	if _, ok := err.(skipError); ok {
+		return nil
+	}
+	return err
+}
+
+// revokeBySerial revokes the certificate with the provided serial through the
+// RA, finding it and checking whether it is already revoked with certs. If
+// opts.IncludePrecert is set, the precertificate with the serial is revoked if
+// there is no final certificate. A certificate which is already revoked, or is
+// skipped as configured by opts, isn't revoked and a skipError is returned.
+// With opts.Force, a certificate revoked with another reason, including one
+// revoked concurrently after it was checked, has its reason changed instead.
+func (r *Revoker) revokeBySerial(ctx context.Context, certs certLookup, serial string, reasonCode revocation.Reason, opts Options) (err error) {
+	var commonName string
+	var result string
+	defer func() {
+		var reportErr error
+		if result == "" && err != nil {
+			result = reportError
+			if _, ok := err.(skipError); ok {
+				result = reportSkipped
+			}
+			reportErr = err
+		}
+		r.report(opts, serial, commonName, reasonCode, result, reportErr)
+	}()
+
+	if err := revocation.CheckAdminReason(reasonCode); err != nil {
+		return berrors.MalformedError("%s", err)
+	}
+
+	serial, err = revocation.NormalizeSerial(serial)
+	if err != nil {
+		return berrors.MalformedError("invalid serial: %s", err)
+	}
+
+	certObj, kind, err := findCertificate(certs, serial, opts.IncludePrecert)
+	if berrors.Is(err, berrors.NotFound) && opts.skipNotFound {
+		r.log.Infof("Certificate %s not found, skipping", serial)
+		return errSkippedNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	cert, err := x509.ParseCertificate(certObj.DER)
+	if err != nil {
+		return
+	}
+	commonName = cert.Subject.CommonName
+
+	if !opts.RevocationDate.IsZero() && opts.RevocationDate.Before(cert.NotBefore) {
+		return berrors.MalformedError("revocation date %s is before the certificate's notBefore %s",
+			opts.RevocationDate.Format(time.RFC3339), cert.NotBefore.Format(time.RFC3339))
+	}
+
+	if opts.SkipExpired && r.clk.Now().After(cert.NotAfter) {
+		r.log.Infof("Certificate %s expired at %s, skipping", serial, cert.NotAfter)
+		return errSkippedExpired
+	}
+
+	if opts.DNSName != "" && !coversDNSName(cert.DNSNames, opts.DNSName, opts.SuffixMatch) {
+		r.log.Infof("Certificate %s doesn't cover %s (names: %s), skipping", serial, opts.DNSName, strings.Join(cert.DNSNames, ", "))
+		return errSkippedDNSName
+	}
+
+	status, err := certs.status(serial)
+	if err != nil {
+		return err
+	}
+	var previousReason *revocation.Reason
+	var previousDate time.Time
+	if status == core.OCSPStatusRevoked {
+		if !opts.Force {
+			r.log.Infof("Certificate %s already revoked, skipping", serial)
+			return errSkippedRevoked
+		}
+		previous, revokedAt, err := certs.previousRevocation(serial)
+		if err != nil {
+			return err
+		}
+		if previous == reasonCode {
+			r.log.Infof("Certificate %s already revoked with reason '%s', skipping even though --force was provided", serial, reasonCode.String())
+			return errSkippedSameReason
+		}
+		previousReason = &previous
+		previousDate = revokedAt
+	}
+
+	if opts.DryRun {
+		r.log.Infof("Would revoke %s %s (CN: %q, notAfter: %s) with reason '%s'",
+			kind, serial, cert.Subject.CommonName, cert.NotAfter, reasonCode.String())
+		opts.Tally.recordImpact(cert.NotAfter, previousReason != nil, r.clk.Now())
+		if r.explain != nil {
+			err = r.explainRevocation(serial, reasonCode, opts, previousReason != nil, previousDate)
+		}
+		result = reportDryRun
+		return
+	}
+
+	operator, err := opts.operator()
+	if err != nil {
+		return
+	}
+	err = r.revokeWithRetry(ctx, cert, reasonCode, operator, opts, previousReason != nil)
+	if berrors.Is(err, berrors.AlreadyRevoked) {
+		// The certificate was revoked after we checked, e.g. by another
+		// revocation of the same serial running concurrently.
+		if !opts.Force {
+			r.log.Infof("Certificate %s was revoked concurrently, skipping", serial)
+			err = errSkippedRevoked
+			return
+		}
+		// With --force, its reason is changed as if it had already been
+		// revoked when we checked. The reason it was revoked with is read
+		// from the SA, since certs may not see the concurrent revocation.
+		var status core.CertificateStatus
+		status, err = r.sac.GetCertificateStatus(ctx, serial)
+		if err != nil {
+			return
+		}
+		if status.RevokedReason == reasonCode {
+			r.log.Infof("Certificate %s was revoked concurrently with reason '%s', skipping even though --force was provided", serial, reasonCode.String())
+			err = errSkippedSameReason
+			return
+		}
+		previousReason = &status.RevokedReason
+		previousDate = status.RevokedDate
+		err = r.revokeWithRetry(ctx, cert, reasonCode, operator, opts, true)
+	}
+	if err != nil {
+		r.revocationErrors.WithLabelValues(reasonCode.String()).Inc()
+		return
+	}
+	r.revokedCerts.WithLabelValues(reasonCode.String()).Inc()
+	result = reportRevoked
+	if kind != kindCertificate {
+		result = fmt.Sprintf("%s %s", reportRevoked, kind)
+	}
+
+	auditMsg := "Administrative revocation"
+	if opts.DirectSA {
+		auditMsg = "Administrative revocation directly through the SA, bypassing the RA"
+	}
+	event := revocationEvent{
+		Serial:         serial,
+		ReasonCode:     reasonCode,
+		ReasonString:   reasonCode.String(),
+		Operator:       operator,
+		Comment:        opts.Comment,
+		Timestamp:      r.clk.Now(),
+		RevocationDate: opts.revocationDate(r.clk),
+		DirectSA:       opts.DirectSA,
+		Revoked:        kind,
+	}
+	// The RA, or the SA with DirectSA, has only succeeded once the new reason
+	// is stored, so only now is the change recorded.
+	if previousReason != nil {
+		event.PreviousReasonCode = previousReason
+		event.PreviousReasonString = previousReason.String()
+		if opts.RevocationDate.IsZero() {
+			event.RevocationDate = previousDate
+		}
+	}
+	r.log.AuditObject(auditMsg, event)
+	if previousReason != nil {
+		r.log.Warningf("Changed the revocation reason of %s %s from '%s' to '%s' because --force was provided",
+			kind, serial, previousReason.String(), reasonCode.String())
+	} else {
+		r.log.Infof("Revoked %s %s with reason '%s'", kind, serial, reasonCode.String())
+	}
+	err = opts.Output.record(serial)
+	if err != nil {
+		r.log.Errf("Revoked certificate %s but couldn't record it to the output file: %s", serial, err)
+		return
+	}
+	if opts.VerifyOCSP != nil {
+		err = opts.VerifyOCSP.Verify(ctx, cert, reasonCode)
+		if err != nil {
+			r.log.Errf("Revoked certificate %s but couldn't verify the revocation with OCSP: %s", serial, err)
+			err = fmt.Errorf("revoked, but OCSP verification failed: %s", err)
+			result = fmt.Sprintf("%s: %s", reportError, err)
+			return
+		}
+		r.log.Infof("Verified revocation of certificate %s with OCSP", serial)
+	}
+	return
+}
+
+// coversDNSName returns whether any of the SANs is the name, ignoring case and
+// a trailing dot, or with suffix, is a subdomain of it. A wildcard SAN is a
+// subdomain of the name it is a wildcard of.
+func coversDNSName(sans []string, name string, suffix bool) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, san := range sans {
+		san = strings.TrimSuffix(strings.ToLower(san), ".")
+		if san == name || (suffix && strings.HasSuffix(san, "."+name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fingerprintToDigest converts a hex encoded SHA-256 fingerprint, optionally
+// colon separated as printed by `openssl x509 -fingerprint -sha256`, to the
+// digest format stored in the certificates table.
+func fingerprintToDigest(fingerprint string) (string, error) {
+	fingerprint = strings.ToLower(strings.Replace(strings.TrimSpace(fingerprint), ":", "", -1))
+	h, err := hex.DecodeString(fingerprint)
+	if err != nil {
+		return "", berrors.MalformedError("fingerprint %q is not valid hex: %s", fingerprint, err)
+	}
+	if len(h) != sha256.Size {
+		return "", berrors.MalformedError("fingerprint %q is not a SHA-256 digest", fingerprint)
+	}
+	return base64.RawURLEncoding.EncodeToString(h), nil
+}
+
+// RevokeFingerprint revokes the certificate with the provided hex SHA-256
+// fingerprint. This scans the whole certificates table, so it can be slow.
+func (r *Revoker) RevokeFingerprint(ctx context.Context, fingerprint string, reasonCode revocation.Reason, opts Options) error {
+	digest, err := fingerprintToDigest(fingerprint)
+	if err != nil {
+		return err
+	}
+	return r.inTransaction(ctx, opts, func(tx db.Executor) error {
+		certObj, err := sa.SelectCertificateByFingerprint(tx, digest)
+		if err != nil {
+			if db.IsNoRows(err) {
+				return berrors.NotFoundError("certificate with fingerprint %q not found", fingerprint)
+			}
+			return err
+		}
+		return ignoreSkipped(r.revokeBySerial(ctx, dbLookup{tx}, certObj.Serial, reasonCode, opts))
+	})
+}
+
+// CertificateMismatchError is returned by RevokeCertificate when the
+// certificate provided doesn't match the one stored with its serial, e.g.
+// because it wasn't issued by this CA.
+type CertificateMismatchError struct {
+	Serial string
+}
+
+func (e CertificateMismatchError) Error() string {
+	return fmt.Sprintf("certificate with serial %s doesn't match the one stored with that serial", e.Serial)
+}
+
+// checkStoredCertificate returns a CertificateMismatchError unless cert is
+// byte for byte the certificate stored with its serial or, if includePrecert
+// is set, the precertificate.
+func checkStoredCertificate(certs certLookup, cert *x509.Certificate, includePrecert bool) error {
+	serial := core.SerialToString(cert.SerialNumber)
+	stored, kind, err := findCertificate(certs, serial, includePrecert)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(stored.DER, cert.Raw) {
+		return nil
+	}
+	if kind == kindBoth {
+		// findCertificate returns the final certificate, but the one provided
+		// may be its precertificate.
+		precert, err := certs.precertificate(serial)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(precert.DER, cert.Raw) {
+			return nil
+		}
+	}
+	return CertificateMismatchError{serial}
+}
+
+// RevokeCertificate revokes cert, as provided e.g. in a report of a
+// compromised certificate, after checking that it is the certificate stored
+// with its serial. A certificate which doesn't match is never revoked, and a
+// CertificateMismatchError is returned.
+func (r *Revoker) RevokeCertificate(ctx context.Context, cert *x509.Certificate, reasonCode revocation.Reason, opts Options) error {
+	serial := core.SerialToString(cert.SerialNumber)
+	r.log.Infof("Revoking certificate %s issued by %q", serial, cert.Issuer.String())
+	return r.inTransaction(ctx, opts, func(tx db.Executor) error {
+		err := checkStoredCertificate(dbLookup{tx}, cert, opts.IncludePrecert)
+		if err != nil {
+			return err
+		}
+		return ignoreSkipped(r.revokeBySerial(ctx, dbLookup{tx}, serial, reasonCode, opts))
+	})
+}
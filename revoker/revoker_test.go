@@ -0,0 +1,3017 @@
+package revoker
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jmhodges/clock"
+	caPB "github.com/letsencrypt/boulder/ca/proto"
+	"github.com/letsencrypt/boulder/cmd"
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/db"
+	berrors "github.com/letsencrypt/boulder/errors"
+	"github.com/letsencrypt/boulder/goodkey"
+	blog "github.com/letsencrypt/boulder/log"
+	"github.com/letsencrypt/boulder/metrics"
+	"github.com/letsencrypt/boulder/mocks"
+	"github.com/letsencrypt/boulder/ra"
+	"github.com/letsencrypt/boulder/revocation"
+	"github.com/letsencrypt/boulder/sa"
+	sapb "github.com/letsencrypt/boulder/sa/proto"
+	"github.com/letsencrypt/boulder/sa/satest"
+	"github.com/letsencrypt/boulder/test"
+	"github.com/letsencrypt/boulder/test/vars"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/crypto/ocsp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/go-gorp/gorp.v2"
+)
+
+type mockCA struct {
+	mocks.MockCA
+}
+
+func (ca *mockCA) GenerateOCSP(ctx context.Context, req *caPB.GenerateOCSPRequest) (*caPB.OCSPResponse, error) {
+	return &caPB.OCSPResponse{}, nil
+}
+
+func TestRevokeBatch(t *testing.T) {
+	log := blog.UseMock()
+	fc := clock.NewFake()
+	// Set to some non-zero time.
+	fc.Set(time.Date(2015, 3, 4, 5, 0, 0, 0, time.UTC))
+	dbMap, err := sa.NewDbMap(vars.DBConnSA, 0)
+	if err != nil {
+		t.Fatalf("Failed to create dbMap: %s", err)
+	}
+	ssa, err := sa.NewSQLStorageAuthority(dbMap, fc, log, metrics.NoopRegisterer, 1)
+	if err != nil {
+		t.Fatalf("Failed to create SA: %s", err)
+	}
+	defer test.ResetSATestDatabase(t)
+	reg := satest.CreateWorkingRegistration(t, ssa)
+
+	ra := ra.NewRegistrationAuthorityImpl(fc,
+		log,
+		metrics.NoopRegisterer,
+		1, goodkey.KeyPolicy{}, 100, true, false, 300*24*time.Hour, 7*24*time.Hour, nil, nil, 0, nil, nil, &x509.Certificate{})
+	ra.SA = ssa
+	ra.CA = &mockCA{}
+
+	serialFile, err := ioutil.TempFile("", "serials")
+	test.AssertNotError(t, err, "failed to open temp file")
+	defer os.Remove(serialFile.Name())
+
+	// The file is read as by batch-revoke, ignoring comments, surrounding
+	// whitespace and Windows line endings.
+	_, err = serialFile.WriteString("# serials to revoke\r\n")
+	test.AssertNotError(t, err, "failed to write comment to temp file")
+
+	serials := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)}
+	k, err := rsa.GenerateKey(rand.Reader, 512)
+	test.AssertNotError(t, err, "failed to generate test key")
+	issued := time.Now().UnixNano()
+	for _, serial := range serials {
+		template := &x509.Certificate{
+			SerialNumber: serial,
+			DNSNames:     []string{"asd"},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &k.PublicKey, k)
+		test.AssertNotError(t, err, "failed to generate test cert")
+		_, err = ssa.AddPrecertificate(context.Background(), &sapb.AddCertificateRequest{
+			Der:    der,
+			RegID:  &reg.ID,
+			Issued: &issued,
+		})
+		test.AssertNotError(t, err, "failed to add test cert")
+		now := time.Now()
+		_, err = ssa.AddCertificate(context.Background(), der, reg.ID, nil, &now)
+		test.AssertNotError(t, err, "failed to add test cert")
+		_, err = serialFile.WriteString(fmt.Sprintf("  %s\r\n", core.SerialToString(serial)))
+		test.AssertNotError(t, err, "failed to write serial to temp file")
+	}
+	// A serial which can't be revoked is recorded as a failure, without
+	// stopping the others from being revoked.
+	missing := core.SerialToString(big.NewInt(4))
+	_, err = serialFile.WriteString(missing + "\n")
+	test.AssertNotError(t, err, "failed to write serial to temp file")
+
+	r := New(ra, ssa, dbMap, log, fc, metrics.NoopRegisterer)
+	result, err := r.RevokeBatch(context.Background(), serialFile.Name(), 0, 2, Options{})
+	test.AssertNotError(t, err, "revokeBatch failed")
+	test.AssertEquals(t, result.Revoked, len(serials))
+	test.AssertEquals(t, len(result.Failures), 1)
+	test.AssertEquals(t, result.Failures[0].Serial, missing)
+
+	for _, serial := range serials {
+		status, err := ssa.GetCertificateStatus(context.Background(), core.SerialToString(serial))
+		test.AssertNotError(t, err, "failed to retrieve certificate status")
+		test.AssertEquals(t, status.Status, core.OCSPStatusRevoked)
+		matches := log.GetAllMatching(fmt.Sprintf(`Administrative revocation JSON=\{"serial":"%s","reasonCode":0,`, core.SerialToString(serial)))
+		test.AssertEquals(t, len(matches), 1)
+	}
+	test.AssertEquals(t, test.CountCounterVec("reason", "unspecified", r.revokedCerts), len(serials))
+	test.AssertEquals(t, test.CountCounterVec("reason", "unspecified", r.revocationErrors), 0)
+}
+
+func TestRevokeByRegParallel(t *testing.T) {
+	log := blog.UseMock()
+	fc := clock.NewFake()
+	fc.Set(time.Date(2015, 3, 4, 5, 0, 0, 0, time.UTC))
+	dbMap, err := sa.NewDbMap(vars.DBConnSA, 0)
+	if err != nil {
+		t.Fatalf("Failed to create dbMap: %s", err)
+	}
+	ssa, err := sa.NewSQLStorageAuthority(dbMap, fc, log, metrics.NoopRegisterer, 1)
+	if err != nil {
+		t.Fatalf("Failed to create SA: %s", err)
+	}
+	defer test.ResetSATestDatabase(t)
+	reg := satest.CreateWorkingRegistration(t, ssa)
+
+	ra := ra.NewRegistrationAuthorityImpl(fc,
+		log,
+		metrics.NoopRegisterer,
+		1, goodkey.KeyPolicy{}, 100, true, false, 300*24*time.Hour, 7*24*time.Hour, nil, nil, 0, nil, nil, &x509.Certificate{})
+	ra.SA = ssa
+	ra.CA = &mockCA{}
+
+	serials := []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4), big.NewInt(5)}
+	k, err := rsa.GenerateKey(rand.Reader, 512)
+	test.AssertNotError(t, err, "failed to generate test key")
+	issued := time.Now().UnixNano()
+	for _, serial := range serials {
+		template := &x509.Certificate{
+			SerialNumber: serial,
+			DNSNames:     []string{"asd"},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &k.PublicKey, k)
+		test.AssertNotError(t, err, "failed to generate test cert")
+		_, err = ssa.AddPrecertificate(context.Background(), &sapb.AddCertificateRequest{
+			Der:    der,
+			RegID:  &reg.ID,
+			Issued: &issued,
+		})
+		test.AssertNotError(t, err, "failed to add test cert")
+		now := time.Now()
+		_, err = ssa.AddCertificate(context.Background(), der, reg.ID, nil, &now)
+		test.AssertNotError(t, err, "failed to add test cert")
+	}
+
+	// The test certificates have no NotAfter, so they're counted as expired
+	// until revoked.
+	r := New(ra, ssa, dbMap, log, fc, metrics.NoopRegisterer)
+	counts, err := r.CountRegistrationCertificates(reg.ID)
+	test.AssertNotError(t, err, "CountRegistrationCertificates failed")
+	test.AssertEquals(t, counts, RegCertCounts{Total: 5, Expired: 5})
+
+	// Use a small page size so that selecting the certificates takes several
+	// pages, including a partial one.
+	defer func(size int) { regSerialsPageSize = size }(regSerialsPageSize)
+	regSerialsPageSize = 2
+
+	result, err := r.RevokeRegistrationParallel(context.Background(), reg.ID, IssuedWindow{}, 0, Options{}, 3, nil)
+	test.AssertNotError(t, err, "RevokeRegistrationParallel failed")
+	test.AssertEquals(t, result.Revoked, len(serials))
+	test.AssertEquals(t, len(result.Failures), 0)
+
+	var listed []CertificateInfo
+	impact, err := r.ListRegistration(reg.ID, IssuedWindow{}, func(info CertificateInfo) error {
+		listed = append(listed, info)
+		return nil
+	})
+	test.AssertNotError(t, err, "ListRegistration failed")
+	test.AssertEquals(t, len(listed), len(serials))
+	test.AssertEquals(t, impact.Unexpired, 0)
+	for _, info := range listed {
+		test.AssertEquals(t, info.Status, core.OCSPStatusRevoked)
+		test.AssertDeepEquals(t, info.DNSNames, []string{"asd"})
+	}
+
+	counts, err = r.CountRegistrationCertificates(reg.ID)
+	test.AssertNotError(t, err, "CountRegistrationCertificates failed")
+	test.AssertEquals(t, counts, RegCertCounts{Total: 5, Revoked: 5})
+
+	for _, serial := range serials {
+		status, err := ssa.GetCertificateStatus(context.Background(), core.SerialToString(serial))
+		test.AssertNotError(t, err, "failed to retrieve certificate status")
+		test.AssertEquals(t, status.Status, core.OCSPStatusRevoked)
+	}
+}
+
+func TestRevokeForceChangesStoredReason(t *testing.T) {
+	log := blog.UseMock()
+	fc := clock.NewFake()
+	fc.Set(time.Date(2015, 3, 4, 5, 0, 0, 0, time.UTC))
+	dbMap, err := sa.NewDbMap(vars.DBConnSA, 0)
+	if err != nil {
+		t.Fatalf("Failed to create dbMap: %s", err)
+	}
+	ssa, err := sa.NewSQLStorageAuthority(dbMap, fc, log, metrics.NoopRegisterer, 1)
+	if err != nil {
+		t.Fatalf("Failed to create SA: %s", err)
+	}
+	defer test.ResetSATestDatabase(t)
+	reg := satest.CreateWorkingRegistration(t, ssa)
+
+	ra := ra.NewRegistrationAuthorityImpl(fc,
+		log,
+		metrics.NoopRegisterer,
+		1, goodkey.KeyPolicy{}, 100, true, false, 300*24*time.Hour, 7*24*time.Hour, nil, nil, 0, nil, nil, &x509.Certificate{})
+	ra.SA = ssa
+	ra.CA = &mockCA{}
+
+	k, err := rsa.GenerateKey(rand.Reader, 512)
+	test.AssertNotError(t, err, "failed to generate test key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"asd"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &k.PublicKey, k)
+	test.AssertNotError(t, err, "failed to generate test cert")
+	issued := time.Now().UnixNano()
+	_, err = ssa.AddPrecertificate(context.Background(), &sapb.AddCertificateRequest{
+		Der:    der,
+		RegID:  &reg.ID,
+		Issued: &issued,
+	})
+	test.AssertNotError(t, err, "failed to add test cert")
+	now := time.Now()
+	_, err = ssa.AddCertificate(context.Background(), der, reg.ID, nil, &now)
+	test.AssertNotError(t, err, "failed to add test cert")
+	serial := core.SerialToString(template.SerialNumber)
+
+	r := New(ra, ssa, dbMap, log, fc, metrics.NoopRegisterer)
+	err = r.RevokeSerial(context.Background(), serial, revocation.Reason(ocsp.Superseded), Options{Operator: "alice"})
+	test.AssertNotError(t, err, "RevokeSerial failed")
+	revokedAt := fc.Now()
+
+	checkStored := func(reason revocation.Reason) {
+		t.Helper()
+		status, err := ssa.GetCertificateStatus(context.Background(), serial)
+		test.AssertNotError(t, err, "failed to retrieve certificate status")
+		test.AssertEquals(t, status.Status, core.OCSPStatusRevoked)
+		test.AssertEquals(t, status.RevokedReason, reason)
+		test.AssertEquals(t, status.RevokedDate, revokedAt)
+	}
+
+	// Without --force, the certificate is skipped and keeps its reason.
+	fc.Add(time.Hour)
+	err = r.RevokeSerial(context.Background(), serial, revocation.Reason(ocsp.KeyCompromise), Options{Operator: "alice"})
+	test.AssertNotError(t, err, "RevokeSerial failed for an already revoked certificate")
+	checkStored(revocation.Reason(ocsp.Superseded))
+
+	// With --force, the RA stores the new reason, keeping the date it was
+	// revoked at, and only then is the change logged and audited.
+	err = r.RevokeSerial(context.Background(), serial, revocation.Reason(ocsp.KeyCompromise), Options{Operator: "alice", Force: true})
+	test.AssertNotError(t, err, "RevokeSerial failed with --force")
+	checkStored(revocation.Reason(ocsp.KeyCompromise))
+	test.AssertEquals(t, len(log.GetAllMatching(`"reasonCode":1,.*"previousReasonCode":4,"previousReasonString":"superseded"`)), 1)
+	test.AssertEquals(t, len(log.GetAllMatching("Changed the revocation reason of certificate [0-9a-f]+ from 'superseded' to 'keyCompromise'")), 1)
+
+	// The same holds directly through the SA.
+	err = r.RevokeSerial(context.Background(), serial, revocation.Reason(ocsp.CessationOfOperation), Options{Operator: "alice", Force: true, DirectSA: true})
+	test.AssertNotError(t, err, "RevokeSerial failed with --force and --direct-sa")
+	checkStored(revocation.Reason(ocsp.CessationOfOperation))
+	test.AssertEquals(t, len(log.GetAllMatching(`"reasonCode":5,.*"previousReasonCode":1,"previousReasonString":"keyCompromise"`)), 1)
+}
+
+func TestReadSerialFile(t *testing.T) {
+	serialFile, err := ioutil.TempFile("", "serials")
+	test.AssertNotError(t, err, "failed to open temp file")
+	defer os.Remove(serialFile.Name())
+
+	_, err = serialFile.WriteString("# compromised serials\n\n0000000000000000000000000000000000a1\n  0000000000000000000000000000000000b2  \n#0000000000000000000000000000000000c3\n")
+	test.AssertNotError(t, err, "failed to write temp file")
+
+	serials, err := ReadSerialFile(serialFile.Name())
+	test.AssertNotError(t, err, "ReadSerialFile failed")
+	test.AssertDeepEquals(t, serials, []string{
+		"0000000000000000000000000000000000a1",
+		"0000000000000000000000000000000000b2",
+	})
+
+	_, err = ReadSerialFile("/does/not/exist")
+	test.AssertError(t, err, "ReadSerialFile didn't fail on a missing file")
+}
+
+func TestReadSerialReasonFile(t *testing.T) {
+	write := func(contents string) string {
+		f, err := ioutil.TempFile("", "serials")
+		test.AssertNotError(t, err, "failed to open temp file")
+		_, err = f.WriteString(contents)
+		test.AssertNotError(t, err, "failed to write temp file")
+		_ = f.Close()
+		return f.Name()
+	}
+
+	path := write("# serial,reason\n0000000000000000000000000000000000a1\n0000000000000000000000000000000000b2, keyCompromise\n0000000000000000000000000000000000c3,4\n0000000000000000000000000000000000c3,superseded\n")
+	defer os.Remove(path)
+	serials, reasons, err := ReadSerialReasonFile(path)
+	test.AssertNotError(t, err, "ReadSerialReasonFile failed")
+	test.AssertDeepEquals(t, serials, []string{
+		"0000000000000000000000000000000000a1",
+		"0000000000000000000000000000000000b2",
+		"0000000000000000000000000000000000c3",
+		"0000000000000000000000000000000000c3",
+	})
+	test.AssertDeepEquals(t, reasons, map[string]revocation.Reason{
+		"0000000000000000000000000000000000b2": ocsp.KeyCompromise,
+		"0000000000000000000000000000000000c3": ocsp.Superseded,
+	})
+
+	for _, contents := range []string{
+		"0000000000000000000000000000000000a1,certificateHold\n",
+		"0000000000000000000000000000000000a1,7\n",
+		"0000000000000000000000000000000000a1,notAReason\n",
+		"0000000000000000000000000000000000a1,1\n0000000000000000000000000000000000a1,4\n",
+	} {
+		path := write(contents)
+		defer os.Remove(path)
+		_, _, err = ReadSerialReasonFile(path)
+		test.AssertError(t, err, fmt.Sprintf("ReadSerialReasonFile accepted %q", contents))
+	}
+}
+
+func TestFingerprintToDigest(t *testing.T) {
+	der := []byte("not really a certificate")
+	sum := sha256.Sum256(der)
+	expected := core.Fingerprint256(der)
+
+	digest, err := fingerprintToDigest(hex.EncodeToString(sum[:]))
+	test.AssertNotError(t, err, "fingerprintToDigest failed")
+	test.AssertEquals(t, digest, expected)
+
+	var colons []string
+	for _, b := range sum {
+		colons = append(colons, fmt.Sprintf("%02X", b))
+	}
+	digest, err = fingerprintToDigest(strings.Join(colons, ":"))
+	test.AssertNotError(t, err, "fingerprintToDigest failed on colon separated fingerprint")
+	test.AssertEquals(t, digest, expected)
+
+	_, err = fingerprintToDigest("zz")
+	test.AssertError(t, err, "fingerprintToDigest didn't fail on non-hex input")
+	test.Assert(t, berrors.Is(err, berrors.Malformed), "expected a malformed error")
+
+	_, err = fingerprintToDigest("abcd")
+	test.AssertError(t, err, "fingerprintToDigest didn't fail on a short fingerprint")
+}
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	test.AssertNotError(t, err, "failed to create temp dir")
+	defer os.RemoveAll(dir)
+	path := dir + "/checkpoint"
+
+	var nilCP *Checkpoint
+	test.Assert(t, !nilCP.contains("a1"), "nil checkpoint shouldn't contain anything")
+	test.AssertNotError(t, nilCP.record("a1"), "recording to a nil checkpoint failed")
+
+	cp, err := OpenCheckpoint(path)
+	test.AssertNotError(t, err, "OpenCheckpoint failed on a new file")
+	test.Assert(t, !cp.contains("a1"), "new checkpoint shouldn't contain anything")
+	test.AssertNotError(t, cp.record("a1"), "record failed")
+	test.AssertNotError(t, cp.record("b2"), "record failed")
+	test.Assert(t, cp.contains("a1"), "checkpoint should contain recorded serial")
+	test.AssertNotError(t, cp.Close(), "Close failed")
+
+	cp, err = OpenCheckpoint(path)
+	test.AssertNotError(t, err, "OpenCheckpoint failed on an existing file")
+	test.Assert(t, cp.contains("a1"), "reopened checkpoint should contain a1")
+	test.Assert(t, cp.contains("b2"), "reopened checkpoint should contain b2")
+	test.Assert(t, !cp.contains("c3"), "reopened checkpoint shouldn't contain c3")
+	test.AssertNotError(t, cp.record("c3"), "record failed")
+	test.AssertNotError(t, cp.Close(), "Close failed")
+
+	contents, err := ioutil.ReadFile(path)
+	test.AssertNotError(t, err, "failed to read checkpoint file")
+	test.AssertEquals(t, string(contents), "a1\nb2\nc3\n")
+
+	// Serials are recorded normalized, and match however they're written.
+	cp, err = OpenCheckpoint(path)
+	test.AssertNotError(t, err, "OpenCheckpoint failed on an existing file")
+	test.Assert(t, cp.contains("0xA1"), "checkpoint should contain 0xA1 as a1")
+	test.AssertNotError(t, cp.record("0xD4:E5"), "record failed")
+	test.Assert(t, cp.contains("d4e5"), "checkpoint should contain d4e5 as recorded from 0xD4:E5")
+	test.AssertNotError(t, cp.Close(), "Close failed")
+	contents, err = ioutil.ReadFile(path)
+	test.AssertNotError(t, err, "failed to read checkpoint file")
+	test.AssertEquals(t, string(contents), "a1\nb2\nc3\nd4e5\n")
+
+	// Serials recorded by an earlier version without normalizing them are
+	// matched too.
+	err = ioutil.WriteFile(path, []byte("0xF6\n"), 0644)
+	test.AssertNotError(t, err, "failed to write checkpoint file")
+	cp, err = OpenCheckpoint(path)
+	test.AssertNotError(t, err, "OpenCheckpoint failed on an existing file")
+	test.Assert(t, cp.contains("f6"), "checkpoint should contain f6 as recorded as 0xF6")
+	test.AssertNotError(t, cp.Close(), "Close failed")
+}
+
+func TestSerialOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "output")
+	test.AssertNotError(t, err, "failed to create temp dir")
+	defer os.RemoveAll(dir)
+	path := dir + "/serials"
+
+	var nilOut *SerialOutput
+	test.AssertNotError(t, nilOut.record("a1"), "recording to a nil output failed")
+	test.AssertNotError(t, nilOut.Close(), "closing a nil output failed")
+
+	out, err := OpenSerialOutput(path)
+	test.AssertNotError(t, err, "OpenSerialOutput failed on a new file")
+	test.AssertNotError(t, out.record("a1"), "record failed")
+	test.AssertNotError(t, out.Close(), "Close failed")
+
+	out, err = OpenSerialOutput(path)
+	test.AssertNotError(t, err, "OpenSerialOutput failed on an existing file")
+	test.AssertNotError(t, out.record("b2"), "record failed")
+	test.AssertNotError(t, out.Close(), "Close failed")
+
+	contents, err := ioutil.ReadFile(path)
+	test.AssertNotError(t, err, "failed to read output file")
+	test.AssertEquals(t, string(contents), "a1\nb2\n")
+}
+
+func TestKeyHashToDigest(t *testing.T) {
+	sum := sha256.Sum256([]byte("not really a key"))
+	expected := base64.StdEncoding.EncodeToString(sum[:])
+
+	for _, input := range []string{
+		hex.EncodeToString(sum[:]),
+		base64.StdEncoding.EncodeToString(sum[:]),
+		base64.RawStdEncoding.EncodeToString(sum[:]),
+		base64.URLEncoding.EncodeToString(sum[:]),
+		base64.RawURLEncoding.EncodeToString(sum[:]),
+	} {
+		digest, err := keyHashToDigest(input)
+		test.AssertNotError(t, err, fmt.Sprintf("keyHashToDigest(%q) failed", input))
+		test.AssertEquals(t, digest, expected)
+	}
+
+	_, err := keyHashToDigest("!!!")
+	test.AssertError(t, err, "keyHashToDigest didn't fail on invalid input")
+	_, err = keyHashToDigest(base64.StdEncoding.EncodeToString([]byte("short")))
+	test.AssertError(t, err, "keyHashToDigest didn't fail on a short digest")
+}
+
+func TestCheckTLSConfig(t *testing.T) {
+	certFile := "cert.pem"
+	var c Config
+	test.AssertNotError(t, checkTLSConfig(c), "checkTLSConfig failed with no gRPC services")
+
+	c.SAService = &cmd.GRPCClientConfig{}
+	err := checkTLSConfig(c)
+	test.AssertError(t, err, "checkTLSConfig didn't fail for SAService without TLS")
+	test.AssertEquals(t, err.Error(), "gRPC SAService configured but no TLS cert provided")
+
+	c.RAService = &cmd.GRPCClientConfig{}
+	err = checkTLSConfig(c)
+	test.AssertError(t, err, "checkTLSConfig didn't fail for RAService without TLS")
+	test.AssertEquals(t, err.Error(), "gRPC RAService configured but no TLS cert provided")
+
+	c.TLS.CertFile = &certFile
+	test.AssertNotError(t, checkTLSConfig(c), "checkTLSConfig failed with TLS cert provided")
+}
+
+// flakyRA fails AdministrativelyRevokeCertificate with each of errs in turn
+// before succeeding.
+type flakyRA struct {
+	core.RegistrationAuthority
+	errs  []error
+	calls int
+}
+
+func (ra *flakyRA) AdministrativelyRevokeCertificate(_ context.Context, _ x509.Certificate, _ revocation.Reason, _ string, _ string, _ time.Time, _ bool) error {
+	ra.calls++
+	if len(ra.errs) == 0 {
+		return nil
+	}
+	err := ra.errs[0]
+	ra.errs = ra.errs[1:]
+	return err
+}
+
+// stuckRA blocks in AdministrativelyRevokeCertificate until the request's
+// context is done, as a stuck gRPC request would.
+type stuckRA struct {
+	core.RegistrationAuthority
+	calls int
+}
+
+func (ra *stuckRA) AdministrativelyRevokeCertificate(ctx context.Context, _ x509.Certificate, _ revocation.Reason, _ string, _ string, _ time.Time, _ bool) error {
+	ra.calls++
+	<-ctx.Done()
+	return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+}
+
+func TestRevokeWithRetry(t *testing.T) {
+	log := blog.UseMock()
+	fc := clock.NewFake()
+	cert := &x509.Certificate{SerialNumber: big.NewInt(1)}
+	opts := Options{MaxAttempts: 3, RetryBaseDelay: time.Second}
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	ra := &flakyRA{errs: []error{unavailable, unavailable}}
+	r := New(ra, nil, nil, log, fc, metrics.NoopRegisterer)
+	err := r.revokeWithRetry(context.Background(), cert, 0, "root", opts, false)
+	test.AssertNotError(t, err, "revokeWithRetry failed after transient errors")
+	test.AssertEquals(t, ra.calls, 3)
+	test.AssertEquals(t, len(log.GetAllMatching("Transient error revoking certificate")), 2)
+	test.AssertEquals(t, test.CountHistogramSamples(r.revocationLatency), 3)
+
+	ra = &flakyRA{errs: []error{unavailable, unavailable, unavailable}}
+	r.rac = ra
+	err = r.revokeWithRetry(context.Background(), cert, 0, "root", opts, false)
+	test.AssertEquals(t, err, unavailable)
+	test.AssertEquals(t, ra.calls, 3)
+
+	permanent := berrors.MalformedError("invalid reason code")
+	ra = &flakyRA{errs: []error{permanent}}
+	r.rac = ra
+	err = r.revokeWithRetry(context.Background(), cert, 0, "root", opts, false)
+	test.AssertEquals(t, err, permanent)
+	test.AssertEquals(t, ra.calls, 1)
+
+	ra = &flakyRA{errs: []error{unavailable}}
+	r.rac = ra
+	err = r.revokeWithRetry(context.Background(), cert, 0, "root", Options{}, false)
+	test.AssertEquals(t, err, unavailable)
+	test.AssertEquals(t, ra.calls, 1)
+
+	// A backend which is overloaded fails the revocation unless the rate is
+	// adaptive, in which case it's slowed down and retried.
+	exhausted := status.Error(codes.ResourceExhausted, "too many requests")
+	ra = &flakyRA{errs: []error{exhausted}}
+	r.rac = ra
+	err = r.revokeWithRetry(context.Background(), cert, 0, "root", opts, false)
+	test.AssertEquals(t, err, exhausted)
+	test.AssertEquals(t, ra.calls, 1)
+
+	ra = &flakyRA{errs: []error{exhausted, exhausted}}
+	r.rac = ra
+	// The limiter waits on a real clock, since nothing advances the fake one.
+	opts.RateLimit = NewAdaptiveRateLimiter(clock.New(), 1000)
+	err = r.revokeWithRetry(context.Background(), cert, 0, "root", opts, false)
+	test.AssertNotError(t, err, "revokeWithRetry failed with an adaptive rate")
+	test.AssertEquals(t, ra.calls, 3)
+	test.AssertEquals(t, len(log.GetAllMatching("RA overloaded revoking certificate 000000000000000000000000000000000001 \\(attempt 2 of 3\\), slowing to 250.00 requests per second")), 1)
+
+	// A request which exceeds the per-call timeout fails as a backend error,
+	// without being retried.
+	stuck := &stuckRA{}
+	r.rac = stuck
+	opts.RateLimit = nil
+	opts.CallTimeout = time.Millisecond
+	err = r.revokeWithRetry(context.Background(), cert, 0, "root", opts, false)
+	test.AssertError(t, err, "revokeWithRetry didn't time out")
+	_, ok := err.(BackendError)
+	test.Assert(t, ok, fmt.Sprintf("expected a BackendError, got %T", err))
+	test.AssertEquals(t, categorizeFailure(err), failureBackend)
+	test.AssertEquals(t, stuck.calls, 1)
+	test.AssertEquals(t, len(log.GetAllMatching("RA request revoking certificate 000000000000000000000000000000000001 \\(attempt 1\\) timed out after 1ms")), 1)
+
+	// The context's own deadline isn't reported as the request timing out.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	opts.CallTimeout = time.Hour
+	err = r.revokeWithRetry(ctx, cert, 0, "root", opts, false)
+	test.AssertError(t, err, "revokeWithRetry didn't fail at the context's deadline")
+	_, ok = err.(BackendError)
+	test.Assert(t, !ok, "the context's deadline was reported as the request timing out")
+}
+
+// mockLookup is a certLookup over certificates held in memory, which records
+// the serials looked up.
+type mockLookup struct {
+	certs    []core.Certificate
+	precerts []core.Certificate
+	statuses map[string]core.OCSPStatus
+	reasons  map[string]revocation.Reason
+	// revokedAt is the date each certificate in reasons was revoked at.
+	revokedAt time.Time
+	lookedUp  []string
+}
+
+func (l *mockLookup) certificate(serial string) (core.Certificate, error) {
+	l.lookedUp = append(l.lookedUp, serial)
+	for _, cert := range l.certs {
+		if cert.Serial == serial {
+			return cert, nil
+		}
+	}
+	return core.Certificate{}, berrors.NotFoundError("certificate with serial %q not found", serial)
+}
+
+func (l *mockLookup) precertificate(serial string) (core.Certificate, error) {
+	for _, precert := range l.precerts {
+		if precert.Serial == serial {
+			return precert, nil
+		}
+	}
+	return core.Certificate{}, berrors.NotFoundError("precertificate with serial %q not found", serial)
+}
+
+func (l *mockLookup) status(serial string) (core.OCSPStatus, error) {
+	if status, ok := l.statuses[serial]; ok {
+		return status, nil
+	}
+	return core.OCSPStatusGood, nil
+}
+
+func (l *mockLookup) previousRevocation(serial string) (revocation.Reason, time.Time, error) {
+	return l.reasons[serial], l.revokedAt, nil
+}
+
+func (l *mockLookup) regCertificates(regID int64, after string, limit int) ([]core.Certificate, error) {
+	var certs []core.Certificate
+	for _, cert := range l.certs {
+		if cert.RegistrationID == regID && cert.Serial > after && len(certs) < limit {
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+// mockRA records the certificates it is asked to revoke, in order, along with
+// the reasons, revocation dates and whether each was forced, failing each
+// request with err if it is set.
+type mockRA struct {
+	core.RegistrationAuthority
+	err     error
+	revoked []string
+	reasons []revocation.Reason
+	dates   []time.Time
+	forced  []bool
+}
+
+func (ra *mockRA) AdministrativelyRevokeCertificate(_ context.Context, cert x509.Certificate, reason revocation.Reason, _ string, _ string, revokedAt time.Time, force bool) error {
+	ra.revoked = append(ra.revoked, core.SerialToString(cert.SerialNumber))
+	ra.reasons = append(ra.reasons, reason)
+	ra.dates = append(ra.dates, revokedAt)
+	ra.forced = append(ra.forced, force)
+	return ra.err
+}
+
+// mockCertificate returns a self-signed certificate with the serial as it would
+// be stored in the certificates table for the registration.
+func mockCertificate(t *testing.T, serial int64, regID int64) core.Certificate {
+	return mockCertificateExpiring(t, serial, regID, time.Time{})
+}
+
+// mockCertificateExpiring is like mockCertificate, but the certificate expires
+// at notAfter.
+func mockCertificateExpiring(t *testing.T, serial int64, regID int64, notAfter time.Time) core.Certificate {
+	return mockCertificateValid(t, serial, regID, time.Time{}, notAfter)
+}
+
+// mockCertificateValid is like mockCertificate, but the certificate is valid
+// from notBefore until notAfter.
+func mockCertificateValid(t *testing.T, serial int64, regID int64, notBefore, notAfter time.Time) core.Certificate {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &k.PublicKey, k)
+	test.AssertNotError(t, err, "failed to generate test cert")
+	return core.Certificate{
+		RegistrationID: regID,
+		Serial:         core.SerialToString(template.SerialNumber),
+		DER:            der,
+	}
+}
+
+func TestCheckStoredCertificate(t *testing.T) {
+	stored := mockCertificate(t, 1, 1)
+	cert, err := x509.ParseCertificate(stored.DER)
+	test.AssertNotError(t, err, "couldn't parse certificate")
+	// A different certificate issued with the same serial.
+	other, err := x509.ParseCertificate(mockCertificateExpiring(t, 1, 1, time.Now().Add(time.Hour)).DER)
+	test.AssertNotError(t, err, "couldn't parse certificate")
+	precert := mockCertificateExpiring(t, 1, 1, time.Now().Add(2*time.Hour))
+	precertParsed, err := x509.ParseCertificate(precert.DER)
+	test.AssertNotError(t, err, "couldn't parse precertificate")
+
+	certs := &mockLookup{certs: []core.Certificate{stored}}
+	test.AssertNotError(t, checkStoredCertificate(certs, cert, true), "stored certificate didn't match")
+	err = checkStoredCertificate(certs, other, true)
+	test.AssertEquals(t, err, error(CertificateMismatchError{stored.Serial}))
+
+	certs = &mockLookup{}
+	err = checkStoredCertificate(certs, cert, true)
+	test.Assert(t, berrors.Is(err, berrors.NotFound), "missing certificate wasn't NotFound")
+
+	// The precertificate of a final certificate matches, unless only final
+	// certificates are being looked for.
+	certs = &mockLookup{certs: []core.Certificate{stored}, precerts: []core.Certificate{precert}}
+	test.AssertNotError(t, checkStoredCertificate(certs, precertParsed, true), "stored precertificate didn't match")
+	err = checkStoredCertificate(certs, precertParsed, false)
+	test.AssertEquals(t, err, error(CertificateMismatchError{stored.Serial}))
+	err = checkStoredCertificate(certs, other, true)
+	test.AssertEquals(t, err, error(CertificateMismatchError{stored.Serial}))
+}
+
+func TestRevokeBySerial(t *testing.T) {
+	valid := mockCertificate(t, 1, 1)
+	revoked := mockCertificate(t, 2, 1)
+	missing := core.SerialToString(big.NewInt(3))
+
+	testCases := []struct {
+		name     string
+		serial   string
+		reason   revocation.Reason
+		raErr    error
+		errType  berrors.ErrorType
+		skipped  error
+		lookedUp []string
+		revoked  []string
+	}{
+		{
+			name:     "valid serial",
+			serial:   valid.Serial,
+			reason:   revocation.Reason(ocsp.KeyCompromise),
+			lookedUp: []string{valid.Serial},
+			revoked:  []string{valid.Serial},
+		},
+		{
+			name:     "serial in OpenSSL format",
+			serial:   "00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:01",
+			reason:   revocation.Reason(ocsp.KeyCompromise),
+			lookedUp: []string{valid.Serial},
+			revoked:  []string{valid.Serial},
+		},
+		{
+			name:     "missing serial",
+			serial:   missing,
+			reason:   revocation.Reason(ocsp.KeyCompromise),
+			errType:  berrors.NotFound,
+			lookedUp: []string{missing},
+		},
+		{
+			name:    "invalid reason",
+			serial:  valid.Serial,
+			reason:  99,
+			errType: berrors.Malformed,
+		},
+		{
+			name:     "already revoked",
+			serial:   revoked.Serial,
+			reason:   revocation.Reason(ocsp.KeyCompromise),
+			skipped:  errSkippedRevoked,
+			lookedUp: []string{revoked.Serial},
+		},
+		{
+			name:     "RA failure",
+			serial:   valid.Serial,
+			reason:   revocation.Reason(ocsp.Superseded),
+			raErr:    berrors.MalformedError("unexpected reason"),
+			errType:  berrors.Malformed,
+			lookedUp: []string{valid.Serial},
+			revoked:  []string{valid.Serial},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookup := &mockLookup{
+				certs:    []core.Certificate{valid, revoked},
+				statuses: map[string]core.OCSPStatus{revoked.Serial: core.OCSPStatusRevoked},
+			}
+			ra := &mockRA{err: tc.raErr}
+			r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+			err := r.revokeBySerial(context.Background(), lookup, tc.serial, tc.reason, Options{Operator: "alice"})
+			if tc.errType != 0 {
+				test.AssertError(t, err, "revokeBySerial succeeded")
+				test.Assert(t, berrors.Is(err, tc.errType), fmt.Sprintf("unexpected error type: %s", err))
+			} else if tc.skipped != nil {
+				test.AssertEquals(t, err, tc.skipped)
+			} else {
+				test.AssertNotError(t, err, "revokeBySerial failed")
+			}
+			test.AssertDeepEquals(t, lookup.lookedUp, tc.lookedUp)
+			test.AssertDeepEquals(t, ra.revoked, tc.revoked)
+			// Only requests to the RA are counted, by whether they succeeded.
+			revokedCount, errorCount := len(tc.revoked), 0
+			if tc.raErr != nil {
+				revokedCount, errorCount = 0, len(tc.revoked)
+			}
+			test.AssertEquals(t, test.CountCounterVec("reason", tc.reason.String(), r.revokedCerts), revokedCount)
+			test.AssertEquals(t, test.CountCounterVec("reason", tc.reason.String(), r.revocationErrors), errorCount)
+		})
+	}
+}
+
+// misassignedLookup is a mockLookup whose selection of a registration's
+// certificates also wrongly includes extra, as an inconsistent database might.
+type misassignedLookup struct {
+	*mockLookup
+	extra core.Certificate
+}
+
+func (l misassignedLookup) regCertificates(regID int64, after string, limit int) ([]core.Certificate, error) {
+	certs, err := l.mockLookup.regCertificates(regID, after, limit)
+	if after == "" {
+		certs = append([]core.Certificate{l.extra}, certs...)
+	}
+	return certs, err
+}
+
+func TestRevokeByRegMismatch(t *testing.T) {
+	other := mockCertificate(t, 1, 2)
+	lookup := misassignedLookup{
+		mockLookup: &mockLookup{certs: []core.Certificate{other, mockCertificate(t, 2, 1)}},
+		extra:      other,
+	}
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+	result, err := r.revokeByReg(context.Background(), lookup, 1, IssuedWindow{}, revocation.Reason(ocsp.Superseded), Options{Operator: "alice"}, nil)
+	test.AssertError(t, err, "revokeByReg revoked a certificate belonging to another registration")
+	test.AssertContains(t, err.Error(), "belongs to registration 2")
+	test.AssertEquals(t, result.Revoked, 0)
+	test.AssertEquals(t, len(ra.revoked), 0)
+}
+
+// recordingSA records the revocations and deactivations written through it,
+// and serves the registrations it holds.
+type recordingSA struct {
+	core.StorageAuthority
+	registrations map[int64]core.Registration
+	revocations   []*sapb.RevokeCertificateRequest
+	updates       []*sapb.RevokeCertificateRequest
+	statuses      map[string]core.CertificateStatus
+	deactivated   []int64
+}
+
+func (ssa *recordingSA) GetCertificateStatus(_ context.Context, serial string) (core.CertificateStatus, error) {
+	return ssa.statuses[serial], nil
+}
+
+func (ssa *recordingSA) GetRegistration(_ context.Context, id int64) (core.Registration, error) {
+	reg, ok := ssa.registrations[id]
+	if !ok {
+		return core.Registration{}, berrors.NotFoundError("no registration with ID %d", id)
+	}
+	return reg, nil
+}
+
+func (ssa *recordingSA) RevokeCertificate(_ context.Context, req *sapb.RevokeCertificateRequest) error {
+	ssa.revocations = append(ssa.revocations, req)
+	return nil
+}
+
+func (ssa *recordingSA) UpdateRevokedCertificate(_ context.Context, req *sapb.RevokeCertificateRequest) error {
+	ssa.updates = append(ssa.updates, req)
+	return nil
+}
+
+func (ssa *recordingSA) DeactivateRegistration(_ context.Context, id int64) error {
+	ssa.deactivated = append(ssa.deactivated, id)
+	return nil
+}
+
+func TestDeactivateRegistration(t *testing.T) {
+	ssa := &recordingSA{}
+	log := blog.NewMock()
+	r := New(nil, ssa, nil, log, clock.NewFake(), metrics.NoopRegisterer)
+
+	err := r.DeactivateRegistration(context.Background(), 1, Options{Operator: "alice", DryRun: true})
+	test.AssertNotError(t, err, "DeactivateRegistration failed for a dry run")
+	test.AssertEquals(t, len(ssa.deactivated), 0)
+
+	err = r.DeactivateRegistration(context.Background(), 1, Options{Operator: "alice", Comment: "abuse"})
+	test.AssertNotError(t, err, "DeactivateRegistration failed")
+	test.AssertDeepEquals(t, ssa.deactivated, []int64{1})
+	test.AssertEquals(t, len(log.GetAllMatching(`Administrative registration deactivation JSON=.*"registrationID":1,"operator":"alice","comment":"abuse"`)), 1)
+}
+
+func TestSerialInfo(t *testing.T) {
+	cert := mockCertificate(t, 1, 1)
+	precert := mockCertificate(t, 2, 1)
+	orphan := mockCertificate(t, 3, 2)
+	lookup := &mockLookup{
+		certs:    []core.Certificate{cert, orphan},
+		precerts: []core.Certificate{precert},
+		statuses: map[string]core.OCSPStatus{cert.Serial: core.OCSPStatusRevoked},
+	}
+	contact := []string{"mailto:admin@example.com"}
+	ssa := &recordingSA{registrations: map[int64]core.Registration{
+		1: {ID: 1, Contact: &contact, Status: core.StatusValid},
+	}}
+	r := New(nil, ssa, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+
+	info, err := r.serialInfo(context.Background(), lookup, cert.Serial)
+	test.AssertNotError(t, err, "serialInfo failed")
+	test.AssertEquals(t, info.Kind, kindCertificate)
+	test.AssertEquals(t, info.Subject, "CN=example.com")
+	test.AssertDeepEquals(t, info.DNSNames, []string{"example.com"})
+	test.AssertEquals(t, info.Status, core.OCSPStatusRevoked)
+	test.AssertEquals(t, info.RegistrationID, int64(1))
+	test.AssertDeepEquals(t, info.Contact, contact)
+	test.AssertEquals(t, info.RegistrationStatus, core.StatusValid)
+
+	info, err = r.serialInfo(context.Background(), lookup, precert.Serial)
+	test.AssertNotError(t, err, "serialInfo failed for a precertificate")
+	test.AssertEquals(t, info.Kind, kindPrecertificate)
+	test.AssertEquals(t, info.Status, core.OCSPStatusGood)
+
+	_, err = r.serialInfo(context.Background(), lookup, orphan.Serial)
+	test.Assert(t, berrors.Is(err, berrors.NotFound), "expected a not found error for a missing registration")
+
+	_, err = r.SerialInfo(context.Background(), "not-a-serial")
+	test.Assert(t, berrors.Is(err, berrors.Malformed), "expected a malformed error for an invalid serial")
+}
+
+func TestRevokeDirectSA(t *testing.T) {
+	cert := mockCertificate(t, 1, 1)
+	lookup := &mockLookup{certs: []core.Certificate{cert}}
+	ra := &mockRA{}
+	ssa := &recordingSA{}
+	log := blog.NewMock()
+	fc := clock.NewFake()
+	r := New(ra, ssa, nil, log, fc, metrics.NoopRegisterer)
+	opts := Options{Operator: "alice", Comment: "RA down", DirectSA: true}
+	err := r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertNotError(t, err, "revokeBySerial failed")
+
+	test.AssertEquals(t, len(ra.revoked), 0)
+	test.AssertEquals(t, len(ssa.revocations), 1)
+	req := ssa.revocations[0]
+	test.AssertEquals(t, *req.Serial, cert.Serial)
+	test.AssertEquals(t, *req.Reason, int64(ocsp.KeyCompromise))
+	test.AssertEquals(t, *req.Date, fc.Now().UnixNano())
+	test.AssertEquals(t, *req.Comment, "revoked by alice: RA down")
+	test.Assert(t, req.Response != nil && len(req.Response) == 0, "response should be empty but set")
+	test.AssertEquals(t, len(log.GetAllMatching(`Administrative revocation directly through the SA, bypassing the RA JSON=.*"directSA":true`)), 1)
+
+	// With --force, the reason of a certificate already revoked is updated,
+	// keeping the date it was revoked at.
+	revokedAt := fc.Now().Add(-time.Hour)
+	lookup.statuses = map[string]core.OCSPStatus{cert.Serial: core.OCSPStatusRevoked}
+	lookup.reasons = map[string]revocation.Reason{cert.Serial: revocation.Reason(ocsp.Superseded)}
+	ssa.statuses = map[string]core.CertificateStatus{cert.Serial: {Status: core.OCSPStatusRevoked, RevokedDate: revokedAt}}
+	opts.Force = true
+	err = r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertNotError(t, err, "revokeBySerial failed with --force")
+	test.AssertEquals(t, len(ssa.revocations), 1)
+	test.AssertEquals(t, len(ssa.updates), 1)
+	test.AssertEquals(t, *ssa.updates[0].Reason, int64(ocsp.KeyCompromise))
+	test.AssertEquals(t, *ssa.updates[0].Date, revokedAt.UnixNano())
+}
+
+func TestRevokeByReg(t *testing.T) {
+	lookup := &mockLookup{
+		certs: []core.Certificate{
+			mockCertificate(t, 1, 1),
+			mockCertificate(t, 2, 2),
+			mockCertificate(t, 3, 1),
+			mockCertificate(t, 4, 1),
+			mockCertificate(t, 5, 1),
+		},
+	}
+	defer func(size int) { regSerialsPageSize = size }(regSerialsPageSize)
+	regSerialsPageSize = 2
+
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+	result, err := r.revokeByReg(context.Background(), lookup, 1, IssuedWindow{}, revocation.Reason(ocsp.Superseded), Options{Operator: "alice"}, nil)
+	test.AssertNotError(t, err, "revokeByReg failed")
+	test.AssertEquals(t, result.Revoked, 4)
+	test.AssertEquals(t, len(result.Failures), 0)
+	var serials []string
+	for _, serial := range []int64{1, 3, 4, 5} {
+		serials = append(serials, core.SerialToString(big.NewInt(serial)))
+	}
+	test.AssertDeepEquals(t, ra.revoked, serials)
+	test.AssertDeepEquals(t, result.Attempted, serials)
+	for _, reason := range ra.reasons {
+		test.AssertEquals(t, reason, revocation.Reason(ocsp.Superseded))
+	}
+
+	// Registrations without certificates revoke nothing.
+	ra.revoked = nil
+	result, err = r.revokeByReg(context.Background(), lookup, 3, IssuedWindow{}, revocation.Reason(ocsp.Superseded), Options{Operator: "alice"}, nil)
+	test.AssertNotError(t, err, "revokeByReg failed for registration without certificates")
+	test.AssertEquals(t, result.Revoked, 0)
+	test.AssertEquals(t, len(ra.revoked), 0)
+}
+
+func TestRevokePrecertificate(t *testing.T) {
+	both := mockCertificate(t, 1, 1)
+	precertOnly := mockCertificate(t, 2, 1)
+	final := mockCertificate(t, 3, 1)
+	missing := core.SerialToString(big.NewInt(4))
+	lookup := &mockLookup{
+		certs:    []core.Certificate{both, final},
+		precerts: []core.Certificate{both, precertOnly},
+	}
+	dir, err := ioutil.TempDir("", "precert")
+	test.AssertNotError(t, err, "failed to create temp dir")
+	defer os.RemoveAll(dir)
+	report, err := OpenReport(dir + "/report.csv")
+	test.AssertNotError(t, err, "OpenReport failed")
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+
+	opts := Options{Operator: "alice", IncludePrecert: true, Report: report}
+	for _, serial := range []string{both.Serial, precertOnly.Serial, final.Serial} {
+		err = r.revokeBySerial(context.Background(), lookup, serial, 1, opts)
+		test.AssertNotError(t, err, fmt.Sprintf("revokeBySerial failed for %s", serial))
+	}
+	err = r.revokeBySerial(context.Background(), lookup, missing, 1, opts)
+	test.Assert(t, berrors.Is(err, berrors.NotFound), "expected a not found error")
+	test.AssertDeepEquals(t, ra.revoked, []string{both.Serial, precertOnly.Serial, final.Serial})
+
+	// Without IncludePrecert, precertificates aren't found.
+	opts = Options{Operator: "alice", Report: report}
+	err = r.revokeBySerial(context.Background(), lookup, precertOnly.Serial, 1, opts)
+	test.Assert(t, berrors.Is(err, berrors.NotFound), "expected a not found error")
+	test.AssertNotError(t, report.Close(), "Close failed")
+
+	f, err := os.Open(dir + "/report.csv")
+	test.AssertNotError(t, err, "failed to open report")
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	test.AssertNotError(t, err, "report wasn't valid CSV")
+	var results []string
+	for _, row := range rows[1:] {
+		results = append(results, row[len(row)-1])
+	}
+	test.AssertDeepEquals(t, results, []string{
+		"revoked certificate and precertificate",
+		"revoked precertificate",
+		"revoked",
+		fmt.Sprintf("error: no certificate or precertificate with serial %q found", missing),
+		fmt.Sprintf("error: certificate with serial %q not found", precertOnly.Serial),
+	})
+}
+
+func TestRevokeBackdated(t *testing.T) {
+	fc := clock.NewFake()
+	fc.Set(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+	cert := mockCertificateValid(t, 1, 1, fc.Now().Add(-72*time.Hour), fc.Now().Add(time.Hour))
+	lookup := &mockLookup{certs: []core.Certificate{cert}}
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), fc, metrics.NoopRegisterer)
+
+	// Without a revocation date, the RA revokes as of now.
+	err := r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), Options{Operator: "alice"})
+	test.AssertNotError(t, err, "revokeBySerial failed")
+	test.AssertDeepEquals(t, ra.dates, []time.Time{{}})
+
+	revokedAt := fc.Now().Add(-48 * time.Hour)
+	opts := Options{Operator: "alice", RevocationDate: revokedAt}
+	err = r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertNotError(t, err, "revokeBySerial failed")
+	test.AssertDeepEquals(t, ra.dates, []time.Time{{}, revokedAt})
+
+	// A certificate can't be revoked before it was valid.
+	ra.revoked = nil
+	opts.RevocationDate = fc.Now().Add(-96 * time.Hour)
+	err = r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertError(t, err, "revokeBySerial succeeded with a date before notBefore")
+	test.Assert(t, berrors.Is(err, berrors.Malformed), fmt.Sprintf("unexpected error type: %s", err))
+	test.AssertEquals(t, len(ra.revoked), 0)
+}
+
+// pagingLookup is a mockLookup recording the limit of each page of a
+// registration's certificates selected.
+type pagingLookup struct {
+	*mockLookup
+	limits []int
+}
+
+func (l *pagingLookup) regCertificates(regID int64, after string, limit int) ([]core.Certificate, error) {
+	l.limits = append(l.limits, limit)
+	return l.mockLookup.regCertificates(regID, after, limit)
+}
+
+func TestRevokeByRegBatchSize(t *testing.T) {
+	lookup := &pagingLookup{mockLookup: &mockLookup{}}
+	for i := int64(1); i <= 5; i++ {
+		lookup.certs = append(lookup.certs, mockCertificate(t, i, 1))
+	}
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+	opts := Options{Operator: "alice", BatchSize: 2}
+	result, err := r.revokeByReg(context.Background(), lookup, 1, IssuedWindow{}, revocation.Reason(ocsp.Superseded), opts, nil)
+	test.AssertNotError(t, err, "revokeByReg failed")
+	test.AssertEquals(t, result.Revoked, 5)
+	test.AssertDeepEquals(t, lookup.limits, []int{2, 2, 2})
+	test.AssertEquals(t, len(ra.revoked), 5)
+
+	// A page out of order might skip or repeat certificates, so it stops the
+	// revocation.
+	ra.revoked = nil
+	lookup.certs[0], lookup.certs[1] = lookup.certs[1], lookup.certs[0]
+	_, err = r.revokeByReg(context.Background(), lookup, 1, IssuedWindow{}, revocation.Reason(ocsp.Superseded), opts, nil)
+	test.AssertError(t, err, "revokeByReg succeeded with a page out of order")
+	test.AssertEquals(t, len(ra.revoked), 1)
+}
+
+// unavailableRA is a mockRA which becomes unavailable once it has revoked
+// available certificates.
+type unavailableRA struct {
+	mockRA
+	available int
+}
+
+func (ra *unavailableRA) AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, reason revocation.Reason, user string, comment string, revokedAt time.Time, force bool) error {
+	if len(ra.revoked) >= ra.available {
+		ra.err = status.Error(codes.Unavailable, "connection refused")
+	}
+	return ra.mockRA.AdministrativelyRevokeCertificate(ctx, cert, reason, user, comment, revokedAt, force)
+}
+
+func TestRevokeByRegUnavailable(t *testing.T) {
+	lookup := &mockLookup{}
+	for i := int64(1); i <= 4; i++ {
+		lookup.certs = append(lookup.certs, mockCertificate(t, i, 1))
+	}
+	ra := &unavailableRA{available: 2}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+	result, err := r.revokeByReg(context.Background(), lookup, 1, IssuedWindow{}, revocation.Reason(ocsp.Superseded), Options{Operator: "alice"}, nil)
+	unavailable, ok := err.(BackendUnavailableError)
+	test.Assert(t, ok, fmt.Sprintf("unexpected error type: %#v", err))
+	test.AssertEquals(t, unavailable.Revoked, 2)
+	test.AssertEquals(t, status.Code(unavailable.Err), codes.Unavailable)
+	// The revocation stops at the first certificate the RA couldn't revoke.
+	test.AssertEquals(t, result.Revoked, 2)
+	test.AssertEquals(t, len(result.Failures), 1)
+	test.AssertEquals(t, result.Failures[0].Serial, lookup.certs[2].Serial)
+	test.AssertEquals(t, len(ra.revoked), 3)
+	test.AssertEquals(t, len(result.Attempted), 3)
+
+	// Other errors from the RA aren't reported as it being unavailable.
+	ra = &unavailableRA{available: 4}
+	ra.err = berrors.InternalServerError("oops")
+	r = New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+	_, err = r.revokeByReg(context.Background(), lookup, 1, IssuedWindow{}, revocation.Reason(ocsp.Superseded), Options{Operator: "alice"}, nil)
+	test.AssertError(t, err, "revokeByReg succeeded with a failing RA")
+	_, ok = err.(BackendUnavailableError)
+	test.Assert(t, !ok, "an internal error was reported as the RA being unavailable")
+}
+
+func TestFindSerials(t *testing.T) {
+	cert := mockCertificate(t, 1, 1)
+	precert := mockCertificate(t, 2, 1)
+	lookup := &mockLookup{certs: []core.Certificate{cert}, precerts: []core.Certificate{precert}}
+	serials := []string{"0x" + cert.Serial, precert.Serial, "000000000000000000000000000000000003", "not-hex"}
+
+	results, err := findSerials(lookup, serials, true)
+	test.AssertNotError(t, err, "findSerials failed")
+	test.AssertEquals(t, len(results), 4)
+	test.AssertEquals(t, results[0].Normalized, cert.Serial)
+	test.AssertEquals(t, results[0].Kind, kindCertificate)
+	test.AssertEquals(t, results[1].Kind, kindPrecertificate)
+	test.Assert(t, !results[2].Found(), "missing serial was found")
+	test.AssertNotError(t, results[2].Err, "missing serial was malformed")
+	test.Assert(t, berrors.Is(results[3].Err, berrors.Malformed), "malformed serial wasn't reported as malformed")
+
+	// Without precertificates, only final certificates are found.
+	results, err = findSerials(lookup, serials[:2], false)
+	test.AssertNotError(t, err, "findSerials failed without precertificates")
+	test.Assert(t, results[0].Found(), "certificate wasn't found")
+	test.Assert(t, !results[1].Found(), "precertificate was found without includePrecert")
+}
+
+func TestRevokeForceChangedReason(t *testing.T) {
+	cert := mockCertificate(t, 1, 1)
+	lookup := &mockLookup{
+		certs:     []core.Certificate{cert},
+		statuses:  map[string]core.OCSPStatus{cert.Serial: core.OCSPStatusRevoked},
+		reasons:   map[string]revocation.Reason{cert.Serial: revocation.Reason(ocsp.Superseded)},
+		revokedAt: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+	}
+	ra := &mockRA{}
+	log := blog.NewMock()
+	fc := clock.NewFake()
+	fc.Set(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+	r := New(ra, nil, nil, log, fc, metrics.NoopRegisterer)
+	opts := Options{Operator: "alice", Force: true}
+
+	// A change the RA fails to make isn't recorded.
+	ra.err = errors.New("oops")
+	err := r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertError(t, err, "revokeBySerial succeeded with a failing RA")
+	test.AssertEquals(t, len(log.GetAllMatching("Changed the revocation reason")), 0)
+	test.AssertEquals(t, len(log.GetAllMatching(`previousReasonCode`)), 0)
+	ra.err = nil
+	ra.revoked = nil
+	ra.forced = nil
+
+	// Re-revoking with the same reason is a no-op.
+	err = r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.Superseded), opts)
+	test.AssertDeepEquals(t, err, errSkippedSameReason)
+	test.AssertEquals(t, len(ra.revoked), 0)
+
+	err = r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertNotError(t, err, "revokeBySerial failed with a changed reason")
+	test.AssertEquals(t, len(ra.revoked), 1)
+	test.AssertDeepEquals(t, ra.forced, []bool{true})
+	test.AssertEquals(t, len(log.GetAllMatching("Changed the revocation reason of certificate [0-9a-f]+ from 'superseded' to 'keyCompromise'")), 1)
+	test.AssertEquals(t, len(log.GetAllMatching(`"previousReasonCode":4,"previousReasonString":"superseded"`)), 1)
+	// The audited revocation date is the one the certificate keeps.
+	test.AssertEquals(t, len(log.GetAllMatching(`"revocationDate":"2020-05-01T00:00:00Z"`)), 1)
+	// The change itself is timestamped by the revoker's clock.
+	test.AssertEquals(t, len(log.GetAllMatching(`"timestamp":"2021-03-01T00:00:00Z"`)), 1)
+}
+
+// revokedRA is a mockRA for which each certificate has already been revoked,
+// so that it only succeeds in forced revocations.
+type revokedRA struct {
+	mockRA
+}
+
+func (ra *revokedRA) AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, reason revocation.Reason, user string, comment string, revokedAt time.Time, force bool) error {
+	ra.err = nil
+	if !force {
+		ra.err = berrors.AlreadyRevokedError("certificate with serial %s already revoked", core.SerialToString(cert.SerialNumber))
+	}
+	return ra.mockRA.AdministrativelyRevokeCertificate(ctx, cert, reason, user, comment, revokedAt, force)
+}
+
+func TestRevokeForceConcurrentlyRevoked(t *testing.T) {
+	cert := mockCertificate(t, 1, 1)
+	// The lookup sees the certificate as not revoked, as it was when checked.
+	lookup := &mockLookup{certs: []core.Certificate{cert}}
+	revokedAt := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	ssa := &recordingSA{statuses: map[string]core.CertificateStatus{cert.Serial: {
+		Status:        core.OCSPStatusRevoked,
+		RevokedReason: revocation.Reason(ocsp.Superseded),
+		RevokedDate:   revokedAt,
+	}}}
+	ra := &revokedRA{}
+	log := blog.NewMock()
+	r := New(ra, ssa, nil, log, clock.NewFake(), metrics.NoopRegisterer)
+
+	// Without --force, it is skipped.
+	err := r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), Options{Operator: "alice"})
+	test.AssertDeepEquals(t, err, errSkippedRevoked)
+
+	// With --force, its reason is changed as though it had been revoked when
+	// checked.
+	ra.revoked, ra.forced = nil, nil
+	opts := Options{Operator: "alice", Force: true}
+	err = r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertNotError(t, err, "revokeBySerial failed for a certificate revoked concurrently")
+	test.AssertDeepEquals(t, ra.forced, []bool{false, true})
+	test.AssertEquals(t, len(log.GetAllMatching(`"previousReasonCode":4,"previousReasonString":"superseded"`)), 1)
+	test.AssertEquals(t, len(log.GetAllMatching(`"revocationDate":"2020-05-01T00:00:00Z"`)), 1)
+
+	// One revoked concurrently with the same reason is skipped.
+	ra.revoked, ra.forced = nil, nil
+	err = r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.Superseded), opts)
+	test.AssertDeepEquals(t, err, errSkippedSameReason)
+	test.AssertDeepEquals(t, ra.forced, []bool{false})
+}
+
+func TestRevokeDNSNameFilter(t *testing.T) {
+	cert := mockCertificate(t, 1, 1)
+	lookup := &mockLookup{certs: []core.Certificate{cert}}
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+
+	opts := Options{Operator: "alice", DNSName: "www.example.com"}
+	err := r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertDeepEquals(t, err, errSkippedDNSName)
+	test.AssertEquals(t, len(ra.revoked), 0)
+
+	opts.DNSName = "Example.com."
+	err = r.revokeBySerial(context.Background(), lookup, cert.Serial, revocation.Reason(ocsp.KeyCompromise), opts)
+	test.AssertNotError(t, err, "revokeBySerial failed for a certificate covering the name")
+	test.AssertEquals(t, len(ra.revoked), 1)
+}
+
+func TestCoversDNSName(t *testing.T) {
+	sans := []string{"example.com", "*.shop.example.net"}
+	testCases := []struct {
+		name    string
+		suffix  bool
+		matches bool
+	}{
+		{"example.com", false, true},
+		{"EXAMPLE.com.", false, true},
+		{"www.example.com", false, false},
+		{"com", false, false},
+		{"com", true, true},
+		{"xample.com", true, false},
+		{"example.net", false, false},
+		{"example.net", true, true},
+		{"shop.example.net", true, true},
+		{"other.example.org", true, false},
+	}
+	for _, tc := range testCases {
+		test.AssertEquals(t, coversDNSName(sans, tc.name, tc.suffix), tc.matches)
+	}
+}
+
+// mockTxDB begins mockTxs, counting how many are committed and rolled back.
+type mockTxDB struct {
+	db.DatabaseMap
+	begun, commits, rollbacks int
+}
+
+func (m *mockTxDB) Begin() (db.Transaction, error) {
+	m.begun++
+	return mockTx{m: m}, nil
+}
+
+type mockTx struct {
+	db.Transaction
+	m *mockTxDB
+}
+
+func (tx mockTx) WithContext(context.Context) gorp.SqlExecutor { return nil }
+func (tx mockTx) Commit() error                                { tx.m.commits++; return nil }
+func (tx mockTx) Rollback() error                              { tx.m.rollbacks++; return nil }
+
+func TestCommittingLookup(t *testing.T) {
+	for _, discard := range []bool{false, true} {
+		m := &mockTxDB{}
+		l := &committingLookup{dbMap: m, ctx: context.Background(), every: 2, discard: discard, log: blog.NewMock()}
+		test.AssertNotError(t, l.begin(), "begin failed")
+		for i := 0; i < 5; i++ {
+			test.AssertNotError(t, l.revoked(), "revoked failed")
+		}
+		test.AssertNotError(t, l.end(), "end failed")
+		test.AssertEquals(t, m.begun, 3)
+		if discard {
+			test.AssertEquals(t, m.commits, 0)
+			test.AssertEquals(t, m.rollbacks, 3)
+		} else {
+			test.AssertEquals(t, m.commits, 3)
+			test.AssertEquals(t, m.rollbacks, 0)
+		}
+	}
+
+	// A failure rolls back only the current transaction.
+	m := &mockTxDB{}
+	l := &committingLookup{dbMap: m, ctx: context.Background(), every: 2, log: blog.NewMock()}
+	test.AssertNotError(t, l.begin(), "begin failed")
+	for i := 0; i < 3; i++ {
+		test.AssertNotError(t, l.revoked(), "revoked failed")
+	}
+	oops := errors.New("oops")
+	test.AssertEquals(t, l.abort(oops), oops)
+	test.AssertEquals(t, m.commits, 1)
+	test.AssertEquals(t, m.rollbacks, 1)
+}
+
+// countingLookup is a mockLookup which counts the revocations it is told of,
+// as a committingLookup would.
+type countingLookup struct {
+	*mockLookup
+	revocations int
+}
+
+func (l *countingLookup) revoked() error {
+	l.revocations++
+	return nil
+}
+
+func TestRevokeSerialsCommitting(t *testing.T) {
+	valid := mockCertificate(t, 1, 1)
+	revoked := mockCertificate(t, 2, 1)
+	checkpointed := mockCertificate(t, 3, 1)
+	lookup := &countingLookup{mockLookup: &mockLookup{
+		certs:    []core.Certificate{valid, revoked, checkpointed},
+		statuses: map[string]core.OCSPStatus{revoked.Serial: core.OCSPStatusRevoked},
+	}}
+	cp := &Checkpoint{done: map[string]bool{checkpointed.Serial: true}}
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+
+	serials := []string{valid.Serial, revoked.Serial, checkpointed.Serial}
+	result, err := r.revokeSerials(context.Background(), lookup, serials, revocation.Reason(ocsp.KeyCompromise), nil, Options{Operator: "alice"}, false, cp)
+	test.AssertNotError(t, err, "revokeSerials failed")
+	test.AssertEquals(t, result.Revoked, 1)
+	test.AssertEquals(t, len(result.Skipped), 2)
+	test.AssertDeepEquals(t, ra.revoked, []string{valid.Serial})
+	// Only revocations count towards committing, not skipped serials.
+	test.AssertEquals(t, lookup.revocations, 1)
+
+	// A reason given for a serial overrides the default.
+	other := mockCertificate(t, 4, 1)
+	lookup.certs = append(lookup.certs, other)
+	ra.revoked, ra.reasons = nil, nil
+	reasons := map[string]revocation.Reason{other.Serial: ocsp.Superseded}
+	_, err = r.revokeSerials(context.Background(), lookup, []string{other.Serial}, revocation.Reason(ocsp.KeyCompromise), reasons, Options{Operator: "alice"}, false, nil)
+	test.AssertNotError(t, err, "revokeSerials failed")
+	test.AssertDeepEquals(t, ra.reasons, []revocation.Reason{ocsp.Superseded})
+
+	// A re-run from a file written differently skips the serials the
+	// checkpoint already has.
+	ra.revoked = nil
+	rerun := []string{"0x" + strings.ToUpper(valid.Serial), strings.ToUpper(checkpointed.Serial)}
+	cp.done = map[string]bool{valid.Serial: true, checkpointed.Serial: true}
+	result, err = r.revokeSerials(context.Background(), lookup, rerun, revocation.Reason(ocsp.KeyCompromise), nil, Options{Operator: "alice"}, false, cp)
+	test.AssertNotError(t, err, "revokeSerials failed")
+	test.AssertEquals(t, result.Revoked, 0)
+	test.AssertEquals(t, len(result.Skipped), 2)
+	test.AssertEquals(t, len(ra.revoked), 0)
+}
+
+// failingRA is a mockRA which fails to revoke the certificates with the
+// serials in fail, with the error given for each.
+type failingRA struct {
+	mockRA
+	fail map[string]error
+}
+
+func (ra *failingRA) AdministrativelyRevokeCertificate(ctx context.Context, cert x509.Certificate, reason revocation.Reason, user string, comment string, revokedAt time.Time, force bool) error {
+	ra.err = ra.fail[core.SerialToString(cert.SerialNumber)]
+	return ra.mockRA.AdministrativelyRevokeCertificate(ctx, cert, reason, user, comment, revokedAt, force)
+}
+
+func TestRevokeSerialsFailures(t *testing.T) {
+	lookup := &countingLookup{mockLookup: &mockLookup{}}
+	var serials []string
+	for i := int64(1); i <= 4; i++ {
+		cert := mockCertificate(t, i, 1)
+		lookup.certs = append(lookup.certs, cert)
+		serials = append(serials, cert.Serial)
+	}
+	ra := &failingRA{fail: map[string]error{serials[1]: berrors.InternalServerError("oops")}}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+
+	// A failure is recorded and the rest of the serials are still revoked.
+	result, err := r.revokeSerials(context.Background(), lookup, serials, revocation.Reason(ocsp.KeyCompromise), nil, Options{Operator: "alice"}, false, nil)
+	test.AssertNotError(t, err, "revokeSerials failed")
+	test.AssertEquals(t, result.Revoked, 3)
+	test.AssertEquals(t, len(result.Failures), 1)
+	test.AssertEquals(t, result.Failures[0].Serial, serials[1])
+	test.AssertDeepEquals(t, ra.revoked, serials)
+	test.AssertEquals(t, lookup.revocations, 3)
+
+	// With strict, the first failure aborts the batch.
+	ra.revoked = nil
+	result, err = r.revokeSerials(context.Background(), lookup, serials, revocation.Reason(ocsp.KeyCompromise), nil, Options{Operator: "alice"}, true, nil)
+	test.AssertError(t, err, "revokeSerials succeeded with a failing serial under strict")
+	test.AssertEquals(t, result.Revoked, 1)
+	test.AssertEquals(t, len(result.Failures), 1)
+	test.AssertDeepEquals(t, ra.revoked, serials[:2])
+
+	// The batch stops once the RA can't be reached.
+	ra.revoked = nil
+	ra.fail[serials[2]] = status.Error(codes.Unavailable, "connection refused")
+	result, err = r.revokeSerials(context.Background(), lookup, serials, revocation.Reason(ocsp.KeyCompromise), nil, Options{Operator: "alice"}, false, nil)
+	unavailable, ok := err.(BackendUnavailableError)
+	test.Assert(t, ok, fmt.Sprintf("unexpected error type: %#v", err))
+	test.AssertEquals(t, unavailable.Revoked, 1)
+	test.AssertEquals(t, len(result.Failures), 2)
+	test.AssertDeepEquals(t, ra.revoked, serials[:3])
+}
+
+func TestRevokeSkipExpired(t *testing.T) {
+	fc := clock.NewFake()
+	fc.Set(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+	valid := mockCertificateExpiring(t, 1, 1, fc.Now().Add(time.Hour))
+	expired := mockCertificateExpiring(t, 2, 1, fc.Now().Add(-time.Hour))
+	lookup := &mockLookup{certs: []core.Certificate{valid, expired}}
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), fc, metrics.NoopRegisterer)
+
+	opts := Options{Operator: "alice", SkipExpired: true}
+	result, err := r.revokeByReg(context.Background(), lookup, 1, IssuedWindow{}, revocation.Reason(ocsp.Superseded), opts, nil)
+	test.AssertNotError(t, err, "revokeByReg failed")
+	test.AssertEquals(t, result.Revoked, 1)
+	test.AssertDeepEquals(t, result.Skipped, []SkippedSerial{{expired.Serial, skipExpired}})
+	test.AssertEquals(t, len(result.Failures), 0)
+	test.AssertDeepEquals(t, ra.revoked, []string{valid.Serial})
+	test.AssertDeepEquals(t, result.Attempted, []string{valid.Serial})
+
+	// Without SkipExpired, expired certificates are revoked too.
+	ra.revoked = nil
+	opts.SkipExpired = false
+	result, err = r.revokeByReg(context.Background(), lookup, 1, IssuedWindow{}, revocation.Reason(ocsp.Superseded), opts, nil)
+	test.AssertNotError(t, err, "revokeByReg failed")
+	test.AssertEquals(t, result.Revoked, 2)
+	test.AssertEquals(t, len(result.Skipped), 0)
+	test.AssertDeepEquals(t, ra.revoked, []string{valid.Serial, expired.Serial})
+}
+
+func TestVerifyRevoked(t *testing.T) {
+	lookup := &mockLookup{
+		statuses: map[string]core.OCSPStatus{
+			"01": core.OCSPStatusRevoked,
+			"02": core.OCSPStatusGood,
+			"03": core.OCSPStatusRevoked,
+		},
+	}
+	notRevoked, err := verifyRevoked(lookup, []string{"01", "02", "03"})
+	test.AssertNotError(t, err, "verifyRevoked failed")
+	test.AssertDeepEquals(t, notRevoked, []string{"02"})
+
+	notRevoked, err = verifyRevoked(lookup, []string{"01", "03"})
+	test.AssertNotError(t, err, "verifyRevoked failed")
+	test.AssertEquals(t, len(notRevoked), 0)
+}
+
+func TestCheckConfig(t *testing.T) {
+	test.AssertNotError(t, checkReasons(), "checkReasons failed")
+	test.AssertError(t, checkServiceConfig(nil), "checkServiceConfig accepted a missing service")
+	test.AssertError(t, checkServiceConfig(&cmd.GRPCClientConfig{}), "checkServiceConfig accepted an empty address")
+	test.AssertNotError(t, checkServiceConfig(&cmd.GRPCClientConfig{ServerAddress: "ra.boulder:9094"}), "checkServiceConfig failed")
+
+	// Nothing is connected to, since neither the database URL nor the TLS
+	// config are valid.
+	c := Config{DBConfig: cmd.DBConfig{DBConnect: "not a DSN"}}
+	checks := CheckConfig(context.Background(), c, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+	var components []string
+	for _, check := range checks {
+		components = append(components, check.Component)
+		if check.Component == "reason codes" {
+			test.AssertNotError(t, check.Err, "reason codes check failed")
+		} else {
+			test.AssertError(t, check.Err, fmt.Sprintf("%s check passed", check.Component))
+		}
+	}
+	test.AssertDeepEquals(t, components, []string{"database URL", "RA service", "SA service", "TLS files", "reason codes"})
+}
+
+func TestCategorizeFailures(t *testing.T) {
+	failures := []SerialError{
+		{"01", berrors.NotFoundError("certificate with serial \"01\" not found")},
+		{"02", berrors.InternalServerError("oops")},
+		{"03", status.Error(codes.Unavailable, "connection refused")},
+		{"04", berrors.MalformedError("invalid serial")},
+		{"05", db.ErrDatabaseOp{Op: "select", Table: "certificateStatus", Err: sql.ErrNoRows}},
+		{"06", berrors.AlreadyRevokedError("already revoked")},
+		{"07", db.ErrDatabaseOp{Op: "select", Table: "certificates", Err: errors.New("bad connection")}},
+		{"08", errors.New("revoked, but OCSP verification failed")},
+	}
+	test.AssertDeepEquals(t, categorizeFailures(failures), []failureCategory{
+		{failureNotFound, 2, failures[0]},
+		{failureAlreadyRevoked, 1, failures[5]},
+		{failureMalformed, 1, failures[3]},
+		{failureBackend, 2, failures[1]},
+		{failureDatabase, 1, failures[6]},
+		{failureOther, 1, failures[7]},
+	})
+	test.AssertEquals(t, len(categorizeFailures(nil)), 0)
+
+	log := blog.NewMock()
+	BatchResult{Revoked: 1, Failures: failures[:3]}.Log(log)
+	test.AssertEquals(t, len(log.GetAllMatching("2 backend error, e.g. 02: oops")), 1)
+	test.AssertEquals(t, len(log.GetAllMatching("1 not found, e.g. 01: ")), 1)
+}
+
+func TestBatchResultSkipped(t *testing.T) {
+	var result BatchResult
+	test.AssertNotError(t, result.add("01", nil), "add failed for a revoked serial")
+	test.AssertNotError(t, result.add("02", errSkippedRevoked), "add failed for an already revoked serial")
+	test.AssertNotError(t, result.add("03", errSkippedExpired), "add failed for an expired serial")
+	test.AssertNotError(t, result.add("04", errSkippedRevoked), "add failed for an already revoked serial")
+	notFound := berrors.NotFoundError("certificate with serial \"05\" not found")
+	test.AssertEquals(t, result.add("05", notFound), notFound)
+	test.AssertEquals(t, result.Revoked, 1)
+	test.AssertDeepEquals(t, result.Skipped, []SkippedSerial{
+		{"02", skipAlreadyRevoked},
+		{"03", skipExpired},
+		{"04", skipAlreadyRevoked},
+	})
+	test.AssertEquals(t, result.SkippedFor(skipAlreadyRevoked), 2)
+	test.AssertEquals(t, result.SkippedFor(skipNotFound), 0)
+
+	log := blog.NewMock()
+	result.Log(log)
+	test.AssertEquals(t, len(log.GetAllMatching("Batch complete: 1 revoked, 3 skipped, 1 failed")), 1)
+	test.AssertEquals(t, len(log.GetAllMatching("Skipped 03: expired")), 1)
+	test.AssertEquals(t, len(log.GetAllMatching("2 skipped as already revoked")), 1)
+	test.AssertEquals(t, len(log.GetAllMatching("skipped as not found")), 0)
+}
+
+func TestRevokedSerials(t *testing.T) {
+	result := BatchResult{
+		Attempted: []string{"01", "02", "03"},
+		Failures:  []SerialError{{"02", errors.New("oops")}},
+	}
+	test.AssertDeepEquals(t, result.RevokedSerials(), []string{"01", "03"})
+	test.AssertEquals(t, len(BatchResult{}.RevokedSerials()), 0)
+}
+
+func TestNotifier(t *testing.T) {
+	mailer := &mocks.Mailer{}
+	log := blog.NewMock()
+	n, err := newNotifier(mailer, defaultNotifySubject,
+		"Revoked for {{.Reason}}: {{range .Serials}}{{.}} {{end}}({{.Comment}})", log)
+	test.AssertNotError(t, err, "newNotifier failed")
+
+	contacts := []string{"mailto:one@example.com", "tel:+15555555555", "mailto:two@example.com"}
+	reg := core.Registration{ID: 1, Contact: &contacts}
+	err = n.NotifyRevoked(reg, []string{"01", "02"}, revocation.Reason(ocsp.KeyCompromise), "key posted publicly")
+	test.AssertNotError(t, err, "NotifyRevoked failed")
+	test.AssertDeepEquals(t, mailer.Messages, []mocks.MailerMessage{
+		{
+			To:      "one@example.com",
+			Subject: "Certificates for your Let's Encrypt account 1 have been revoked",
+			Body:    "Revoked for keyCompromise: 01 02 (key posted publicly)",
+		},
+		{
+			To:      "two@example.com",
+			Subject: "Certificates for your Let's Encrypt account 1 have been revoked",
+			Body:    "Revoked for keyCompromise: 01 02 (key posted publicly)",
+		},
+	})
+
+	// A registration without an email contact isn't notified.
+	mailer.Clear()
+	err = n.NotifyRevoked(core.Registration{ID: 2}, []string{"03"}, revocation.Reason(ocsp.KeyCompromise), "")
+	test.AssertNotError(t, err, "NotifyRevoked failed for a registration without contacts")
+	test.AssertEquals(t, len(mailer.Messages), 0)
+
+	_, err = newNotifier(mailer, "{{.Nope", "", log)
+	test.AssertError(t, err, "newNotifier accepted an invalid subject template")
+
+	// A template referring to a field which doesn't exist fails to execute.
+	n, err = newNotifier(mailer, defaultNotifySubject, "{{.Nope}}", log)
+	test.AssertNotError(t, err, "newNotifier failed")
+	err = n.NotifyRevoked(reg, []string{"01"}, revocation.Reason(ocsp.KeyCompromise), "")
+	test.AssertError(t, err, "NotifyRevoked succeeded with a template which can't be executed")
+}
+
+func TestFeed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed":
+			fmt.Fprint(w, `["00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:01", "0000000000000000000000000000000000ff"]`)
+		case "/invalid":
+			fmt.Fprint(w, `{"serials": []}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	feed, err := NewFeed(srv.URL+"/feed", FeedConfig{})
+	test.AssertNotError(t, err, "NewFeed failed")
+	test.AssertEquals(t, feed.client.Timeout, defaultFeedTimeout)
+	serials, err := feed.Fetch(context.Background())
+	test.AssertNotError(t, err, "Fetch failed")
+	test.AssertDeepEquals(t, serials, []string{"00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:01", "0000000000000000000000000000000000ff"})
+
+	feed, _ = NewFeed(srv.URL+"/invalid", FeedConfig{Timeout: cmd.ConfigDuration{Duration: time.Second}})
+	test.AssertEquals(t, feed.client.Timeout, time.Second)
+	_, err = feed.Fetch(context.Background())
+	test.AssertError(t, err, "Fetch accepted a feed which isn't an array")
+	feed, _ = NewFeed(srv.URL+"/missing", FeedConfig{})
+	_, err = feed.Fetch(context.Background())
+	test.AssertError(t, err, "Fetch accepted a 404")
+}
+
+func TestPusher(t *testing.T) {
+	var method, path string
+	var pushed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			var mf dto.MetricFamily
+			if dec.Decode(&mf) != nil {
+				break
+			}
+			pushed = append(pushed, mf.GetName())
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	registry := prometheus.NewRegistry()
+	r := New(nil, nil, nil, blog.UseMock(), clock.NewFake(), registry)
+	r.revokedCerts.WithLabelValues("keyCompromise").Inc()
+
+	p, err := NewPusher(PushGatewayConfig{URL: srv.URL + "/"}, registry)
+	test.AssertNotError(t, err, "NewPusher failed")
+	test.AssertEquals(t, p.client.Timeout, defaultPushTimeout)
+	err = p.Push(context.Background())
+	test.AssertNotError(t, err, "Push failed")
+	test.AssertEquals(t, method, "PUT")
+	test.AssertEquals(t, path, "/metrics/job/admin-revoker")
+	// The metrics are pushed under the names they are scraped under.
+	test.AssertDeepEquals(t, pushed, []string{"admin_revoker_revocation_latency", "admin_revoker_revoked_certificates"})
+
+	p, _ = NewPusher(PushGatewayConfig{URL: srv.URL, Job: "incident 1"}, registry)
+	test.AssertNotError(t, p.Push(context.Background()), "Push failed")
+	test.AssertEquals(t, path, "/metrics/job/incident 1")
+
+	_, err = NewPusher(PushGatewayConfig{URL: "pushgateway:9091"}, registry)
+	test.AssertError(t, err, "NewPusher accepted a URL without a scheme")
+	p, _ = NewPusher(PushGatewayConfig{URL: srv.URL + "/missing"}, registry)
+	srv.Config.Handler = http.NotFoundHandler()
+	test.AssertError(t, p.Push(context.Background()), "Push accepted a 404")
+}
+
+func TestRevokeFeedSerials(t *testing.T) {
+	processed := mockCertificate(t, 1, 1)
+	fresh := mockCertificate(t, 2, 1)
+	missing := core.SerialToString(big.NewInt(3))
+	lookup := &mockLookup{certs: []core.Certificate{processed, fresh}}
+	state := &Checkpoint{done: map[string]bool{processed.Serial: true}}
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+
+	feed := []string{processed.Serial, "0x" + fresh.Serial, fresh.Serial, missing, "not hex"}
+	result, err := r.revokeFeedSerials(context.Background(), lookup, feed, state, revocation.Reason(ocsp.KeyCompromise), Options{Operator: "alice"})
+	test.AssertNotError(t, err, "revokeFeedSerials failed")
+	test.AssertEquals(t, result.Revoked, 1)
+	test.AssertEquals(t, len(result.Failures), 1)
+	test.AssertEquals(t, result.Failures[0].Serial, "not hex")
+	test.AssertDeepEquals(t, result.Skipped, []SkippedSerial{{missing, skipNotFound}})
+	test.AssertDeepEquals(t, ra.revoked, []string{fresh.Serial})
+	test.Assert(t, state.contains(fresh.Serial), "revoked serial not recorded in state")
+	test.Assert(t, state.contains(missing), "missing serial not recorded in state")
+
+	// Everything valid in the feed has now been processed.
+	ra.revoked = nil
+	result, err = r.revokeFeedSerials(context.Background(), lookup, feed, state, revocation.Reason(ocsp.KeyCompromise), Options{Operator: "alice"})
+	test.AssertNotError(t, err, "revokeFeedSerials failed")
+	test.AssertEquals(t, result.Revoked, 0)
+	test.AssertEquals(t, len(ra.revoked), 0)
+}
+
+// mockCRL returns a DER CRL signed by a throwaway CA listing the serials,
+// each with the reasonCode extension given in reasons, if any.
+func mockCRL(t *testing.T, serials []int64, reasons map[int64]int) []byte {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	test.AssertNotError(t, err, "failed to generate test key")
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other CA"},
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCRLSign | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &k.PublicKey, k)
+	test.AssertNotError(t, err, "failed to generate test CA")
+	ca, err := x509.ParseCertificate(der)
+	test.AssertNotError(t, err, "failed to parse test CA")
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range serials {
+		rc := pkix.RevokedCertificate{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()}
+		if reason, ok := reasons[serial]; ok {
+			value, err := asn1.Marshal(asn1.Enumerated(reason))
+			test.AssertNotError(t, err, "failed to marshal reason code")
+			rc.Extensions = []pkix.Extension{{Id: oidCRLReason, Value: value}}
+		}
+		revoked = append(revoked, rc)
+	}
+	crl, err := ca.CreateCRL(rand.Reader, k, revoked, time.Now(), time.Now().Add(time.Hour))
+	test.AssertNotError(t, err, "failed to generate test CRL")
+	return crl
+}
+
+func TestParseCRL(t *testing.T) {
+	der := mockCRL(t, []int64{1, 2}, map[int64]int{2: ocsp.KeyCompromise})
+	keyCompromise := revocation.Reason(ocsp.KeyCompromise)
+	want := []CRLEntry{
+		{Serial: core.SerialToString(big.NewInt(1))},
+		{Serial: core.SerialToString(big.NewInt(2)), Reason: &keyCompromise},
+	}
+	entries, err := parseCRL(der)
+	test.AssertNotError(t, err, "parseCRL failed on DER")
+	test.AssertDeepEquals(t, entries, want)
+
+	entries, err = parseCRL(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}))
+	test.AssertNotError(t, err, "parseCRL failed on PEM")
+	test.AssertDeepEquals(t, entries, want)
+
+	_, err = parseCRL([]byte("not a CRL"))
+	test.AssertError(t, err, "parseCRL accepted garbage")
+}
+
+func TestRevokeCRLEntries(t *testing.T) {
+	unspecified := mockCertificate(t, 1, 1)
+	compromised := mockCertificate(t, 2, 1)
+	held := mockCertificate(t, 3, 1)
+	lookup := &mockLookup{certs: []core.Certificate{unspecified, compromised, held}}
+	ra := &mockRA{}
+	r := New(ra, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+
+	entries, err := parseCRL(mockCRL(t, []int64{1, 2, 3, 4}, map[int64]int{2: ocsp.KeyCompromise, 3: ocsp.CertificateHold}))
+	test.AssertNotError(t, err, "parseCRL failed")
+	result, err := r.revokeCRLEntries(context.Background(), lookup, entries, revocation.Reason(ocsp.Superseded), Options{Operator: "alice"})
+	test.AssertNotError(t, err, "revokeCRLEntries failed")
+	test.AssertEquals(t, result.Revoked, 2)
+	test.AssertDeepEquals(t, ra.revoked, []string{unspecified.Serial, compromised.Serial})
+	test.AssertDeepEquals(t, ra.reasons, []revocation.Reason{ocsp.Superseded, ocsp.KeyCompromise})
+	test.AssertDeepEquals(t, result.Skipped, []SkippedSerial{{core.SerialToString(big.NewInt(4)), skipNotFound}})
+	test.AssertEquals(t, len(result.Failures), 1)
+	test.AssertEquals(t, result.Failures[0].Serial, held.Serial)
+	test.Assert(t, berrors.Is(result.Failures[0].Err, berrors.Malformed), "certificateHold entry wasn't rejected as malformed")
+}
+
+// mockOCSPStore is an ocspStore over certificate statuses held in memory.
+type mockOCSPStore struct {
+	statuses  map[string]core.CertificateStatus
+	responses map[string][]byte
+	// ders, if set, holds the DER of each certificate. Otherwise a
+	// placeholder naming the serial is returned.
+	ders map[string][]byte
+}
+
+func (s *mockOCSPStore) certificateStatus(serial string) (core.CertificateStatus, error) {
+	status, ok := s.statuses[serial]
+	if !ok {
+		return core.CertificateStatus{}, berrors.NotFoundError("status for certificate with serial %q not found", serial)
+	}
+	return status, nil
+}
+
+func (s *mockOCSPStore) certificateDER(serial string) ([]byte, error) {
+	if s.ders != nil {
+		return s.ders[serial], nil
+	}
+	return []byte("der for " + serial), nil
+}
+
+func (s *mockOCSPStore) storeRevokedResponse(serial string, response []byte, _ time.Time) error {
+	s.responses[serial] = response
+	return nil
+}
+
+// mockOCSPGenerator records the requests made to it, responding with the
+// DER or serial requested.
+type mockOCSPGenerator struct {
+	reqs []*caPB.GenerateOCSPRequest
+}
+
+func (g *mockOCSPGenerator) GenerateOCSP(_ context.Context, req *caPB.GenerateOCSPRequest, _ ...grpc.CallOption) (*caPB.OCSPResponse, error) {
+	g.reqs = append(g.reqs, req)
+	if req.Serial != nil {
+		return &caPB.OCSPResponse{Response: []byte("response for " + *req.Serial)}, nil
+	}
+	return &caPB.OCSPResponse{Response: []byte("response for " + string(req.CertDER))}, nil
+}
+
+func TestRefreshOCSP(t *testing.T) {
+	revokedAt := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	issuerID := int64(42)
+	store := &mockOCSPStore{
+		statuses: map[string]core.CertificateStatus{
+			"01": {Serial: "01", Status: core.OCSPStatusRevoked, RevokedDate: revokedAt, RevokedReason: revocation.Reason(ocsp.KeyCompromise)},
+			"02": {Serial: "02", Status: core.OCSPStatusGood},
+			"03": {Serial: "03", Status: core.OCSPStatusRevoked, RevokedDate: revokedAt, RevokedReason: revocation.Reason(ocsp.Superseded), IssuerID: &issuerID},
+		},
+		responses: make(map[string][]byte),
+	}
+	ogc := &mockOCSPGenerator{}
+	r := New(nil, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+
+	_, err := r.RefreshOCSP(context.Background(), []string{"01"}, Options{})
+	test.AssertEquals(t, err, errNoOCSPGenerator)
+
+	r.ogc = ogc
+	result, err := r.refreshOCSP(context.Background(), store, []string{"01", "02", "0x03", "04", "not hex"}, Options{})
+	test.AssertNotError(t, err, "refreshOCSP failed")
+	test.AssertEquals(t, result.Refreshed, 2)
+	test.AssertEquals(t, result.Skipped, 1)
+	test.AssertEquals(t, len(result.Failures), 2)
+	test.Assert(t, berrors.Is(result.Failures[0].Err, berrors.NotFound), "missing serial should fail with NotFound")
+	test.AssertDeepEquals(t, store.responses, map[string][]byte{
+		"01": []byte("response for der for 01"),
+		"03": []byte("response for 03"),
+	})
+	test.AssertEquals(t, len(ogc.reqs), 2)
+	test.AssertEquals(t, *ogc.reqs[0].Status, string(core.OCSPStatusRevoked))
+	test.AssertEquals(t, *ogc.reqs[0].Reason, int32(ocsp.KeyCompromise))
+	test.AssertEquals(t, *ogc.reqs[0].RevokedAt, revokedAt.UnixNano())
+	test.AssertEquals(t, *ogc.reqs[1].IssuerID, issuerID)
+
+	// A dry run signs and stores nothing.
+	ogc.reqs = nil
+	store.responses = make(map[string][]byte)
+	result, err = r.refreshOCSP(context.Background(), store, []string{"01", "03"}, Options{DryRun: true})
+	test.AssertNotError(t, err, "refreshOCSP failed")
+	test.AssertEquals(t, result.Refreshed, 2)
+	test.AssertEquals(t, len(ogc.reqs), 0)
+	test.AssertEquals(t, len(store.responses), 0)
+}
+
+// mockOCSPQuerier is an ocspQuerier responding with the status and reason held
+// in memory for each serial, or failing if there is none.
+type mockOCSPQuerier struct {
+	responses map[string]*ocsp.Response
+}
+
+func (q *mockOCSPQuerier) query(_ context.Context, cert *x509.Certificate) (*ocsp.Response, error) {
+	resp, ok := q.responses[core.SerialToString(cert.SerialNumber)]
+	if !ok {
+		return nil, errors.New("OCSP responder returned HTTP status 500")
+	}
+	return resp, nil
+}
+
+func TestReconcile(t *testing.T) {
+	revokedAt := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := &mockOCSPStore{
+		statuses:  make(map[string]core.CertificateStatus),
+		responses: make(map[string][]byte),
+		ders:      make(map[string][]byte),
+	}
+	querier := &mockOCSPQuerier{responses: make(map[string]*ocsp.Response)}
+	var serials []string
+	for i, tc := range []struct {
+		dbStatus   core.OCSPStatus
+		dbReason   int
+		ocspStatus int
+		ocspReason int
+	}{
+		{core.OCSPStatusRevoked, ocsp.KeyCompromise, ocsp.Revoked, ocsp.KeyCompromise},
+		{core.OCSPStatusGood, 0, ocsp.Good, 0},
+		{core.OCSPStatusRevoked, ocsp.KeyCompromise, ocsp.Good, 0},
+		{core.OCSPStatusGood, 0, ocsp.Revoked, ocsp.Superseded},
+		{core.OCSPStatusRevoked, ocsp.KeyCompromise, ocsp.Revoked, ocsp.Superseded},
+		{core.OCSPStatusGood, 0, ocsp.Unknown, 0},
+	} {
+		cert := mockCertificate(t, int64(i+1), 1)
+		serials = append(serials, cert.Serial)
+		store.ders[cert.Serial] = cert.DER
+		store.statuses[cert.Serial] = core.CertificateStatus{
+			Serial:        cert.Serial,
+			Status:        tc.dbStatus,
+			RevokedDate:   revokedAt,
+			RevokedReason: revocation.Reason(tc.dbReason),
+		}
+		querier.responses[cert.Serial] = &ocsp.Response{Status: tc.ocspStatus, RevocationReason: tc.ocspReason}
+	}
+	unqueried := mockCertificate(t, 7, 1)
+	store.ders[unqueried.Serial] = unqueried.DER
+	store.statuses[unqueried.Serial] = core.CertificateStatus{Serial: unqueried.Serial, Status: core.OCSPStatusGood}
+	serials = append(serials, unqueried.Serial, "not hex")
+
+	ogc := &mockOCSPGenerator{}
+	r := New(nil, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+	_, err := r.Reconcile(context.Background(), serials, nil, true, Options{})
+	test.AssertEquals(t, err, errNoOCSPGenerator)
+
+	r.ogc = ogc
+	result, err := r.reconcile(context.Background(), store, querier, serials, false, Options{})
+	test.AssertNotError(t, err, "reconcile failed")
+	test.AssertEquals(t, result.Checked, 6)
+	test.AssertEquals(t, len(result.Failures), 2)
+	test.AssertContains(t, result.Failures[0].Err.Error(), "querying OCSP")
+	test.Assert(t, berrors.Is(result.Failures[1].Err, berrors.Malformed), "invalid serial should fail as malformed")
+	test.AssertDeepEquals(t, result.Mismatches, []OCSPMismatch{
+		{Serial: serials[2], DBStatus: core.OCSPStatusRevoked, OCSPStatus: "good"},
+		{Serial: serials[3], DBStatus: core.OCSPStatusGood, OCSPStatus: "revoked"},
+		{Serial: serials[4], DBStatus: core.OCSPStatusRevoked, OCSPStatus: "revoked",
+			Detail: "reason is 'keyCompromise' in the database but 'superseded' in OCSP"},
+		{Serial: serials[5], DBStatus: core.OCSPStatusGood, OCSPStatus: "unknown"},
+	})
+	test.AssertEquals(t, result.Unfixed(), 4)
+	test.AssertEquals(t, len(ogc.reqs), 0)
+
+	// Fixing refreshes the responses of the certificates revoked in the
+	// database, leaving the others alone.
+	result, err = r.reconcile(context.Background(), store, querier, serials[:6], true, Options{})
+	test.AssertNotError(t, err, "reconcile failed")
+	test.AssertEquals(t, result.Unfixed(), 2)
+	test.Assert(t, result.Mismatches[0].Fixed, "revoked certificate good in OCSP wasn't fixed")
+	test.Assert(t, !result.Mismatches[1].Fixed, "good certificate revoked in OCSP was fixed")
+	test.AssertEquals(t, len(ogc.reqs), 2)
+	test.AssertEquals(t, len(store.responses), 2)
+}
+
+// mockRunStore is a runStore over runs held in memory.
+type mockRunStore struct {
+	started   map[string]string
+	completes map[string]time.Time
+	counts    map[string]RunCounts
+	runs      []RunRecord
+}
+
+func (s *mockRunStore) completed(runID string) (bool, *time.Time, error) {
+	if _, ok := s.started[runID]; !ok {
+		return false, nil, nil
+	}
+	if completed, ok := s.completes[runID]; ok {
+		return true, &completed, nil
+	}
+	return true, nil, nil
+}
+
+func (s *mockRunStore) start(runID, command, operator, args string, _ time.Time) error {
+	s.started[runID] = command + " " + args + " by " + operator
+	delete(s.completes, runID)
+	return nil
+}
+
+func (s *mockRunStore) complete(runID string, completed time.Time, counts RunCounts) error {
+	s.completes[runID] = completed
+	s.counts[runID] = counts
+	return nil
+}
+
+func (s *mockRunStore) list(since time.Time) ([]RunRecord, error) {
+	var runs []RunRecord
+	for _, run := range s.runs {
+		if !run.Started.Before(since) {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+// mockReadOnlySelector answers SELECT @@global.read_only with readOnly, or
+// fails with err if it is set.
+type mockReadOnlySelector struct {
+	readOnly int64
+	err      error
+}
+
+func (s mockReadOnlySelector) SelectOne(holder interface{}, _ string, _ ...interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	*holder.(*int64) = s.readOnly
+	return nil
+}
+
+func TestCheckWritable(t *testing.T) {
+	test.AssertNotError(t, checkWritable(mockReadOnlySelector{readOnly: 0}), "checkWritable failed for a writable database")
+
+	err := checkWritable(mockReadOnlySelector{readOnly: 1})
+	test.AssertDeepEquals(t, err, DatabaseError{ErrReadOnlyDatabase})
+
+	err = checkWritable(mockReadOnlySelector{err: errors.New("oops")})
+	test.AssertError(t, err, "checkWritable succeeded when the check failed")
+	_, ok := err.(DatabaseError)
+	test.Assert(t, ok, fmt.Sprintf("expected a DatabaseError, got %T", err))
+}
+
+func TestRetryConnect(t *testing.T) {
+	clk := clock.NewFake()
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	// The service is reached on the third attempt.
+	var attempts int
+	start := clk.Now()
+	err := retryConnect("RA", 3, clk, blog.NewMock(), func() error {
+		attempts++
+		if attempts < 3 {
+			return unavailable
+		}
+		return nil
+	})
+	test.AssertNotError(t, err, "retryConnect failed")
+	test.AssertEquals(t, attempts, 3)
+	test.Assert(t, clk.Since(start) > 0, "retryConnect didn't back off")
+
+	// Retries are bounded.
+	attempts = 0
+	err = retryConnect("RA", 2, clk, blog.NewMock(), func() error {
+		attempts++
+		return status.Error(codes.DeadlineExceeded, "context deadline exceeded")
+	})
+	test.AssertEquals(t, status.Code(err), codes.DeadlineExceeded)
+	test.AssertEquals(t, attempts, 3)
+
+	// Errors other than failing to reach the service aren't retried.
+	attempts = 0
+	err = retryConnect("RA", 2, clk, blog.NewMock(), func() error {
+		attempts++
+		return status.Error(codes.PermissionDenied, "not authorized")
+	})
+	test.AssertEquals(t, status.Code(err), codes.PermissionDenied)
+	test.AssertEquals(t, attempts, 1)
+}
+
+func TestConnectServiceSetup(t *testing.T) {
+	sc := &cmd.GRPCClientConfig{ServerAddress: "ra:9094", HealthCheckTimeout: cmd.ConfigDuration{Duration: time.Second}}
+	var setUp *cmd.GRPCClientConfig
+	setup := func(c *cmd.GRPCClientConfig) (*grpc.ClientConn, error) {
+		setUp = c
+		return nil, errors.New("oops")
+	}
+	_, err := connectService("RA", Config{}, sc, setup, clock.NewFake(), blog.NewMock())
+	test.AssertError(t, err, "connectService succeeded when setup failed")
+	test.Assert(t, setUp == sc, "connectService without retries didn't set up the configured service")
+
+	// With retries, the service's own health check is replaced by the retried
+	// one, and a failure to set up the connection isn't retried.
+	_, err = connectService("RA", Config{ConnectRetries: 3}, sc, setup, clock.NewFake(), blog.NewMock())
+	test.AssertError(t, err, "connectService succeeded when setup failed")
+	test.AssertEquals(t, setUp.HealthCheckTimeout.Duration, time.Duration(0))
+	test.AssertEquals(t, sc.HealthCheckTimeout.Duration, time.Second)
+}
+
+func TestRunLog(t *testing.T) {
+	store := &mockRunStore{started: map[string]string{}, completes: map[string]time.Time{}, counts: map[string]RunCounts{}}
+	clk := clock.NewFake()
+	rl := &RunLog{store: store, log: blog.NewMock(), clk: clk}
+	opts := Options{Operator: "alice"}
+	args := []string{"serials.txt", "1"}
+
+	err := rl.Start("incident-1", "batch-revoke", args, Options{Operator: "alice", DryRun: true}, false)
+	test.AssertNotError(t, err, "Start failed for a dry run")
+	test.AssertEquals(t, len(store.started), 0)
+
+	test.AssertNotError(t, rl.Start("incident-1", "batch-revoke", args, opts, false), "Start failed")
+	test.AssertEquals(t, store.started["incident-1"], "batch-revoke serials.txt 1 by alice")
+	// A run which didn't complete can be run again.
+	test.AssertNotError(t, rl.Start("incident-1", "batch-revoke", args, opts, false), "Start failed for an incomplete run")
+
+	counts := RunCounts{Revoked: 3, Skipped: 1}
+	test.AssertNotError(t, rl.Complete("incident-1", counts), "Complete failed")
+	test.AssertEquals(t, store.counts["incident-1"], counts)
+	err = rl.Start("incident-1", "batch-revoke", args, opts, false)
+	test.AssertDeepEquals(t, err, RunCompletedError{RunID: "incident-1", Completed: clk.Now()})
+	test.AssertNotError(t, rl.Start("incident-1", "batch-revoke", args, opts, true), "Start failed for a forced run")
+	_, completed, _ := store.completed("incident-1")
+	test.Assert(t, completed == nil, "forced run wasn't started again")
+}
+
+func TestListRuns(t *testing.T) {
+	clk := clock.NewFake()
+	earlier := clk.Now().Add(-time.Hour)
+	store := &mockRunStore{runs: []RunRecord{
+		{RunID: "incident-1", Command: "batch-revoke", Started: earlier},
+		{RunID: "incident-2", Command: "reg-revoke", Started: clk.Now()},
+	}}
+	rl := &RunLog{store: store, log: blog.NewMock(), clk: clk}
+
+	runs, err := rl.ListRuns(time.Time{})
+	test.AssertNotError(t, err, "ListRuns failed")
+	test.AssertEquals(t, len(runs), 2)
+
+	runs, err = rl.ListRuns(earlier.Add(time.Minute))
+	test.AssertNotError(t, err, "ListRuns failed with since")
+	test.AssertEquals(t, len(runs), 1)
+	test.AssertEquals(t, runs[0].RunID, "incident-2")
+}
+
+func TestRunTally(t *testing.T) {
+	var tally *RunTally
+	tally.record(reportRevoked, nil)
+	test.AssertEquals(t, tally.Counts(), RunCounts{})
+	test.AssertDeepEquals(t, tally.Summary(), TallySummary{SkippedBy: map[string]int{}, FailedBy: map[string]int{}})
+
+	tally = &RunTally{}
+	for _, r := range []struct {
+		result string
+		err    error
+	}{
+		{reportRevoked, nil},
+		{reportRevoked + " precertificate", nil},
+		{reportSkipped, errSkippedRevoked},
+		{reportSkipped, errSkippedRevoked},
+		{reportSkipped, errSkippedExpired},
+		{reportDryRun, nil},
+		{reportError, berrors.NotFoundError("no certificate")},
+		{reportError + ": revoked, but OCSP verification failed", nil},
+	} {
+		tally.record(r.result, r.err)
+	}
+	test.AssertEquals(t, tally.Counts(), RunCounts{Revoked: 2, Skipped: 3, Failed: 2})
+	summary := tally.Summary()
+	test.AssertEquals(t, summary.DryRun, 1)
+	test.AssertEquals(t, summary.Total(), 8)
+	test.AssertDeepEquals(t, summary.SkippedBy, map[string]int{skipAlreadyRevoked: 2, skipExpired: 1})
+	test.AssertDeepEquals(t, summary.FailedBy, map[string]int{failureNotFound: 1, failureOther: 1})
+}
+
+func TestRevocationImpact(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	var impact RevocationImpact
+	test.AssertEquals(t, impact.String(), "no unexpired certificates would be added to the revocation lists")
+	impact.add(now.Add(-time.Hour), false, now)
+	impact.add(now.Add(90*24*time.Hour), true, now)
+	impact.add(now.Add(24*time.Hour), false, now)
+	impact.add(now.Add(60*24*time.Hour), false, now)
+	test.AssertDeepEquals(t, impact, RevocationImpact{
+		Unexpired:    2,
+		ExpiringSoon: 1,
+		LastExpiry:   now.Add(60 * 24 * time.Hour),
+		CRLBytes:     2 * crlEntrySize,
+	})
+	test.AssertEquals(t, impact.String(), "2 unexpired certificates would be added to the revocation lists, about 80 bytes of CRL entries, "+
+		"1 of them expiring within 7 days and the last at 2020-07-31T00:00:00Z")
+}
+
+func TestRevokeDryRunImpact(t *testing.T) {
+	fc := clock.NewFake()
+	fc.Set(time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+	valid := mockCertificateExpiring(t, 1, 1, fc.Now().Add(30*24*time.Hour))
+	expired := mockCertificateExpiring(t, 2, 1, fc.Now().Add(-time.Hour))
+	lookup := &mockLookup{certs: []core.Certificate{valid, expired}}
+	r := New(&mockRA{}, nil, nil, blog.NewMock(), fc, metrics.NoopRegisterer)
+
+	tally := &RunTally{}
+	opts := Options{Operator: "alice", DryRun: true, Tally: tally}
+	result, err := r.revokeSerials(context.Background(), lookup, []string{valid.Serial, expired.Serial}, 0, nil, opts, false, nil)
+	test.AssertNotError(t, err, "revokeSerials failed")
+	test.AssertEquals(t, result.Revoked, 2)
+	summary := tally.Summary()
+	test.AssertEquals(t, summary.DryRun, 2)
+	test.AssertEquals(t, summary.Impact.Unexpired, 1)
+	test.AssertEquals(t, summary.Impact.LastExpiry, fc.Now().Add(30*24*time.Hour))
+}
+
+func TestLockKey(t *testing.T) {
+	test.AssertEquals(t, LockKey(LockConfig{}, "reg-revoke", []string{"1", "2"}), "admin-revoker:reg-revoke:1,2")
+	test.AssertEquals(t, LockKey(LockConfig{KeyPrefix: "staging"}, "issuer-revoke", nil), "staging:issuer-revoke:")
+
+	long := LockKey(LockConfig{}, "batch-revoke", []string{"/var/tmp/incident-2020-06-01/serials-batch-0001.txt"})
+	test.AssertEquals(t, len(long), maxLockKeyLen)
+	test.Assert(t, strings.HasPrefix(long, "admin-revoker:batch-revoke:/va"), "long key doesn't keep its prefix")
+	other := LockKey(LockConfig{}, "batch-revoke", []string{"/var/tmp/incident-2020-06-01/serials-batch-0002.txt"})
+	test.Assert(t, long != other, "distinct long targets got the same key")
+}
+
+// mockLockSession holds the locks in held, as if another session held those
+// initially in it.
+type mockLockSession struct {
+	held     map[string]bool
+	released []string
+	closed   int
+}
+
+func (s *mockLockSession) getLock(_ context.Context, key string, _ time.Duration) (bool, error) {
+	if s.held[key] {
+		return false, nil
+	}
+	s.held[key] = true
+	return true, nil
+}
+
+func (s *mockLockSession) releaseLock(key string) error {
+	s.released = append(s.released, key)
+	delete(s.held, key)
+	return nil
+}
+
+func (s *mockLockSession) close() error {
+	s.closed++
+	return nil
+}
+
+func TestAcquireLock(t *testing.T) {
+	session := &mockLockSession{held: map[string]bool{"admin-revoker:reg-revoke:1": true}}
+	_, err := acquireLock(context.Background(), session, "admin-revoker:reg-revoke:1", 0, blog.NewMock())
+	test.AssertEquals(t, err, error(LockHeldError{"admin-revoker:reg-revoke:1"}))
+	test.AssertEquals(t, err.Error(), `another revocation is in progress: lock "admin-revoker:reg-revoke:1" is held by another run`)
+	test.AssertEquals(t, session.closed, 1)
+
+	session = &mockLockSession{held: map[string]bool{}}
+	lock, err := acquireLock(context.Background(), session, "admin-revoker:reg-revoke:2", 0, blog.NewMock())
+	test.AssertNotError(t, err, "acquireLock failed")
+	lock.Release()
+	lock.Release()
+	test.AssertDeepEquals(t, session.released, []string{"admin-revoker:reg-revoke:2"})
+	test.AssertEquals(t, session.closed, 1)
+	(*Lock)(nil).Release()
+}
+
+func TestSummarizeArgs(t *testing.T) {
+	test.AssertEquals(t, summarizeArgs([]string{"serials.txt", "1"}), "serials.txt 1")
+	long := summarizeArgs([]string{strings.Repeat("a", maxRunArgs), "1"})
+	test.AssertEquals(t, len(long), maxRunArgs)
+	test.Assert(t, strings.HasSuffix(long, "..."), "long arguments weren't truncated")
+}
+
+func TestRedactDBURL(t *testing.T) {
+	test.AssertEquals(t, redactDBURL("revoker:hunter2@tcp(replica:3306)/boulder_sa"), "revoker:REDACTED@tcp(replica:3306)/boulder_sa")
+	test.AssertEquals(t, redactDBURL("revoker@tcp(boulder-mysql:3306)/boulder_sa_integration"), "revoker@tcp(boulder-mysql:3306)/boulder_sa_integration")
+	test.AssertEquals(t, redactDBURL("revoker:hunter2@tcp(replica:3306"), "(unparseable URL)")
+}
+
+func TestIssuedWindow(t *testing.T) {
+	test.Assert(t, IssuedWindow{}.Contains(time.Now()), "open window doesn't contain now")
+	test.AssertEquals(t, IssuedWindow{}.String(), "at any time")
+
+	w := IssuedWindow{
+		After:  time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		Before: time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC),
+	}
+	test.AssertEquals(t, w.String(), "after 2020-05-01T00:00:00Z and before 2020-05-02T00:00:00Z")
+	test.Assert(t, w.Contains(time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)), "window doesn't contain time within it")
+	test.Assert(t, !w.Contains(time.Date(2020, 4, 30, 12, 0, 0, 0, time.UTC)), "window contains time before it")
+	test.Assert(t, !w.Contains(time.Date(2020, 5, 2, 12, 0, 0, 0, time.UTC)), "window contains time after it")
+
+	w = IssuedWindow{After: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)}
+	test.AssertEquals(t, w.String(), "after 2020-05-01T00:00:00Z")
+	test.Assert(t, w.Contains(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)), "window doesn't contain time after its start")
+}
+
+func TestRevokeSerialsParallelStopped(t *testing.T) {
+	stop := make(chan struct{})
+	close(stop)
+	opts := Options{Stop: stop}
+	test.Assert(t, opts.stopped(), "closed Stop channel not reported as stopped")
+	test.Assert(t, !(Options{}).stopped(), "nil Stop channel reported as stopped")
+
+	// No certificates are revoked once stopped, so no database or RA is
+	// needed.
+	r := New(nil, nil, nil, blog.UseMock(), clock.NewFake(), metrics.NoopRegisterer)
+	result, err := r.revokeSerialsParallel(context.Background(), func(work chan<- string) error {
+		for _, serial := range []string{"a1", "a2", "a3"} {
+			work <- serial
+		}
+		return ErrInterrupted
+	}, 0, opts, 2, nil)
+	test.AssertEquals(t, err, ErrInterrupted)
+	test.AssertEquals(t, result.Revoked, 0)
+	test.AssertEquals(t, len(result.Failures), 0)
+}
+
+func TestOptionsOperator(t *testing.T) {
+	operator, err := Options{Operator: "alice"}.operator()
+	test.AssertNotError(t, err, "operator failed")
+	test.AssertEquals(t, operator, "alice")
+
+	u, err := user.Current()
+	test.AssertNotError(t, err, "failed to get current user")
+	operator, err = Options{}.operator()
+	test.AssertNotError(t, err, "operator failed")
+	test.AssertEquals(t, operator, u.Username)
+}
+
+func TestReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	test.AssertNotError(t, err, "failed to create temp dir")
+	defer os.RemoveAll(dir)
+	path := dir + "/report.csv"
+
+	var nilReport *Report
+	test.AssertNotError(t, nilReport.Close(), "closing a nil report failed")
+
+	report, err := OpenReport(path)
+	test.AssertNotError(t, err, "OpenReport failed")
+	fc := clock.NewFake()
+	fc.Set(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+	r := New(nil, nil, nil, blog.UseMock(), fc, metrics.NoopRegisterer)
+	opts := Options{Operator: "alice", Report: report}
+
+	// Neither of these revocations reach the database.
+	err = r.revokeBySerial(context.Background(), nil, "a1", 99, opts)
+	test.AssertError(t, err, "revokeBySerial accepted an invalid reason code")
+	cp := &Checkpoint{done: map[string]bool{"a2": true}}
+	err = r.revokeCheckpointed(context.Background(), nil, "a2", 1, opts, cp)
+	test.AssertEquals(t, err, errSkippedCheckpointed)
+	test.AssertNotError(t, report.Close(), "Close failed")
+
+	f, err := os.Open(path)
+	test.AssertNotError(t, err, "failed to open report")
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	test.AssertNotError(t, err, "report wasn't valid CSV")
+	test.AssertDeepEquals(t, rows, [][]string{
+		reportHeader,
+		{"a1", "", "99", "unknown(99)", "alice", "2020-05-01T00:00:00Z", "error: invalid reason code: 99"},
+		{"a2", "", "1", "keyCompromise", "alice", "2020-05-01T00:00:00Z", "skipped: revoked by a previous run"},
+	})
+}
+
+func TestProgress(t *testing.T) {
+	var nilProgress *Progress
+	nilProgress.SetTotal(1)
+	nilProgress.record("a1", reportRevoked, nil)
+
+	cert := mockCertificate(t, 1, 1)
+	lookup := &mockLookup{certs: []core.Certificate{cert}}
+	var out bytes.Buffer
+	progress := NewProgress(&out)
+	progress.SetTotal(3)
+	r := New(&mockRA{}, nil, nil, blog.NewMock(), clock.NewFake(), metrics.NoopRegisterer)
+	opts := Options{Operator: "alice", Progress: progress}
+
+	err := r.revokeBySerial(context.Background(), lookup, cert.Serial, 1, opts)
+	test.AssertNotError(t, err, "revokeBySerial failed")
+	err = r.revokeBySerial(context.Background(), lookup, "a1", 99, opts)
+	test.AssertError(t, err, "revokeBySerial accepted an invalid reason code")
+	cp := &Checkpoint{done: map[string]bool{"a2": true}}
+	err = r.revokeCheckpointed(context.Background(), lookup, "a2", 1, opts, cp)
+	test.AssertEquals(t, err, errSkippedCheckpointed)
+
+	test.AssertEquals(t, out.String(), fmt.Sprintf(`{"serial":"%s","status":"revoked","done":1,"total":3}
+{"serial":"a1","status":"error","error":"invalid reason code: 99","done":2,"total":3}
+{"serial":"a2","status":"skipped","reason":"revoked by a previous run","done":3,"total":3}
+`, cert.Serial))
+
+	// The total is omitted when it isn't known.
+	out.Reset()
+	NewProgress(&out).record("a3", reportDryRun, nil)
+	test.AssertEquals(t, out.String(), `{"serial":"a3","status":"dry-run","done":1}`+"\n")
+}
+
+func TestRateLimiter(t *testing.T) {
+	fc := clock.NewFake()
+	test.Assert(t, NewRateLimiter(fc, 0) == nil, "a rate of 0 should be unlimited")
+	var unlimited *RateLimiter
+	test.AssertNotError(t, unlimited.wait(context.Background()), "waiting for a nil limiter failed")
+
+	rl := NewRateLimiter(fc, 10)
+	test.AssertEquals(t, rl.reserve(), time.Duration(0))
+	test.AssertEquals(t, rl.reserve(), 100*time.Millisecond)
+	test.AssertEquals(t, rl.reserve(), 200*time.Millisecond)
+
+	// Unused time doesn't accumulate into a burst.
+	fc.Add(time.Minute)
+	test.AssertEquals(t, rl.reserve(), time.Duration(0))
+	test.AssertEquals(t, rl.reserve(), 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	test.AssertEquals(t, rl.wait(ctx), context.Canceled)
+
+	test.Assert(t, !rl.isAdaptive(), "NewRateLimiter returned an adaptive limiter")
+	rl.speedUp()
+	test.AssertEquals(t, rl.rate(), 10.0)
+
+	rl = NewAdaptiveRateLimiter(fc, 10)
+	test.Assert(t, rl.isAdaptive(), "NewAdaptiveRateLimiter returned a fixed limiter")
+	test.AssertEquals(t, rl.slowDown(), 5.0)
+	test.AssertEquals(t, rl.reserve(), time.Duration(0))
+	test.AssertEquals(t, rl.reserve(), 200*time.Millisecond)
+	rl.speedUp()
+	test.AssertEquals(t, rl.interval, 200*time.Millisecond-200*time.Millisecond/11)
+	// The rate never exceeds the one configured, nor falls below a 64th of it.
+	for i := 0; i < 100; i++ {
+		rl.speedUp()
+	}
+	test.AssertEquals(t, rl.rate(), 10.0)
+	for i := 0; i < 100; i++ {
+		rl.slowDown()
+	}
+	test.AssertEquals(t, rl.rate(), 10.0/64)
+}
+
+func TestOCSPVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	test.AssertNotError(t, err, "failed to generate key")
+	issuerTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	issuerDER, err := x509.CreateCertificate(rand.Reader, issuerTemplate, issuerTemplate, key.Public(), key)
+	test.AssertNotError(t, err, "failed to create issuer")
+	issuer, err := x509.ParseCertificate(issuerDER)
+	test.AssertNotError(t, err, "failed to parse issuer")
+	certTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, certTemplate, issuer, key.Public(), key)
+	test.AssertNotError(t, err, "failed to create certificate")
+	cert, err := x509.ParseCertificate(certDER)
+	test.AssertNotError(t, err, "failed to parse certificate")
+
+	// The responder only reports the certificate as revoked from the second
+	// query onwards.
+	var queries int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		template := ocsp.Response{
+			SerialNumber: cert.SerialNumber,
+			Status:       ocsp.Good,
+			ThisUpdate:   time.Now(),
+		}
+		if queries > 1 {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Now()
+			template.RevocationReason = ocsp.KeyCompromise
+		}
+		resp, err := ocsp.CreateResponse(issuer, issuer, template, key)
+		test.AssertNotError(t, err, "failed to create OCSP response")
+		_, _ = w.Write(resp)
+	}))
+	defer srv.Close()
+
+	v := NewOCSPVerifier(issuer, srv.URL, 200*time.Millisecond, time.Millisecond, clock.Default())
+	err = v.Verify(context.Background(), cert, ocsp.KeyCompromise)
+	test.AssertNotError(t, err, "Verify failed")
+	test.AssertEquals(t, queries, 2)
+
+	err = v.Verify(context.Background(), cert, ocsp.Superseded)
+	test.AssertError(t, err, "Verify succeeded with the wrong reason")
+	test.AssertContains(t, err.Error(), "not 'superseded'")
+
+	v = NewOCSPVerifier(issuer, "", 200*time.Millisecond, time.Millisecond, clock.Default())
+	err = v.Verify(context.Background(), cert, ocsp.KeyCompromise)
+	test.AssertError(t, err, "Verify succeeded without a responder")
+}
+
+func TestMatchedDomain(t *testing.T) {
+	_, err := ParseNameMatch("")
+	test.AssertError(t, err, "ParseNameMatch accepted an empty match")
+	match, err := ParseNameMatch("registered-domain")
+	test.AssertNotError(t, err, "ParseNameMatch rejected registered-domain")
+	test.AssertEquals(t, match, MatchRegisteredDomain)
+
+	testCases := []struct {
+		name     string
+		match    NameMatch
+		expected string
+	}{
+		{"WWW.Example.com.", MatchExact, "www.example.com"},
+		{"*.example.com", MatchExact, "*.example.com"},
+		{"www.example.com", MatchRegisteredDomain, "example.com"},
+		{"*.www.example.co.uk", MatchRegisteredDomain, "example.co.uk"},
+		{"example.com", MatchRegisteredDomain, "example.com"},
+	}
+	for _, tc := range testCases {
+		domain, err := MatchedDomain(tc.name, tc.match)
+		test.AssertNotError(t, err, fmt.Sprintf("MatchedDomain(%q, %q) failed", tc.name, tc.match))
+		test.AssertEquals(t, domain, tc.expected)
+	}
+
+	_, err = MatchedDomain("co.uk", MatchRegisteredDomain)
+	test.AssertError(t, err, "MatchedDomain accepted a public suffix")
+	test.Assert(t, berrors.Is(err, berrors.Malformed), "public suffix error should be Malformed")
+	_, err = MatchedDomain(" ", MatchExact)
+	test.AssertError(t, err, "MatchedDomain accepted an empty name")
+}
+
+func TestExplain(t *testing.T) {
+	var out strings.Builder
+	explainLogger{&out}.Printf("%s%s [%s] (%v)", "", "\n\tSELECT 1 FROM certificates WHERE serial = ?", `1:"00ff"`, time.Millisecond)
+	test.AssertEquals(t, out.String(), "SELECT 1 FROM certificates WHERE serial = ?;\n-- bound values: 1:\"00ff\"\n\n")
+
+	test.AssertEquals(t, explainArgs([]interface{}{"revoked", revocation.Reason(1), []byte("resp"), 5}),
+		`1:"revoked" 2:1 3:"resp" 4:5`)
+
+	out.Reset()
+	fc := clock.NewFake()
+	r := New(nil, nil, nil, blog.NewMock(), fc, metrics.NoopRegisterer)
+	r.explain = &out
+	err := r.explainRevocation("00ff", revocation.Reason(ocsp.KeyCompromise), Options{Operator: "alice"}, false, time.Time{})
+	test.AssertNotError(t, err, "explainRevocation failed")
+	test.AssertContains(t, out.String(), "UPDATE certificateStatus SET")
+	test.AssertContains(t, out.String(), fmt.Sprintf(`1:"revoked" 2:1 3:%s`, time.Unix(0, fc.Now().UnixNano())))
+	test.AssertContains(t, out.String(), fmt.Sprintf(`5:%q 6:"00ff" 7:"revoked"`, explainedResponse))
+
+	// With --force, the statement updates the revoked certificate's reason,
+	// keeping the date it was revoked at.
+	out.Reset()
+	revokedAt := fc.Now().Add(-time.Hour)
+	err = r.explainRevocation("00ff", revocation.Reason(ocsp.KeyCompromise), Options{Operator: "alice"}, true, revokedAt)
+	test.AssertNotError(t, err, "explainRevocation failed with force")
+	test.AssertContains(t, out.String(), "changes the certificate's revocation reason")
+	test.AssertContains(t, out.String(), "WHERE serial = ? AND status = ?")
+	test.AssertContains(t, out.String(), fmt.Sprintf(`1:1 2:%s 3:%s`, time.Unix(0, revokedAt.UnixNano()), fc.Now()))
+}
+
+func TestIssuerMatcher(t *testing.T) {
+	_, err := ParseIssuer(" ")
+	test.AssertError(t, err, "ParseIssuer accepted an empty issuer")
+
+	cert := &x509.Certificate{AuthorityKeyId: []byte{0xab, 0xcd, 0xef}}
+	cert.Issuer.CommonName = "Happy Hacker Fake CA"
+
+	byKeyID, err := ParseIssuer("AB:CD:EF")
+	test.AssertNotError(t, err, "ParseIssuer rejected a key ID")
+	test.Assert(t, byKeyID.Matches(cert), "issuer should match by key ID")
+
+	byName, err := ParseIssuer("Happy Hacker Fake CA")
+	test.AssertNotError(t, err, "ParseIssuer rejected a common name")
+	test.Assert(t, byName.Matches(cert), "issuer should match by common name")
+	test.AssertEquals(t, byName.String(), `issuer with common name "Happy Hacker Fake CA"`)
+
+	other, err := ParseIssuer("abcd")
+	test.AssertNotError(t, err, "ParseIssuer rejected a key ID")
+	test.Assert(t, !other.Matches(cert), "issuer shouldn't match a different key ID")
+}
+
+func TestConfirm(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"yes\n", true},
+		{"  yes  \n", true},
+		{"yes", true},
+		{"y\n", false},
+		{"YES\n", false},
+		{"no\n", false},
+		{"", false},
+	}
+	for _, tc := range testCases {
+		var out bytes.Buffer
+		ok, err := Confirm(strings.NewReader(tc.input), &out, "Really?")
+		test.AssertNotError(t, err, "confirm failed")
+		test.AssertEquals(t, ok, tc.expected)
+		test.AssertContains(t, out.String(), "Really?")
+	}
+}
+
+func TestListReasons(t *testing.T) {
+	var out bytes.Buffer
+	err := ListReasons(&out, "text", false, false)
+	test.AssertNotError(t, err, "ListReasons failed")
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	test.AssertEquals(t, len(lines), len(revocation.ReasonToString)+1)
+	test.AssertEquals(t, strings.Fields(lines[0])[0], "CODE")
+	test.AssertEquals(t, lines[2], "1     keyCompromise         accepted")
+	test.Assert(t, strings.HasPrefix(lines[1], "0     unspecified           accepted, but: "), "unexpected row for unspecified")
+	test.Assert(t, strings.HasPrefix(lines[7], "6     certificateHold       not accepted: "), "unexpected row for certificateHold")
+	// The name column starts at the same offset on every line.
+	for _, line := range lines {
+		test.AssertEquals(t, line[4:6], "  ")
+		test.Assert(t, line[6] != ' ', fmt.Sprintf("name column misaligned in %q", line))
+	}
+	test.AssertNotContains(t, out.String(), warningColor)
+
+	out.Reset()
+	err = ListReasons(&out, "text", false, true)
+	test.AssertNotError(t, err, "ListReasons failed")
+	lines = strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	test.AssertEquals(t, lines[2], "1     keyCompromise         accepted")
+	test.Assert(t, strings.HasPrefix(lines[7], warningColor+"6     certificateHold"), "certificateHold isn't highlighted")
+	test.Assert(t, strings.HasSuffix(lines[7], resetColor), "certificateHold highlight isn't reset")
+
+	out.Reset()
+	err = ListReasons(&out, "json", false, true)
+	test.AssertNotError(t, err, "ListReasons failed")
+	var reasons []reasonJSON
+	err = json.Unmarshal(out.Bytes(), &reasons)
+	test.AssertNotError(t, err, "ListReasons output wasn't valid JSON")
+	test.AssertEquals(t, len(reasons), len(revocation.ReasonToString))
+	test.AssertEquals(t, reasons[1], reasonJSON{Code: 1, Name: "keyCompromise", Accepted: true})
+	test.AssertEquals(t, reasons[6].Accepted, false)
+	test.AssertEquals(t, reasons[6].Description, "")
+	test.Assert(t, reasons[6].Note != "", "certificateHold has no note")
+	for i := 1; i < len(reasons); i++ {
+		test.Assert(t, reasons[i-1].Code < reasons[i].Code, "reasons weren't sorted by code")
+	}
+
+	out.Reset()
+	err = ListReasons(&out, "json", true, false)
+	test.AssertNotError(t, err, "ListReasons failed")
+	reasons = nil
+	err = json.Unmarshal(out.Bytes(), &reasons)
+	test.AssertNotError(t, err, "ListReasons output wasn't valid JSON")
+	test.AssertEquals(t, reasons[1].Description, revocation.ReasonDescription[1])
+
+	out.Reset()
+	err = ListReasons(&out, "text", true, false)
+	test.AssertNotError(t, err, "ListReasons failed")
+	test.AssertContains(t, out.String(), "DESCRIPTION")
+	test.AssertContains(t, out.String(), revocation.ReasonDescription[4])
+
+	err = ListReasons(&out, "yaml", false, false)
+	test.AssertError(t, err, "ListReasons didn't fail on unknown format")
+}
+
+func TestPrintSerialInfo(t *testing.T) {
+	info := SerialInfo{
+		Serial:             "00000000000000000000000000000001",
+		Kind:               "certificate",
+		Subject:            "CN=example.com",
+		DNSNames:           []string{"example.com", "www.example.com"},
+		NotBefore:          time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:           time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+		Status:             core.OCSPStatusGood,
+		RegistrationID:     7,
+		RegistrationStatus: core.StatusValid,
+	}
+	var out bytes.Buffer
+	test.AssertNotError(t, PrintSerialInfo(&out, info, "text"), "PrintSerialInfo failed")
+	test.AssertContains(t, out.String(), "Serial:               00000000000000000000000000000001 (certificate)\n")
+	test.AssertContains(t, out.String(), "Names:                example.com, www.example.com\n")
+	test.AssertContains(t, out.String(), "Not after:            2020-04-01T00:00:00Z\n")
+	test.AssertContains(t, out.String(), "Registration:         7\n")
+	test.AssertContains(t, out.String(), "Contact:              none\n")
+
+	out.Reset()
+	test.AssertNotError(t, PrintSerialInfo(&out, info, "json"), "PrintSerialInfo failed for json")
+	test.AssertContains(t, out.String(), `"registrationID":7,"contact":null,"registrationStatus":"valid"`)
+
+	test.AssertError(t, PrintSerialInfo(&out, info, "xml"), "PrintSerialInfo accepted an unknown format")
+}
+
+func TestRunSummary(t *testing.T) {
+	base := RunSummary{Command: "batch-revoke", Arguments: []string{"serials.txt", "1"}, Operator: "alice", RunID: "incident-1"}
+	tally := TallySummary{
+		RunCounts: RunCounts{Revoked: 3, Skipped: 2, Failed: 1},
+		SkippedBy: map[string]int{"already revoked": 2},
+		FailedBy:  map[string]int{"backend error": 1},
+	}
+	s := NewRunSummary(base, tally, 1500*time.Millisecond, 4, "Batch revocation failed")
+	test.AssertEquals(t, s.Total, 6)
+	test.AssertEquals(t, s.Errored, 1)
+	test.AssertEquals(t, s.DurationSeconds, 1.5)
+
+	f, err := ioutil.TempFile("", "summary")
+	test.AssertNotError(t, err, "failed to open temp file")
+	_ = f.Close()
+	defer os.Remove(f.Name())
+	test.AssertNotError(t, WriteRunSummary(f.Name(), s), "WriteRunSummary failed")
+	written, err := ioutil.ReadFile(f.Name())
+	test.AssertNotError(t, err, "failed to read summary")
+	test.AssertEquals(t, string(written), `{"command":"batch-revoke","arguments":["serials.txt","1"],"operator":"alice","runID":"incident-1",`+
+		`"total":6,"revoked":3,"dryRun":0,"skipped":2,"skippedBy":{"already revoked":2},"errored":1,"erroredBy":{"backend error":1},`+
+		`"durationSeconds":1.5,"exitCode":4,"exitReason":"Batch revocation failed"}`+"\n")
+
+	// A command which doesn't revoke anything still has every field.
+	s = NewRunSummary(RunSummary{Command: "list-reasons"}, (*RunTally)(nil).Summary(), 0, 0, "completed")
+	encoded, err := json.Marshal(s)
+	test.AssertNotError(t, err, "failed to marshal summary")
+	test.AssertContains(t, string(encoded), `"arguments":[]`)
+	test.AssertContains(t, string(encoded), `"skippedBy":{},"errored":0,"erroredBy":{}`)
+	test.AssertContains(t, string(encoded), `"exitReason":"completed"`)
+	test.AssertNotContains(t, string(encoded), `"impact"`)
+
+	// A dry run includes the estimate of its impact.
+	tally = TallySummary{DryRun: 2, Impact: RevocationImpact{Unexpired: 1, CRLBytes: 40}}
+	s = NewRunSummary(base, tally, 0, 0, "completed")
+	encoded, err = json.Marshal(s)
+	test.AssertNotError(t, err, "failed to marshal summary")
+	test.AssertContains(t, string(encoded), `"impact":{"unexpired":1,"expiringSoon":0,"lastExpiry":"0001-01-01T00:00:00Z","crlBytes":40}`)
+}
+
+func TestPrintSerialPresence(t *testing.T) {
+	var out bytes.Buffer
+	missing, malformed := PrintSerialPresence(&out, []SerialPresence{
+		{Serial: "0x01", Normalized: "000000000000000000000000000000000001", Kind: "certificate"},
+		{Serial: "02", Normalized: "000000000000000000000000000000000002"},
+		{Serial: "zz", Err: errors.New("invalid serial")},
+	})
+	test.AssertEquals(t, missing, 1)
+	test.AssertEquals(t, malformed, 1)
+	test.AssertEquals(t, out.String(), "found     000000000000000000000000000000000001 (certificate)\n"+
+		"missing   000000000000000000000000000000000002\n"+
+		"malformed zz: invalid serial\n"+
+		"3 serials: 1 found, 1 missing, 1 malformed\n")
+}
+
+func TestPrintRuns(t *testing.T) {
+	completed := time.Date(2020, 6, 2, 13, 0, 0, 0, time.UTC)
+	runs := []RunRecord{
+		{
+			RunID:     "incident-1",
+			Command:   "batch-revoke",
+			Operator:  "alice",
+			Args:      "serials.txt 1",
+			Started:   time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC),
+			Completed: &completed,
+			Revoked:   10,
+			Skipped:   2,
+		},
+		{
+			RunID:    "incident-2",
+			Command:  "reg-revoke",
+			Operator: "bob",
+			Args:     "7 1",
+			Started:  time.Date(2020, 6, 3, 12, 0, 0, 0, time.UTC),
+		},
+	}
+	var out bytes.Buffer
+	test.AssertNotError(t, PrintRuns(&out, runs, "text"), "PrintRuns failed")
+	lines := strings.Split(out.String(), "\n")
+	test.AssertEquals(t, len(lines), 4)
+	test.AssertEquals(t, lines[1], "incident-1  alice     batch-revoke  serials.txt 1  2020-06-02T12:00:00Z  2020-06-02T13:00:00Z  10       2        0")
+	test.AssertEquals(t, lines[2], "incident-2  bob       reg-revoke    7 1            2020-06-03T12:00:00Z  incomplete            -        -        -")
+
+	out.Reset()
+	test.AssertNotError(t, PrintRuns(&out, runs, "json"), "PrintRuns failed for json")
+	test.AssertContains(t, out.String(), `"completed":"2020-06-02T13:00:00Z","revoked":10,"skipped":2,"failed":0`)
+	test.AssertContains(t, out.String(), `"completed":null`)
+
+	out.Reset()
+	test.AssertNotError(t, PrintRuns(&out, nil, "json"), "PrintRuns failed for no runs")
+	test.AssertEquals(t, out.String(), "[]\n")
+
+	test.AssertError(t, PrintRuns(&out, runs, "xml"), "PrintRuns accepted an unknown format")
+}
+
+func TestPromptReason(t *testing.T) {
+	var out bytes.Buffer
+	reason, err := PromptReason(strings.NewReader("6\nnotAReason\n\nsuperseded\n"), &out)
+	test.AssertNotError(t, err, "PromptReason failed")
+	test.AssertEquals(t, reason, revocation.Reason(4))
+	test.AssertContains(t, out.String(), revocation.ReasonDescription[1])
+	// certificateHold is only mentioned by the error for choosing it.
+	test.AssertNotContains(t, out.String(), "\n6 ")
+	test.AssertEquals(t, strings.Count(out.String(), "Invalid reason: "), 2)
+	test.AssertEquals(t, strings.Count(out.String(), "Reason code or name: "), 4)
+
+	out.Reset()
+	reason, err = PromptReason(strings.NewReader("1"), &out)
+	test.AssertNotError(t, err, "PromptReason failed without a trailing newline")
+	test.AssertEquals(t, reason, revocation.Reason(1))
+
+	_, err = PromptReason(strings.NewReader("6\n"), &out)
+	test.AssertError(t, err, "PromptReason accepted EOF without a valid reason")
+}
+
+func TestRegistrationResultLog(t *testing.T) {
+	log := blog.NewMock()
+	notFound := berrors.NotFoundError("registration 1 not found")
+	test.AssertEquals(t, RegistrationResult{RegID: 1, Err: notFound}.Log(log), notFound)
+	test.AssertEquals(t, len(log.GetAllMatching("Registration 1: registration 1 not found")), 1)
+
+	failure := berrors.InternalServerError("oops")
+	rr := RegistrationResult{RegID: 2, Result: BatchResult{
+		Revoked:  3,
+		Skipped:  []SkippedSerial{{Serial: "a2", Reason: "expired"}, {Serial: "a3", Reason: "already revoked"}},
+		Failures: []SerialError{{Serial: "a1", Err: failure}},
+	}}
+	test.AssertEquals(t, rr.Log(log), failure)
+	test.AssertEquals(t, len(log.GetAllMatching("Registration 2: 3 revoked, 2 skipped, 1 failed")), 1)
+
+	test.AssertNotError(t, RegistrationResult{RegID: 3}.Log(log), "Log returned an error for a successful registration")
+
+	rr = RegistrationResult{RegID: 4, Result: BatchResult{Revoked: 5}, Err: ErrInterrupted}
+	test.AssertEquals(t, rr.Log(log), ErrInterrupted)
+	test.AssertEquals(t, len(log.GetAllMatching("Registration 4: interrupted after 5 revoked, 0 failed")), 1)
+
+	rr = RegistrationResult{RegID: 5, Result: BatchResult{Revoked: 1}, Deactivated: true}
+	test.AssertNotError(t, rr.Log(log), "Log returned an error for a deactivated registration")
+	test.AssertEquals(t, len(log.GetAllMatching("Registration 5: 1 revoked, 0 skipped, 0 failed, deactivated")), 1)
+
+	rr = RegistrationResult{RegID: 6, Result: BatchResult{Revoked: 1}, DeactivateErr: failure}
+	test.AssertEquals(t, rr.Log(log), failure)
+	test.AssertEquals(t, len(log.GetAllMatching("Registration 6: couldn't deactivate: oops")), 1)
+
+	rr = RegistrationResult{RegID: 7, Result: BatchResult{Revoked: 1}, Deactivated: true, Notified: true}
+	test.AssertNotError(t, rr.Log(log), "Log returned an error for a notified registration")
+	test.AssertEquals(t, len(log.GetAllMatching("Registration 7: 1 revoked, 0 skipped, 0 failed, deactivated, notified")), 1)
+}
+
+func TestPrintConfigChecks(t *testing.T) {
+	checks := []ConfigCheck{
+		{Component: "database URL"},
+		{Component: "RA service", Err: errors.New("not configured")},
+	}
+	var out bytes.Buffer
+	test.AssertEquals(t, PrintConfigChecks(&out, checks, false), 1)
+	test.AssertEquals(t, out.String(), "[ OK ] database URL\n[FAIL] RA service: not configured\n")
+
+	out.Reset()
+	test.AssertEquals(t, PrintConfigChecks(&out, checks[:1], true), 0)
+	test.AssertEquals(t, out.String(), okColor+"[ OK ] database URL"+resetColor+"\n")
+}
+
+func TestCertLister(t *testing.T) {
+	info := CertificateInfo{
+		Serial:     "0000000000000000000000000000000000a1",
+		CommonName: "example.com",
+		DNSNames:   []string{"example.com", "www.example.com"},
+		Issued:     time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC),
+		Expires:    time.Date(2020, 7, 30, 0, 0, 0, 0, time.UTC),
+		Status:     core.OCSPStatusGood,
+	}
+
+	var out bytes.Buffer
+	list, err := NewCertLister(&out, "text")
+	test.AssertNotError(t, err, "NewCertLister failed")
+	test.AssertNotError(t, list.Write(info), "Write failed")
+	test.AssertNotError(t, list.Flush(), "Flush failed")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	test.AssertEquals(t, len(lines), 2)
+	test.Assert(t, strings.HasPrefix(lines[0], "SERIAL"), "missing header")
+	test.AssertEquals(t, strings.Join(strings.Fields(lines[1]), " "),
+		"0000000000000000000000000000000000a1 good 2020-05-01T00:00:00Z 2020-07-30T00:00:00Z example.com example.com,www.example.com")
+
+	out.Reset()
+	list, err = NewCertLister(&out, "json")
+	test.AssertNotError(t, err, "NewCertLister failed")
+	test.AssertNotError(t, list.Write(info), "Write failed")
+	test.AssertNotError(t, list.Write(info), "Write failed")
+	test.AssertNotError(t, list.Flush(), "Flush failed")
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	test.AssertEquals(t, len(lines), 2)
+	var decoded CertificateInfo
+	test.AssertNotError(t, json.Unmarshal([]byte(lines[0]), &decoded), "output wasn't valid JSON")
+	test.AssertDeepEquals(t, decoded, info)
+
+	_, err = NewCertLister(&out, "yaml")
+	test.AssertError(t, err, "NewCertLister didn't fail on unknown format")
+}
+
+func TestStopperHandleSignals(t *testing.T) {
+	defer signal.Reset(syscall.SIGINT, syscall.SIGTERM)
+	aborted := make(chan struct{})
+	s := NewStopper()
+	s.HandleSignals(blog.NewMock(), func() { close(aborted) })
+	stop := s.C()
+
+	test.AssertNotError(t, syscall.Kill(os.Getpid(), syscall.SIGINT), "failed to send SIGINT")
+	select {
+	case <-stop:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop wasn't closed after the first signal")
+	}
+	select {
+	case <-aborted:
+		t.Fatal("aborted after the first signal")
+	default:
+	}
+
+	test.AssertNotError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM), "failed to send SIGTERM")
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("abort wasn't called after the second signal")
+	}
+}
+
+func TestPrintCertificate(t *testing.T) {
+	serial, cert := test.ThrowAwayCertWithSerial(t, 2, big.NewInt(1))
+	var out bytes.Buffer
+	test.AssertNotError(t, PrintCertificate(&out, cert, "certificate"), "PrintCertificate failed")
+	test.AssertContains(t, out.String(), fmt.Sprintf("Serial:               %s (certificate)\n", serial))
+	test.AssertContains(t, out.String(), fmt.Sprintf("Names:                %s\n", strings.Join(cert.DNSNames, ", ")))
+	test.AssertContains(t, out.String(), fmt.Sprintf("SHA-256 fingerprint:  %x\n", sha256.Sum256(cert.Raw)))
+	test.AssertContains(t, out.String(), "Not before:           0001-01-01T00:00:00Z\n")
+}
+
+func TestStopperWatchRuntime(t *testing.T) {
+	s := NewStopper()
+	test.Assert(t, !s.RuntimeExhausted(), "runtime exhausted before it was watched")
+	timer := s.WatchRuntime(blog.NewMock(), time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-s.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop wasn't closed after the runtime passed")
+	}
+	// Stopping again, e.g. on a signal, is harmless.
+	s.Stop()
+	test.Assert(t, s.RuntimeExhausted(), "runtime not exhausted after it passed")
+
+	s = NewStopper()
+	s.Stop()
+	test.Assert(t, !s.RuntimeExhausted(), "runtime exhausted by stopping")
+	test.Assert(t, !(*Stopper)(nil).RuntimeExhausted(), "nil Stopper's runtime exhausted")
+}
+
+func TestFinisher(t *testing.T) {
+	// The zero value does nothing.
+	var f Finisher
+	f.Finish(0, "completed")
+
+	dir, err := ioutil.TempDir("", "finisher")
+	test.AssertNotError(t, err, "failed to create temp dir")
+	defer os.RemoveAll(dir)
+	path := dir + "/summary.json"
+
+	clk := clock.NewFake()
+	tally := &RunTally{}
+	tally.record(reportRevoked, nil)
+	f.SummarizeTo(path, RunSummary{Command: "batch-revoke", Operator: "alice"}, tally, clk)
+	clk.Add(2 * time.Second)
+	f.Finish(4, "Batch revocation failed")
+
+	written, err := ioutil.ReadFile(path)
+	test.AssertNotError(t, err, "failed to read summary")
+	var s RunSummary
+	test.AssertNotError(t, json.Unmarshal(written, &s), "summary wasn't valid JSON")
+	test.AssertEquals(t, s.Operator, "alice")
+	test.AssertEquals(t, s.Revoked, 1)
+	test.AssertEquals(t, s.DurationSeconds, 2.0)
+	test.AssertEquals(t, s.ExitCode, 4)
+	test.AssertEquals(t, s.ExitReason, "Batch revocation failed")
+}
+
+func TestRegistrationsPrompt(t *testing.T) {
+	test.AssertEquals(t, registrationsPrompt(3, IssuedWindow{}, revocation.Reason(1), Options{}),
+		"Revoke all 3 certificates with reason 'keyCompromise'?")
+	window := IssuedWindow{After: time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)}
+	test.AssertEquals(t, registrationsPrompt(3, window, revocation.Reason(1), Options{DNSName: "example.com"}),
+		"Revoke those certificates issued after 2020-05-01T00:00:00Z and covering example.com with reason 'keyCompromise'?")
+	test.AssertEquals(t, registrationsPrompt(3, IssuedWindow{}, revocation.Reason(4), Options{DNSName: "example.com", SuffixMatch: true}),
+		"Revoke those certificates covering example.com or a subdomain of it with reason 'superseded'?")
+}
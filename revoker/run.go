@@ -0,0 +1,320 @@
+package revoker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+
+	"github.com/letsencrypt/boulder/db"
+	blog "github.com/letsencrypt/boulder/log"
+)
+
+// runStore records the runs of the admin-revoker given a run ID. It is
+// implemented over the database by dbRunStore, and is an interface so that
+// the guard against replaying a run can be tested without a database.
+type runStore interface {
+	// completed returns whether the run with the ID has been recorded, and
+	// when it completed, which is nil if it hasn't.
+	completed(runID string) (bool, *time.Time, error)
+	// start records that the run with the ID started, replacing any previous
+	// record of it.
+	start(runID, command, operator, args string, started time.Time) error
+	// complete records that the run with the ID completed with the counts.
+	complete(runID string, completed time.Time, counts RunCounts) error
+	// list returns the runs which started at or after since, or every run if
+	// since is zero, oldest first.
+	list(since time.Time) ([]RunRecord, error)
+}
+
+// dbRunStore is a runStore over the admin_revocation_runs table.
+type dbRunStore struct {
+	dbMap db.Executor
+}
+
+func (s dbRunStore) completed(runID string) (bool, *time.Time, error) {
+	var completed []struct {
+		Completed *time.Time `db:"completed"`
+	}
+	_, err := s.dbMap.Select(&completed, "SELECT completed FROM admin_revocation_runs WHERE runID = ?", runID)
+	if err != nil || len(completed) == 0 {
+		return false, nil, err
+	}
+	return true, completed[0].Completed, nil
+}
+
+func (s dbRunStore) start(runID, command, operator, args string, started time.Time) error {
+	_, err := s.dbMap.Exec(
+		`INSERT INTO admin_revocation_runs (runID, command, operator, args, started, completed, revoked, skipped, failed)
+		VALUES (?, ?, ?, ?, ?, NULL, 0, 0, 0)
+		ON DUPLICATE KEY UPDATE command = VALUES(command), operator = VALUES(operator),
+		args = VALUES(args), started = VALUES(started), completed = NULL,
+		revoked = 0, skipped = 0, failed = 0`,
+		runID, command, operator, args, started,
+	)
+	return err
+}
+
+func (s dbRunStore) complete(runID string, completed time.Time, counts RunCounts) error {
+	_, err := s.dbMap.Exec(
+		"UPDATE admin_revocation_runs SET completed = ?, revoked = ?, skipped = ?, failed = ? WHERE runID = ?",
+		completed, counts.Revoked, counts.Skipped, counts.Failed, runID,
+	)
+	return err
+}
+
+func (s dbRunStore) list(since time.Time) ([]RunRecord, error) {
+	query := "SELECT runID, command, operator, args, started, completed, revoked, skipped, failed FROM admin_revocation_runs"
+	var args []interface{}
+	if !since.IsZero() {
+		query += " WHERE started >= ?"
+		args = append(args, since)
+	}
+	var runs []RunRecord
+	_, err := s.dbMap.Select(&runs, query+" ORDER BY started, id", args...)
+	return runs, err
+}
+
+// maxRunArgs bounds the length of the summary of a run's arguments, which is
+// truncated to fit the args column.
+const maxRunArgs = 1024
+
+// summarizeArgs joins the positional arguments of a run, truncating them to
+// maxRunArgs.
+func summarizeArgs(args []string) string {
+	summary := strings.Join(args, " ")
+	if len(summary) > maxRunArgs {
+		summary = summary[:maxRunArgs-3] + "..."
+	}
+	return summary
+}
+
+// RunCounts are the numbers of certificates a run revoked, skipped, and failed
+// to revoke.
+type RunCounts struct {
+	Revoked int `json:"revoked"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// RunTally counts the outcome of every attempt to revoke a certificate during
+// a run, so that the counts can be recorded once it completes. It is safe for
+// concurrent use. A nil *RunTally counts nothing.
+type RunTally struct {
+	mu        sync.Mutex
+	counts    RunCounts
+	dryRun    int
+	skippedBy map[string]int
+	failedBy  map[string]int
+	impact    RevocationImpact
+}
+
+// record counts a result recorded by Revoker.report, along with the reason a
+// skipped certificate was skipped or the kind of failure of one which failed,
+// given by err. In the latter case err may be nil if the failure is described
+// by result alone.
+func (t *RunTally) record(result string, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case strings.HasPrefix(result, reportError):
+		t.counts.Failed++
+		category := failureOther
+		if err != nil {
+			category = categorizeFailure(err)
+		}
+		if t.failedBy == nil {
+			t.failedBy = make(map[string]int)
+		}
+		t.failedBy[category]++
+	case strings.HasPrefix(result, reportSkipped):
+		t.counts.Skipped++
+		reason := result
+		if skip, ok := err.(skipError); ok {
+			reason = skip.reason
+		}
+		if t.skippedBy == nil {
+			t.skippedBy = make(map[string]int)
+		}
+		t.skippedBy[reason]++
+	case strings.HasPrefix(result, reportRevoked):
+		t.counts.Revoked++
+	case result == reportDryRun:
+		t.dryRun++
+	}
+}
+
+// recordImpact counts a certificate expiring at notAfter which a dry run would
+// have revoked, in the estimate of the impact of revoking them for real.
+// revoked is set if it is already revoked, and being revoked again with --force.
+func (t *RunTally) recordImpact(notAfter time.Time, revoked bool, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.impact.add(notAfter, revoked, now)
+}
+
+// Counts returns the counts so far.
+func (t *RunTally) Counts() RunCounts {
+	if t == nil {
+		return RunCounts{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts
+}
+
+// TallySummary breaks down the outcomes counted by a RunTally.
+type TallySummary struct {
+	RunCounts
+	// DryRun is the number of certificates a dry run would have revoked.
+	DryRun int
+	// Impact estimates the impact of revoking those certificates for real.
+	Impact RevocationImpact
+	// SkippedBy counts the certificates skipped for each reason, and FailedBy
+	// those which failed with each kind of failure, as summarized by
+	// BatchResult.Log.
+	SkippedBy map[string]int
+	FailedBy  map[string]int
+}
+
+// Total returns the number of certificates whose outcome was counted.
+func (ts TallySummary) Total() int {
+	return ts.Revoked + ts.Skipped + ts.Failed + ts.DryRun
+}
+
+// Summary returns the counts so far, broken down by reason and kind of
+// failure. The maps are never nil.
+func (t *RunTally) Summary() TallySummary {
+	summary := TallySummary{SkippedBy: map[string]int{}, FailedBy: map[string]int{}}
+	if t == nil {
+		return summary
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	summary.RunCounts = t.counts
+	summary.DryRun = t.dryRun
+	summary.Impact = t.impact
+	for reason, n := range t.skippedBy {
+		summary.SkippedBy[reason] = n
+	}
+	for category, n := range t.failedBy {
+		summary.FailedBy[category] = n
+	}
+	return summary
+}
+
+// RunRecord describes a run recorded in the admin_revocation_runs table.
+type RunRecord struct {
+	RunID    string `db:"runID" json:"runID"`
+	Command  string `db:"command" json:"command"`
+	Operator string `db:"operator" json:"operator"`
+	// Args summarizes the positional arguments the command was run with.
+	Args    string    `db:"args" json:"args"`
+	Started time.Time `db:"started" json:"started"`
+	// Completed is nil if the run failed, was interrupted, or is still in
+	// progress, in which case the counts are all zero.
+	Completed *time.Time `db:"completed" json:"completed"`
+	Revoked   int        `db:"revoked" json:"revoked"`
+	Skipped   int        `db:"skipped" json:"skipped"`
+	Failed    int        `db:"failed" json:"failed"`
+}
+
+// RunCompletedError is returned by RunLog.Start when the run ID has already
+// completed, so that the operator can tell a replay apart from a failure.
+type RunCompletedError struct {
+	RunID     string
+	Completed time.Time
+}
+
+func (e RunCompletedError) Error() string {
+	return fmt.Sprintf("run %q already completed at %s", e.RunID, e.Completed.Format(time.RFC3339))
+}
+
+// RunLog guards against running the same revocation twice by recording each
+// run given a run ID at its start and marking it complete once it succeeds. A
+// run which failed or was interrupted isn't complete, so it can be re-run to
+// finish it.
+type RunLog struct {
+	store runStore
+	log   blog.Logger
+	clk   clock.Clock
+}
+
+// OpenRunLog connects to the database described by the config and returns a
+// RunLog recording runs in its admin_revocation_runs table. If writable is
+// true, a DatabaseError is returned if the database is read-only, since the
+// run couldn't be recorded.
+func OpenRunLog(c Config, logger blog.Logger, clk clock.Clock, writable bool) (*RunLog, error) {
+	dbMap, err := connectDB(c, logger)
+	if err != nil {
+		return nil, err
+	}
+	if writable {
+		err = checkWritable(dbMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &RunLog{store: dbRunStore{dbMap}, log: logger, clk: clk}, nil
+}
+
+// Start records the start of the run of command with the ID and positional
+// args. It returns a RunCompletedError if the run has already completed,
+// unless force is true, in which case the run is started again. With
+// opts.DryRun, the run is checked but nothing is recorded.
+func (rl *RunLog) Start(runID, command string, args []string, opts Options, force bool) error {
+	operator, err := opts.operator()
+	if err != nil {
+		return err
+	}
+	found, completed, err := rl.store.completed(runID)
+	if err != nil {
+		return DatabaseError{err}
+	}
+	if completed != nil {
+		if !force {
+			return RunCompletedError{RunID: runID, Completed: *completed}
+		}
+		rl.log.Warningf("Run %q already completed at %s, running it again", runID, completed.Format(time.RFC3339))
+	} else if found {
+		rl.log.Infof("Run %q was started before but didn't complete, resuming it", runID)
+	}
+	if opts.DryRun {
+		return nil
+	}
+	err = rl.store.start(runID, command, operator, summarizeArgs(args), rl.clk.Now())
+	if err != nil {
+		return DatabaseError{err}
+	}
+	rl.log.Infof("Started run %q of %s as %s", runID, command, operator)
+	return nil
+}
+
+// Complete marks the run with the ID as complete, recording the counts of
+// its outcomes, so that it won't be run again without force.
+func (rl *RunLog) Complete(runID string, counts RunCounts) error {
+	err := rl.store.complete(runID, rl.clk.Now(), counts)
+	if err != nil {
+		return DatabaseError{err}
+	}
+	rl.log.Infof("Completed run %q: %d revoked, %d skipped, %d failed", runID, counts.Revoked, counts.Skipped, counts.Failed)
+	return nil
+}
+
+// ListRuns returns the runs recorded as having started at or after since,
+// oldest first. A zero since returns every run.
+func (rl *RunLog) ListRuns(since time.Time) ([]RunRecord, error) {
+	runs, err := rl.store.list(since)
+	if err != nil {
+		return nil, DatabaseError{err}
+	}
+	return runs, nil
+}
@@ -0,0 +1,78 @@
+package revoker
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// RunSummary is the JSON object written by the admin-revoker's --summary-json
+// once a command exits, so that automation wrapping the admin-revoker can
+// learn its outcome without parsing the log. Every command writes the same
+// fields; those which don't revoke anything have zero counts.
+type RunSummary struct {
+	Command string `json:"command"`
+	// Arguments are the command's positional arguments.
+	Arguments []string `json:"arguments"`
+	Operator  string   `json:"operator"`
+	RunID     string   `json:"runID"`
+	// Total is the number of certificates processed: revoked, skipped,
+	// errored, or which a dry run would have revoked.
+	Total     int            `json:"total"`
+	Revoked   int            `json:"revoked"`
+	DryRun    int            `json:"dryRun"`
+	Skipped   int            `json:"skipped"`
+	SkippedBy map[string]int `json:"skippedBy"`
+	Errored   int            `json:"errored"`
+	ErroredBy map[string]int `json:"erroredBy"`
+	// Impact estimates the impact on the CRLs and OCSP of revoking the
+	// certificates a dry run would have revoked. It is omitted unless the
+	// command was a dry run.
+	Impact *RevocationImpact `json:"impact,omitempty"`
+	// DurationSeconds is the time from parsing the flags to exiting.
+	DurationSeconds float64 `json:"durationSeconds"`
+	ExitCode        int     `json:"exitCode"`
+	// ExitReason is "completed", or the message the command failed with.
+	ExitReason string `json:"exitReason"`
+}
+
+// NewRunSummary returns the summary of the command described by base exiting
+// with code for the reason, having counted the outcome of each certificate in
+// tally.
+func NewRunSummary(base RunSummary, tally TallySummary, duration time.Duration, code int, reason string) RunSummary {
+	s := base
+	if s.Arguments == nil {
+		s.Arguments = []string{}
+	}
+	s.Total = tally.Total()
+	s.Revoked = tally.Revoked
+	s.DryRun = tally.DryRun
+	s.Skipped = tally.Skipped
+	s.SkippedBy = tally.SkippedBy
+	s.Errored = tally.Failed
+	s.ErroredBy = tally.FailedBy
+	if tally.DryRun > 0 {
+		impact := tally.Impact
+		s.Impact = &impact
+	}
+	s.DurationSeconds = duration.Seconds()
+	s.ExitCode = code
+	s.ExitReason = reason
+	return s
+}
+
+// WriteRunSummary writes the summary as a single line of JSON to path, or to
+// stdout if path is "-".
+func WriteRunSummary(path string, s RunSummary) error {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	encoded = append(encoded, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(encoded)
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
@@ -0,0 +1,124 @@
+package revoker
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/letsencrypt/boulder/core"
+	"github.com/letsencrypt/boulder/revocation"
+)
+
+// verifyMaxDelay bounds the exponential backoff between OCSP queries.
+const verifyMaxDelay = 10 * time.Second
+
+// OCSPVerifier confirms that revocations have propagated to the OCSP
+// responder, by querying it until it reports the certificate as revoked with
+// the expected reason.
+type OCSPVerifier struct {
+	issuer    *x509.Certificate
+	url       string
+	timeout   time.Duration
+	baseDelay time.Duration
+	client    *http.Client
+	clk       clock.Clock
+}
+
+// NewOCSPVerifier returns an OCSPVerifier for certificates issued by issuer.
+// If url is empty, the responder given in each certificate's AIA extension is
+// queried. Each certificate is queried for up to timeout, starting baseDelay
+// apart and backing off exponentially.
+func NewOCSPVerifier(issuer *x509.Certificate, url string, timeout, baseDelay time.Duration, clk clock.Clock) *OCSPVerifier {
+	return &OCSPVerifier{
+		issuer:    issuer,
+		url:       url,
+		timeout:   timeout,
+		baseDelay: baseDelay,
+		client:    &http.Client{Timeout: 5 * time.Second},
+		clk:       clk,
+	}
+}
+
+// Verify queries the OCSP responder for cert until it reports the certificate
+// as revoked with reasonCode, returning the last error if it doesn't do so
+// before the OCSPVerifier's timeout.
+func (v *OCSPVerifier) Verify(ctx context.Context, cert *x509.Certificate, reasonCode revocation.Reason) error {
+	deadline := v.clk.Now().Add(v.timeout)
+	for attempt := 1; ; attempt++ {
+		err := v.check(ctx, cert, reasonCode)
+		if err == nil {
+			return nil
+		}
+		delay := core.RetryBackoff(attempt, v.baseDelay, verifyMaxDelay, 2)
+		if v.clk.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("not verified after %d attempts: %s", attempt, err)
+		}
+		select {
+		case <-v.clk.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// query makes a single OCSP query for cert, returning the response if it is
+// valid.
+func (v *OCSPVerifier) query(ctx context.Context, cert *x509.Certificate) (*ocsp.Response, error) {
+	url := v.url
+	if url == "" {
+		if len(cert.OCSPServer) == 0 {
+			return nil, fmt.Errorf("no OCSP responder configured or in certificate")
+		}
+		url = cert.OCSPServer[0]
+	}
+	ocspReq, err := ocsp.CreateRequest(cert, v.issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest("POST", url, bytes.NewReader(ocspReq))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Add("Content-Type", "application/ocsp-request")
+	httpResp, err := v.client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder returned HTTP status %d", httpResp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ocsp.ParseResponseForCert(body, cert, v.issuer)
+	if err != nil {
+		return nil, fmt.Errorf("parsing OCSP response: %s", err)
+	}
+	return resp, nil
+}
+
+// check makes a single OCSP query for cert, returning an error unless the
+// response is valid and shows the certificate revoked with reasonCode.
+func (v *OCSPVerifier) check(ctx context.Context, cert *x509.Certificate, reasonCode revocation.Reason) error {
+	resp, err := v.query(ctx, cert)
+	if err != nil {
+		return err
+	}
+	if resp.Status != ocsp.Revoked {
+		return fmt.Errorf("OCSP status is %d, not revoked", resp.Status)
+	}
+	if revocation.Reason(resp.RevocationReason) != reasonCode {
+		return fmt.Errorf("OCSP revocation reason is '%s', not '%s'",
+			revocation.Reason(resp.RevocationReason).String(), reasonCode.String())
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE `admin_revocation_runs` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `runID` varchar(255) NOT NULL UNIQUE,
+    `command` varchar(255) NOT NULL,
+    `operator` varchar(255) NOT NULL,
+    `started` datetime NOT NULL,
+    `completed` datetime DEFAULT NULL,
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE `admin_revocation_runs`;
@@ -0,0 +1,18 @@
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE `admin_revocation_runs`
+    ADD COLUMN `args` varchar(1024) NOT NULL DEFAULT '',
+    ADD COLUMN `revoked` int(11) NOT NULL DEFAULT 0,
+    ADD COLUMN `skipped` int(11) NOT NULL DEFAULT 0,
+    ADD COLUMN `failed` int(11) NOT NULL DEFAULT 0;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE `admin_revocation_runs`
+    DROP COLUMN `args`,
+    DROP COLUMN `revoked`,
+    DROP COLUMN `skipped`,
+    DROP COLUMN `failed`;
@@ -3,6 +3,8 @@ package sa
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"gopkg.in/go-gorp/gorp.v2"
@@ -12,11 +14,59 @@ import (
 	blog "github.com/letsencrypt/boulder/log"
 )
 
+// DbSettings controls the connection pool of a DbMap.
+type DbSettings struct {
+	// MaxOpenConns is the maximum number of open connections to the database.
+	// If zero, the number is unlimited.
+	MaxOpenConns int
+	// MaxIdleConns is the maximum number of idle connections kept open for
+	// reuse. If zero, database/sql's default of 2 is used, and if negative no
+	// idle connections are kept.
+	MaxIdleConns int
+	// ConnMaxLifetime is the maximum time a connection may be reused for
+	// before it is closed. If zero, connections are reused indefinitely.
+	ConnMaxLifetime time.Duration
+}
+
+// defaultMaxIdleConns is database/sql's default maximum number of idle
+// connections, used when DbSettings.MaxIdleConns is zero.
+const defaultMaxIdleConns = 2
+
+// String describes the settings, with the defaults for any zero values
+// resolved, for logging.
+func (s DbSettings) String() string {
+	maxOpen := "unlimited"
+	if s.MaxOpenConns > 0 {
+		maxOpen = strconv.Itoa(s.MaxOpenConns)
+	}
+	maxIdle := s.MaxIdleConns
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdleConns
+	} else if maxIdle < 0 {
+		maxIdle = 0
+	}
+	if s.MaxOpenConns > 0 && maxIdle > s.MaxOpenConns {
+		// database/sql never keeps more idle connections than it may open.
+		maxIdle = s.MaxOpenConns
+	}
+	lifetime := "unlimited"
+	if s.ConnMaxLifetime > 0 {
+		lifetime = s.ConnMaxLifetime.String()
+	}
+	return fmt.Sprintf("max open conns %s, max idle conns %d, conn max lifetime %s", maxOpen, maxIdle, lifetime)
+}
+
 // NewDbMap creates a wrapped root gorp mapping object. Create one of these for
 // each database schema you wish to map. Each DbMap contains a list of mapped
 // tables. It automatically maps the tables for the primary parts of Boulder
 // around the Storage Authority.
 func NewDbMap(dbConnect string, maxOpenConns int) (*boulderDB.WrappedMap, error) {
+	return NewDbMapWithSettings(dbConnect, DbSettings{MaxOpenConns: maxOpenConns})
+}
+
+// NewDbMapWithSettings functions similarly to NewDbMap, but also configures
+// how idle connections are kept for reuse, as described by settings.
+func NewDbMapWithSettings(dbConnect string, settings DbSettings) (*boulderDB.WrappedMap, error) {
 	var err error
 	var config *mysql.Config
 
@@ -25,7 +75,7 @@ func NewDbMap(dbConnect string, maxOpenConns int) (*boulderDB.WrappedMap, error)
 		return nil, err
 	}
 
-	return NewDbMapFromConfig(config, maxOpenConns)
+	return newDbMapFromConfig(config, settings)
 }
 
 // sqlOpen is used in the tests to check that the arguments are properly
@@ -39,9 +89,23 @@ var setMaxOpenConns = func(db *sql.DB, maxOpenConns int) {
 	db.SetMaxOpenConns(maxOpenConns)
 }
 
+// setConnReuse is likewise replaced for testing.
+var setConnReuse = func(db *sql.DB, maxIdleConns int, connMaxLifetime time.Duration) {
+	if maxIdleConns != 0 {
+		db.SetMaxIdleConns(maxIdleConns)
+	}
+	db.SetConnMaxLifetime(connMaxLifetime)
+}
+
 // NewDbMapFromConfig functions similarly to NewDbMap, but it takes the
 // decomposed form of the connection string, a *mysql.Config.
 func NewDbMapFromConfig(config *mysql.Config, maxOpenConns int) (*boulderDB.WrappedMap, error) {
+	return newDbMapFromConfig(config, DbSettings{MaxOpenConns: maxOpenConns})
+}
+
+// newDbMapFromConfig is NewDbMapFromConfig, configuring the connection pool
+// with all of settings.
+func newDbMapFromConfig(config *mysql.Config, settings DbSettings) (*boulderDB.WrappedMap, error) {
 	adjustMySQLConfig(config)
 
 	db, err := sqlOpen("mysql", config.FormatDSN())
@@ -51,7 +115,8 @@ func NewDbMapFromConfig(config *mysql.Config, maxOpenConns int) (*boulderDB.Wrap
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
-	setMaxOpenConns(db, maxOpenConns)
+	setMaxOpenConns(db, settings.MaxOpenConns)
+	setConnReuse(db, settings.MaxIdleConns, settings.ConnMaxLifetime)
 
 	dialect := gorp.MySQLDialect{Engine: "InnoDB", Encoding: "UTF8"}
 	dbmap := &gorp.DbMap{Db: db, Dialect: dialect, TypeConverter: BoulderTypeConverter{}}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/letsencrypt/boulder/test"
 	"github.com/letsencrypt/boulder/test/vars"
@@ -37,6 +38,42 @@ func TestMaxOpenConns(t *testing.T) {
 	}
 }
 
+func TestNewDbMapWithSettings(t *testing.T) {
+	oldSetMaxOpenConns := setMaxOpenConns
+	oldSetConnReuse := setConnReuse
+	defer func() {
+		setMaxOpenConns = oldSetMaxOpenConns
+		setConnReuse = oldSetConnReuse
+	}()
+	var got DbSettings
+	setMaxOpenConns = func(db *sql.DB, m int) {
+		got.MaxOpenConns = m
+		oldSetMaxOpenConns(db, m)
+	}
+	setConnReuse = func(db *sql.DB, maxIdle int, lifetime time.Duration) {
+		got.MaxIdleConns = maxIdle
+		got.ConnMaxLifetime = lifetime
+		oldSetConnReuse(db, maxIdle, lifetime)
+	}
+	expected := DbSettings{MaxOpenConns: 100, MaxIdleConns: 10, ConnMaxLifetime: time.Minute}
+	_, err := NewDbMapWithSettings("sa@tcp(boulder-mysql:3306)/boulder_sa_integration", expected)
+	if err != nil {
+		t.Errorf("connecting to DB: %s", err)
+	}
+	if got != expected {
+		t.Errorf("connection settings were not set: expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestDbSettingsString(t *testing.T) {
+	test.AssertEquals(t, DbSettings{}.String(),
+		"max open conns unlimited, max idle conns 2, conn max lifetime unlimited")
+	test.AssertEquals(t, DbSettings{MaxOpenConns: 1, ConnMaxLifetime: time.Hour}.String(),
+		"max open conns 1, max idle conns 1, conn max lifetime 1h0m0s")
+	test.AssertEquals(t, DbSettings{MaxOpenConns: 20, MaxIdleConns: -1}.String(),
+		"max open conns 20, max idle conns 0, conn max lifetime unlimited")
+}
+
 func TestNewDbMap(t *testing.T) {
 	const mysqlConnectURL = "policy:password@tcp(boulder-mysql:3306)/boulder_policy_integration?readTimeout=800ms&writeTimeout=800ms"
 	const expected = "policy:password@tcp(boulder-mysql:3306)/boulder_policy_integration?clientFoundRows=true&parseTime=true&readTimeout=800ms&writeTimeout=800ms&long_query_time=0.6400000000000001&max_statement_time=0.76&sql_mode=STRICT_ALL_TABLES"
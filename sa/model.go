@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	jose "gopkg.in/square/go-jose.v2"
@@ -63,6 +65,15 @@ func selectRegistration(s db.OneSelector, q string, args ...interface{}) (*regMo
 	return &model, err
 }
 
+// SelectRegistrationIDsByKeyHash selects the IDs of all registrations whose
+// jwk_sha256 column matches the provided key hash, which must be the padded
+// standard base64 encoding produced by core.KeyDigestB64.
+func SelectRegistrationIDsByKeyHash(s db.Selector, keyHash string) ([]int64, error) {
+	var ids []int64
+	_, err := s.Select(&ids, "SELECT id FROM registrations WHERE jwk_sha256 = ?", keyHash)
+	return ids, err
+}
+
 const certFields = "registrationID, serial, digest, der, issued, expires"
 
 // SelectCertificate selects all fields of one certificate object
@@ -76,6 +87,110 @@ func SelectCertificate(s db.OneSelector, q string, args ...interface{}) (core.Ce
 	return model, err
 }
 
+// disallowedWhereKeywords match the keywords a where clause given to
+// SelectCertificatesWhere may not contain: LIMIT, which it appends itself, and
+// those which would let the query read anything other than certificates rows.
+var disallowedWhereKeywords = regexp.MustCompile(`(?i)\b(limit|union|select|into)\b`)
+
+// checkWhereClause checks that a where clause given to SelectCertificatesWhere
+// takes every value it compares against from args, by rejecting quotes,
+// comments and statement separators, and that it has a placeholder for each
+// of args.
+func checkWhereClause(where string, args []interface{}) error {
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(where)), "WHERE ") {
+		return fmt.Errorf("where clause %q must begin with WHERE", where)
+	}
+	if strings.ContainsAny(where, "'\"`\\;#") || strings.Contains(where, "--") || strings.Contains(where, "/*") {
+		return fmt.Errorf("where clause %q must not contain quotes, backslashes, semicolons or comments, bind values as arguments instead", where)
+	}
+	if keyword := disallowedWhereKeywords.FindString(where); keyword != "" {
+		return fmt.Errorf("where clause %q must not contain %s", where, strings.ToUpper(keyword))
+	}
+	if n := strings.Count(where, "?"); n != len(args) {
+		return fmt.Errorf("where clause %q has %d placeholders but %d arguments", where, n, len(args))
+	}
+	return nil
+}
+
+// SelectCertificatesWhere selects all fields of at most limit certificates
+// matching the where clause, e.g. "WHERE registrationID = ? ORDER BY serial",
+// with each ? bound to the corresponding argument. Every value must be bound
+// rather than written into the clause: a clause containing quotes, comments,
+// or a LIMIT of its own is rejected, as is a limit less than 1.
+func SelectCertificatesWhere(s db.Selector, where string, limit int, args ...interface{}) ([]core.Certificate, error) {
+	if limit < 1 {
+		return nil, fmt.Errorf("limit must be at least 1, got %d", limit)
+	}
+	err := checkWhereClause(where, args)
+	if err != nil {
+		return nil, err
+	}
+	var certs []core.Certificate
+	_, err = s.Select(
+		&certs,
+		"SELECT "+certFields+" FROM certificates "+where+" LIMIT ?",
+		append(args[:len(args):len(args)], limit)...,
+	)
+	return certs, err
+}
+
+// SelectCertificateByFingerprint selects all fields of the certificate whose
+// digest, as computed by core.Fingerprint256, matches the one provided. The
+// digest column is not indexed, so this requires a full scan of the
+// certificates table and is only suitable for infrequent administrative use.
+func SelectCertificateByFingerprint(s db.OneSelector, digest string) (core.Certificate, error) {
+	return SelectCertificate(s, "WHERE digest = ?", digest)
+}
+
+// SelectCertificatesByPublicKeyHash selects all fields of every certificate
+// whose SubjectPublicKeyInfo has the provided SHA-256 hash, as recorded in the
+// keyHashToSerial table. Only certificates issued while the StoreKeyHashes
+// feature was enabled are found.
+func SelectCertificatesByPublicKeyHash(s db.Selector, keyHash []byte) ([]core.Certificate, error) {
+	var certs []core.Certificate
+	_, err := s.Select(
+		&certs,
+		`SELECT c.registrationID, c.serial, c.digest, c.der, c.issued, c.expires
+		FROM keyHashToSerial AS k
+		JOIN certificates AS c ON c.serial = k.certSerial
+		WHERE k.keyHash = ?`,
+		keyHash,
+	)
+	return certs, err
+}
+
+// likeEscaper escapes the characters which are special in a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SelectCertificatesByName selects the registration ID, serial, issued and
+// expires fields of every certificate including name, as recorded in the
+// issuedNames table. If includeSubdomains is true, certificates including any
+// subdomain of name, or a wildcard under it, are selected too. Otherwise a
+// wildcard is only matched if name is that wildcard, e.g. "*.example.com".
+// Each certificate is selected once, however many of its names match, ordered
+// by serial. The DER isn't selected since a name may have very many
+// certificates.
+func SelectCertificatesByName(s db.Selector, name string, includeSubdomains bool) ([]core.Certificate, error) {
+	reversed := ReverseName(name)
+	match := "reversedName = :reversed"
+	if includeSubdomains {
+		match += " OR reversedName LIKE :pattern"
+	}
+	var certs []core.Certificate
+	_, err := s.Select(
+		&certs,
+		`SELECT registrationID, serial, issued, expires
+		FROM certificates
+		WHERE serial IN (SELECT serial FROM issuedNames WHERE `+match+`)
+		ORDER BY serial`,
+		map[string]interface{}{
+			"reversed": reversed,
+			"pattern":  likeEscaper.Replace(reversed) + ".%",
+		},
+	)
+	return certs, err
+}
+
 const precertFields = "registrationID, serial, der, issued, expires"
 
 // SelectPrecertificate selects all fields of one precertificate object
@@ -117,6 +232,9 @@ func SelectCertificateStatus(s db.OneSelector, q string, args ...interface{}) (c
 	if features.Enabled(features.StoreIssuerInfo) {
 		fields += ", issuerID"
 	}
+	if features.Enabled(features.StoreRevocationComment) {
+		fields += ", revokedComment"
+	}
 	var model certStatusModel
 	err := s.SelectOne(
 		&model,
@@ -161,6 +279,7 @@ type certStatusModel struct {
 	NotAfter              time.Time         `db:"notAfter"`
 	IsExpired             bool              `db:"isExpired"`
 	IssuerID              *int64            `db:"issuerID"`
+	RevokedComment        *string           `db:"revokedComment"`
 }
 
 // challModel is the description of a core.Challenge in the database
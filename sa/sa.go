@@ -1541,23 +1541,66 @@ func (ssa *SQLStorageAuthority) FinalizeAuthorization2(ctx context.Context, req
 // RevokeCertificate stores revocation information about a certificate. It will only store this
 // information if the certificate is not already marked as revoked.
 func (ssa *SQLStorageAuthority) RevokeCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) error {
+	query, args := RevokeCertificateQuery(req)
+	res, err := ssa.dbMap.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		// Distinguish a certificate which was already revoked, which callers may
+		// want to treat as success, from one whose status is missing.
+		status, err := SelectCertificateStatus(ssa.dbMap, "WHERE serial = ?", *req.Serial)
+		if err == nil && status.Status == core.OCSPStatusRevoked {
+			return berrors.AlreadyRevokedError("certificate with serial %s already revoked", *req.Serial)
+		}
+		// InternalServerError because we expected this certificate status to exist and
+		// not be revoked.
+		return berrors.InternalServerError("no certificate with serial %s and status %s", *req.Serial, string(core.OCSPStatusRevoked))
+	}
+	return nil
+}
+
+// RevokeCertificateQuery returns the statement RevokeCertificate executes for
+// req, along with its arguments, so that the admin-revoker can explain it.
+func RevokeCertificateQuery(req *sapb.RevokeCertificateRequest) (string, []interface{}) {
 	revokedDate := time.Unix(0, *req.Date)
-	res, err := ssa.dbMap.Exec(
-		`UPDATE certificateStatus SET
-				status = ?,
-				revokedReason = ?,
-				revokedDate = ?,
-				ocspLastUpdated = ?,
-				ocspResponse = ?
-			WHERE serial = ? AND status != ?`,
+	// The revokedComment column only exists once the relevant migration has
+	// been applied, so it is only set when the feature is enabled.
+	commentField := ""
+	args := []interface{}{
 		string(core.OCSPStatusRevoked),
 		revocation.Reason(*req.Reason),
 		revokedDate,
 		revokedDate,
 		req.Response,
-		*req.Serial,
-		string(core.OCSPStatusRevoked),
-	)
+	}
+	if features.Enabled(features.StoreRevocationComment) && req.Comment != nil {
+		commentField = ", revokedComment = ?"
+		args = append(args, *req.Comment)
+	}
+	args = append(args, *req.Serial, string(core.OCSPStatusRevoked))
+	query := fmt.Sprintf(`UPDATE certificateStatus SET
+			status = ?,
+			revokedReason = ?,
+			revokedDate = ?,
+			ocspLastUpdated = ?,
+			ocspResponse = ?%s
+		WHERE serial = ? AND status != ?`, commentField)
+	return query, args
+}
+
+// UpdateRevokedCertificate changes the reason, date and OCSP response stored
+// for a certificate which is already revoked, e.g. when it is found to have
+// been revoked for keyCompromise after being revoked for another reason. It
+// returns a NotFound error if the certificate isn't revoked, so that it can't
+// be used to revoke a certificate without going through RevokeCertificate.
+func (ssa *SQLStorageAuthority) UpdateRevokedCertificate(ctx context.Context, req *sapb.RevokeCertificateRequest) error {
+	query, args := UpdateRevokedCertificateQuery(req, ssa.clk.Now())
+	res, err := ssa.dbMap.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -1566,13 +1609,36 @@ func (ssa *SQLStorageAuthority) RevokeCertificate(ctx context.Context, req *sapb
 		return err
 	}
 	if rows == 0 {
-		// InternalServerError because we expected this certificate status to exist and
-		// not be revoked.
-		return berrors.InternalServerError("no certificate with serial %s and status %s", *req.Serial, string(core.OCSPStatusRevoked))
+		return berrors.NotFoundError("no certificate with serial %s and status %s", *req.Serial, string(core.OCSPStatusRevoked))
 	}
 	return nil
 }
 
+// UpdateRevokedCertificateQuery returns the statement UpdateRevokedCertificate
+// executes for req at now, along with its arguments, so that the admin-revoker
+// can explain it.
+func UpdateRevokedCertificateQuery(req *sapb.RevokeCertificateRequest, now time.Time) (string, []interface{}) {
+	commentField := ""
+	args := []interface{}{
+		revocation.Reason(*req.Reason),
+		time.Unix(0, *req.Date),
+		now,
+		req.Response,
+	}
+	if features.Enabled(features.StoreRevocationComment) && req.Comment != nil {
+		commentField = ", revokedComment = ?"
+		args = append(args, *req.Comment)
+	}
+	args = append(args, *req.Serial, string(core.OCSPStatusRevoked))
+	query := fmt.Sprintf(`UPDATE certificateStatus SET
+			revokedReason = ?,
+			revokedDate = ?,
+			ocspLastUpdated = ?,
+			ocspResponse = ?%s
+		WHERE serial = ? AND status = ?`, commentField)
+	return query, args
+}
+
 // GetPendingAuthorization2 returns the most recent Pending authorization with
 // the given identifier, if available. This method is intended to deprecate
 // GetPendingAuthorization. This method only supports DNS identifier types.
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"database/sql"
 	"encoding/json"
@@ -14,6 +15,7 @@ import (
 	"net"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -35,6 +37,7 @@ import (
 	"github.com/letsencrypt/boulder/sa/satest"
 	"github.com/letsencrypt/boulder/test"
 	"github.com/letsencrypt/boulder/test/vars"
+	"golang.org/x/crypto/ocsp"
 	jose "gopkg.in/square/go-jose.v2"
 )
 
@@ -238,6 +241,184 @@ func TestAddCertificate(t *testing.T) {
 	test.AssertNotError(t, err, "Couldn't add test-cert2.der")
 }
 
+func TestSelectRegistrationIDsByKeyHash(t *testing.T) {
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := satest.CreateWorkingRegistration(t, sa)
+	keyHash, err := core.KeyDigestB64(reg.Key)
+	test.AssertNotError(t, err, "Couldn't compute key hash")
+
+	ids, err := SelectRegistrationIDsByKeyHash(sa.dbMap, keyHash)
+	test.AssertNotError(t, err, "Couldn't select registration IDs by key hash")
+	test.AssertDeepEquals(t, ids, []int64{reg.ID})
+
+	ids, err = SelectRegistrationIDsByKeyHash(sa.dbMap, "nope")
+	test.AssertNotError(t, err, "Couldn't select registration IDs by unknown key hash")
+	test.AssertEquals(t, len(ids), 0)
+}
+
+func TestSelectCertificateByFingerprint(t *testing.T) {
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := satest.CreateWorkingRegistration(t, sa)
+
+	certDER, err := ioutil.ReadFile("www.eff.org.der")
+	test.AssertNotError(t, err, "Couldn't read example cert DER")
+	issued := sa.clk.Now()
+	digest, err := sa.AddCertificate(ctx, certDER, reg.ID, nil, &issued)
+	test.AssertNotError(t, err, "Couldn't add www.eff.org.der")
+
+	cert, err := SelectCertificateByFingerprint(sa.dbMap, digest)
+	test.AssertNotError(t, err, "Couldn't select www.eff.org.der by fingerprint")
+	test.AssertEquals(t, cert.Serial, "000000000000000000000000000000021bd4")
+	test.AssertByteEquals(t, cert.DER, certDER)
+
+	_, err = SelectCertificateByFingerprint(sa.dbMap, core.Fingerprint256([]byte("nope")))
+	test.Assert(t, db.IsNoRows(err), "Expected NoRows error for unknown fingerprint")
+}
+
+func TestSelectCertificatesByPublicKeyHash(t *testing.T) {
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+	err := features.Set(map[string]bool{"StoreKeyHashes": true})
+	test.AssertNotError(t, err, "failed to set features")
+	defer features.Reset()
+
+	reg := satest.CreateWorkingRegistration(t, sa)
+	serial, testCert := test.ThrowAwayCert(t, 1)
+	issued := testCert.NotBefore.UnixNano()
+	_, err = sa.AddPrecertificate(ctx, &sapb.AddCertificateRequest{
+		Der:    testCert.Raw,
+		RegID:  &reg.ID,
+		Ocsp:   []byte{1, 2, 3},
+		Issued: &issued,
+	})
+	test.AssertNotError(t, err, "failed to add precert")
+	issuedTime := testCert.NotBefore
+	_, err = sa.AddCertificate(ctx, testCert.Raw, reg.ID, nil, &issuedTime)
+	test.AssertNotError(t, err, "failed to add cert")
+
+	spkiHash := sha256.Sum256(testCert.RawSubjectPublicKeyInfo)
+	certs, err := SelectCertificatesByPublicKeyHash(sa.dbMap, spkiHash[:])
+	test.AssertNotError(t, err, "Couldn't select certificates by public key hash")
+	test.AssertEquals(t, len(certs), 1)
+	test.AssertEquals(t, certs[0].Serial, serial)
+	test.AssertEquals(t, certs[0].RegistrationID, reg.ID)
+
+	unknown := sha256.Sum256([]byte("nope"))
+	certs, err = SelectCertificatesByPublicKeyHash(sa.dbMap, unknown[:])
+	test.AssertNotError(t, err, "Couldn't select certificates by unknown public key hash")
+	test.AssertEquals(t, len(certs), 0)
+}
+
+func TestSelectCertificatesByName(t *testing.T) {
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := satest.CreateWorkingRegistration(t, sa)
+	serial, testCert := test.ThrowAwayCert(t, 2)
+	issued := testCert.NotBefore.UnixNano()
+	_, err := sa.AddPrecertificate(ctx, &sapb.AddCertificateRequest{
+		Der:    testCert.Raw,
+		RegID:  &reg.ID,
+		Ocsp:   []byte{1, 2, 3},
+		Issued: &issued,
+	})
+	test.AssertNotError(t, err, "failed to add precert")
+	issuedTime := testCert.NotBefore
+	_, err = sa.AddCertificate(ctx, testCert.Raw, reg.ID, nil, &issuedTime)
+	test.AssertNotError(t, err, "failed to add cert")
+
+	certs, err := SelectCertificatesByName(sa.dbMap, testCert.DNSNames[0], false)
+	test.AssertNotError(t, err, "Couldn't select certificates by exact name")
+	test.AssertEquals(t, len(certs), 1)
+	test.AssertEquals(t, certs[0].Serial, serial)
+	test.AssertEquals(t, certs[0].RegistrationID, reg.ID)
+
+	// Both of the certificate's names are subdomains of example.com, but it
+	// should only be selected once.
+	certs, err = SelectCertificatesByName(sa.dbMap, "example.com", true)
+	test.AssertNotError(t, err, "Couldn't select certificates by domain")
+	test.AssertEquals(t, len(certs), 1)
+	test.AssertEquals(t, certs[0].Serial, serial)
+
+	certs, err = SelectCertificatesByName(sa.dbMap, "example.com", false)
+	test.AssertNotError(t, err, "Couldn't select certificates by exact domain")
+	test.AssertEquals(t, len(certs), 0)
+
+	certs, err = SelectCertificatesByName(sa.dbMap, "ample.com", true)
+	test.AssertNotError(t, err, "Couldn't select certificates by unrelated domain")
+	test.AssertEquals(t, len(certs), 0)
+}
+
+func TestSelectCertificatesWhere(t *testing.T) {
+	sa, _, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := satest.CreateWorkingRegistration(t, sa)
+	var serials []string
+	for i := 0; i < 3; i++ {
+		serial, testCert := test.ThrowAwayCert(t, 1)
+		issued := testCert.NotBefore
+		_, err := sa.AddCertificate(ctx, testCert.Raw, reg.ID, nil, &issued)
+		test.AssertNotError(t, err, "failed to add cert")
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+
+	certs, err := SelectCertificatesWhere(sa.dbMap, "WHERE registrationID = ? ORDER BY serial", 10, reg.ID)
+	test.AssertNotError(t, err, "Couldn't select certificates by registration")
+	test.AssertEquals(t, len(certs), 3)
+	for i, cert := range certs {
+		test.AssertEquals(t, cert.Serial, serials[i])
+		test.AssertEquals(t, cert.RegistrationID, reg.ID)
+		test.Assert(t, len(cert.DER) > 0, "certificate DER wasn't selected")
+	}
+
+	// The limit bounds the number of rows selected.
+	certs, err = SelectCertificatesWhere(sa.dbMap, "WHERE registrationID = ? AND serial > ? ORDER BY serial", 1, reg.ID, serials[0])
+	test.AssertNotError(t, err, "Couldn't select certificates after a serial")
+	test.AssertEquals(t, len(certs), 1)
+	test.AssertEquals(t, certs[0].Serial, serials[1])
+
+	// A value containing SQL is only ever compared against, never executed.
+	certs, err = SelectCertificatesWhere(sa.dbMap, "WHERE serial = ?", 10, "' OR '1'='1")
+	test.AssertNotError(t, err, "Couldn't select certificates by a malicious serial")
+	test.AssertEquals(t, len(certs), 0)
+}
+
+func TestSelectCertificatesWhereRejected(t *testing.T) {
+	testCases := []struct {
+		name  string
+		where string
+		limit int
+		args  []interface{}
+	}{
+		{"no WHERE", "registrationID = ?", 1, []interface{}{1}},
+		{"literal", "WHERE serial = '00ff'", 1, nil},
+		{"double quoted literal", `WHERE serial = "00ff"`, 1, nil},
+		{"statement separator", "WHERE registrationID = ?; DELETE FROM certificates", 1, []interface{}{1}},
+		{"comment", "WHERE registrationID = ? -- ", 1, []interface{}{1}},
+		{"hash comment", "WHERE registrationID = ? # ", 1, []interface{}{1}},
+		{"block comment", "WHERE registrationID = ? /* */", 1, []interface{}{1}},
+		{"own limit", "WHERE registrationID = ? LIMIT 5", 1, []interface{}{1}},
+		{"union", "WHERE registrationID = ? UNION SELECT * FROM registrations", 1, []interface{}{1}},
+		{"subquery", "WHERE serial IN (select serial FROM certificateStatus)", 1, nil},
+		{"too few arguments", "WHERE registrationID = ? AND serial = ?", 1, []interface{}{1}},
+		{"too many arguments", "WHERE registrationID = ?", 1, []interface{}{1, 2}},
+		{"zero limit", "WHERE registrationID = ?", 0, []interface{}{1}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The clause is rejected before anything is selected.
+			_, err := SelectCertificatesWhere(nil, tc.where, tc.limit, tc.args...)
+			test.AssertError(t, err, "SelectCertificatesWhere accepted an unsafe query")
+		})
+	}
+}
+
 func TestCountCertificatesByNames(t *testing.T) {
 	sa, clk, cleanUp := initSA(t)
 	defer cleanUp()
@@ -1625,6 +1806,72 @@ func TestRevokeCertificate(t *testing.T) {
 		Response: response,
 	})
 	test.AssertError(t, err, "RevokeCertificate should've failed when certificate already revoked")
+	test.Assert(t, berrors.Is(err, berrors.AlreadyRevoked), "RevokeCertificate should've returned an AlreadyRevoked error")
+
+	unknown := "000000000000000000000000000000000000"
+	err = sa.RevokeCertificate(context.Background(), &sapb.RevokeCertificateRequest{
+		Serial:   &unknown,
+		Date:     &dateUnix,
+		Reason:   &reason,
+		Response: response,
+	})
+	test.AssertError(t, err, "RevokeCertificate should've failed for an unknown certificate")
+	test.Assert(t, berrors.Is(err, berrors.InternalServer), "RevokeCertificate should've returned an InternalServer error")
+}
+
+func TestUpdateRevokedCertificate(t *testing.T) {
+	sa, fc, cleanUp := initSA(t)
+	defer cleanUp()
+
+	reg := satest.CreateWorkingRegistration(t, sa)
+	certDER, err := ioutil.ReadFile("www.eff.org.der")
+	test.AssertNotError(t, err, "Couldn't read example cert DER")
+	issued := sa.clk.Now().UnixNano()
+	_, err = sa.AddPrecertificate(ctx, &sapb.AddCertificateRequest{
+		Der:    certDER,
+		RegID:  &reg.ID,
+		Ocsp:   nil,
+		Issued: &issued,
+	})
+	test.AssertNotError(t, err, "Couldn't add www.eff.org.der")
+	serial := "000000000000000000000000000000021bd4"
+
+	fc.Add(1 * time.Hour)
+	revokedAt := fc.Now()
+	dateUnix := revokedAt.UnixNano()
+	reason := int64(ocsp.Superseded)
+	req := &sapb.RevokeCertificateRequest{
+		Serial:   &serial,
+		Date:     &dateUnix,
+		Reason:   &reason,
+		Response: []byte{1, 2, 3},
+	}
+	// A certificate which isn't revoked can't have its revocation updated.
+	err = sa.UpdateRevokedCertificate(context.Background(), req)
+	test.Assert(t, berrors.Is(err, berrors.NotFound), "UpdateRevokedCertificate should've returned a NotFound error")
+	status, err := sa.GetCertificateStatus(ctx, serial)
+	test.AssertNotError(t, err, "GetCertificateStatus failed")
+	test.AssertEquals(t, status.Status, core.OCSPStatusGood)
+
+	err = sa.RevokeCertificate(context.Background(), req)
+	test.AssertNotError(t, err, "RevokeCertificate failed")
+
+	fc.Add(1 * time.Hour)
+	reason = int64(ocsp.KeyCompromise)
+	err = sa.UpdateRevokedCertificate(context.Background(), &sapb.RevokeCertificateRequest{
+		Serial:   &serial,
+		Date:     &dateUnix,
+		Reason:   &reason,
+		Response: []byte{4, 5, 6},
+	})
+	test.AssertNotError(t, err, "UpdateRevokedCertificate failed")
+	status, err = sa.GetCertificateStatus(ctx, serial)
+	test.AssertNotError(t, err, "GetCertificateStatus failed")
+	test.AssertEquals(t, status.Status, core.OCSPStatusRevoked)
+	test.AssertEquals(t, status.RevokedReason, revocation.Reason(ocsp.KeyCompromise))
+	test.AssertEquals(t, status.RevokedDate, revokedAt)
+	test.AssertEquals(t, status.OCSPLastUpdated, fc.Now())
+	test.AssertDeepEquals(t, status.OCSPResponse, []byte{4, 5, 6})
 }
 
 func TestAddCertificateRenewalBit(t *testing.T) {
@@ -44,6 +44,9 @@ GRANT SELECT ON precertificates TO 'ocsp_update'@'localhost';
 -- Revoker Tool
 GRANT SELECT ON registrations TO 'revoker'@'localhost';
 GRANT SELECT ON certificates TO 'revoker'@'localhost';
+GRANT SELECT ON precertificates TO 'revoker'@'localhost';
+GRANT SELECT,UPDATE ON certificateStatus TO 'revoker'@'localhost';
+GRANT SELECT,INSERT,UPDATE ON admin_revocation_runs TO 'revoker'@'localhost';
 
 -- Expiration mailer
 GRANT SELECT ON certificates TO 'mailer'@'localhost';